@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// apiKeySecretPrefix marks merchant API key signing secrets
+const apiKeySecretPrefix = "mks_"
+
+var ErrInvalidSealedSecret = errors.New("sealed API key secret is invalid")
+
+// NewAPIKeySecret generates a random signing secret for a merchant API key.
+func NewAPIKeySecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return apiKeySecretPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// SealAPIKeySecret encrypts the signing secret of a key with AES-256-GCM under
+// a key derived from the master secret, so a copy of the database alone can't
+// sign requests. The key ID is authenticated with the secret, so a sealed
+// secret can't be moved to another key.
+func SealAPIKeySecret(master, keyID, secret string) (string, error) {
+	aead, err := apiKeySealer(master)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(secret), []byte(keyID))
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenAPIKeySecret decrypts a signing secret sealed by SealAPIKeySecret.
+func OpenAPIKeySecret(master, keyID, sealed string) (string, error) {
+	aead, err := apiKeySealer(master)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", ErrInvalidSealedSecret
+	}
+	secret, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", ErrInvalidSealedSecret
+	}
+	return string(secret), nil
+}
+
+func apiKeySealer(master string) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, []byte(master))
+	mac.Write([]byte("merchant-api-key-seal"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SignRequest returns the hex encoded HMAC-SHA256 signature of a merchant API
+// request. The signed string is the upper case method, the request URI with
+// its query, the Unix timestamp, the nonce and the hex SHA-256 of the body,
+// separated by newlines.
+func SignRequest(secret, method, requestURI, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	payload := strings.Join([]string{
+		strings.ToUpper(method),
+		requestURI,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequestSignature checks signature against the expected signature in
+// constant time.
+func VerifyRequestSignature(secret, method, requestURI, timestamp, nonce string, body []byte, signature string) bool {
+	expected := SignRequest(secret, method, requestURI, timestamp, nonce, body)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
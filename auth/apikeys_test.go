@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealAPIKeySecret(t *testing.T) {
+	secret, err := NewAPIKeySecret()
+	assert.NoError(t, err)
+	assert.Regexp(t, "^mks_[A-Za-z0-9_-]{43}$", secret)
+
+	other, err := NewAPIKeySecret()
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+
+	sealed, err := SealAPIKeySecret("master", "mk_one", secret)
+	assert.NoError(t, err)
+	assert.NotContains(t, sealed, secret)
+
+	opened, err := OpenAPIKeySecret("master", "mk_one", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, secret, opened)
+
+	// The secret only opens with the same master secret and key ID
+	_, err = OpenAPIKeySecret("other", "mk_one", sealed)
+	assert.Equal(t, ErrInvalidSealedSecret, err)
+	_, err = OpenAPIKeySecret("master", "mk_two", sealed)
+	assert.Equal(t, ErrInvalidSealedSecret, err)
+	_, err = OpenAPIKeySecret("master", "mk_one", "not sealed")
+	assert.Equal(t, ErrInvalidSealedSecret, err)
+}
+
+func TestVerifyRequestSignature(t *testing.T) {
+	body := []byte(`{"amount":1000}`)
+	signature := SignRequest("secret", "post", "/api/v1/merchant-api/payments?page=2", "1700000000", "nonce-0123456789", body)
+
+	assert.True(t, VerifyRequestSignature("secret", "POST", "/api/v1/merchant-api/payments?page=2", "1700000000", "nonce-0123456789", body, signature))
+
+	// Every signed part is covered
+	assert.False(t, VerifyRequestSignature("other", "POST", "/api/v1/merchant-api/payments?page=2", "1700000000", "nonce-0123456789", body, signature))
+	assert.False(t, VerifyRequestSignature("secret", "GET", "/api/v1/merchant-api/payments?page=2", "1700000000", "nonce-0123456789", body, signature))
+	assert.False(t, VerifyRequestSignature("secret", "POST", "/api/v1/merchant-api/payments?page=3", "1700000000", "nonce-0123456789", body, signature))
+	assert.False(t, VerifyRequestSignature("secret", "POST", "/api/v1/merchant-api/payments?page=2", "1700000001", "nonce-0123456789", body, signature))
+	assert.False(t, VerifyRequestSignature("secret", "POST", "/api/v1/merchant-api/payments?page=2", "1700000000", "nonce-9876543210", body, signature))
+	assert.False(t, VerifyRequestSignature("secret", "POST", "/api/v1/merchant-api/payments?page=2", "1700000000", "nonce-0123456789", []byte(`{"amount":9000}`), signature))
+}
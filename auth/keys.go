@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const minRSAKeyBits = 2048
+
+var (
+	ErrNoSigningKey       = errors.New("no active signing key")
+	ErrUnknownKey         = errors.New("unknown signing key")
+	ErrUnsupportedKeyType = errors.New("unsupported key type, expected RSA or Ed25519")
+)
+
+// Keys is the key set used to sign and verify access tokens. It is loaded
+// once at startup by InitKeys.
+var Keys *KeySet
+
+// InitKeys loads the configured signing keys into Keys. Without configured
+// keys an ephemeral Ed25519 key is generated, which is only suitable for a
+// single instance in development since tokens die with the process.
+func InitKeys(cfg *config.Config) error {
+	keys, err := LoadKeySet(cfg.JWTSigningKeys, cfg.JWTKeyOverlap)
+	if err != nil {
+		return err
+	}
+	if len(cfg.JWTSigningKeys) == 0 {
+		log.Printf("Warning: JWT_SIGNING_KEYS is not set, signing with an ephemeral key")
+	}
+	Keys = keys
+	return nil
+}
+
+// SigningKey is an asymmetric key identified by its kid.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	Private     crypto.Signer
+	ActivatesAt time.Time
+}
+
+// NewSigningKey wraps an RSA or Ed25519 private key and picks its algorithm.
+func NewSigningKey(id string, private crypto.Signer, activatesAt time.Time) (*SigningKey, error) {
+	key := &SigningKey{ID: id, Private: private, ActivatesAt: activatesAt}
+
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key %q is %d bits, at least %d are required", id, k.N.BitLen(), minRSAKeyBits)
+		}
+		key.Algorithm = AlgorithmRS256
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgorithmEdDSA
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+	return key, nil
+}
+
+// Public returns the key's public half.
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// Method returns the JWT signing method for the key.
+func (k *SigningKey) Method() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// ParsePrivateKeyPEM reads an RSA or Ed25519 private key in PKCS #8 or, for
+// RSA, PKCS #1 form.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, ErrUnsupportedKeyType
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// KeySet holds the signing keys ordered by activation. The newest active key
+// signs; a key that has been superseded keeps verifying for the overlap
+// period so tokens it signed stay valid until they expire.
+type KeySet struct {
+	keys    []*SigningKey
+	overlap time.Duration
+}
+
+// NewKeySet builds a key set from keys with unique IDs.
+func NewKeySet(overlap time.Duration, keys ...*SigningKey) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate signing key id %q", key.ID)
+		}
+		seen[key.ID] = true
+	}
+
+	sorted := append([]*SigningKey(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActivatesAt.Before(sorted[j].ActivatesAt)
+	})
+	return &KeySet{keys: sorted, overlap: overlap}, nil
+}
+
+// LoadKeySet reads the keys named by specs from disk, or generates an
+// ephemeral Ed25519 key when specs is empty.
+func LoadKeySet(specs config.KeySpecs, overlap time.Duration) (*KeySet, error) {
+	if len(specs) == 0 {
+		key, err := GenerateEphemeralKey()
+		if err != nil {
+			return nil, err
+		}
+		return NewKeySet(overlap, key)
+	}
+
+	keys := make([]*SigningKey, 0, len(specs))
+	for _, spec := range specs {
+		data, err := os.ReadFile(spec.Path)
+		if err != nil {
+			return nil, fmt.Errorf("reading signing key %q: %w", spec.ID, err)
+		}
+		private, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing signing key %q: %w", spec.ID, err)
+		}
+		key, err := NewSigningKey(spec.ID, private, spec.ActivatesAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(overlap, keys...)
+}
+
+// GenerateEphemeralKey creates an in-memory Ed25519 signing key.
+func GenerateEphemeralKey() (*SigningKey, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	return NewSigningKey("ephemeral-"+hex.EncodeToString(suffix), private, time.Time{})
+}
+
+// Signing returns the key that signs tokens at now.
+func (s *KeySet) Signing(now time.Time) (*SigningKey, error) {
+	var current *SigningKey
+	for _, key := range s.keys {
+		if !key.ActivatesAt.After(now) {
+			current = key
+		}
+	}
+	if current == nil {
+		return nil, ErrNoSigningKey
+	}
+	return current, nil
+}
+
+// Published returns the keys that verify tokens at now: the signing key,
+// superseded keys still within their overlap and keys scheduled to activate
+// later, so that other services can cache them ahead of the rotation.
+func (s *KeySet) Published(now time.Time) []*SigningKey {
+	var published []*SigningKey
+	for i, key := range s.keys {
+		if i+1 < len(s.keys) {
+			successor := s.keys[i+1]
+			if !successor.ActivatesAt.After(now) && !now.Before(successor.ActivatesAt.Add(s.overlap)) {
+				continue
+			}
+		}
+		published = append(published, key)
+	}
+	return published
+}
+
+// Verification returns the published key with the given kid.
+func (s *KeySet) Verification(id string, now time.Time) (*SigningKey, error) {
+	for _, key := range s.Published(now) {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the published public keys.
+func (s *KeySet) JWKS(now time.Time) JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range s.Published(now) {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch public := key.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
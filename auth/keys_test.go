@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestLoadKeySetFromPEM(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	rsaPath := writePEM(t, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	assert.NoError(t, err)
+	edPath := writePEM(t, dir, "ed.pem", "PRIVATE KEY", der)
+
+	rotation := time.Now().Add(time.Hour)
+	keys, err := LoadKeySet(config.KeySpecs{
+		{ID: "rsa-1", Path: rsaPath},
+		{ID: "ed-2", Path: edPath, ActivatesAt: rotation},
+	}, 24*time.Hour)
+	assert.NoError(t, err)
+
+	signing, err := keys.Signing(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "rsa-1", signing.ID)
+	assert.Equal(t, AlgorithmRS256, signing.Algorithm)
+
+	signing, err = keys.Signing(rotation)
+	assert.NoError(t, err)
+	assert.Equal(t, "ed-2", signing.ID)
+	assert.Equal(t, AlgorithmEdDSA, signing.Algorithm)
+}
+
+func TestLoadKeySetRejectsWeakAndUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	weakPath := writePEM(t, dir, "weak.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(weak))
+	_, err = LoadKeySet(config.KeySpecs{{ID: "weak", Path: weakPath}}, time.Hour)
+	assert.Error(t, err)
+
+	_, err = LoadKeySet(config.KeySpecs{{ID: "missing", Path: filepath.Join(dir, "missing.pem")}}, time.Hour)
+	assert.Error(t, err)
+
+	garbagePath := writePEM(t, dir, "garbage.pem", "CERTIFICATE", []byte("nope"))
+	_, err = LoadKeySet(config.KeySpecs{{ID: "garbage", Path: garbagePath}}, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestLoadKeySetWithoutSpecsIsEphemeral(t *testing.T) {
+	keys, err := LoadKeySet(nil, time.Hour)
+	assert.NoError(t, err)
+
+	signing, err := keys.Signing(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmEdDSA, signing.Algorithm)
+}
+
+func TestDuplicateKeyIDsAreRejected(t *testing.T) {
+	_, err := NewKeySet(time.Hour, mustEd25519Key("a", time.Time{}), mustEd25519Key("a", time.Now()))
+	assert.Error(t, err)
+}
+
+func TestRotationKeepsOldKeyDuringOverlap(t *testing.T) {
+	start := time.Now().Add(-48 * time.Hour)
+	rotation := time.Now()
+	keys := mustKeySet(mustEd25519Key("old", start), mustEd25519Key("new", rotation))
+
+	// Before the rotation the new key is already published
+	before := rotation.Add(-time.Minute)
+	assert.Len(t, keys.JWKS(before).Keys, 2)
+
+	oldToken, err := newTestServiceWithKeys(before, keys).IssueAccess(uuid.New(), "1234567890", uuid.New())
+	assert.NoError(t, err)
+
+	// During the overlap the old key still verifies but no longer signs
+	during := rotation.Add(time.Hour)
+	_, err = newTestServiceWithKeys(during, keys).Parse(oldToken, TokenTypeAccess)
+	assert.NoError(t, err)
+	signing, err := keys.Signing(during)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", signing.ID)
+
+	// Afterwards it is dropped from verification and the JWKS
+	after := rotation.Add(25 * time.Hour)
+	_, err = keys.Verification("old", after)
+	assert.Equal(t, ErrUnknownKey, err)
+	assert.Len(t, keys.JWKS(after).Keys, 1)
+	assert.Equal(t, "new", keys.JWKS(after).Keys[0].Kid)
+}
+
+func TestTokenWithUnknownKidIsRejected(t *testing.T) {
+	token, err := newTestServiceWithKeys(time.Now(), mustKeySet(mustEd25519Key("other", time.Time{}))).
+		IssueAccess(uuid.New(), "1234567890", uuid.New())
+	assert.NoError(t, err)
+
+	_, err = newTestService(time.Now()).Parse(token, TokenTypeAccess)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestJWKSFormat(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	rsaSigning, err := NewSigningKey("rsa", rsaKey, time.Time{})
+	assert.NoError(t, err)
+
+	set := mustKeySet(rsaSigning, mustEd25519Key("ed", time.Now().Add(time.Hour))).JWKS(time.Now())
+	assert.Len(t, set.Keys, 2)
+
+	assert.Equal(t, JWK{Kty: "RSA", Kid: "rsa", Use: "sig", Alg: "RS256", N: set.Keys[0].N, E: "AQAB"}, set.Keys[0])
+	assert.NotEmpty(t, set.Keys[0].N)
+	assert.Equal(t, "OKP", set.Keys[1].Kty)
+	assert.Equal(t, "Ed25519", set.Keys[1].Crv)
+	assert.Len(t, set.Keys[1].X, 43)
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Token types
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrInvalidTokenType = errors.New("invalid token type")
+)
+
+// Claims are the claims carried by every token we issue. The subject is the
+// user ID; SessionID links an access token to its token family and the jti of
+// a refresh token is the ID of its server-side record.
+type Claims struct {
+	jwt.RegisteredClaims
+	Type      string `json:"type"`
+	Phone     string `json:"phone,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+}
+
+// UserID parses the subject as a user ID.
+func (c *Claims) UserID() (uuid.UUID, error) {
+	return uuid.Parse(c.Subject)
+}
+
+// Session parses the sid claim.
+func (c *Claims) Session() (uuid.UUID, error) {
+	return uuid.Parse(c.SessionID)
+}
+
+// TokenID parses the jti claim.
+func (c *Claims) TokenID() (uuid.UUID, error) {
+	return uuid.Parse(c.ID)
+}
+
+// TokenService issues and validates access and refresh tokens. Access tokens
+// are signed with the asymmetric key set so other services can verify them
+// from the JWKS; refresh tokens are only ever read by us and use HS256.
+type TokenService struct {
+	keys          *KeySet
+	refreshSecret []byte
+	accessTTL     time.Duration
+	refreshTTL    time.Duration
+	issuer        string
+	audience      string
+	leeway        time.Duration
+	now           func() time.Time
+}
+
+func NewTokenService(cfg *config.Config, keys *KeySet) *TokenService {
+	return &TokenService{
+		keys:          keys,
+		refreshSecret: []byte(cfg.RefreshTokenSecret),
+		accessTTL:     cfg.JWTExpiration.Duration(),
+		refreshTTL:    cfg.RefreshTokenExpiration.Duration(),
+		issuer:        cfg.JWTIssuer,
+		audience:      cfg.JWTAudience,
+		leeway:        cfg.JWTLeeway,
+		now:           time.Now,
+	}
+}
+
+// AccessTTL is how long access tokens are valid.
+func (s *TokenService) AccessTTL() time.Duration {
+	return s.accessTTL
+}
+
+// RefreshExpiry returns the expiry for a refresh token issued now.
+func (s *TokenService) RefreshExpiry() time.Time {
+	return s.now().Add(s.refreshTTL)
+}
+
+// IssueAccess signs an access token for the user's session.
+func (s *TokenService) IssueAccess(userID uuid.UUID, phoneNumber string, sessionID uuid.UUID) (string, error) {
+	now := s.now()
+	key, err := s.keys.Signing(now)
+	if err != nil {
+		return "", err
+	}
+
+	claims := &Claims{
+		RegisteredClaims: s.registered(userID, uuid.NewString(), now, now.Add(s.accessTTL)),
+		Type:             TokenTypeAccess,
+		Phone:            phoneNumber,
+		SessionID:        sessionID.String(),
+	}
+	token := jwt.NewWithClaims(key.Method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// IssueRefresh signs a refresh token whose jti and expiry come from its
+// server-side record.
+func (s *TokenService) IssueRefresh(userID uuid.UUID, phoneNumber string, jti uuid.UUID, expiresAt time.Time) (string, error) {
+	claims := &Claims{
+		RegisteredClaims: s.registered(userID, jti.String(), s.now(), expiresAt),
+		Type:             TokenTypeRefresh,
+		Phone:            phoneNumber,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.refreshSecret)
+}
+
+// Parse validates a token of the expected type and returns its claims.
+// Expiry and not-before are checked with the configured leeway for clock
+// skew; issuer and audience must match.
+func (s *TokenService) Parse(tokenString string, tokenType string) (*Claims, error) {
+	keyFunc := s.accessKey
+	methods := []string{AlgorithmRS256, AlgorithmEdDSA}
+	if tokenType == TokenTypeRefresh {
+		keyFunc = func(token *jwt.Token) (interface{}, error) {
+			return s.refreshSecret, nil
+		}
+		methods = []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc,
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.leeway),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Type != tokenType {
+		return nil, ErrInvalidTokenType
+	}
+	if _, err := claims.UserID(); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// accessKey looks up the public key named by the token's kid header.
+func (s *TokenService) accessKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := s.keys.Verification(kid, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != token.Method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.Public(), nil
+}
+
+func (s *TokenService) registered(userID uuid.UUID, jti string, issuedAt, expiresAt time.Time) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    s.issuer,
+		Subject:   userID.String(),
+		Audience:  jwt.ClaimStrings{s.audience},
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		NotBefore: jwt.NewNumericDate(issuedAt),
+		IssuedAt:  jwt.NewNumericDate(issuedAt),
+		ID:        jti,
+	}
+}
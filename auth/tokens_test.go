@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var testKeys = mustKeySet(mustEd25519Key("test", time.Time{}))
+
+func mustEd25519Key(id string, activatesAt time.Time) *SigningKey {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	key, err := NewSigningKey(id, private, activatesAt)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func mustKeySet(keys ...*SigningKey) *KeySet {
+	set, err := NewKeySet(24*time.Hour, keys...)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+func newTestService(now time.Time) *TokenService {
+	return newTestServiceWithKeys(now, testKeys)
+}
+
+func newTestServiceWithKeys(now time.Time, keys *KeySet) *TokenService {
+	cfg := &config.Config{
+		RefreshTokenSecret:     "refresh-secret",
+		JWTExpiration:          config.Hours(24 * time.Hour),
+		RefreshTokenExpiration: config.Days(7 * 24 * time.Hour),
+		JWTIssuer:              "ewallet-api",
+		JWTAudience:            "ewallet-api",
+		JWTLeeway:              30 * time.Second,
+	}
+	service := NewTokenService(cfg, keys)
+	service.now = func() time.Time { return now }
+	return service
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	now := time.Now()
+	service := newTestService(now)
+	userID, sessionID := uuid.New(), uuid.New()
+
+	token, err := service.IssueAccess(userID, "1234567890", sessionID)
+	assert.NoError(t, err)
+
+	claims, err := service.Parse(token, TokenTypeAccess)
+	assert.NoError(t, err)
+	parsedUser, _ := claims.UserID()
+	parsedSession, _ := claims.Session()
+	assert.Equal(t, userID, parsedUser)
+	assert.Equal(t, sessionID, parsedSession)
+	assert.Equal(t, "ewallet-api", claims.Issuer)
+	assert.Equal(t, now.Add(24*time.Hour).Unix(), claims.ExpiresAt.Unix())
+	assert.NotEmpty(t, claims.ID)
+}
+
+func TestAccessTTLIsNotScaledTwice(t *testing.T) {
+	service := newTestService(time.Now())
+	assert.Equal(t, 24*time.Hour, service.AccessTTL())
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), service.RefreshExpiry(), time.Second)
+}
+
+func TestTokenTypesAreNotInterchangeable(t *testing.T) {
+	service := newTestService(time.Now())
+	userID := uuid.New()
+
+	refresh, err := service.IssueRefresh(userID, "1234567890", uuid.New(), time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	_, err = service.Parse(refresh, TokenTypeAccess)
+	assert.Error(t, err)
+
+	access, err := service.IssueAccess(userID, "1234567890", uuid.New())
+	assert.NoError(t, err)
+	_, err = service.Parse(access, TokenTypeRefresh)
+	assert.Error(t, err)
+}
+
+func TestExpiryAllowsLeeway(t *testing.T) {
+	issuedAt := time.Now().Add(-48 * time.Hour)
+	token, err := newTestService(issuedAt).IssueAccess(uuid.New(), "1234567890", uuid.New())
+	assert.NoError(t, err)
+	expiresAt := issuedAt.Add(24 * time.Hour)
+
+	_, err = newTestService(expiresAt.Add(20*time.Second)).Parse(token, TokenTypeAccess)
+	assert.NoError(t, err)
+	_, err = newTestService(expiresAt.Add(time.Minute)).Parse(token, TokenTypeAccess)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestIssuerAndAudienceMustMatch(t *testing.T) {
+	service := newTestService(time.Now())
+	token, err := service.IssueAccess(uuid.New(), "1234567890", uuid.New())
+	assert.NoError(t, err)
+
+	other := newTestService(time.Now())
+	other.audience = "another-service"
+	_, err = other.Parse(token, TokenTypeAccess)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	other = newTestService(time.Now())
+	other.issuer = "someone-else"
+	_, err = other.Parse(token, TokenTypeAccess)
+	assert.Equal(t, ErrInvalidToken, err)
+}
@@ -17,10 +17,83 @@ type Config struct {
 	DBName     string `envconfig:"DB_NAME" default:"ewallet_api"`
 
 	// JWT configuration
-	JWTSecret                string        `envconfig:"JWT_SECRET" default:"secretKeysJwt"`
-	JWTExpirationHours      time.Duration `envconfig:"JWT_EXPIRATION_HOURS" default:"24h"`
-	RefreshTokenSecret      string        `envconfig:"REFRESH_TOKEN_SECRET" default:"refreshSecretKeysJwt"`
-	RefreshTokenExpirationDays time.Duration `envconfig:"REFRESH_TOKEN_EXPIRATION_DAYS" default:"168h"`
+	JWTExpiration          Hours         `envconfig:"JWT_EXPIRATION_HOURS" default:"24"`
+	RefreshTokenSecret     string        `envconfig:"REFRESH_TOKEN_SECRET" default:"refreshSecretKeysJwt"`
+	RefreshTokenExpiration Days          `envconfig:"REFRESH_TOKEN_EXPIRATION_DAYS" default:"7"`
+	JWTIssuer              string        `envconfig:"JWT_ISSUER" default:"ewallet-api"`
+	JWTAudience            string        `envconfig:"JWT_AUDIENCE" default:"ewallet-api"`
+	JWTLeeway              time.Duration `envconfig:"JWT_LEEWAY" default:"30s"`
+
+	// Access token signing keys
+	JWTSigningKeys KeySpecs      `envconfig:"JWT_SIGNING_KEYS"`
+	JWTKeyOverlap  time.Duration `envconfig:"JWT_KEY_OVERLAP" default:"24h"`
+
+	// Authorization hold configuration
+	HoldDefaultTTL     time.Duration `envconfig:"HOLD_DEFAULT_TTL" default:"168h"`
+	HoldMaxTTL         time.Duration `envconfig:"HOLD_MAX_TTL" default:"720h"`
+	HoldExpiryInterval time.Duration `envconfig:"HOLD_EXPIRY_INTERVAL" default:"1m"`
+
+	// How often wallet balances are reconciled against the ledger
+	LedgerReconcileInterval time.Duration `envconfig:"LEDGER_RECONCILE_INTERVAL" default:"1h"`
+
+	// Standing order scheduler configuration; a run that fails for lack of
+	// funds is retried up to STANDING_ORDER_MAX_ATTEMPTS times in total
+	StandingOrderInterval      time.Duration `envconfig:"STANDING_ORDER_INTERVAL" default:"1m"`
+	StandingOrderMaxAttempts   int           `envconfig:"STANDING_ORDER_MAX_ATTEMPTS" default:"3"`
+	StandingOrderRetryInterval time.Duration `envconfig:"STANDING_ORDER_RETRY_INTERVAL" default:"1h"`
+
+	// Payment request configuration
+	PaymentRequestDefaultTTL time.Duration `envconfig:"PAYMENT_REQUEST_DEFAULT_TTL" default:"168h"`
+	PaymentRequestMaxTTL     time.Duration `envconfig:"PAYMENT_REQUEST_MAX_TTL" default:"720h"`
+
+	// Merchant API key configuration. Key secrets are sealed with
+	// MERCHANT_API_KEY_SECRET, which has no default; changing it invalidates
+	// every key
+	MerchantAPIKeySecret        string        `envconfig:"MERCHANT_API_KEY_SECRET" required:"true"`
+	MerchantAPISignatureWindow  time.Duration `envconfig:"MERCHANT_API_SIGNATURE_WINDOW" default:"5m"`
+	MerchantAPIKeyRotationGrace time.Duration `envconfig:"MERCHANT_API_KEY_ROTATION_GRACE" default:"24h"`
+
+	// Merchant QR code configuration; every payload needs a country and a
+	// city, so merchants without a city use QR_DEFAULT_CITY
+	QRCodeDefaultTTL time.Duration `envconfig:"QR_CODE_DEFAULT_TTL" default:"15m"`
+	QRCodeMaxTTL     time.Duration `envconfig:"QR_CODE_MAX_TTL" default:"24h"`
+	QRCountryCode    string        `envconfig:"QR_COUNTRY_CODE" default:"ID"`
+	QRDefaultCity    string        `envconfig:"QR_DEFAULT_CITY" default:"JAKARTA"`
+
+	// Checkout session configuration. Sessions are returned with a link to
+	// CHECKOUT_BASE_URL/<session id> when it is set
+	CheckoutSessionDefaultTTL time.Duration `envconfig:"CHECKOUT_SESSION_DEFAULT_TTL" default:"24h"`
+	CheckoutSessionMaxTTL     time.Duration `envconfig:"CHECKOUT_SESSION_MAX_TTL" default:"720h"`
+	CheckoutBaseURL           string        `envconfig:"CHECKOUT_BASE_URL"`
+
+	// Login lockout configuration
+	MaxLoginAttempts        int           `envconfig:"MAX_LOGIN_ATTEMPTS" default:"5"`
+	MaxLoginAttemptsPerIP   int           `envconfig:"MAX_LOGIN_ATTEMPTS_PER_IP" default:"20"`
+	LoginLockoutDuration    Minutes       `envconfig:"LOGIN_LOCKOUT_DURATION" default:"15"`
+	LoginLockoutMaxDuration time.Duration `envconfig:"LOGIN_LOCKOUT_MAX_DURATION" default:"24h"`
+
+	// KYC document storage configuration
+	KYCStorageDir    string `envconfig:"KYC_STORAGE_DIR" default:"./data/kyc"`
+	KYCMaxUploadSize int64  `envconfig:"KYC_MAX_UPLOAD_SIZE" default:"5242880"`
+
+	// Fee schedule file (JSON); the built-in schedule is used when unset
+	FeeScheduleFile string `envconfig:"FEE_SCHEDULE_FILE"`
+
+	// Currency conversion configuration; conversion is disabled without a
+	// rates file
+	FXRatesFile         string        `envconfig:"FX_RATES_FILE"`
+	FXSpreadBasisPoints int64         `envconfig:"FX_SPREAD_BASIS_POINTS" default:"50"`
+	FXQuoteTTL          time.Duration `envconfig:"FX_QUOTE_TTL" default:"30s"`
+
+	// Rate limit configuration; a limit of 0 disables the bucket
+	IPRateLimit            int     `envconfig:"IP_RATE_LIMIT" default:"300"`
+	IPRateLimitDuration    Seconds `envconfig:"IP_RATE_LIMIT_DURATION" default:"60"`
+	RateLimit              int     `envconfig:"RATE_LIMIT" default:"100"`
+	RateLimitDuration      Seconds `envconfig:"RATE_LIMIT_DURATION" default:"60"`
+	LoginRateLimit         int     `envconfig:"LOGIN_RATE_LIMIT" default:"10"`
+	LoginRateLimitDuration Seconds `envconfig:"LOGIN_RATE_LIMIT_DURATION" default:"60"`
+	MoneyRateLimit         int     `envconfig:"MONEY_RATE_LIMIT" default:"20"`
+	MoneyRateLimitDuration Seconds `envconfig:"MONEY_RATE_LIMIT_DURATION" default:"60"`
 }
 
 var cfg Config
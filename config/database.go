@@ -28,7 +28,7 @@ func InitDB() {
 	}
 
 	// Auto Migrate the schema
-	err = DB.AutoMigrate(&models.User{}, &models.Transaction{})
+	err = DB.AutoMigrate(&models.User{}, &models.Transaction{}, &models.IdempotencyKey{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.LoginAttempt{}, &models.AuditLog{}, &models.TokenFamily{}, &models.RefreshToken{}, &models.KYCSubmission{}, &models.Wallet{}, &models.FXQuote{}, &models.StandingOrder{}, &models.PaymentRequest{}, &models.SplitBill{}, &models.Merchant{}, &models.MerchantAPIKey{}, &models.MerchantAPIKeyUsage{}, &models.QRCode{}, &models.CheckoutSession{}, &models.CheckoutLineItem{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// Seconds is a duration read from a variable holding either a bare number of
+// seconds ("60") or a Go duration string ("1m").
+type Seconds time.Duration
+
+// Decode implements envconfig.Decoder.
+func (s *Seconds) Decode(value string) error {
+	d, err := parseDuration(value, time.Second)
+	*s = Seconds(d)
+	return err
+}
+
+// Duration returns s as a time.Duration.
+func (s Seconds) Duration() time.Duration {
+	return time.Duration(s)
+}
+
+// Minutes is a duration read from a variable holding either a bare number of
+// minutes ("15") or a Go duration string ("15m").
+type Minutes time.Duration
+
+// Decode implements envconfig.Decoder.
+func (m *Minutes) Decode(value string) error {
+	d, err := parseDuration(value, time.Minute)
+	*m = Minutes(d)
+	return err
+}
+
+// Duration returns m as a time.Duration.
+func (m Minutes) Duration() time.Duration {
+	return time.Duration(m)
+}
+
+// Hours is like Minutes with bare numbers counted in hours ("24").
+type Hours time.Duration
+
+// Decode implements envconfig.Decoder.
+func (h *Hours) Decode(value string) error {
+	d, err := parseDuration(value, time.Hour)
+	*h = Hours(d)
+	return err
+}
+
+// Duration returns h as a time.Duration.
+func (h Hours) Duration() time.Duration {
+	return time.Duration(h)
+}
+
+// Days is like Minutes with bare numbers counted in days ("7").
+type Days time.Duration
+
+// Decode implements envconfig.Decoder.
+func (d *Days) Decode(value string) error {
+	parsed, err := parseDuration(value, 24*time.Hour)
+	*d = Days(parsed)
+	return err
+}
+
+// Duration returns d as a time.Duration.
+func (d Days) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// parseDuration reads a bare number as a count of unit and anything else as
+// a Go duration string.
+func parseDuration(value string, unit time.Duration) (time.Duration, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return time.Duration(n) * unit, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KeySpec names a PEM encoded private key used to sign access tokens and the
+// time it starts signing. Keys without an activation time are active
+// immediately.
+type KeySpec struct {
+	ID          string
+	Path        string
+	ActivatesAt time.Time
+}
+
+// KeySpecs is read from a comma separated list of kid=path entries, each
+// optionally followed by @ and an RFC 3339 activation time, e.g.
+// "2024-01=/keys/2024-01.pem,2024-07=/keys/2024-07.pem@2024-07-01T00:00:00Z".
+type KeySpecs []KeySpec
+
+// Decode implements envconfig.Decoder.
+func (k *KeySpecs) Decode(value string) error {
+	var specs KeySpecs
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, rest, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || rest == "" {
+			return fmt.Errorf("invalid signing key %q, expected kid=path[@activation]", entry)
+		}
+
+		spec := KeySpec{ID: id, Path: rest}
+		if path, activation, ok := strings.Cut(rest, "@"); ok {
+			activatesAt, err := time.Parse(time.RFC3339, activation)
+			if err != nil {
+				return fmt.Errorf("invalid activation time for signing key %q: %w", id, err)
+			}
+			spec.Path, spec.ActivatesAt = path, activatesAt
+		}
+		specs = append(specs, spec)
+	}
+
+	*k = specs
+	return nil
+}
@@ -0,0 +1,43 @@
+package fx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/denys89/ewallet-api/models"
+)
+
+// MemoryProvider serves rates set at runtime. It is meant for tests and
+// local development.
+type MemoryProvider struct {
+	mu    sync.RWMutex
+	rates map[string]models.Rate
+}
+
+func NewMemoryProvider() *MemoryProvider {
+	return &MemoryProvider{rates: make(map[string]models.Rate)}
+}
+
+// Set sets the rate from one currency to another. The opposite direction is
+// served as its inverse unless it is set too.
+func (p *MemoryProvider) Set(from, to string, rate models.Rate) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rates[from+"/"+to] = rate
+}
+
+func (p *MemoryProvider) Rate(ctx context.Context, from, to string) (models.Rate, error) {
+	if from == to {
+		return models.OneRate, nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if rate, ok := p.rates[from+"/"+to]; ok {
+		return rate, nil
+	}
+	if rate, ok := p.rates[to+"/"+from]; ok {
+		return rate.Invert(), nil
+	}
+	return 0, ErrRateNotAvailable
+}
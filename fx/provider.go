@@ -0,0 +1,16 @@
+package fx
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/ewallet-api/models"
+)
+
+var ErrRateNotAvailable = errors.New("exchange rate not available")
+
+// RateProvider returns mid-market exchange rates. Rate(ctx, "USD", "IDR")
+// is how many IDR one USD buys.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (models.Rate, error)
+}
@@ -0,0 +1,77 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryProvider(t *testing.T) {
+	provider := NewMemoryProvider()
+	ctx := context.Background()
+
+	_, err := provider.Rate(ctx, "USD", "IDR")
+	assert.Equal(t, ErrRateNotAvailable, err)
+
+	provider.Set("USD", "IDR", models.MustParseRate("16250"))
+	rate, err := provider.Rate(ctx, "USD", "IDR")
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseRate("16250"), rate)
+
+	// The opposite direction is derived until it is set
+	rate, err = provider.Rate(ctx, "IDR", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseRate("0.00006154"), rate)
+
+	provider.Set("IDR", "USD", models.MustParseRate("0.0000615"))
+	rate, err = provider.Rate(ctx, "IDR", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseRate("0.0000615"), rate)
+
+	rate, err = provider.Rate(ctx, "EUR", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OneRate, rate)
+}
+
+func TestStaticProviderCrossRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	data := `{"base": "USD", "rates": {"IDR": "16250", "EUR": 0.92, "JPY": "151.37"}}`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	provider, err := LoadStaticRates(path)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	rate, err := provider.Rate(ctx, "USD", "IDR")
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseRate("16250"), rate)
+
+	rate, err = provider.Rate(ctx, "EUR", "IDR")
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseRate("17663.04347826"), rate)
+
+	rate, err = provider.Rate(ctx, "IDR", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseRate("0.00006154"), rate)
+
+	_, err = provider.Rate(ctx, "USD", "SGD")
+	assert.Equal(t, ErrRateNotAvailable, err)
+}
+
+func TestParseStaticRatesRejectsBadFiles(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"base": "XXX", "rates": {}}`,
+		`{"base": "USD", "rates": {"XXX": "1"}}`,
+		`{"base": "USD", "rates": {"IDR": "-16250"}}`,
+		`{"base": "USD", "rates": {"USD": "2"}}`,
+	} {
+		_, err := ParseStaticRates([]byte(data))
+		assert.True(t, errors.Is(err, ErrInvalidRates), data)
+	}
+}
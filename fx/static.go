@@ -0,0 +1,69 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/denys89/ewallet-api/models"
+)
+
+var ErrInvalidRates = errors.New("invalid exchange rates")
+
+// StaticProvider serves rates read from a file. The file gives the value of
+// one unit of a base currency in every other currency, and cross rates are
+// derived from those:
+//
+//	{"base": "USD", "rates": {"IDR": "16250", "EUR": "0.92"}}
+type StaticProvider struct {
+	base  string
+	rates map[string]models.Rate
+}
+
+type staticRates struct {
+	Base  string                 `json:"base"`
+	Rates map[string]models.Rate `json:"rates"`
+}
+
+// ParseStaticRates decodes and validates a JSON rates file.
+func ParseStaticRates(data []byte) (*StaticProvider, error) {
+	var file staticRates
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRates, err)
+	}
+
+	if _, ok := models.Currencies[file.Base]; !ok {
+		return nil, fmt.Errorf("%w: unsupported base currency %q", ErrInvalidRates, file.Base)
+	}
+	rates := map[string]models.Rate{file.Base: models.OneRate}
+	for currency, rate := range file.Rates {
+		if _, ok := models.Currencies[currency]; !ok {
+			return nil, fmt.Errorf("%w: unsupported currency %q", ErrInvalidRates, currency)
+		}
+		if currency == file.Base && rate != models.OneRate {
+			return nil, fmt.Errorf("%w: the base currency rate must be 1", ErrInvalidRates)
+		}
+		rates[currency] = rate
+	}
+	return &StaticProvider{base: file.Base, rates: rates}, nil
+}
+
+// LoadStaticRates reads a JSON rates file.
+func LoadStaticRates(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStaticRates(data)
+}
+
+func (p *StaticProvider) Rate(ctx context.Context, from, to string) (models.Rate, error) {
+	fromRate, fromOK := p.rates[from]
+	toRate, toOK := p.rates[to]
+	if !fromOK || !toOK {
+		return 0, ErrRateNotAvailable
+	}
+	return toRate.Cross(fromRate), nil
+}
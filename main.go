@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
+	"github.com/denys89/ewallet-api/auth"
 	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/fx"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
 	"github.com/denys89/ewallet-api/routes"
+	"github.com/denys89/ewallet-api/storage"
+	"github.com/denys89/ewallet-api/workers"
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -18,6 +25,11 @@ func main() {
 		log.Fatal("Failed to load configuration:", err)
 	}
 
+	// Merchant API key secrets are sealed with this secret
+	if len(cfg.MerchantAPIKeySecret) < 32 {
+		log.Fatal("MERCHANT_API_KEY_SECRET must be at least 32 characters")
+	}
+
 	// Setup database connection
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		cfg.DBUser,
@@ -34,6 +46,45 @@ func main() {
 
 	config.DB = db
 
+	// Load access token signing keys
+	if err := auth.InitKeys(cfg); err != nil {
+		log.Fatal("Failed to load signing keys:", err)
+	}
+
+	// Load the fee schedule
+	if cfg.FeeScheduleFile != "" {
+		fees, err := models.LoadFeeSchedule(cfg.FeeScheduleFile)
+		if err != nil {
+			log.Fatal("Failed to load fee schedule:", err)
+		}
+		models.Fees = fees
+	}
+
+	// Load exchange rates; without a rates file every rate is unavailable
+	var rates fx.RateProvider = fx.NewMemoryProvider()
+	if cfg.FXRatesFile != "" {
+		if rates, err = fx.LoadStaticRates(cfg.FXRatesFile); err != nil {
+			log.Fatal("Failed to load exchange rates:", err)
+		}
+	}
+	if cfg.FXSpreadBasisPoints < 0 || cfg.FXSpreadBasisPoints >= 10000 {
+		log.Fatal("FX_SPREAD_BASIS_POINTS must be between 0 and 9999")
+	}
+
+	// KYC documents are kept on the local filesystem
+	kycStore, err := storage.NewLocalStore(cfg.KYCStorageDir)
+	if err != nil {
+		log.Fatal("Failed to open KYC document storage:", err)
+	}
+
+	// Start background workers
+	workers.StartHoldExpiry(context.Background(), db, cfg.HoldExpiryInterval)
+	workers.StartLedgerReconciliation(context.Background(), db, cfg.LedgerReconcileInterval)
+	workers.StartStandingOrders(context.Background(), db, cfg.StandingOrderInterval, models.RetryPolicy{
+		MaxAttempts: cfg.StandingOrderMaxAttempts,
+		Interval:    cfg.StandingOrderRetryInterval,
+	})
+
 	// Setup Gin router
 	router := gin.Default()
 
@@ -49,7 +100,7 @@ func main() {
 	}
 
 	// Setup routes
-	routes.SetupRoutes(router)
+	routes.SetupRoutes(router, middleware.NewMemoryRateLimitStore(), middleware.NewMemoryNonceStore(), kycStore, rates)
 
 	// Start server
 	log.Printf("Server starting on port %s", cfg.ServerPort)
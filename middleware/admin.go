@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminMiddleware only lets users with the ADMIN role through. It must run
+// after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := c.Get(UserIDKey)
+		if !ok {
+			respondWithError(c, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		userRepo := repositories.NewUserRepository(config.DB)
+		user, err := userRepo.FindByID(userID.(uuid.UUID))
+		if err != nil || !user.IsAdmin() {
+			respondWithError(c, http.StatusForbidden, "Admin access required")
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -1,17 +1,19 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
 	"strings"
 
+	"github.com/denys89/ewallet-api/auth"
 	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/repositories"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 )
 
-const UserIDKey = "user_id"
+const (
+	UserIDKey    = "user_id"
+	SessionIDKey = "session_id"
+)
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -31,46 +33,40 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenStr := strings.TrimPrefix(authHeader, bearerPrefix)
 
-		// Parse and validate the JWT token
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			// Ensure the signing method is HMAC
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			// Return the secret key for validation
-			return []byte(config.Get().JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		// Parse and validate the access token; refresh tokens are rejected
+		claims, err := auth.NewTokenService(config.Get(), auth.Keys).Parse(tokenStr, auth.TokenTypeAccess)
+		if err != nil {
 			respondWithError(c, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
-		// Extract and validate claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			respondWithError(c, http.StatusUnauthorized, "Invalid token claims")
+		userID, err := claims.UserID()
+		if err != nil {
+			respondWithError(c, http.StatusUnauthorized, "Invalid user ID in token")
 			return
 		}
 
-		// Extract user_id from claims
-		userIDStr, ok := claims["user_id"].(string)
-		if !ok {
-			respondWithError(c, http.StatusUnauthorized, "Invalid user ID in token")
+		// Reject tokens from sessions that have been logged out
+		sessionID, err := claims.Session()
+		if err != nil {
+			respondWithError(c, http.StatusUnauthorized, "Invalid token")
 			return
 		}
-		log.Printf("userIDStr: %+v\n", userIDStr)
 
-		// Validate user_id format (UUID)
-		userID, err := uuid.Parse(userIDStr)
+		active, err := repositories.NewTokenRepository(config.DB).IsSessionActive(sessionID, userID)
 		if err != nil {
-			respondWithError(c, http.StatusUnauthorized, "Invalid user ID in token")
+			respondWithError(c, http.StatusInternalServerError, "Failed to validate session")
+			return
+		}
+		if !active {
+			respondWithError(c, http.StatusUnauthorized, "Session has been logged out")
 			return
 		}
 
 		// Store the user ID in the context for later use
 
 		c.Set(UserIDKey, userID)
+		c.Set(SessionIDKey, sessionID)
 
 		// Proceed to the next middleware or handler
 		c.Next()
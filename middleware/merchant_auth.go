@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/denys89/ewallet-api/auth"
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	MerchantIDKey = "merchant_id"
+	APIKeyKey     = "api_key"
+)
+
+// Headers of a signed merchant API request
+const (
+	HeaderAPIKey    = "X-Api-Key"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// Nonces must be between minNonceLength and maxNonceLength characters
+const (
+	minNonceLength = 16
+	maxNonceLength = 64
+)
+
+// maxSignedBodySize caps the request body read for signature checks
+const maxSignedBodySize = 1 << 20
+
+// NonceStore remembers the nonces of signed requests. Implementations must be
+// safe for concurrent use; a shared store lets several instances reject one
+// replay.
+type NonceStore interface {
+	// Use records nonce until expiresAt and reports whether it was unused.
+	Use(nonce string, expiresAt time.Time, now time.Time) (bool, error)
+}
+
+// MemoryNonceStore keeps nonces in process memory.
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (s *MemoryNonceStore) Use(nonce string, expiresAt time.Time, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	if expiry, ok := s.nonces[nonce]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	s.nonces[nonce] = expiresAt
+	return true, nil
+}
+
+// sweep drops nonces whose requests would now be rejected by their timestamp
+// anyway.
+func (s *MemoryNonceStore) sweep(now time.Time) {
+	for nonce, expiry := range s.nonces {
+		if !now.Before(expiry) {
+			delete(s.nonces, nonce)
+		}
+	}
+	s.lastSweep = now
+}
+
+// MerchantAuthMiddleware authenticates merchant API requests signed with a
+// merchant API key instead of a user's access token. The request must carry
+// the key ID, a Unix timestamp within tolerance of the server clock, a nonce
+// that has not been used before and the HMAC-SHA256 signature described by
+// auth.SignRequest. secret is the master secret API key secrets are sealed
+// with. Handlers act as the merchant's owner, and every request made with a
+// known key is logged against it.
+func MerchantAuthMiddleware(nonces NonceStore, secret string, tolerance time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := c.GetHeader(HeaderAPIKey)
+		timestamp := c.GetHeader(HeaderTimestamp)
+		nonce := c.GetHeader(HeaderNonce)
+		signature := c.GetHeader(HeaderSignature)
+		if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+			respondWithError(c, http.StatusUnauthorized, "API key, timestamp, nonce and signature headers are required")
+			return
+		}
+
+		keyRepo := repositories.NewMerchantAPIKeyRepository(config.DB)
+		key, err := keyRepo.FindByKeyID(keyID)
+		if err != nil {
+			respondWithError(c, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+
+		now := time.Now()
+		defer recordAPIKeyUsage(c, keyRepo, key, now)
+
+		if !key.IsUsable(now) {
+			respondWithError(c, http.StatusUnauthorized, "API key has been revoked or has expired")
+			return
+		}
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			respondWithError(c, http.StatusUnauthorized, "Invalid timestamp")
+			return
+		}
+		signedAt := time.Unix(unix, 0)
+		if signedAt.Before(now.Add(-tolerance)) || signedAt.After(now.Add(tolerance)) {
+			respondWithError(c, http.StatusUnauthorized, "Request timestamp is outside the allowed window")
+			return
+		}
+
+		if len(nonce) < minNonceLength || len(nonce) > maxNonceLength {
+			respondWithError(c, http.StatusUnauthorized, "Nonce must be between 16 and 64 characters")
+			return
+		}
+
+		// Read the body for the signature and put it back for the handler
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBodySize+1))
+		if err != nil || len(body) > maxSignedBodySize {
+			respondWithError(c, http.StatusRequestEntityTooLarge, "Request body is too large")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		keySecret, err := auth.OpenAPIKeySecret(secret, key.KeyID, key.SecretCiphertext)
+		if err != nil {
+			respondWithError(c, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+		if !auth.VerifyRequestSignature(keySecret, c.Request.Method, c.Request.URL.RequestURI(), timestamp, nonce, body, signature) {
+			respondWithError(c, http.StatusUnauthorized, "Invalid signature")
+			return
+		}
+
+		// Only a correctly signed request spends its nonce, so forged requests
+		// can't burn the nonces of genuine ones
+		fresh, err := nonces.Use(key.KeyID+":"+nonce, signedAt.Add(tolerance), now)
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to validate nonce")
+			return
+		}
+		if !fresh {
+			respondWithError(c, http.StatusUnauthorized, "Nonce has already been used")
+			return
+		}
+
+		merchant, err := repositories.NewMerchantRepository(config.DB).FindByID(key.MerchantID)
+		if err != nil {
+			respondWithError(c, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+		if merchant.Status != models.MerchantActive {
+			respondWithError(c, http.StatusForbidden, "Merchant is not active")
+			return
+		}
+
+		c.Set(UserIDKey, merchant.OwnerID)
+		c.Set(MerchantIDKey, merchant.ID)
+		c.Set(APIKeyKey, key)
+
+		c.Next()
+	}
+}
+
+// RequireScope only lets requests signed with a key granted scope through. It
+// must run after MerchantAuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := c.Get(APIKeyKey)
+		if !ok || !key.(*models.MerchantAPIKey).HasScope(scope) {
+			respondWithError(c, http.StatusForbidden, "API key is missing the "+scope+" scope")
+			return
+		}
+		c.Next()
+	}
+}
+
+func recordAPIKeyUsage(c *gin.Context, keyRepo *repositories.MerchantAPIKeyRepository, key *models.MerchantAPIKey, now time.Time) {
+	usage := &models.MerchantAPIKeyUsage{
+		APIKeyID:   key.ID,
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+		StatusCode: c.Writer.Status(),
+		IPAddress:  c.ClientIP(),
+		CreatedAt:  now,
+	}
+	if len(usage.Path) > 255 {
+		usage.Path = usage.Path[:255]
+	}
+	// A failed log must not fail the request it describes
+	if err := keyRepo.RecordUsage(usage); err != nil {
+		log.Printf("Failed to record API key usage: %v", err)
+	}
+}
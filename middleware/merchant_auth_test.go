@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/auth"
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore()
+	now := time.Now()
+
+	fresh, err := store.Use("n", now.Add(time.Minute), now)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+
+	fresh, err = store.Use("n", now.Add(time.Minute), now.Add(30*time.Second))
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+
+	// Expired nonces are forgotten
+	_, _ = store.Use("other", now.Add(3*time.Minute), now.Add(2*time.Minute))
+	assert.Len(t, store.nonces, 1)
+}
+
+func TestMerchantAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	err = db.AutoMigrate(&models.User{}, &models.LedgerAccount{}, &models.Wallet{}, &models.Merchant{}, &models.MerchantAPIKey{}, &models.MerchantAPIKeyUsage{})
+	assert.NoError(t, err)
+	config.DB = db
+
+	owner := &models.User{FirstName: "Warung", LastName: "Owner", PhoneNumber: "1234567890", Address: "123 Main St", Pin: "123456", KYCTier: models.KYCTierFull}
+	assert.NoError(t, repositories.NewUserRepository(db).Create(owner))
+	merchant := &models.Merchant{OwnerID: owner.ID, Name: "Warung Makan", CategoryCode: "5812", SettlementCurrency: models.DefaultCurrency}
+	assert.NoError(t, repositories.NewMerchantRepository(db).Create(merchant))
+
+	keyID, err := models.NewAPIKeyID()
+	assert.NoError(t, err)
+	secret, err := auth.NewAPIKeySecret()
+	assert.NoError(t, err)
+	sealed, err := auth.SealAPIKeySecret("master", keyID, secret)
+	assert.NoError(t, err)
+
+	keyRepo := repositories.NewMerchantAPIKeyRepository(db)
+	key := &models.MerchantAPIKey{MerchantID: merchant.ID, KeyID: keyID, Name: "POS", SecretCiphertext: sealed}
+	assert.NoError(t, keyRepo.Create(key, []string{models.ScopePaymentsRead}, time.Now()))
+
+	router := gin.New()
+	router.Use(MerchantAuthMiddleware(NewMemoryNonceStore(), "master", 5*time.Minute))
+	router.POST("/payments", RequireScope(models.ScopePaymentsRead), func(c *gin.Context) {
+		assert.Equal(t, owner.ID, c.MustGet(UserIDKey).(uuid.UUID))
+		assert.Equal(t, merchant.ID, c.MustGet(MerchantIDKey).(uuid.UUID))
+		c.Status(http.StatusOK)
+	})
+	router.POST("/refunds", RequireScope(models.ScopeRefundsWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path, nonce string, signedAt time.Time, sign func(signature string) string) *httptest.ResponseRecorder {
+		body := `{"page":1}`
+		timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+		signature := sign(auth.SignRequest(secret, http.MethodPost, path, timestamp, nonce, []byte(body)))
+
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(HeaderAPIKey, key.KeyID)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderNonce, nonce)
+		req.Header.Set(HeaderSignature, signature)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	signed := func(signature string) string { return signature }
+
+	assert.Equal(t, http.StatusOK, request("/payments", "nonce-0000000001", time.Now(), signed).Code)
+
+	// Replays are rejected
+	assert.Equal(t, http.StatusUnauthorized, request("/payments", "nonce-0000000001", time.Now(), signed).Code)
+
+	// So are tampered signatures and stale timestamps
+	forged := func(string) string { return strings.Repeat("0", 64) }
+	assert.Equal(t, http.StatusUnauthorized, request("/payments", "nonce-0000000002", time.Now(), forged).Code)
+	assert.Equal(t, http.StatusUnauthorized, request("/payments", "nonce-0000000003", time.Now().Add(-10*time.Minute), signed).Code)
+
+	// A forged request doesn't spend the nonce
+	assert.Equal(t, http.StatusOK, request("/payments", "nonce-0000000002", time.Now(), signed).Code)
+
+	// The key lacks the refunds scope
+	assert.Equal(t, http.StatusForbidden, request("/refunds", "nonce-0000000004", time.Now(), signed).Code)
+
+	// Revoked keys stop working
+	_, err = keyRepo.Revoke(merchant.ID, key.KeyID, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, request("/payments", "nonce-0000000005", time.Now(), signed).Code)
+
+	// Every request with the key is logged
+	usage, total, err := keyRepo.GetUsage(key.ID, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), total)
+	assert.Equal(t, http.StatusUnauthorized, usage[0].StatusCode)
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RateLimit is a token bucket that holds up to Limit requests and refills
+// completely over Period.
+type RateLimit struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Enabled reports whether the limit should be enforced.
+func (l RateLimit) Enabled() bool {
+	return l.Limit > 0 && l.Period > 0
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+	// RetryAfter is how long until the next token, when not allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets. Implementations must be safe for
+// concurrent use; a shared store lets several instances enforce one limit.
+type RateLimitStore interface {
+	Take(key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// MemoryRateLimitStore keeps buckets in process memory.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*bucket)}
+}
+
+// sweepInterval is how often idle, refilled buckets are dropped
+const sweepInterval = time.Minute
+
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	capacity := float64(limit.Limit)
+	rate := capacity / limit.Period.Seconds()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.period = limit.Period
+
+	// Refill for the time since the last request
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+		b.last = now
+	}
+
+	result := RateLimitResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = secondsToDuration((capacity - b.tokens) / rate)
+	return result, nil
+}
+
+// sweep drops buckets that have had time to refill; they are equivalent to
+// a new bucket.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.last) >= b.period {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+// IPRateLimitMiddleware limits requests with one bucket per client IP. It
+// needs no authentication, so it goes in front of the auth middleware to
+// stop floods of bad credentials before they reach the database. name
+// separates buckets of different limiters.
+func IPRateLimitMiddleware(store RateLimitStore, name string, limit RateLimit) gin.HandlerFunc {
+	return rateLimitMiddleware(store, limit, func(c *gin.Context) string {
+		return name + ":ip:" + c.ClientIP()
+	})
+}
+
+// UserRateLimitMiddleware limits requests with one bucket per user, so it
+// must run after the auth middleware has set the user ID. Requests without
+// one pass through.
+func UserRateLimitMiddleware(store RateLimitStore, name string, limit RateLimit) gin.HandlerFunc {
+	return rateLimitMiddleware(store, limit, func(c *gin.Context) string {
+		userID, ok := c.Get(UserIDKey)
+		if !ok {
+			return ""
+		}
+		return name + ":user:" + userID.(uuid.UUID).String()
+	})
+}
+
+// rateLimitMiddleware takes a token from the bucket key returns for the
+// request; an empty key skips the limit.
+func rateLimitMiddleware(store RateLimitStore, limit RateLimit, key func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		bucketKey := key(c)
+		if bucketKey == "" {
+			c.Next()
+			return
+		}
+
+		result, err := store.Take(bucketKey, limit, time.Now())
+		if err != nil {
+			// Fail open; the limiter must not take the API down with it
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			respondWithError(c, http.StatusTooManyRequests, "Too many requests, please slow down")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
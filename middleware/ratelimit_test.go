@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Limit: 3, Period: 3 * time.Second}
+	now := time.Now()
+
+	for i := 2; i >= 0; i-- {
+		result, err := store.Take("k", limit, now)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result, err := store.Take("k", limit, now)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 3*time.Second, result.ResetAfter)
+
+	// One token refills per second
+	result, err = store.Take("k", limit, now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	// Other keys have their own bucket
+	result, err = store.Take("other", limit, now)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
+
+func TestMemoryStoreSweepsRefilledBuckets(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Limit: 1, Period: time.Second}
+	now := time.Now()
+
+	_, _ = store.Take("a", limit, now)
+	_, _ = store.Take("b", limit, now.Add(2*time.Minute))
+	assert.Len(t, store.buckets, 1)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewMemoryRateLimitStore()
+	userID := uuid.New()
+
+	router := gin.New()
+	router.GET("/public", IPRateLimitMiddleware(store, "test", RateLimit{Limit: 1, Period: time.Minute}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/private", func(c *gin.Context) {
+		c.Set(UserIDKey, userID)
+	}, UserRateLimitMiddleware(store, "test", RateLimit{Limit: 1, Period: time.Minute}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := request("/public")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("X-RateLimit-Reset"))
+
+	w = request("/public")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// The authenticated route is keyed by user, not by the exhausted IP
+	w = request("/private")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request("/private")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestIPRateLimitRunsBeforeAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewMemoryRateLimitStore()
+	authCalls := 0
+
+	router := gin.New()
+	router.Use(IPRateLimitMiddleware(store, "test", RateLimit{Limit: 2, Period: time.Minute}), func(c *gin.Context) {
+		authCalls++
+		respondWithError(c, http.StatusUnauthorized, "Invalid token")
+	}, UserRateLimitMiddleware(store, "test", RateLimit{Limit: 100, Period: time.Minute}))
+	router.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	codes := []int{}
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		codes = append(codes, w.Code)
+	}
+
+	assert.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests}, codes)
+	assert.Equal(t, 2, authCalls)
+}
+
+func TestDisabledRateLimitAllowsEverything(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", IPRateLimitMiddleware(NewMemoryRateLimitStore(), "test", RateLimit{}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+}
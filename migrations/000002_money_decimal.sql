@@ -0,0 +1,16 @@
+USE ewallet_api;
+
+-- Normalize monetary columns to exact DECIMAL(15,2).
+-- Rows written while these columns were floating point are rounded
+-- half away from zero to the nearest cent before the type change.
+UPDATE users SET balance = ROUND(balance, 2);
+UPDATE transactions
+SET amount = ROUND(amount, 2),
+    balance_before = ROUND(balance_before, 2),
+    balance_after = ROUND(balance_after, 2);
+
+ALTER TABLE users MODIFY balance DECIMAL(15,2) NOT NULL DEFAULT 0.00;
+ALTER TABLE transactions
+    MODIFY amount DECIMAL(15,2) NOT NULL,
+    MODIFY balance_before DECIMAL(15,2) NOT NULL,
+    MODIFY balance_after DECIMAL(15,2) NOT NULL;
@@ -0,0 +1,70 @@
+USE ewallet_api;
+
+-- Double-entry ledger. users.balance is kept as a cached value that must
+-- always equal the sum of the postings on the user's wallet account.
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id CHAR(36) PRIMARY KEY,
+    code VARCHAR(100) UNIQUE NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    user_id CHAR(36),
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id CHAR(36) PRIMARY KEY,
+    kind VARCHAR(30) NOT NULL,
+    transaction_id CHAR(36),
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id CHAR(36) PRIMARY KEY,
+    journal_entry_id CHAR(36) NOT NULL,
+    account_id CHAR(36) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (journal_entry_id) REFERENCES journal_entries(id),
+    FOREIGN KEY (account_id) REFERENCES ledger_accounts(id)
+);
+
+CREATE INDEX idx_ledger_accounts_user_id ON ledger_accounts(user_id);
+CREATE INDEX idx_journal_entries_transaction_id ON journal_entries(transaction_id);
+CREATE INDEX idx_postings_journal_entry_id ON postings(journal_entry_id);
+CREATE INDEX idx_postings_account_id ON postings(account_id);
+
+ALTER TABLE postings ADD CONSTRAINT check_non_zero_posting CHECK (amount <> 0);
+
+-- System accounts
+INSERT IGNORE INTO ledger_accounts (id, code, type, name) VALUES
+    (UUID(), 'SYSTEM:TOPUP_FUNDING', 'SYSTEM', 'SYSTEM:TOPUP_FUNDING'),
+    (UUID(), 'SYSTEM:PAYMENT_SETTLEMENT', 'SYSTEM', 'SYSTEM:PAYMENT_SETTLEMENT'),
+    (UUID(), 'SYSTEM:FEE_REVENUE', 'SYSTEM', 'SYSTEM:FEE_REVENUE'),
+    (UUID(), 'SYSTEM:OPENING_BALANCE', 'SYSTEM', 'SYSTEM:OPENING_BALANCE');
+
+-- Wallet accounts for existing users
+INSERT IGNORE INTO ledger_accounts (id, code, type, user_id, name)
+SELECT UUID(), CONCAT('USER:', id), 'USER', id, 'User wallet' FROM users;
+
+-- Opening balance journals so the ledger matches existing cached balances
+CREATE TEMPORARY TABLE opening_balances AS
+SELECT UUID() AS journal_id, a.id AS account_id, u.balance AS amount
+FROM users u
+JOIN ledger_accounts a ON a.code = CONCAT('USER:', u.id)
+WHERE u.balance <> 0;
+
+INSERT INTO journal_entries (id, kind, description)
+SELECT journal_id, 'OPENING_BALANCE', 'Opening balance' FROM opening_balances;
+
+INSERT INTO postings (id, journal_entry_id, account_id, amount)
+SELECT UUID(), ob.journal_id, ob.account_id, ob.amount FROM opening_balances ob;
+
+INSERT INTO postings (id, journal_entry_id, account_id, amount)
+SELECT UUID(), ob.journal_id, sa.id, -ob.amount
+FROM opening_balances ob
+JOIN ledger_accounts sa ON sa.code = 'SYSTEM:OPENING_BALANCE';
+
+DROP TEMPORARY TABLE opening_balances;
@@ -0,0 +1,15 @@
+USE ewallet_api;
+
+-- Roles for admin-only endpoints
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'USER';
+
+-- Refund tracking on the original transaction and links from compensating
+-- REFUND / REVERSAL transactions back to it
+ALTER TABLE transactions
+    ADD COLUMN refunded_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    ADD COLUMN original_transaction_id CHAR(36),
+    ADD CONSTRAINT fk_transactions_original FOREIGN KEY (original_transaction_id) REFERENCES transactions(id),
+    ADD CONSTRAINT check_refund_within_amount CHECK (refunded_amount >= 0 AND refunded_amount <= amount);
+
+CREATE INDEX idx_transactions_original_transaction_id ON transactions(original_transaction_id);
+
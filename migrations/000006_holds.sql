@@ -0,0 +1,22 @@
+USE ewallet_api;
+
+-- Authorization holds: reserve funds now, capture or void later
+CREATE TABLE IF NOT EXISTS holds (
+    id CHAR(36) PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    captured_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    status VARCHAR(20) NOT NULL,
+    description TEXT,
+    transaction_id CHAR(36),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX idx_holds_user_status ON holds(user_id, status);
+CREATE INDEX idx_holds_status_expires ON holds(status, expires_at);
+
+ALTER TABLE holds ADD CONSTRAINT check_positive_hold CHECK (amount > 0 AND captured_amount <= amount);
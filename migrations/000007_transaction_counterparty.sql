@@ -0,0 +1,16 @@
+USE ewallet_api;
+
+-- The other user involved in a transfer or refund, stored on both rows so
+-- history can be filtered by counterparty from either side
+ALTER TABLE transactions ADD COLUMN counterparty_id CHAR(36);
+
+UPDATE transactions SET counterparty_id = recipient_id WHERE recipient_id IS NOT NULL;
+
+-- Recipient CREDIT rows of transfers reference the sender's DEBIT row
+UPDATE transactions t
+JOIN transactions s ON t.reference_number = s.id
+SET t.counterparty_id = s.user_id
+WHERE t.type = 'CREDIT' AND t.transaction_type = 'TRANSFER';
+
+CREATE INDEX idx_transactions_counterparty_id ON transactions(counterparty_id);
+CREATE INDEX idx_transactions_user_type ON transactions(user_id, transaction_type);
@@ -0,0 +1,4 @@
+USE ewallet_api;
+
+-- Backs keyset pagination of history ordered by (created_at, id)
+CREATE INDEX idx_transactions_user_created_id ON transactions(user_id, created_at, id);
@@ -0,0 +1,29 @@
+USE ewallet_api;
+
+-- Failed login counters per phone number and per client IP
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id CHAR(36) PRIMARY KEY,
+    scope VARCHAR(10) NOT NULL,
+    attempt_key VARCHAR(255) NOT NULL,
+    failed_count INT NOT NULL DEFAULT 0,
+    lockout_count INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NULL,
+    locked_until TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_login_attempts_scope_key (scope, attempt_key)
+);
+
+-- Security audit trail
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id CHAR(36) PRIMARY KEY,
+    user_id CHAR(36),
+    action VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(45),
+    details TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_audit_logs_user_id ON audit_logs(user_id);
+CREATE INDEX idx_audit_logs_action ON audit_logs(action);
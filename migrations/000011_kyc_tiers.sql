@@ -0,0 +1,7 @@
+USE ewallet_api;
+
+-- KYC tier drives balance and transaction limits. New users start
+-- unverified; existing users keep transferring under the basic tier.
+ALTER TABLE users ADD COLUMN kyc_tier VARCHAR(20) NOT NULL DEFAULT 'UNVERIFIED';
+
+UPDATE users SET kyc_tier = 'BASIC';
@@ -0,0 +1,30 @@
+USE ewallet_api;
+
+-- Current verification state of the user
+ALTER TABLE users ADD COLUMN kyc_status VARCHAR(20) NOT NULL DEFAULT 'NONE';
+ALTER TABLE users ADD COLUMN kyc_reviewed_at TIMESTAMP NULL;
+
+-- Tier upgrade requests with identity document details; the images live in
+-- blob storage under the *_image_key paths
+CREATE TABLE IF NOT EXISTS kyc_submissions (
+    id CHAR(36) PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    requested_tier VARCHAR(20) NOT NULL,
+    document_type VARCHAR(30) NOT NULL,
+    document_number VARCHAR(50) NOT NULL,
+    full_name VARCHAR(255) NOT NULL,
+    date_of_birth DATE NOT NULL,
+    document_image_key VARCHAR(255) NOT NULL,
+    selfie_image_key VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    rejection_reason TEXT,
+    reviewed_by CHAR(36) NULL,
+    reviewed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (reviewed_by) REFERENCES users(id)
+);
+
+CREATE INDEX idx_kyc_submissions_user_id ON kyc_submissions(user_id);
+CREATE INDEX idx_kyc_submissions_status ON kyc_submissions(status);
@@ -0,0 +1,5 @@
+USE ewallet_api;
+
+-- Fee charged on top of a payment or transfer. The fee itself is recorded as
+-- a separate FEE transaction pointing back via original_transaction_id.
+ALTER TABLE transactions ADD COLUMN fee DECIMAL(15,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,32 @@
+USE ewallet_api;
+
+-- Multi-currency wallets. users.balance moves into each user's IDR wallet;
+-- wallets.balance is the cached value that must equal the ledger balance of
+-- the matching USER:<id>:<currency> account.
+CREATE TABLE IF NOT EXISTS wallets (
+    id CHAR(36) PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    balance DECIMAL(15,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE KEY idx_wallets_user_currency (user_id, currency)
+);
+
+INSERT INTO wallets (id, user_id, currency, balance)
+SELECT UUID(), id, 'IDR', balance FROM users;
+
+-- MySQL refuses to drop a column that a CHECK constraint still refers to
+ALTER TABLE users DROP CHECK check_positive_balance;
+ALTER TABLE users DROP COLUMN balance;
+
+-- Every amount is in the currency of its row; existing rows are all IDR
+ALTER TABLE transactions ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE holds ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE ledger_accounts ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR';
+
+-- Ledger accounts are kept per currency
+UPDATE ledger_accounts SET code = CONCAT(code, ':IDR');
+
+CREATE INDEX idx_holds_user_currency_status ON holds(user_id, currency, status);
@@ -0,0 +1,23 @@
+USE ewallet_api;
+
+-- Currency conversion quotes. A quote locks a rate until expires_at and is
+-- marked USED by the conversion that executes it.
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id CHAR(36) PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    from_currency CHAR(3) NOT NULL,
+    to_currency CHAR(3) NOT NULL,
+    from_amount DECIMAL(15,2) NOT NULL,
+    to_amount DECIMAL(15,2) NOT NULL,
+    mid_rate DECIMAL(20,8) NOT NULL,
+    rate DECIMAL(20,8) NOT NULL,
+    spread_basis_points BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+    transaction_id CHAR(36),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_fx_quotes_user_id ON fx_quotes(user_id);
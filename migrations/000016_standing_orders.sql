@@ -0,0 +1,32 @@
+USE ewallet_api;
+
+-- Scheduled one-off and recurring transfers and payments. The scheduler
+-- executes ACTIVE orders once next_run_at has passed; next_run_at is NULL
+-- once an order has no runs left.
+CREATE TABLE IF NOT EXISTS standing_orders (
+    id CHAR(36) PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    transaction_type VARCHAR(20) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    amount DECIMAL(15,2) NOT NULL,
+    recipient_id CHAR(36),
+    description TEXT,
+    schedule VARCHAR(20) NOT NULL,
+    cron_expression VARCHAR(100),
+    start_at TIMESTAMP NOT NULL,
+    end_at TIMESTAMP NULL,
+    next_run_at TIMESTAMP NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+    attempts INT NOT NULL DEFAULT 0,
+    run_count INT NOT NULL DEFAULT 0,
+    last_run_at TIMESTAMP NULL,
+    last_transaction_id CHAR(36),
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (recipient_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_standing_orders_user_id ON standing_orders(user_id);
+CREATE INDEX idx_standing_orders_due ON standing_orders(status, next_run_at);
@@ -0,0 +1,25 @@
+USE ewallet_api;
+
+-- Requests for money from one user to another. Accepting a request executes
+-- a transfer from the payer to the requester, recorded in transaction_id. A
+-- PENDING request past expires_at can no longer be answered.
+CREATE TABLE IF NOT EXISTS payment_requests (
+    id CHAR(36) PRIMARY KEY,
+    requester_id CHAR(36) NOT NULL,
+    payer_id CHAR(36) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    amount DECIMAL(15,2) NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    transaction_id CHAR(36),
+    expires_at TIMESTAMP NOT NULL,
+    responded_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (requester_id) REFERENCES users(id),
+    FOREIGN KEY (payer_id) REFERENCES users(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX idx_payment_requests_requester_id ON payment_requests(requester_id);
+CREATE INDEX idx_payment_requests_payer_id ON payment_requests(payer_id);
@@ -0,0 +1,30 @@
+USE ewallet_api;
+
+-- Bills split between their owner and other users. Every other participant
+-- is sent a payment request for their share; collected_amount grows as the
+-- requests are accepted until the bill is COLLECTED.
+CREATE TABLE IF NOT EXISTS split_bills (
+    id CHAR(36) PRIMARY KEY,
+    owner_id CHAR(36) NOT NULL,
+    transaction_id CHAR(36),
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    total_amount DECIMAL(15,2) NOT NULL,
+    owner_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    collected_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    method VARCHAR(20) NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (owner_id) REFERENCES users(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX idx_split_bills_owner_id ON split_bills(owner_id);
+CREATE UNIQUE INDEX idx_split_bills_transaction_id ON split_bills(transaction_id);
+
+ALTER TABLE payment_requests
+    ADD COLUMN split_bill_id CHAR(36) AFTER transaction_id,
+    ADD CONSTRAINT fk_payment_requests_split_bill FOREIGN KEY (split_bill_id) REFERENCES split_bills(id);
+
+CREATE INDEX idx_payment_requests_split_bill_id ON payment_requests(split_bill_id);
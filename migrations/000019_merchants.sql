@@ -0,0 +1,29 @@
+USE ewallet_api;
+
+-- Merchant business profiles. Payments to a merchant settle into the owner's
+-- wallet in settlement_currency.
+CREATE TABLE IF NOT EXISTS merchants (
+    id CHAR(36) PRIMARY KEY,
+    owner_id CHAR(36) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    category_code CHAR(4) NOT NULL,
+    email VARCHAR(255),
+    phone_number VARCHAR(20),
+    address TEXT,
+    city VARCHAR(100),
+    settlement_currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (owner_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_merchants_owner_id ON merchants(owner_id);
+
+-- Payments to a merchant, their settlement credits and refunds carry the
+-- merchant's ID
+ALTER TABLE transactions
+    ADD COLUMN merchant_id CHAR(36) AFTER original_transaction_id,
+    ADD CONSTRAINT fk_transactions_merchant FOREIGN KEY (merchant_id) REFERENCES merchants(id);
+
+CREATE INDEX idx_transactions_merchant_id ON transactions(merchant_id);
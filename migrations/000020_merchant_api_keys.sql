@@ -0,0 +1,38 @@
+USE ewallet_api;
+
+-- API keys merchants sign their server-to-server requests with. Only the
+-- public key_id is stored; the signing secret is derived from it and the
+-- server's master secret.
+CREATE TABLE IF NOT EXISTS merchant_api_keys (
+    id CHAR(36) PRIMARY KEY,
+    merchant_id CHAR(36) NOT NULL,
+    key_id VARCHAR(40) NOT NULL,
+    name VARCHAR(100),
+    scopes VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+    expires_at TIMESTAMP NULL,
+    rotated_from CHAR(36),
+    last_used_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_merchant_api_keys_key_id (key_id),
+    FOREIGN KEY (merchant_id) REFERENCES merchants(id),
+    FOREIGN KEY (rotated_from) REFERENCES merchant_api_keys(id)
+);
+
+CREATE INDEX idx_merchant_api_keys_merchant_id ON merchant_api_keys(merchant_id);
+
+-- One row per request made with a merchant API key
+CREATE TABLE IF NOT EXISTS merchant_api_key_usages (
+    id CHAR(36) PRIMARY KEY,
+    api_key_id CHAR(36) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    status_code INT NOT NULL,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (api_key_id) REFERENCES merchant_api_keys(id)
+);
+
+CREATE INDEX idx_api_key_usage_key_created ON merchant_api_key_usages(api_key_id, created_at);
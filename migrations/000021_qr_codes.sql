@@ -0,0 +1,25 @@
+USE ewallet_api;
+
+-- Dynamic QR codes merchants issue for one payment each. Static codes are
+-- derived from the merchant and not stored.
+CREATE TABLE IF NOT EXISTS qr_codes (
+    id CHAR(36) PRIMARY KEY,
+    merchant_id CHAR(36) NOT NULL,
+    reference VARCHAR(25) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    amount DECIMAL(15,2) NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    payer_id CHAR(36),
+    transaction_id CHAR(36),
+    expires_at TIMESTAMP NOT NULL,
+    paid_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_qr_codes_reference (reference),
+    FOREIGN KEY (merchant_id) REFERENCES merchants(id),
+    FOREIGN KEY (payer_id) REFERENCES users(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX idx_qr_codes_merchant_id ON qr_codes(merchant_id);
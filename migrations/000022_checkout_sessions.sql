@@ -0,0 +1,40 @@
+USE ewallet_api;
+
+-- Hosted checkout sessions merchants send customers a link to pay, and the
+-- line items of their orders.
+CREATE TABLE IF NOT EXISTS checkout_sessions (
+    id CHAR(36) PRIMARY KEY,
+    merchant_id CHAR(36) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    amount DECIMAL(15,2) NOT NULL,
+    description TEXT,
+    client_reference VARCHAR(100),
+    success_url VARCHAR(2048) NOT NULL,
+    cancel_url VARCHAR(2048) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN',
+    payer_id CHAR(36),
+    transaction_id CHAR(36),
+    expires_at TIMESTAMP NOT NULL,
+    paid_at TIMESTAMP NULL,
+    cancelled_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (merchant_id) REFERENCES merchants(id),
+    FOREIGN KEY (payer_id) REFERENCES users(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX idx_checkout_sessions_merchant_id ON checkout_sessions(merchant_id);
+
+CREATE TABLE IF NOT EXISTS checkout_line_items (
+    id CHAR(36) PRIMARY KEY,
+    session_id CHAR(36) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    quantity BIGINT NOT NULL,
+    unit_amount DECIMAL(15,2) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES checkout_sessions(id)
+);
+
+CREATE INDEX idx_checkout_line_items_session_id ON checkout_line_items(session_id);
@@ -0,0 +1,10 @@
+USE ewallet_api;
+
+-- Merchant API keys now have random signing secrets, stored sealed with
+-- MERCHANT_API_KEY_SECRET. Keys issued before had secrets derived from their
+-- public key_id and must be reissued.
+ALTER TABLE merchant_api_keys ADD COLUMN secret_ciphertext VARCHAR(255) AFTER name;
+
+UPDATE merchant_api_keys
+SET status = 'REVOKED', revoked_at = CURRENT_TIMESTAMP
+WHERE secret_ciphertext IS NULL AND status = 'ACTIVE';
@@ -0,0 +1,22 @@
+USE ewallet_api;
+
+-- Reverts 000014_wallets.sql. Only IDR balances can move back onto users, so
+-- run this before any wallet, hold or ledger account in another currency
+-- exists.
+ALTER TABLE users ADD COLUMN balance DECIMAL(15,2) NOT NULL DEFAULT 0;
+
+UPDATE users u
+JOIN wallets w ON w.user_id = u.id AND w.currency = 'IDR'
+SET u.balance = w.balance;
+
+ALTER TABLE users ADD CONSTRAINT check_positive_balance CHECK (balance >= 0);
+
+DROP INDEX idx_holds_user_currency_status ON holds;
+
+UPDATE ledger_accounts SET code = LEFT(code, CHAR_LENGTH(code) - 4) WHERE code LIKE '%:IDR';
+
+ALTER TABLE ledger_accounts DROP COLUMN currency;
+ALTER TABLE holds DROP COLUMN currency;
+ALTER TABLE transactions DROP COLUMN currency;
+
+DROP TABLE wallets;
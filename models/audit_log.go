@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditLoginLockout      = "LOGIN_LOCKOUT"
+	AuditRefreshTokenReuse = "REFRESH_TOKEN_REUSE"
+	AuditKYCSubmitted      = "KYC_SUBMITTED"
+	AuditKYCApproved       = "KYC_APPROVED"
+	AuditKYCRejected       = "KYC_REJECTED"
+)
+
+// AuditLog records a security relevant event.
+type AuditLog struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" gorm:"type:char(36);index"`
+	Action    string     `json:"action" gorm:"size:50;not null;index"`
+	IPAddress string     `json:"ip_address" gorm:"size:45"`
+	Details   string     `json:"details" gorm:"type:text"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
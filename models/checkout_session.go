@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Checkout session statuses. A session is OPEN in the database until the
+// customer pays or the merchant cancels it, and its link shows EXPIRED once
+// ExpiresAt passes. PAID and CANCELLED are final.
+const (
+	CheckoutSessionOpen      = "OPEN"
+	CheckoutSessionPaid      = "PAID"
+	CheckoutSessionExpired   = "EXPIRED"
+	CheckoutSessionCancelled = "CANCELLED"
+)
+
+var (
+	ErrCheckoutSessionNotOpen = errors.New("checkout session is no longer open")
+	ErrCheckoutSessionExpired = errors.New("checkout session has expired")
+	ErrLineItemsMismatch      = errors.New("amount does not match the line items")
+)
+
+// CheckoutSession is a hosted payment link a merchant created for one payment
+// of Amount. Any signed in user with the link can pay it once; the payer's
+// PAYMENT is recorded in TransactionID and the customer is then sent to
+// SuccessURL, or to CancelURL if they leave without paying. ClientReference
+// is the merchant's own order number, if any.
+type CheckoutSession struct {
+	ID              uuid.UUID          `json:"id" gorm:"type:char(36);primary_key"`
+	MerchantID      uuid.UUID          `json:"merchant_id" gorm:"type:char(36);not null;index"`
+	Currency        string             `json:"currency" gorm:"size:3;not null;default:IDR"`
+	Amount          Money              `json:"amount" gorm:"not null"`
+	Description     string             `json:"description"`
+	ClientReference string             `json:"client_reference,omitempty" gorm:"size:100"`
+	SuccessURL      string             `json:"success_url" gorm:"size:2048;not null"`
+	CancelURL       string             `json:"cancel_url" gorm:"size:2048;not null"`
+	Status          string             `json:"status" gorm:"size:20;not null;default:OPEN"`
+	LineItems       []CheckoutLineItem `json:"line_items,omitempty" gorm:"foreignKey:SessionID"`
+	PayerID         *uuid.UUID         `json:"payer_id,omitempty" gorm:"type:char(36)"`
+	TransactionID   *uuid.UUID         `json:"transaction_id,omitempty" gorm:"type:char(36)"`
+	ExpiresAt       time.Time          `json:"expires_at" gorm:"not null"`
+	PaidAt          *time.Time         `json:"paid_at,omitempty"`
+	CancelledAt     *time.Time         `json:"cancelled_at,omitempty"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+}
+
+// CheckoutLineItem is one line of a checkout session's order. Amount is
+// UnitAmount times Quantity.
+type CheckoutLineItem struct {
+	ID         uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	SessionID  uuid.UUID `json:"session_id" gorm:"type:char(36);not null;index"`
+	Name       string    `json:"name" gorm:"size:100;not null"`
+	Quantity   int64     `json:"quantity" gorm:"not null"`
+	UnitAmount Money     `json:"unit_amount" gorm:"not null"`
+	Amount     Money     `json:"amount" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// IsExpired reports whether the session can no longer be paid at now.
+func (s *CheckoutSession) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// CurrentStatus returns the status, reporting an open session past its expiry
+// as EXPIRED.
+func (s *CheckoutSession) CurrentStatus(now time.Time) string {
+	if s.Status == CheckoutSessionOpen && s.IsExpired(now) {
+		return CheckoutSessionExpired
+	}
+	return s.Status
+}
+
+// CheckOpen returns why the session can't be paid or cancelled at now, if it
+// can't.
+func (s *CheckoutSession) CheckOpen(now time.Time) error {
+	switch s.CurrentStatus(now) {
+	case CheckoutSessionOpen:
+		return nil
+	case CheckoutSessionExpired:
+		return ErrCheckoutSessionExpired
+	default:
+		return ErrCheckoutSessionNotOpen
+	}
+}
+
+// PriceLineItems sets the amount of every line item and returns their total,
+// or ErrAmountOutOfRange if it doesn't fit in Money.
+func PriceLineItems(items []CheckoutLineItem) (Money, error) {
+	var total Money
+	for i := range items {
+		item := &items[i]
+		if item.Quantity <= 0 || item.UnitAmount <= 0 || item.UnitAmount > MaxMoney/Money(item.Quantity) {
+			return 0, ErrAmountOutOfRange
+		}
+		item.Amount = item.UnitAmount * Money(item.Quantity)
+		if item.Amount > MaxMoney-total {
+			return 0, ErrAmountOutOfRange
+		}
+		total += item.Amount
+	}
+	return total, nil
+}
+
+func (s *CheckoutSession) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+func (i *CheckoutLineItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
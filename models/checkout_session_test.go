@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceLineItems(t *testing.T) {
+	items := []CheckoutLineItem{
+		{Name: "Nasi goreng", Quantity: 2, UnitAmount: MustParseMoney("25000")},
+		{Name: "Es teh", Quantity: 3, UnitAmount: MustParseMoney("5000.50")},
+	}
+	total, err := PriceLineItems(items)
+	assert.NoError(t, err)
+	assert.Equal(t, MustParseMoney("65001.50"), total)
+	assert.Equal(t, MustParseMoney("50000"), items[0].Amount)
+	assert.Equal(t, MustParseMoney("15001.50"), items[1].Amount)
+
+	_, err = PriceLineItems([]CheckoutLineItem{{Name: "Free", Quantity: 1, UnitAmount: 0}})
+	assert.Equal(t, ErrAmountOutOfRange, err)
+	_, err = PriceLineItems([]CheckoutLineItem{{Name: "Huge", Quantity: 10, UnitAmount: MaxMoney / 5}})
+	assert.Equal(t, ErrAmountOutOfRange, err)
+	_, err = PriceLineItems([]CheckoutLineItem{
+		{Name: "Half", Quantity: 1, UnitAmount: MaxMoney / 2},
+		{Name: "Half", Quantity: 1, UnitAmount: MaxMoney / 2},
+		{Name: "One more", Quantity: 1, UnitAmount: 2},
+	})
+	assert.Equal(t, ErrAmountOutOfRange, err)
+}
+
+func TestCheckoutSessionCheckOpen(t *testing.T) {
+	now := time.Now()
+
+	session := &CheckoutSession{Status: CheckoutSessionOpen, ExpiresAt: now.Add(time.Minute)}
+	assert.NoError(t, session.CheckOpen(now))
+	assert.Equal(t, CheckoutSessionExpired, session.CurrentStatus(now.Add(time.Minute)))
+	assert.Equal(t, ErrCheckoutSessionExpired, session.CheckOpen(now.Add(time.Minute)))
+
+	// Paid and cancelled sessions stay so after their expiry
+	session.Status = CheckoutSessionPaid
+	assert.Equal(t, CheckoutSessionPaid, session.CurrentStatus(now.Add(time.Hour)))
+	assert.Equal(t, ErrCheckoutSessionNotOpen, session.CheckOpen(now))
+	session.Status = CheckoutSessionCancelled
+	assert.Equal(t, ErrCheckoutSessionNotOpen, session.CheckOpen(now))
+}
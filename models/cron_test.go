@@ -0,0 +1,96 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		assert.NoError(t, err)
+		return parsed
+	}
+
+	cases := []struct {
+		expr, from, want string
+	}{
+		{"0 9 1 * *", "2026-01-15 10:00", "2026-02-01 09:00"},
+		{"0 9 1 * *", "2026-02-01 08:59", "2026-02-01 09:00"},
+		{"0 9 1 * *", "2026-02-01 09:00", "2026-03-01 09:00"},
+		{"*/15 8-17 * * 1-5", "2026-10-16 17:50", "2026-10-19 08:00"},
+		{"30 12 * * 7", "2026-10-18 12:30", "2026-10-25 12:30"},
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		// Both day fields restricted: the 13th or any Friday
+		{"0 0 13 * 5", "2026-10-01 00:00", "2026-10-02 00:00"},
+	}
+	for _, tc := range cases {
+		schedule, err := ParseCron(tc.expr)
+		assert.NoError(t, err, tc.expr)
+		assert.Equal(t, at(tc.want), schedule.Next(at(tc.from)), tc.expr)
+	}
+
+	never, err := ParseCron("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, never.Next(at("2026-01-01 00:00")).IsZero())
+}
+
+func TestParseCronRejectsInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseCron(expr)
+		assert.True(t, errors.Is(err, ErrInvalidCron), expr)
+	}
+}
+
+func TestStandingOrderNextRun(t *testing.T) {
+	start := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	order := &StandingOrder{Schedule: ScheduleMonthly, StartAt: start}
+
+	// Runs on the 31st fall on the last day of shorter months
+	next, ok := order.NextRunAfter(start)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC), next)
+	next, _ = order.NextRunAfter(next)
+	assert.Equal(t, time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC), next)
+	next, _ = order.NextRunAfter(time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 4, 30, 9, 0, 0, 0, time.UTC), next)
+
+	// Long after the start the next run is still found directly
+	next, _ = order.NextRunAfter(time.Date(2031, 7, 31, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2031, 8, 31, 9, 0, 0, 0, time.UTC), next)
+
+	end := time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC)
+	order.EndAt = &end
+	_, ok = order.NextRunAfter(end)
+	assert.False(t, ok)
+
+	weekly := &StandingOrder{Schedule: ScheduleWeekly, StartAt: start}
+	next, _ = weekly.NextRunAfter(start.Add(time.Hour))
+	assert.Equal(t, start.AddDate(0, 0, 7), next)
+
+	once := &StandingOrder{Schedule: ScheduleOnce, StartAt: start}
+	first, ok := once.FirstRun()
+	assert.True(t, ok)
+	assert.Equal(t, start, first)
+	_, ok = once.NextRunAfter(start)
+	assert.False(t, ok)
+
+	cron := &StandingOrder{Schedule: ScheduleCron, CronExpression: "0 9 1 * *", StartAt: start}
+	first, ok = cron.FirstRun()
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC), first)
+}
+
+func TestStandingOrderValidateSchedule(t *testing.T) {
+	start := time.Now()
+	before := start.Add(-time.Hour)
+
+	assert.NoError(t, (&StandingOrder{Schedule: ScheduleDaily, StartAt: start}).ValidateSchedule())
+	assert.Equal(t, ErrInvalidSchedule, (&StandingOrder{Schedule: "YEARLY", StartAt: start}).ValidateSchedule())
+	assert.Equal(t, ErrInvalidSchedule, (&StandingOrder{Schedule: ScheduleDaily, CronExpression: "* * * * *", StartAt: start}).ValidateSchedule())
+	assert.Equal(t, ErrInvalidSchedule, (&StandingOrder{Schedule: ScheduleDaily, StartAt: start, EndAt: &before}).ValidateSchedule())
+	assert.True(t, errors.Is((&StandingOrder{Schedule: ScheduleCron, StartAt: start}).ValidateSchedule(), ErrInvalidCron))
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// DefaultCurrency is the currency of every user's primary wallet. KYC limits
+// and the built-in fee schedule are expressed in it.
+const DefaultCurrency = "IDR"
+
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrCurrencyPrecision   = errors.New("amount has more decimal places than the currency allows")
+	ErrCurrencyMismatch    = errors.New("currencies do not match")
+)
+
+// Currency is an ISO 4217 currency wallets can be held in. Exponent is the
+// number of decimal places of its minor unit. Amounts are stored with two
+// decimal places, so only currencies with an exponent of at most two can be
+// supported. NumericCode is the three digit ISO 4217 code used in QR codes.
+type Currency struct {
+	Code        string `json:"code"`
+	NumericCode string `json:"numeric_code"`
+	Name        string `json:"name"`
+	Exponent    int    `json:"exponent"`
+}
+
+// Currencies are the supported currencies by code.
+var Currencies = map[string]Currency{
+	"IDR": {Code: "IDR", NumericCode: "360", Name: "Indonesian Rupiah", Exponent: 2},
+	"USD": {Code: "USD", NumericCode: "840", Name: "US Dollar", Exponent: 2},
+	"EUR": {Code: "EUR", NumericCode: "978", Name: "Euro", Exponent: 2},
+	"SGD": {Code: "SGD", NumericCode: "702", Name: "Singapore Dollar", Exponent: 2},
+	"MYR": {Code: "MYR", NumericCode: "458", Name: "Malaysian Ringgit", Exponent: 2},
+	"JPY": {Code: "JPY", NumericCode: "392", Name: "Japanese Yen", Exponent: 0},
+}
+
+// LookupCurrency returns the supported currency with the given code, which
+// is matched case-insensitively.
+func LookupCurrency(code string) (Currency, error) {
+	currency, ok := Currencies[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Currency{}, ErrUnsupportedCurrency
+	}
+	return currency, nil
+}
+
+// LookupNumericCurrency returns the supported currency with the given ISO
+// 4217 numeric code.
+func LookupNumericCurrency(numericCode string) (Currency, error) {
+	for _, currency := range Currencies {
+		if currency.NumericCode == numericCode {
+			return currency, nil
+		}
+	}
+	return Currency{}, ErrUnsupportedCurrency
+}
+
+// SupportedCurrencies lists the supported currencies ordered by code.
+func SupportedCurrencies() []Currency {
+	currencies := make([]Currency, 0, len(Currencies))
+	for _, currency := range Currencies {
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Code < currencies[j].Code })
+	return currencies
+}
+
+// minorUnit returns the smallest amount that can be expressed in the currency.
+func (c Currency) minorUnit() Money {
+	unit := Money(1)
+	for i := c.Exponent; i < MoneyScale; i++ {
+		unit *= 10
+	}
+	return unit
+}
+
+// ValidateAmount rejects amounts finer than the currency's minor unit, such
+// as 10.50 JPY.
+func (c Currency) ValidateAmount(m Money) error {
+	if m%c.minorUnit() != 0 {
+		return ErrCurrencyPrecision
+	}
+	return nil
+}
+
+// Format formats an amount with the currency's decimal places, e.g. 1500 JPY
+// rather than 1500.00.
+func (c Currency) Format(m Money) string {
+	s := m.String()
+	if c.Exponent < MoneyScale {
+		s = s[:len(s)-(MoneyScale-c.Exponent)]
+		s = strings.TrimSuffix(s, ".")
+	}
+	return s
+}
+
+// Round rounds a derived amount half away from zero to the currency's minor
+// unit.
+func (c Currency) Round(m Money) Money {
+	unit := c.minorUnit()
+	return m.MulRatio(1, int64(unit)) * unit
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupCurrency(t *testing.T) {
+	currency, err := LookupCurrency(" usd ")
+	assert.NoError(t, err)
+	assert.Equal(t, "USD", currency.Code)
+
+	_, err = LookupCurrency("XYZ")
+	assert.Equal(t, ErrUnsupportedCurrency, err)
+}
+
+func TestCurrencyPrecision(t *testing.T) {
+	usd := Currencies["USD"]
+	jpy := Currencies["JPY"]
+
+	assert.NoError(t, usd.ValidateAmount(MustParseMoney("10.55")))
+	assert.NoError(t, jpy.ValidateAmount(MustParseMoney("1000")))
+	assert.Equal(t, ErrCurrencyPrecision, jpy.ValidateAmount(MustParseMoney("1000.50")))
+
+	assert.Equal(t, MustParseMoney("10.55"), usd.Round(MustParseMoney("10.55")))
+	assert.Equal(t, MustParseMoney("1001"), jpy.Round(MustParseMoney("1000.50")))
+	assert.Equal(t, MustParseMoney("1000"), jpy.Round(MustParseMoney("1000.49")))
+	assert.Equal(t, MustParseMoney("-1001"), jpy.Round(MustParseMoney("-1000.50")))
+}
+
+func TestCurrencyFormatAndNumericCode(t *testing.T) {
+	assert.Equal(t, "45000.50", Currencies["IDR"].Format(MustParseMoney("45000.5")))
+	assert.Equal(t, "1500", Currencies["JPY"].Format(MustParseMoney("1500")))
+
+	currency, err := LookupNumericCurrency("360")
+	assert.NoError(t, err)
+	assert.Equal(t, "IDR", currency.Code)
+
+	_, err = LookupNumericCurrency("999")
+	assert.Equal(t, ErrUnsupportedCurrency, err)
+}
+
+func TestLimitValue(t *testing.T) {
+	for code := range Currencies {
+		_, ok := LimitRates[code]
+		assert.True(t, ok, "no limit rate for %s", code)
+	}
+
+	value, err := LimitValue("USD", MustParseMoney("100"))
+	assert.NoError(t, err)
+	assert.Equal(t, MustParseMoney("1700000"), value)
+
+	value, err = LimitValue("JPY", MaxMoney)
+	assert.NoError(t, err)
+	assert.Equal(t, MaxMoney, value)
+
+	_, err = LimitValue("XYZ", MustParseMoney("1"))
+	assert.Equal(t, ErrUnsupportedCurrency, err)
+}
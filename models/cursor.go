@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cursor directions
+const (
+	CursorNext = "next"
+	CursorPrev = "prev"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TransactionCursor is a position in transaction history ordered by
+// (created_at, id) descending. Next walks towards older rows, prev towards
+// newer ones. Clients only ever see it as an opaque token.
+type TransactionCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Direction string    `json:"d"`
+}
+
+// NewTransactionCursor returns a cursor positioned at t.
+func NewTransactionCursor(t *Transaction, direction string) *TransactionCursor {
+	return &TransactionCursor{CreatedAt: t.CreatedAt, ID: t.ID, Direction: direction}
+}
+
+// Encode returns the opaque token for the cursor.
+func (c *TransactionCursor) Encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodeTransactionCursor parses a token produced by Encode.
+func DecodeTransactionCursor(token string) (*TransactionCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor TransactionCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.ID == uuid.Nil || cursor.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Direction != CursorNext && cursor.Direction != CursorPrev {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionCursorRoundTrip(t *testing.T) {
+	transaction := &Transaction{ID: uuid.New(), CreatedAt: time.Date(2024, 5, 1, 10, 30, 0, 123000000, time.UTC)}
+	cursor := NewTransactionCursor(transaction, CursorNext)
+
+	decoded, err := DecodeTransactionCursor(cursor.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, transaction.ID, decoded.ID)
+	assert.True(t, transaction.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, CursorNext, decoded.Direction)
+}
+
+func TestDecodeTransactionCursorRejectsGarbage(t *testing.T) {
+	for _, token := range []string{"not base64!", "e30", "eyJkIjoic2lkZXdheXMifQ"} {
+		_, err := DecodeTransactionCursor(token)
+		assert.Equal(t, ErrInvalidCursor, err, token)
+	}
+}
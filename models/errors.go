@@ -3,5 +3,11 @@ package models
 import "errors"
 
 var (
-	ErrInvalidTransaction = errors.New("invalid transaction")
+	ErrInvalidTransaction       = errors.New("invalid transaction")
+	ErrSelfTransfer             = errors.New("cannot transfer to yourself")
+	ErrTransactionNotRefundable = errors.New("transaction cannot be refunded")
+	ErrRefundExceedsAmount      = errors.New("refund exceeds refundable amount")
+	ErrHoldNotActive            = errors.New("hold is not active")
+	ErrHoldExpired              = errors.New("hold has expired")
+	ErrCaptureExceedsHold       = errors.New("capture exceeds held amount")
 )
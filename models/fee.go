@@ -0,0 +1,263 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// FEE is the transaction type of a fee charged on top of another transaction
+const FEE = "FEE"
+
+// Fee cap markers reported in FeeBreakdown.Cap
+const (
+	FeeCapMin = "MIN"
+	FeeCapMax = "MAX"
+)
+
+// basisPointsPerUnit is the number of basis points in 100%
+const basisPointsPerUnit = 10000
+
+var ErrInvalidFeeSchedule = errors.New("invalid fee schedule")
+
+// FeeTier is one amount band of a tiered fee. UpTo is the inclusive upper
+// bound of the band; zero means unbounded and is only allowed on the last
+// tier.
+type FeeTier struct {
+	UpTo        Money `json:"up_to"`
+	Flat        Money `json:"flat"`
+	BasisPoints int64 `json:"basis_points"`
+}
+
+// FeeRule prices one transaction type in one currency (the default currency
+// when empty), optionally only for one KYC tier. The fee is Flat plus
+// BasisPoints of the amount (1 basis point = 0.01%), or the same taken from
+// the matching band when Tiers is set, then held between Min and Max. A zero
+// Max means no upper cap.
+type FeeRule struct {
+	TransactionType string    `json:"transaction_type"`
+	Currency        string    `json:"currency,omitempty"`
+	KYCTier         string    `json:"kyc_tier,omitempty"`
+	Flat            Money     `json:"flat"`
+	BasisPoints     int64     `json:"basis_points"`
+	Tiers           []FeeTier `json:"tiers,omitempty"`
+	Min             Money     `json:"min"`
+	Max             Money     `json:"max"`
+}
+
+// FeeSchedule is the set of fee rules. A rule for the user's KYC tier wins
+// over a rule for any tier; transactions without a matching rule are free.
+type FeeSchedule struct {
+	Rules []FeeRule `json:"rules"`
+}
+
+// FeeBreakdown shows how the fee of a transaction was worked out. Cap is MIN
+// or MAX when the fee was raised or lowered to a cap.
+type FeeBreakdown struct {
+	Amount        Money  `json:"amount"`
+	FlatFee       Money  `json:"flat_fee"`
+	PercentageFee Money  `json:"percentage_fee"`
+	Cap           string `json:"cap,omitempty"`
+	Fee           Money  `json:"fee"`
+	Total         Money  `json:"total_amount"`
+}
+
+// FeeTransactionTypes are the transaction types fees can be charged on.
+var FeeTransactionTypes = []string{PAYMENT, TRANSFER}
+
+// DefaultFeeSchedule charges transfers: a flat fee for basic users, and for
+// fully verified users free transfers up to 1,000,000 with 0.1% above that,
+// capped at 10,000. Payments are free.
+var DefaultFeeSchedule = FeeSchedule{
+	Rules: []FeeRule{
+		{TransactionType: TRANSFER, KYCTier: KYCTierBasic, Flat: NewMoneyFromMajor(2500)},
+		{
+			TransactionType: TRANSFER,
+			KYCTier:         KYCTierFull,
+			Tiers: []FeeTier{
+				{UpTo: NewMoneyFromMajor(1000000)},
+				{BasisPoints: 10},
+			},
+			Max: NewMoneyFromMajor(10000),
+		},
+	},
+}
+
+// Fees is the schedule in effect. It is replaced at startup when a fee
+// schedule file is configured.
+var Fees = &DefaultFeeSchedule
+
+// Quote works out the fee of a transaction in currency for a user of the
+// given tier. A nil schedule charges nothing.
+func (s *FeeSchedule) Quote(transactionType, kycTier, currency string, amount Money) FeeBreakdown {
+	breakdown := FeeBreakdown{Amount: amount, Total: amount}
+	if s == nil {
+		return breakdown
+	}
+	rule := s.ruleFor(transactionType, kycTier, currency)
+	if rule == nil {
+		return breakdown
+	}
+
+	flat, basisPoints := rule.Flat, rule.BasisPoints
+	if len(rule.Tiers) > 0 {
+		tier := rule.tierFor(amount)
+		flat, basisPoints = tier.Flat, tier.BasisPoints
+	}
+
+	breakdown.FlatFee = flat
+	breakdown.PercentageFee = Currencies[currency].Round(amount.MulRatio(basisPoints, basisPointsPerUnit))
+	breakdown.Fee = breakdown.FlatFee + breakdown.PercentageFee
+
+	switch {
+	case breakdown.Fee < rule.Min:
+		breakdown.Fee = rule.Min
+		breakdown.Cap = FeeCapMin
+	case rule.Max > 0 && breakdown.Fee > rule.Max:
+		breakdown.Fee = rule.Max
+		breakdown.Cap = FeeCapMax
+	}
+
+	breakdown.Total = amount + breakdown.Fee
+	return breakdown
+}
+
+func (s *FeeSchedule) ruleFor(transactionType, kycTier, currency string) *FeeRule {
+	var fallback *FeeRule
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		if rule.TransactionType != transactionType || rule.currency() != currency {
+			continue
+		}
+		if rule.KYCTier == kycTier {
+			return rule
+		}
+		if rule.KYCTier == "" {
+			fallback = rule
+		}
+	}
+	return fallback
+}
+
+func (r *FeeRule) currency() string {
+	if r.Currency == "" {
+		return DefaultCurrency
+	}
+	return r.Currency
+}
+
+// tierFor returns the band the amount falls in. Amounts above a bounded last
+// band are priced by that band.
+func (r *FeeRule) tierFor(amount Money) FeeTier {
+	for _, tier := range r.Tiers {
+		if tier.UpTo == 0 || amount <= tier.UpTo {
+			return tier
+		}
+	}
+	return r.Tiers[len(r.Tiers)-1]
+}
+
+// Validate checks that every rule is well formed and that no two rules
+// cover the same transaction type, currency and tier.
+func (s *FeeSchedule) Validate() error {
+	seen := make(map[string]bool)
+	for i, rule := range s.Rules {
+		invalid := func(reason string) error {
+			return fmt.Errorf("%w: rule %d: %s", ErrInvalidFeeSchedule, i, reason)
+		}
+
+		if !isFeeTransactionType(rule.TransactionType) {
+			return invalid("unsupported transaction_type " + rule.TransactionType)
+		}
+		currency, ok := Currencies[rule.currency()]
+		if !ok {
+			return invalid("unsupported currency " + rule.Currency)
+		}
+		if _, ok := KYCTierLimits[rule.KYCTier]; rule.KYCTier != "" && !ok {
+			return invalid("unknown kyc_tier " + rule.KYCTier)
+		}
+		key := rule.TransactionType + "/" + currency.Code + "/" + rule.KYCTier
+		if seen[key] {
+			return invalid("duplicate rule for " + key)
+		}
+		seen[key] = true
+
+		if rule.Min < 0 || rule.Max < 0 {
+			return invalid("min and max must not be negative")
+		}
+		if rule.Max > 0 && rule.Max < rule.Min {
+			return invalid("max must not be less than min")
+		}
+		if err := validateFeeComponents(currency, rule.Flat, rule.BasisPoints); err != nil {
+			return invalid(err.Error())
+		}
+		if currency.ValidateAmount(rule.Min) != nil || currency.ValidateAmount(rule.Max) != nil {
+			return invalid("min and max must fit the currency precision")
+		}
+
+		if len(rule.Tiers) == 0 {
+			continue
+		}
+		if rule.Flat != 0 || rule.BasisPoints != 0 {
+			return invalid("flat and basis_points must be set on the tiers of a tiered rule")
+		}
+		for j, tier := range rule.Tiers {
+			if err := validateFeeComponents(currency, tier.Flat, tier.BasisPoints); err != nil {
+				return invalid(fmt.Sprintf("tier %d: %v", j, err))
+			}
+			last := j == len(rule.Tiers)-1
+			if tier.UpTo < 0 || (tier.UpTo == 0 && !last) {
+				return invalid(fmt.Sprintf("tier %d: only the last tier may be unbounded", j))
+			}
+			if j > 0 && tier.UpTo != 0 && tier.UpTo <= rule.Tiers[j-1].UpTo {
+				return invalid(fmt.Sprintf("tier %d: up_to must increase", j))
+			}
+		}
+	}
+	return nil
+}
+
+func validateFeeComponents(currency Currency, flat Money, basisPoints int64) error {
+	if flat < 0 {
+		return errors.New("flat must not be negative")
+	}
+	if currency.ValidateAmount(flat) != nil {
+		return errors.New("flat must fit the currency precision")
+	}
+	if basisPoints < 0 || basisPoints > basisPointsPerUnit {
+		return errors.New("basis_points must be between 0 and 10000")
+	}
+	return nil
+}
+
+func isFeeTransactionType(transactionType string) bool {
+	for _, t := range FeeTransactionTypes {
+		if t == transactionType {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFeeSchedule decodes and validates a JSON fee schedule. Amounts are in
+// major units, like every other amount in the API.
+func ParseFeeSchedule(data []byte) (*FeeSchedule, error) {
+	var schedule FeeSchedule
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeeSchedule, err)
+	}
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// LoadFeeSchedule reads a JSON fee schedule file.
+func LoadFeeSchedule(path string) (*FeeSchedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFeeSchedule(data)
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeScheduleQuote(t *testing.T) {
+	schedule := &FeeSchedule{
+		Rules: []FeeRule{
+			{TransactionType: PAYMENT, BasisPoints: 150, Min: NewMoneyFromMajor(1000), Max: NewMoneyFromMajor(5000)},
+			{TransactionType: TRANSFER, Flat: NewMoneyFromMajor(2500)},
+			{
+				TransactionType: TRANSFER,
+				KYCTier:         KYCTierFull,
+				Tiers: []FeeTier{
+					{UpTo: NewMoneyFromMajor(100000)},
+					{UpTo: NewMoneyFromMajor(1000000), Flat: NewMoneyFromMajor(1000)},
+					{Flat: NewMoneyFromMajor(1000), BasisPoints: 10},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name            string
+		transactionType string
+		tier            string
+		amount          Money
+		fee             Money
+		cap             string
+	}{
+		{"percentage", PAYMENT, KYCTierBasic, NewMoneyFromMajor(100000), NewMoneyFromMajor(1500), ""},
+		{"rounded to the cent", PAYMENT, KYCTierBasic, MustParseMoney("100000.30"), MustParseMoney("1500.00"), ""},
+		{"raised to min", PAYMENT, KYCTierBasic, NewMoneyFromMajor(10000), NewMoneyFromMajor(1000), FeeCapMin},
+		{"lowered to max", PAYMENT, KYCTierBasic, NewMoneyFromMajor(1000000), NewMoneyFromMajor(5000), FeeCapMax},
+		{"any tier rule", TRANSFER, KYCTierBasic, NewMoneyFromMajor(50000), NewMoneyFromMajor(2500), ""},
+		{"first band", TRANSFER, KYCTierFull, NewMoneyFromMajor(100000), 0, ""},
+		{"second band", TRANSFER, KYCTierFull, NewMoneyFromMajor(500000), NewMoneyFromMajor(1000), ""},
+		{"open band", TRANSFER, KYCTierFull, NewMoneyFromMajor(2000000), NewMoneyFromMajor(3000), ""},
+		{"no rule", TOPUP, KYCTierBasic, NewMoneyFromMajor(100000), 0, ""},
+	}
+
+	for _, c := range cases {
+		quote := schedule.Quote(c.transactionType, c.tier, DefaultCurrency, c.amount)
+		assert.Equal(t, c.fee, quote.Fee, c.name)
+		assert.Equal(t, c.cap, quote.Cap, c.name)
+		assert.Equal(t, c.amount+c.fee, quote.Total, c.name)
+	}
+}
+
+func TestFeeScheduleQuotePerCurrency(t *testing.T) {
+	schedule := &FeeSchedule{
+		Rules: []FeeRule{
+			{TransactionType: TRANSFER, Flat: NewMoneyFromMajor(2500)},
+			{TransactionType: TRANSFER, Currency: "JPY", BasisPoints: 15},
+		},
+	}
+
+	// Rules only apply to their own currency
+	assert.Equal(t, Money(0), schedule.Quote(TRANSFER, KYCTierBasic, "USD", NewMoneyFromMajor(100)).Fee)
+
+	// Percentages are rounded to the currency's minor unit: 0.15% of 1,000
+	// yen is 1.5 yen, which rounds up to 2
+	quote := schedule.Quote(TRANSFER, KYCTierBasic, "JPY", NewMoneyFromMajor(1000))
+	assert.Equal(t, NewMoneyFromMajor(2), quote.Fee)
+}
+
+func TestNilFeeScheduleIsFree(t *testing.T) {
+	var schedule *FeeSchedule
+	quote := schedule.Quote(TRANSFER, KYCTierFull, DefaultCurrency, NewMoneyFromMajor(100))
+	assert.Equal(t, Money(0), quote.Fee)
+	assert.Equal(t, NewMoneyFromMajor(100), quote.Total)
+}
+
+func TestDefaultFeeScheduleIsValid(t *testing.T) {
+	assert.NoError(t, DefaultFeeSchedule.Validate())
+}
+
+func TestParseFeeSchedule(t *testing.T) {
+	schedule, err := ParseFeeSchedule([]byte(`{"rules":[{"transaction_type":"TRANSFER","kyc_tier":"BASIC","flat":2500.50,"max":"10000"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, MustParseMoney("2500.50"), schedule.Rules[0].Flat)
+	assert.Equal(t, NewMoneyFromMajor(10000), schedule.Rules[0].Max)
+
+	invalid := []string{
+		`not json`,
+		`{"rules":[{"transaction_type":"TOPUP","flat":1}]}`,
+		`{"rules":[{"transaction_type":"TRANSFER","kyc_tier":"GOLD","flat":1}]}`,
+		`{"rules":[{"transaction_type":"TRANSFER","currency":"XYZ","flat":1}]}`,
+		`{"rules":[{"transaction_type":"TRANSFER","currency":"JPY","flat":1.5}]}`,
+		`{"rules":[{"transaction_type":"TRANSFER","flat":1},{"transaction_type":"TRANSFER","flat":2}]}`,
+		`{"rules":[{"transaction_type":"TRANSFER","basis_points":10001}]}`,
+		`{"rules":[{"transaction_type":"TRANSFER","min":10,"max":5}]}`,
+		`{"rules":[{"transaction_type":"TRANSFER","flat":1,"tiers":[{"flat":1}]}]}`,
+		`{"rules":[{"transaction_type":"TRANSFER","tiers":[{"flat":1},{"up_to":100}]}]}`,
+		`{"rules":[{"transaction_type":"TRANSFER","tiers":[{"up_to":100},{"up_to":50}]}]}`,
+	}
+	for _, data := range invalid {
+		_, err := ParseFeeSchedule([]byte(data))
+		assert.True(t, errors.Is(err, ErrInvalidFeeSchedule), data)
+	}
+}
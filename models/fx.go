@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CONVERSION is the transaction type of a currency conversion between two of
+// a user's wallets. It is recorded as a DEBIT on the source wallet and a
+// CREDIT on the target wallet.
+const CONVERSION = "CONVERSION"
+
+// FX quote statuses. A quote is stored ACTIVE until a conversion or converted
+// transfer uses it; EXPIRED is never stored, it only means the locked rate ran
+// out before anyone used it.
+const (
+	FXQuoteActive  = "ACTIVE"
+	FXQuoteUsed    = "USED"
+	FXQuoteExpired = "EXPIRED"
+)
+
+var (
+	ErrSameCurrency       = errors.New("cannot convert a currency to itself")
+	ErrConversionTooSmall = errors.New("amount is too small to convert")
+	ErrInvalidSpread      = errors.New("spread must be between 0 and 9999 basis points")
+	ErrFXQuoteNotActive   = errors.New("quote has already been used")
+	ErrFXQuoteExpired     = errors.New("quote has expired")
+)
+
+// FXQuote locks an exchange rate for converting FromAmount of one currency
+// into ToAmount of another until ExpiresAt. Rate is the mid-market rate less
+// the spread, and is the rate the user gets.
+type FXQuote struct {
+	ID                uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID            uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	FromCurrency      string     `json:"from_currency" gorm:"size:3;not null"`
+	ToCurrency        string     `json:"to_currency" gorm:"size:3;not null"`
+	FromAmount        Money      `json:"from_amount" gorm:"not null"`
+	ToAmount          Money      `json:"to_amount" gorm:"not null"`
+	MidRate           Rate       `json:"mid_rate" gorm:"not null"`
+	Rate              Rate       `json:"rate" gorm:"not null"`
+	SpreadBasisPoints int64      `json:"spread_basis_points" gorm:"not null"`
+	Status            string     `json:"status" gorm:"size:20;not null;default:ACTIVE"`
+	TransactionID     *uuid.UUID `json:"transaction_id,omitempty" gorm:"type:char(36)"`
+	ExpiresAt         time.Time  `json:"expires_at" gorm:"not null"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// NewFXQuote prices converting amount from one currency into another at the
+// mid rate less spreadBasisPoints. The converted amount is rounded to the
+// target currency.
+func NewFXQuote(userID uuid.UUID, from, to string, amount Money, midRate Rate, spreadBasisPoints int64, ttl time.Duration) (*FXQuote, error) {
+	if from == to {
+		return nil, ErrSameCurrency
+	}
+	if midRate <= 0 {
+		return nil, ErrInvalidRate
+	}
+	if spreadBasisPoints < 0 || spreadBasisPoints >= basisPointsPerUnit {
+		return nil, ErrInvalidSpread
+	}
+
+	rate := midRate.LessBasisPoints(spreadBasisPoints)
+	toAmount := Currencies[to].Round(rate.Convert(amount))
+	if toAmount <= 0 {
+		return nil, ErrConversionTooSmall
+	}
+	if !toAmount.IsValid() {
+		return nil, ErrAmountOutOfRange
+	}
+
+	return &FXQuote{
+		UserID:            userID,
+		FromCurrency:      from,
+		ToCurrency:        to,
+		FromAmount:        amount,
+		ToAmount:          toAmount,
+		MidRate:           midRate,
+		Rate:              rate,
+		SpreadBasisPoints: spreadBasisPoints,
+		Status:            FXQuoteActive,
+		ExpiresAt:         time.Now().Add(ttl),
+	}, nil
+}
+
+// IsExpired reports whether the quote can no longer be used at the given
+// time.
+func (q *FXQuote) IsExpired(now time.Time) bool {
+	return !now.Before(q.ExpiresAt)
+}
+
+// CurrentStatus returns the status, reporting an unused quote past its
+// expiry as EXPIRED.
+func (q *FXQuote) CurrentStatus(now time.Time) string {
+	if q.Status == FXQuoteActive && q.IsExpired(now) {
+		return FXQuoteExpired
+	}
+	return q.Status
+}
+
+func (q *FXQuote) BeforeCreate(tx *gorm.DB) error {
+	if q.ID == uuid.Nil {
+		q.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Hold statuses
+const (
+	HoldActive   = "ACTIVE"
+	HoldCaptured = "CAPTURED"
+	HoldVoided   = "VOIDED"
+	HoldExpired  = "EXPIRED"
+)
+
+// Hold reserves part of a user's balance for a later payment. An active hold
+// reduces the available balance but leaves the ledger balance untouched until
+// it is captured.
+type Hold struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID         uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index:idx_holds_user_status"`
+	Currency       string     `json:"currency" gorm:"size:3;not null;default:IDR"`
+	Amount         Money      `json:"amount" gorm:"not null"`
+	CapturedAmount Money      `json:"captured_amount" gorm:"not null;default:0"`
+	Status         string     `json:"status" gorm:"size:20;not null;index:idx_holds_user_status;index:idx_holds_status_expires"`
+	Description    string     `json:"description"`
+	TransactionID  *uuid.UUID `json:"transaction_id,omitempty" gorm:"type:char(36)"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null;index:idx_holds_status_expires"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	User           User       `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// IsExpired reports whether an active hold has outlived its expiry.
+func (h *Hold) IsExpired(now time.Time) bool {
+	return !now.Before(h.ExpiresAt)
+}
+
+func (h *Hold) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey records the outcome of a money-moving request so that a
+// retried request with the same Idempotency-Key header replays the original
+// response instead of moving money twice.
+type IdempotencyKey struct {
+	ID           uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	UserID       uuid.UUID `json:"user_id" gorm:"type:char(36);not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `json:"key" gorm:"column:idempotency_key;size:255;not null;uniqueIndex:idx_idempotency_user_key"`
+	Endpoint     string    `json:"endpoint" gorm:"size:255;not null"`
+	RequestHash  string    `json:"request_hash" gorm:"size:64;not null"`
+	ResponseCode int       `json:"response_code"`
+	ResponseBody string    `json:"response_body" gorm:"type:text"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (k *IdempotencyKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
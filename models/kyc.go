@@ -0,0 +1,152 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// KYC tiers, from least to most verified
+const (
+	KYCTierUnverified = "UNVERIFIED"
+	KYCTierBasic      = "BASIC"
+	KYCTierFull       = "FULL"
+)
+
+// Limit kinds reported by LimitExceededError
+const (
+	LimitPerTransaction      = "PER_TRANSACTION"
+	LimitDaily               = "DAILY"
+	LimitMonthly             = "MONTHLY"
+	LimitMaxBalance          = "MAX_BALANCE"
+	LimitRecipientMaxBalance = "RECIPIENT_MAX_BALANCE"
+)
+
+var ErrLimitExceeded = errors.New("transaction limit exceeded")
+
+// LimitExceededError tells which limit a transaction would break and how
+// much headroom was left.
+type LimitExceededError struct {
+	Limit           string
+	TransactionType string
+	Remaining       Money
+}
+
+func (e *LimitExceededError) Error() string {
+	if e.TransactionType == "" {
+		return fmt.Sprintf("%s limit exceeded, %s remaining", e.Limit, e.Remaining)
+	}
+	return fmt.Sprintf("%s %s limit exceeded, %s remaining", e.TransactionType, e.Limit, e.Remaining)
+}
+
+// Is makes errors.Is(err, ErrLimitExceeded) match.
+func (e *LimitExceededError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// TransactionLimit caps one transaction type. A zero limit blocks the type.
+type TransactionLimit struct {
+	PerTransaction Money
+	Daily          Money
+	Monthly        Money
+}
+
+// TierLimits are the limits of one KYC tier.
+type TierLimits struct {
+	MaxBalance   Money
+	Transactions map[string]TransactionLimit
+}
+
+// KYCTierLimits holds the limits of every tier. Unverified users can hold and
+// spend small amounts but not send transfers.
+var KYCTierLimits = map[string]TierLimits{
+	KYCTierUnverified: {
+		MaxBalance: NewMoneyFromMajor(2000000),
+		Transactions: map[string]TransactionLimit{
+			TOPUP:    {PerTransaction: NewMoneyFromMajor(1000000), Daily: NewMoneyFromMajor(2000000), Monthly: NewMoneyFromMajor(20000000)},
+			PAYMENT:  {PerTransaction: NewMoneyFromMajor(1000000), Daily: NewMoneyFromMajor(2000000), Monthly: NewMoneyFromMajor(20000000)},
+			TRANSFER: {},
+		},
+	},
+	KYCTierBasic: {
+		MaxBalance: NewMoneyFromMajor(10000000),
+		Transactions: map[string]TransactionLimit{
+			TOPUP:    {PerTransaction: NewMoneyFromMajor(5000000), Daily: NewMoneyFromMajor(10000000), Monthly: NewMoneyFromMajor(40000000)},
+			PAYMENT:  {PerTransaction: NewMoneyFromMajor(5000000), Daily: NewMoneyFromMajor(10000000), Monthly: NewMoneyFromMajor(40000000)},
+			TRANSFER: {PerTransaction: NewMoneyFromMajor(2500000), Daily: NewMoneyFromMajor(5000000), Monthly: NewMoneyFromMajor(20000000)},
+		},
+	},
+	KYCTierFull: {
+		MaxBalance: NewMoneyFromMajor(20000000),
+		Transactions: map[string]TransactionLimit{
+			TOPUP:    {PerTransaction: NewMoneyFromMajor(10000000), Daily: NewMoneyFromMajor(20000000), Monthly: NewMoneyFromMajor(100000000)},
+			PAYMENT:  {PerTransaction: NewMoneyFromMajor(10000000), Daily: NewMoneyFromMajor(20000000), Monthly: NewMoneyFromMajor(100000000)},
+			TRANSFER: {PerTransaction: NewMoneyFromMajor(10000000), Daily: NewMoneyFromMajor(20000000), Monthly: NewMoneyFromMajor(100000000)},
+		},
+	},
+}
+
+// LimitRates value one unit of each supported currency in the default
+// currency for tier limits, so amounts in every currency count towards the
+// same limits. They are fixed and set above market rates rather than taken
+// from the FX rate provider, so a user's limits don't move with the market.
+var LimitRates = map[string]Rate{
+	"IDR": OneRate,
+	"USD": MustParseRate("17000"),
+	"EUR": MustParseRate("19000"),
+	"SGD": MustParseRate("13000"),
+	"MYR": MustParseRate("4000"),
+	"JPY": MustParseRate("115"),
+}
+
+// LimitValue converts an amount to the default currency at its limit rate.
+// Values too large for Money are capped at MaxMoney, far above any limit.
+func LimitValue(currency string, amount Money) (Money, error) {
+	rate, ok := LimitRates[currency]
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+	if amount > MaxMoney.MulRatio(rateUnitsPerOne, int64(rate)) {
+		return MaxMoney, nil
+	}
+	return rate.Convert(amount), nil
+}
+
+// LimitedTransactionTypes are the transaction types subject to tier limits.
+var LimitedTransactionTypes = []string{TOPUP, PAYMENT, TRANSFER}
+
+// LimitsForTier returns the limits of a tier, treating unknown tiers as
+// unverified.
+func LimitsForTier(tier string) TierLimits {
+	if limits, ok := KYCTierLimits[tier]; ok {
+		return limits
+	}
+	return KYCTierLimits[KYCTierUnverified]
+}
+
+// LimitUsage is how much of a periodic limit has been used.
+type LimitUsage struct {
+	Limit     Money `json:"limit"`
+	Used      Money `json:"used"`
+	Remaining Money `json:"remaining"`
+}
+
+// TransactionLimitSummary shows a transaction type's limits and usage.
+// MaxAmount is the largest single transaction allowed right now.
+type TransactionLimitSummary struct {
+	PerTransaction Money      `json:"per_transaction"`
+	Daily          LimitUsage `json:"daily"`
+	Monthly        LimitUsage `json:"monthly"`
+	MaxAmount      Money      `json:"max_amount"`
+}
+
+// LimitSummary shows a user's tier limits and how much is left of each.
+// Amounts are in the default currency; Balance values every wallet at its
+// limit rate.
+type LimitSummary struct {
+	Tier            string                             `json:"kyc_tier"`
+	Currency        string                             `json:"currency"`
+	MaxBalance      Money                              `json:"max_balance"`
+	Balance         Money                              `json:"balance"`
+	BalanceHeadroom Money                              `json:"balance_headroom"`
+	Transactions    map[string]TransactionLimitSummary `json:"transactions"`
+}
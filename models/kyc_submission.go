@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// KYC review statuses, used both for submissions and for the user's current
+// verification state (where NONE means nothing was ever submitted)
+const (
+	KYCStatusNone     = "NONE"
+	KYCStatusPending  = "PENDING"
+	KYCStatusApproved = "APPROVED"
+	KYCStatusRejected = "REJECTED"
+)
+
+// Identity document types
+const (
+	DocumentTypeNationalID     = "NATIONAL_ID"
+	DocumentTypePassport       = "PASSPORT"
+	DocumentTypeDrivingLicense = "DRIVING_LICENSE"
+)
+
+var (
+	ErrKYCAlreadyPending = errors.New("a KYC submission is already under review")
+	ErrKYCNotPending     = errors.New("KYC submission has already been reviewed")
+	ErrKYCTierNotHigher  = errors.New("requested KYC tier must be higher than the current tier")
+	ErrKYCSelfReview     = errors.New("cannot review your own KYC submission")
+)
+
+// kycTierRank orders the tiers so upgrades can be validated
+var kycTierRank = map[string]int{
+	KYCTierUnverified: 0,
+	KYCTierBasic:      1,
+	KYCTierFull:       2,
+}
+
+// IsHigherKYCTier reports whether tier ranks above current.
+func IsHigherKYCTier(tier, current string) bool {
+	rank, ok := kycTierRank[tier]
+	return ok && rank > kycTierRank[current]
+}
+
+// KYCSubmission is one request to upgrade a user's KYC tier, with the
+// identity document details and the keys of the uploaded images.
+type KYCSubmission struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID           uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	RequestedTier    string     `json:"requested_tier" gorm:"size:20;not null"`
+	DocumentType     string     `json:"document_type" gorm:"size:30;not null"`
+	DocumentNumber   string     `json:"document_number" gorm:"size:50;not null"`
+	FullName         string     `json:"full_name" gorm:"not null"`
+	DateOfBirth      time.Time  `json:"date_of_birth" gorm:"type:date;not null"`
+	DocumentImageKey string     `json:"-" gorm:"size:255;not null"`
+	SelfieImageKey   string     `json:"-" gorm:"size:255;not null"`
+	Status           string     `json:"status" gorm:"size:20;not null;index"`
+	RejectionReason  string     `json:"rejection_reason,omitempty"`
+	ReviewedBy       *uuid.UUID `json:"reviewed_by,omitempty" gorm:"type:char(36)"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func (s *KYCSubmission) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
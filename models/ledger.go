@@ -0,0 +1,138 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Ledger account types
+const (
+	AccountTypeUser   = "USER"
+	AccountTypeSystem = "SYSTEM"
+)
+
+// System account codes. Every journal that moves money into or out of a
+// wallet balances against one of these accounts, kept once per currency.
+const (
+	SystemAccountTopUpFunding      = "SYSTEM:TOPUP_FUNDING"
+	SystemAccountPaymentSettlement = "SYSTEM:PAYMENT_SETTLEMENT"
+	SystemAccountFeeRevenue        = "SYSTEM:FEE_REVENUE"
+	SystemAccountOpeningBalance    = "SYSTEM:OPENING_BALANCE"
+	SystemAccountFXPosition        = "SYSTEM:FX_POSITION"
+)
+
+// Journal entry kinds
+const (
+	JournalTopUp          = "TOPUP"
+	JournalPayment        = "PAYMENT"
+	JournalTransfer       = "TRANSFER"
+	JournalRefund         = "REFUND"
+	JournalReversal       = "REVERSAL"
+	JournalFee            = "FEE"
+	JournalConversion     = "CONVERSION"
+	JournalOpeningBalance = "OPENING_BALANCE"
+)
+
+var (
+	ErrUnbalancedJournal = errors.New("journal postings do not sum to zero")
+	ErrInvalidPosting    = errors.New("journal must have at least two non-zero postings")
+	ErrBalanceMismatch   = errors.New("cached balance does not match ledger")
+)
+
+// LedgerAccount is a single account in the double-entry ledger, holding one
+// currency. Each user wallet has an account; system accounts represent money
+// entering or leaving the platform.
+type LedgerAccount struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	Code      string     `json:"code" gorm:"size:100;unique;not null"`
+	Type      string     `json:"type" gorm:"size:20;not null"`
+	Currency  string     `json:"currency" gorm:"size:3;not null;default:IDR"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" gorm:"type:char(36);index"`
+	Name      string     `json:"name" gorm:"not null"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// JournalEntry groups the postings of one business event. The postings of an
+// entry always sum to zero.
+type JournalEntry struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	Kind          string     `json:"kind" gorm:"size:30;not null"`
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty" gorm:"type:char(36);index"`
+	Description   string     `json:"description"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Postings      []Posting  `json:"postings" gorm:"foreignKey:JournalEntryID"`
+}
+
+// Posting moves Amount into an account: a positive amount increases the
+// account balance and a negative amount decreases it.
+type Posting struct {
+	ID             uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	JournalEntryID uuid.UUID `json:"journal_entry_id" gorm:"type:char(36);not null;index"`
+	AccountID      uuid.UUID `json:"account_id" gorm:"type:char(36);not null;index"`
+	Amount         Money     `json:"amount" gorm:"not null"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// UserAccountCode returns the ledger account code of a user's wallet in the
+// given currency.
+func UserAccountCode(userID uuid.UUID, currency string) string {
+	return "USER:" + userID.String() + ":" + currency
+}
+
+// SystemAccountCode returns the code of a system account in the given
+// currency, e.g. SYSTEM:FEE_REVENUE:IDR.
+func SystemAccountCode(code, currency string) string {
+	return code + ":" + currency
+}
+
+// Validate checks the double-entry invariant.
+func (e *JournalEntry) Validate() error {
+	if len(e.Postings) < 2 {
+		return ErrInvalidPosting
+	}
+	var sum Money
+	for _, p := range e.Postings {
+		if p.Amount == 0 {
+			return ErrInvalidPosting
+		}
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return ErrUnbalancedJournal
+	}
+	return nil
+}
+
+func (a *LedgerAccount) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+func (e *JournalEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+func (p *Posting) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// BalanceMismatch reports a wallet whose cached balance differs from the sum
+// of the postings on its ledger account.
+type BalanceMismatch struct {
+	UserID        uuid.UUID `json:"user_id"`
+	Currency      string    `json:"currency"`
+	CachedBalance Money     `json:"cached_balance"`
+	LedgerBalance Money     `json:"ledger_balance"`
+}
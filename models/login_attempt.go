@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Login attempt scopes
+const (
+	LoginScopePhone = "PHONE"
+	LoginScopeIP    = "IP"
+)
+
+// LoginAttempt counts recent failed logins for one phone number or client IP.
+// After too many failures the key is locked, and every further lockout lasts
+// twice as long as the previous one.
+type LoginAttempt struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	Scope        string     `json:"scope" gorm:"size:10;not null;uniqueIndex:idx_login_attempts_scope_key"`
+	Key          string     `json:"key" gorm:"column:attempt_key;size:255;not null;uniqueIndex:idx_login_attempts_scope_key"`
+	FailedCount  int        `json:"failed_count" gorm:"not null;default:0"`
+	LockoutCount int        `json:"lockout_count" gorm:"not null;default:0"`
+	LastFailedAt *time.Time `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// IsLocked reports whether the key is locked out at now.
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+func (a *LoginAttempt) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// LockoutPolicy configures how failed logins turn into lockouts.
+type LockoutPolicy struct {
+	MaxAttempts int
+	// BaseDuration is the first lockout; each further one doubles it
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	// ResetAfter forgets failures and backoff after this long without one
+	ResetAfter time.Duration
+}
+
+// LockoutDuration returns how long the level-th consecutive lockout lasts.
+func (p LockoutPolicy) LockoutDuration(level int) time.Duration {
+	d := p.BaseDuration
+	for i := 1; i < level && d < p.MaxDuration; i++ {
+		d *= 2
+	}
+	if d > p.MaxDuration {
+		d = p.MaxDuration
+	}
+	return d
+}
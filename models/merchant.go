@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Merchant statuses. Suspended merchants cannot accept payments.
+const (
+	MerchantActive    = "ACTIVE"
+	MerchantSuspended = "SUSPENDED"
+)
+
+var (
+	ErrMerchantNotActive   = errors.New("merchant is not accepting payments")
+	ErrMerchantRequiresKYC = errors.New("merchant accounts require full KYC verification")
+	ErrInvalidCategoryCode = errors.New("merchant category code must be four digits")
+	ErrPayOwnMerchant      = errors.New("cannot pay your own merchant")
+)
+
+// Merchant is a business profile owned by a user. Payments to the merchant
+// settle into the owner's wallet in SettlementCurrency, and are recorded with
+// the merchant's ID on both the payer's and the owner's rows. CategoryCode is
+// the ISO 18245 merchant category code (MCC).
+type Merchant struct {
+	ID                 uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	OwnerID            uuid.UUID `json:"owner_id" gorm:"type:char(36);not null;index"`
+	Name               string    `json:"name" gorm:"size:100;not null"`
+	CategoryCode       string    `json:"category_code" gorm:"size:4;not null"`
+	Email              string    `json:"email" gorm:"size:255"`
+	PhoneNumber        string    `json:"phone_number" gorm:"size:20"`
+	Address            string    `json:"address"`
+	City               string    `json:"city" gorm:"size:100"`
+	SettlementCurrency string    `json:"settlement_currency" gorm:"size:3;not null;default:IDR"`
+	Status             string    `json:"status" gorm:"size:20;not null;default:ACTIVE"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// ValidateCategoryCode checks that code is a four digit merchant category
+// code.
+func ValidateCategoryCode(code string) error {
+	if len(code) != 4 {
+		return ErrInvalidCategoryCode
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return ErrInvalidCategoryCode
+		}
+	}
+	return nil
+}
+
+func (m *Merchant) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// MerchantDailyTotal sums the payments a merchant received on one day. Refunds
+// count against the day of the payment they refund.
+type MerchantDailyTotal struct {
+	Date     string `json:"date"`
+	Count    int    `json:"count"`
+	Gross    Money  `json:"gross_amount"`
+	Refunded Money  `json:"refunded_amount"`
+	Net      Money  `json:"net_amount"`
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Merchant API key scopes
+const (
+	ScopePaymentsRead  = "payments:read"
+	ScopeRefundsWrite  = "refunds:write"
+	ScopeQRWrite       = "qr:write"
+	ScopeCheckoutWrite = "checkout:write"
+)
+
+// APIKeyScopes lists every scope a merchant API key can be granted.
+var APIKeyScopes = []string{ScopePaymentsRead, ScopeRefundsWrite, ScopeQRWrite, ScopeCheckoutWrite}
+
+// Merchant API key statuses. Rotation leaves the old key ACTIVE with an
+// ExpiresAt for its grace period; after that signatures made with it are
+// refused and it is listed as EXPIRED.
+const (
+	APIKeyActive  = "ACTIVE"
+	APIKeyRevoked = "REVOKED"
+	APIKeyExpired = "EXPIRED"
+)
+
+// apiKeyIDPrefix marks public merchant API key identifiers
+const apiKeyIDPrefix = "mk_"
+
+var (
+	ErrInvalidScope     = errors.New("unknown API key scope")
+	ErrAPIKeyNotActive  = errors.New("API key has been revoked or has expired")
+	ErrMerchantKeyLimit = errors.New("merchant has too many active API keys")
+)
+
+// MerchantAPIKey lets a merchant's own systems call the merchant API. KeyID is
+// the public identifier sent with each request. The random signing secret is
+// only shown when the key is issued and is stored sealed with the server's
+// master secret, see auth.SealAPIKeySecret. Rotating a key issues a new one
+// and lets the old one work until ExpiresAt.
+type MerchantAPIKey struct {
+	ID         uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	MerchantID uuid.UUID `json:"merchant_id" gorm:"type:char(36);not null;index"`
+	KeyID      string    `json:"key_id" gorm:"size:40;not null;uniqueIndex"`
+	Name       string    `json:"name" gorm:"size:100"`
+	// SecretCiphertext is the sealed signing secret
+	SecretCiphertext string `json:"-" gorm:"size:255"`
+	// Scopes is a comma separated, sorted list of granted scopes
+	Scopes      string     `json:"scopes" gorm:"size:255;not null"`
+	Status      string     `json:"status" gorm:"size:20;not null;default:ACTIVE"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RotatedFrom *uuid.UUID `json:"rotated_from,omitempty" gorm:"type:char(36)"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// NormalizeScopes checks that every scope is known and returns them sorted
+// without duplicates.
+func NormalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range APIKeyScopes {
+			if s == scope {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, ErrInvalidScope
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// ScopeList returns the granted scopes.
+func (k *MerchantAPIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *MerchantAPIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUsable reports whether the key may sign requests at now.
+func (k *MerchantAPIKey) IsUsable(now time.Time) bool {
+	if k.Status != APIKeyActive {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// CurrentStatus returns the status, reporting an active key past its expiry
+// as EXPIRED.
+func (k *MerchantAPIKey) CurrentStatus(now time.Time) string {
+	if k.Status == APIKeyActive && !k.IsUsable(now) {
+		return APIKeyExpired
+	}
+	return k.Status
+}
+
+// NewAPIKeyID generates the public identifier of a merchant API key.
+func NewAPIKeyID() (string, error) {
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return apiKeyIDPrefix + hex.EncodeToString(suffix), nil
+}
+
+func (k *MerchantAPIKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
+
+// MerchantAPIKeyUsage records one request signed with a merchant API key,
+// including requests rejected after the key was identified.
+type MerchantAPIKeyUsage struct {
+	ID         uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	APIKeyID   uuid.UUID `json:"api_key_id" gorm:"type:char(36);not null;index:idx_api_key_usage_key_created,priority:1"`
+	Method     string    `json:"method" gorm:"size:10;not null"`
+	Path       string    `json:"path" gorm:"size:255;not null"`
+	StatusCode int       `json:"status_code" gorm:"not null"`
+	IPAddress  string    `json:"ip_address" gorm:"size:45"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index:idx_api_key_usage_key_created,priority:2"`
+}
+
+func (u *MerchantAPIKeyUsage) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	return nil
+}
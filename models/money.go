@@ -0,0 +1,270 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact monetary amount stored as integer minor units (cents).
+// It maps to the DECIMAL(15,2) columns in the database and serializes to JSON
+// as a decimal number with two fractional digits.
+type Money int64
+
+const (
+	// MoneyScale is the number of fractional digits kept for every amount.
+	MoneyScale = 2
+
+	minorUnitsPerMajor = 100
+
+	// MaxMoney is the largest amount a DECIMAL(15,2) column can hold.
+	MaxMoney Money = 999999999999999
+)
+
+var (
+	ErrInvalidAmount          = errors.New("invalid amount")
+	ErrAmountPrecisionTooHigh = errors.New("amount has more than 2 decimal places")
+	ErrAmountOutOfRange       = errors.New("amount is out of range")
+)
+
+// ParseMoney parses a decimal string such as "10", "10.5" or "-0.25" into
+// Money. Inputs with more than two fractional digits are rejected rather than
+// rounded so that a client never gets charged an amount it did not send.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, ErrInvalidAmount
+	}
+	if hasDot && fracPart == "" {
+		return 0, ErrInvalidAmount
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, ErrInvalidAmount
+	}
+
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > MoneyScale {
+		return 0, ErrAmountPrecisionTooHigh
+	}
+	fracPart += strings.Repeat("0", MoneyScale-len(fracPart))
+
+	intPart = strings.TrimLeft(intPart, "0")
+	if len(intPart) > 13 {
+		return 0, ErrAmountOutOfRange
+	}
+
+	var major, minor int64
+	var err error
+	if intPart != "" {
+		if major, err = strconv.ParseInt(intPart, 10, 64); err != nil {
+			return 0, ErrInvalidAmount
+		}
+	}
+	if minor, err = strconv.ParseInt(fracPart, 10, 64); err != nil {
+		return 0, ErrInvalidAmount
+	}
+
+	m := Money(major*minorUnitsPerMajor + minor)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// MustParseMoney is like ParseMoney but panics on error. It is intended for
+// constants and tests.
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewMoneyFromMajor converts a whole number of major units into Money.
+func NewMoneyFromMajor(major int64) Money {
+	return Money(major * minorUnitsPerMajor)
+}
+
+// NewMoneyFromFloat converts a float into Money, rounding half away from zero
+// to the nearest minor unit. It exists only for reading legacy float data and
+// must not be used for arithmetic.
+func NewMoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * minorUnitsPerMajor))
+}
+
+// MinorUnits returns the amount as an integer number of cents.
+func (m Money) MinorUnits() int64 {
+	return int64(m)
+}
+
+// IsPositive reports whether the amount is greater than zero.
+func (m Money) IsPositive() bool {
+	return m > 0
+}
+
+// IsValid reports whether the amount fits in a DECIMAL(15,2) column.
+func (m Money) IsValid() bool {
+	return m >= -MaxMoney && m <= MaxMoney
+}
+
+// MulRatio multiplies the amount by num/den and rounds the result half away
+// from zero to the nearest minor unit. It is the single rounding rule used for
+// every derived amount (percentages, conversions, splits).
+func (m Money) MulRatio(num, den int64) Money {
+	return Money(mulRatio(int64(m), num, den))
+}
+
+// mulRatio returns v*num/den rounded half away from zero, computed without
+// intermediate overflow.
+func mulRatio(v, num, den int64) int64 {
+	if den == 0 {
+		panic("models: MulRatio with zero denominator")
+	}
+	product := new(big.Int).Mul(big.NewInt(v), big.NewInt(num))
+	divisor := big.NewInt(den)
+
+	negative := product.Sign()*divisor.Sign() < 0
+	product.Abs(product)
+	divisor.Abs(divisor)
+
+	quotient, remainder := new(big.Int).QuoRem(product, divisor, new(big.Int))
+	if remainder.Mul(remainder, big.NewInt(2)).Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if negative {
+		quotient.Neg(quotient)
+	}
+	return quotient.Int64()
+}
+
+// String formats the amount as a plain decimal with two fractional digits.
+func (m Money) String() string {
+	v := int64(m)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/minorUnitsPerMajor, v%minorUnitsPerMajor)
+}
+
+// MarshalJSON encodes the amount as a JSON number, e.g. 1500.50.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts either a JSON number or a quoted decimal string. The
+// raw text is parsed directly so no precision is lost through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+	if bytes.ContainsAny(data, "eE") {
+		return ErrInvalidAmount
+	}
+
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// UnmarshalParam lets gin bind amounts from query strings and forms.
+func (m *Money) UnmarshalParam(param string) error {
+	if param == "" {
+		return nil
+	}
+	parsed, err := ParseMoney(param)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount as an exact decimal string.
+func (m Money) Value() (driver.Value, error) {
+	if !m.IsValid() {
+		return nil, ErrAmountOutOfRange
+	}
+	return m.String(), nil
+}
+
+// Scan reads a DECIMAL column. MySQL returns the exact text representation;
+// SQLite may hand back integers or floats for numeric columns.
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = NewMoneyFromMajor(v)
+		return nil
+	case float64:
+		*m = NewMoneyFromFloat(v)
+		return nil
+	default:
+		return fmt.Errorf("models: cannot scan %T into Money", value)
+	}
+}
+
+func (m *Money) scanString(s string) error {
+	parsed, err := ParseMoney(s)
+	if err == ErrAmountPrecisionTooHigh {
+		// Legacy rows written from float columns may carry extra digits.
+		f, ferr := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if ferr != nil {
+			return err
+		}
+		parsed, err = NewMoneyFromFloat(f), nil
+	}
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// GormDataType makes AutoMigrate create a DECIMAL(15,2) column.
+func (Money) GormDataType() string {
+	return "decimal(15,2)"
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Money
+		err  error
+	}{
+		{"0", 0, nil},
+		{"10", 1000, nil},
+		{"10.5", 1050, nil},
+		{"10.50", 1050, nil},
+		{"0.01", 1, nil},
+		{".25", 25, nil},
+		{"-3.10", -310, nil},
+		{"1.230", 123, nil},
+		{"9999999999999.99", MaxMoney, nil},
+		{"1.005", 0, ErrAmountPrecisionTooHigh},
+		{"10000000000000", 0, ErrAmountOutOfRange},
+		{"", 0, ErrInvalidAmount},
+		{"1.", 0, ErrInvalidAmount},
+		{"abc", 0, ErrInvalidAmount},
+		{"1e3", 0, ErrInvalidAmount},
+	}
+
+	for _, c := range cases {
+		got, err := ParseMoney(c.in)
+		assert.Equal(t, c.err, err, c.in)
+		assert.Equal(t, c.want, got, c.in)
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "0.00", Money(0).String())
+	assert.Equal(t, "0.05", Money(5).String())
+	assert.Equal(t, "1500.50", Money(150050).String())
+	assert.Equal(t, "-0.10", Money(-10).String())
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	var req struct {
+		Amount Money `json:"amount"`
+	}
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": 0.1}`), &req))
+	assert.Equal(t, Money(10), req.Amount)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": "25000.75"}`), &req))
+	assert.Equal(t, Money(2500075), req.Amount)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 0.001}`), &req))
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 1e2}`), &req))
+
+	out, err := json.Marshal(struct {
+		Amount Money `json:"amount"`
+	}{Amount: 2500075})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount": 25000.75}`, string(out))
+}
+
+func TestMoneyScan(t *testing.T) {
+	var m Money
+
+	assert.NoError(t, m.Scan([]byte("123.45")))
+	assert.Equal(t, Money(12345), m)
+
+	assert.NoError(t, m.Scan(int64(7)))
+	assert.Equal(t, Money(700), m)
+
+	assert.NoError(t, m.Scan(0.3))
+	assert.Equal(t, Money(30), m)
+
+	// Legacy float data is rounded half away from zero
+	assert.NoError(t, m.Scan("0.30000000000000004"))
+	assert.Equal(t, Money(30), m)
+
+	v, err := Money(30).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "0.30", v)
+}
+
+func TestMoneyMulRatio(t *testing.T) {
+	assert.Equal(t, Money(2), Money(15).MulRatio(1, 10))    // 1.5 -> 2
+	assert.Equal(t, Money(1), Money(14).MulRatio(1, 10))    // 1.4 -> 1
+	assert.Equal(t, Money(-2), Money(-15).MulRatio(1, 10))  // -1.5 -> -2
+	assert.Equal(t, Money(333), Money(1000).MulRatio(1, 3)) // 3.333 -> 3.33
+	assert.Equal(t, Money(250), Money(10000).MulRatio(250, 10000))
+}
+
+func TestMoneyAdditionDoesNotDrift(t *testing.T) {
+	var total Money
+	tenCents := MustParseMoney("0.1")
+	for i := 0; i < 1000000; i++ {
+		total += tenCents
+	}
+	assert.Equal(t, MustParseMoney("100000"), total)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Payment request statuses. ACCEPTED and DECLINED record the payer's answer.
+// A request left unanswered past ExpiresAt keeps PENDING in the database and
+// reads as EXPIRED through CurrentStatus.
+const (
+	PaymentRequestPending  = "PENDING"
+	PaymentRequestAccepted = "ACCEPTED"
+	PaymentRequestDeclined = "DECLINED"
+	PaymentRequestExpired  = "EXPIRED"
+)
+
+var (
+	ErrPaymentRequestNotPending = errors.New("payment request is no longer pending")
+	ErrPaymentRequestExpired    = errors.New("payment request has expired")
+)
+
+// PaymentRequest asks PayerID to send Amount to RequesterID. Accepting it
+// executes a TRANSFER from the payer to the requester, recorded in
+// TransactionID. SplitBillID is set when the request is a share of a split
+// bill.
+type PaymentRequest struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	RequesterID   uuid.UUID  `json:"requester_id" gorm:"type:char(36);not null;index"`
+	PayerID       uuid.UUID  `json:"payer_id" gorm:"type:char(36);not null;index"`
+	Currency      string     `json:"currency" gorm:"size:3;not null;default:IDR"`
+	Amount        Money      `json:"amount" gorm:"not null"`
+	Description   string     `json:"description"`
+	Status        string     `json:"status" gorm:"size:20;not null;default:PENDING"`
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty" gorm:"type:char(36)"`
+	SplitBillID   *uuid.UUID `json:"split_bill_id,omitempty" gorm:"type:char(36);index"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// IsExpired reports whether the request can no longer be accepted at now.
+func (p *PaymentRequest) IsExpired(now time.Time) bool {
+	return !now.Before(p.ExpiresAt)
+}
+
+// CurrentStatus returns the status, reporting a pending request past its
+// expiry as EXPIRED.
+func (p *PaymentRequest) CurrentStatus(now time.Time) string {
+	if p.Status == PaymentRequestPending && p.IsExpired(now) {
+		return PaymentRequestExpired
+	}
+	return p.Status
+}
+
+func (p *PaymentRequest) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
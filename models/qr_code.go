@@ -0,0 +1,73 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// QR code statuses. Only PENDING and PAID are stored; EXPIRED is what
+// CurrentStatus shows for a code nobody paid before it lapsed.
+const (
+	QRCodePending = "PENDING"
+	QRCodePaid    = "PAID"
+	QRCodeExpired = "EXPIRED"
+)
+
+var (
+	ErrQRCodeNotPending = errors.New("QR code has already been paid")
+	ErrQRCodeExpired    = errors.New("QR code has expired")
+)
+
+// QRCode is a dynamic QR code a merchant issued for one payment of Amount.
+// Reference is carried in the QR payload and identifies the code when it is
+// scanned; paying it records the payer's PAYMENT in TransactionID. Static QR
+// codes are derived from the merchant alone and are not stored.
+type QRCode struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	MerchantID    uuid.UUID  `json:"merchant_id" gorm:"type:char(36);not null;index"`
+	Reference     string     `json:"reference" gorm:"size:25;not null;uniqueIndex"`
+	Currency      string     `json:"currency" gorm:"size:3;not null;default:IDR"`
+	Amount        Money      `json:"amount" gorm:"not null"`
+	Description   string     `json:"description"`
+	Status        string     `json:"status" gorm:"size:20;not null;default:PENDING"`
+	PayerID       *uuid.UUID `json:"payer_id,omitempty" gorm:"type:char(36)"`
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty" gorm:"type:char(36)"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// IsExpired reports whether the code can no longer be paid at now.
+func (q *QRCode) IsExpired(now time.Time) bool {
+	return !now.Before(q.ExpiresAt)
+}
+
+// CurrentStatus returns the status, reporting a pending code past its expiry
+// as EXPIRED.
+func (q *QRCode) CurrentStatus(now time.Time) string {
+	if q.Status == QRCodePending && q.IsExpired(now) {
+		return QRCodeExpired
+	}
+	return q.Status
+}
+
+func (q *QRCode) BeforeCreate(tx *gorm.DB) error {
+	if q.ID == uuid.Nil {
+		q.ID = uuid.New()
+	}
+	if q.Reference == "" {
+		suffix := make([]byte, 10)
+		if _, err := rand.Read(suffix); err != nil {
+			return err
+		}
+		q.Reference = "QR" + strings.ToUpper(hex.EncodeToString(suffix))
+	}
+	return nil
+}
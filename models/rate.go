@@ -0,0 +1,151 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Rate is an exchange rate stored as an integer number of 10^-8 units, so
+// 16250.5 is 1625050000000. It maps to DECIMAL(20,8) columns and serializes
+// to JSON as a decimal string.
+type Rate int64
+
+const (
+	// RateScale is the number of fractional digits kept for every rate.
+	RateScale = 8
+
+	rateUnitsPerOne = 100000000
+
+	// maxRateDigits is the number of integer digits a DECIMAL(20,8) holds.
+	maxRateDigits = 12
+)
+
+// OneRate converts an amount to itself.
+const OneRate Rate = rateUnitsPerOne
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// ParseRate parses a positive decimal such as "16250.5" or "0.0000615".
+// Rates with more than eight fractional digits are rejected.
+func ParseRate(s string) (Rate, error) {
+	intPart, fracPart, hasDot := strings.Cut(strings.TrimSpace(s), ".")
+	if (intPart == "" && fracPart == "") || (hasDot && fracPart == "") {
+		return 0, ErrInvalidRate
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, ErrInvalidRate
+	}
+
+	fracPart = strings.TrimRight(fracPart, "0")
+	intPart = strings.TrimLeft(intPart, "0")
+	if len(fracPart) > RateScale || len(intPart) > maxRateDigits {
+		return 0, ErrInvalidRate
+	}
+
+	units, err := strconv.ParseInt(intPart+fracPart+strings.Repeat("0", RateScale-len(fracPart)), 10, 64)
+	if err != nil || units <= 0 {
+		return 0, ErrInvalidRate
+	}
+	return Rate(units), nil
+}
+
+// MustParseRate is like ParseRate but panics on error. It is intended for
+// constants and tests.
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Convert converts an amount at this rate, rounding half away from zero to
+// the nearest cent. Callers round the result to the target currency.
+func (r Rate) Convert(m Money) Money {
+	return m.MulRatio(int64(r), rateUnitsPerOne)
+}
+
+// Invert returns the rate of the opposite direction.
+func (r Rate) Invert() Rate {
+	return Rate(mulRatio(rateUnitsPerOne, rateUnitsPerOne, int64(r)))
+}
+
+// Cross returns the rate from one currency to another given both of their
+// rates against a common base, i.e. r/base.
+func (r Rate) Cross(base Rate) Rate {
+	return Rate(mulRatio(int64(r), rateUnitsPerOne, int64(base)))
+}
+
+// LessBasisPoints returns the rate lowered by bps basis points, used to
+// apply a spread.
+func (r Rate) LessBasisPoints(bps int64) Rate {
+	return Rate(mulRatio(int64(r), basisPointsPerUnit-bps, basisPointsPerUnit))
+}
+
+// String formats the rate as a plain decimal without trailing zeros.
+func (r Rate) String() string {
+	s := fmt.Sprintf("%d.%08d", int64(r)/rateUnitsPerOne, int64(r)%rateUnitsPerOne)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// MarshalJSON encodes the rate as a JSON string, e.g. "16250.5", so clients
+// don't lose digits through floats.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + r.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts either a JSON number or a quoted decimal string.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(bytes.TrimSpace(data), `"`)
+	parsed, err := ParseRate(string(data))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Value stores the rate as an exact decimal string.
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// Scan reads a DECIMAL column. SQLite may hand back integers or floats.
+func (r *Rate) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = 0
+		return nil
+	case []byte:
+		return r.scanString(string(v))
+	case string:
+		return r.scanString(v)
+	case int64:
+		*r = Rate(v * rateUnitsPerOne)
+		return nil
+	case float64:
+		*r = Rate(math.Round(v * rateUnitsPerOne))
+		return nil
+	default:
+		return fmt.Errorf("models: cannot scan %T into Rate", value)
+	}
+}
+
+func (r *Rate) scanString(s string) error {
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// GormDataType makes AutoMigrate create a DECIMAL(20,8) column.
+func (Rate) GormDataType() string {
+	return "decimal(20,8)"
+}
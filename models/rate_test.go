@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := map[string]Rate{
+		"1":          OneRate,
+		"16250.5":    1625050000000,
+		"0.0000615":  6150,
+		"0.00000001": 1,
+		"007.10":     710000000,
+	}
+	for input, want := range cases {
+		got, err := ParseRate(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "0", "-1", "1.", ".", "1e3", "0.000000001", "1234567890123"} {
+		_, err := ParseRate(input)
+		assert.Equal(t, ErrInvalidRate, err, input)
+	}
+}
+
+func TestRateArithmetic(t *testing.T) {
+	usdIDR := MustParseRate("16250")
+	assert.Equal(t, MustParseMoney("1625000.00"), usdIDR.Convert(NewMoneyFromMajor(100)))
+	assert.Equal(t, MustParseRate("0.00006154"), usdIDR.Invert())
+	assert.Equal(t, MustParseRate("0.92"), MustParseRate("14950").Cross(usdIDR))
+	assert.Equal(t, MustParseRate("16168.75"), usdIDR.LessBasisPoints(50))
+	assert.Equal(t, "16250", usdIDR.String())
+	assert.Equal(t, "0.00006154", usdIDR.Invert().String())
+
+	data, err := json.Marshal(usdIDR)
+	assert.NoError(t, err)
+	assert.Equal(t, `"16250"`, string(data))
+	var decoded Rate
+	assert.NoError(t, json.Unmarshal([]byte(`16250.5`), &decoded))
+	assert.Equal(t, MustParseRate("16250.5"), decoded)
+}
+
+func TestNewFXQuote(t *testing.T) {
+	userID := uuid.New()
+
+	quote, err := NewFXQuote(userID, "USD", "IDR", MustParseMoney("10.55"), MustParseRate("16250"), 50, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, MustParseRate("16168.75"), quote.Rate)
+	assert.Equal(t, MustParseMoney("170580.31"), quote.ToAmount)
+	assert.Equal(t, FXQuoteActive, quote.CurrentStatus(time.Now()))
+	assert.Equal(t, FXQuoteExpired, quote.CurrentStatus(time.Now().Add(time.Minute)))
+
+	// Converted amounts are rounded to the target currency
+	quote, err = NewFXQuote(userID, "USD", "JPY", MustParseMoney("10.55"), MustParseRate("151.37"), 0, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoneyFromMajor(1597), quote.ToAmount)
+
+	_, err = NewFXQuote(userID, "IDR", "USD", MustParseMoney("0.01"), MustParseRate("0.00006154"), 50, time.Minute)
+	assert.Equal(t, ErrConversionTooSmall, err)
+	_, err = NewFXQuote(userID, "USD", "USD", NewMoneyFromMajor(1), OneRate, 0, time.Minute)
+	assert.Equal(t, ErrSameCurrency, err)
+	_, err = NewFXQuote(userID, "USD", "IDR", NewMoneyFromMajor(1), OneRate, 10000, time.Minute)
+	assert.Equal(t, ErrInvalidSpread, err)
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TokenFamily is one login session. Every refresh token issued by rotating
+// the session's first token belongs to the same family, and access tokens
+// carry the family ID so revoking the family logs the session out.
+type TokenFamily struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// IsRevoked reports whether the session has been logged out.
+func (f *TokenFamily) IsRevoked() bool {
+	return f.RevokedAt != nil
+}
+
+func (f *TokenFamily) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return nil
+}
+
+// RefreshToken is the server-side record of an issued refresh token. Its ID
+// is the token's jti. A token may be exchanged once; presenting it again is
+// treated as theft and revokes the whole family.
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	FamilyID  uuid.UUID  `json:"family_id" gorm:"type:char(36);not null;index"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
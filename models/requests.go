@@ -27,18 +27,29 @@ type UpdateProfileRequest struct {
 }
 
 type TransactionRequest struct {
-	Amount      float64 `json:"amount" binding:"required,gt=0"`
-	Description string  `json:"description" binding:"required"`
+	Amount      Money  `json:"amount" binding:"required,gt=0"`
+	Description string `json:"description" binding:"required"`
 }
 
 type TransferRequest struct {
 	RecipientID uuid.UUID `json:"recipient_id" binding:"required"`
-	Amount      float64   `json:"amount" binding:"required,gt=0"`
+	Amount      Money     `json:"amount" binding:"required,gt=0"`
 	Description string    `json:"description" binding:"required"`
 }
 
+// TransactionFilter narrows the transaction history. Dates are inclusive
+// calendar days (YYYY-MM-DD); zero values mean "no filter".
 type TransactionFilter struct {
-	StartDate time.Time `form:"start_date"`
-	EndDate   time.Time `form:"end_date"`
-	Type      string    `form:"type" binding:"omitempty,oneof=TOP_UP PAYMENT TRANSFER"`
+	StartDate       time.Time `form:"start_date" time_format:"2006-01-02"`
+	EndDate         time.Time `form:"end_date" time_format:"2006-01-02"`
+	Type            string    `form:"type" binding:"omitempty,oneof=DEBIT CREDIT"`
+	Currency        string    `form:"currency" binding:"omitempty,len=3"`
+	TransactionType string    `form:"transaction_type" binding:"omitempty,oneof=TOPUP PAYMENT TRANSFER REFUND REVERSAL FEE CONVERSION"`
+	Status          string    `form:"status" binding:"omitempty,oneof=SUCCESS PARTIALLY_REFUNDED REFUNDED REVERSED"`
+	MinAmount       Money     `form:"min_amount" binding:"omitempty,gt=0"`
+	MaxAmount       Money     `form:"max_amount" binding:"omitempty,gt=0"`
+	// Counterparty is the other user's ID or phone number
+	Counterparty string `form:"counterparty"`
+	// Search matches remarks containing the text
+	Search string `form:"q" binding:"omitempty,max=100"`
 }
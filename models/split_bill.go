@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Split bill methods
+const (
+	SplitEqual  = "EQUAL"
+	SplitShares = "SHARES"
+	SplitExact  = "EXACT"
+)
+
+// Split bill statuses. A bill is COLLECTED once every participant's share has
+// been paid, and PARTIALLY_COLLECTED once every share has been paid, declined
+// or has expired with some left unpaid. Both are final.
+const (
+	SplitBillOpen               = "OPEN"
+	SplitBillCollected          = "COLLECTED"
+	SplitBillPartiallyCollected = "PARTIALLY_COLLECTED"
+)
+
+var (
+	ErrInvalidSplit             = errors.New("split does not add up to the total")
+	ErrShareTooSmall            = errors.New("every participant must owe something")
+	ErrTransactionNotSplittable = errors.New("only your own payments can be split")
+	ErrTransactionAlreadySplit  = errors.New("payment has already been split")
+)
+
+// SplitBill divides TotalAmount between the owner and other users. Each other
+// participant is sent a PaymentRequest for their share, and CollectedAmount
+// grows as they accept. OwnerAmount is the owner's own share, which is never
+// requested. TransactionID is the PAYMENT the bill was created from, if any.
+type SplitBill struct {
+	ID              uuid.UUID        `json:"id" gorm:"type:char(36);primary_key"`
+	OwnerID         uuid.UUID        `json:"owner_id" gorm:"type:char(36);not null;index"`
+	TransactionID   *uuid.UUID       `json:"transaction_id,omitempty" gorm:"type:char(36);uniqueIndex"`
+	Currency        string           `json:"currency" gorm:"size:3;not null;default:IDR"`
+	TotalAmount     Money            `json:"total_amount" gorm:"not null"`
+	OwnerAmount     Money            `json:"owner_amount" gorm:"not null;default:0"`
+	CollectedAmount Money            `json:"collected_amount" gorm:"not null;default:0"`
+	Method          string           `json:"method" gorm:"size:20;not null"`
+	Description     string           `json:"description"`
+	Status          string           `json:"status" gorm:"size:20;not null;default:OPEN"`
+	Requests        []PaymentRequest `json:"requests,omitempty" gorm:"foreignKey:SplitBillID"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+// AmountToCollect returns the total of the other participants' shares.
+func (b *SplitBill) AmountToCollect() Money {
+	return b.TotalAmount - b.OwnerAmount
+}
+
+// CurrentStatus returns the status, reporting an open bill with no request
+// left pending as of now as PARTIALLY_COLLECTED, since expired requests are
+// not updated. Requests must be loaded.
+func (b *SplitBill) CurrentStatus(now time.Time) string {
+	if b.Status != SplitBillOpen || len(b.Requests) == 0 {
+		return b.Status
+	}
+	for i := range b.Requests {
+		if b.Requests[i].CurrentStatus(now) == PaymentRequestPending {
+			return SplitBillOpen
+		}
+	}
+	return SplitBillPartiallyCollected
+}
+
+// UncollectedRequests returns the shares that were declined or expired
+// unpaid as of now. Requests must be loaded.
+func (b *SplitBill) UncollectedRequests(now time.Time) []PaymentRequest {
+	uncollected := []PaymentRequest{}
+	for _, request := range b.Requests {
+		switch request.CurrentStatus(now) {
+		case PaymentRequestDeclined, PaymentRequestExpired:
+			uncollected = append(uncollected, request)
+		}
+	}
+	return uncollected
+}
+
+func (b *SplitBill) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
+
+// SplitAmount divides total in proportion to weights, in whole minor units of
+// the currency. Units left over after rounding down go one each to the
+// participants with the largest remainders, earlier participants first on a
+// tie, so the shares always add up to total.
+func SplitAmount(currency Currency, total Money, weights []int64) ([]Money, error) {
+	var weightSum int64
+	for _, weight := range weights {
+		if weight <= 0 {
+			return nil, ErrInvalidSplit
+		}
+		weightSum += weight
+	}
+	if len(weights) == 0 {
+		return nil, ErrInvalidSplit
+	}
+	if err := currency.ValidateAmount(total); err != nil {
+		return nil, err
+	}
+
+	unit := currency.minorUnit()
+	units := int64(total / unit)
+
+	shares := make([]Money, len(weights))
+	remainders := make([]int64, len(weights))
+	left := units
+	for i, weight := range weights {
+		shares[i] = Money(units * weight / weightSum)
+		remainders[i] = units * weight % weightSum
+		left -= int64(shares[i])
+	}
+	for ; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		shares[largest]++
+		remainders[largest] = -1
+	}
+
+	for i := range shares {
+		shares[i] *= unit
+		if shares[i] <= 0 {
+			return nil, ErrShareTooSmall
+		}
+	}
+	return shares, nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAmount(t *testing.T) {
+	idr := Currencies["IDR"]
+
+	shares, err := SplitAmount(idr, MustParseMoney("100.00"), []int64{1, 1, 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Money{MustParseMoney("33.34"), MustParseMoney("33.33"), MustParseMoney("33.33")}, shares)
+
+	// Leftover units go to the largest remainders
+	shares, err = SplitAmount(idr, MustParseMoney("1.00"), []int64{1, 2, 4})
+	assert.NoError(t, err)
+	assert.Equal(t, []Money{MustParseMoney("0.14"), MustParseMoney("0.29"), MustParseMoney("0.57")}, shares)
+
+	// Shares are whole minor units of the currency
+	shares, err = SplitAmount(Currencies["JPY"], MustParseMoney("1000"), []int64{1, 1, 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Money{MustParseMoney("334"), MustParseMoney("333"), MustParseMoney("333")}, shares)
+
+	_, err = SplitAmount(idr, MustParseMoney("0.02"), []int64{1, 1, 1})
+	assert.Equal(t, ErrShareTooSmall, err)
+	_, err = SplitAmount(idr, MustParseMoney("10"), []int64{1, 0})
+	assert.Equal(t, ErrInvalidSplit, err)
+	_, err = SplitAmount(idr, MustParseMoney("10"), nil)
+	assert.Equal(t, ErrInvalidSplit, err)
+}
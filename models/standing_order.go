@@ -0,0 +1,171 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Standing order schedules
+const (
+	ScheduleOnce    = "ONCE"
+	ScheduleDaily   = "DAILY"
+	ScheduleWeekly  = "WEEKLY"
+	ScheduleMonthly = "MONTHLY"
+	ScheduleCron    = "CRON"
+)
+
+// Standing order statuses. COMPLETED orders have no runs left; FAILED is a
+// one-off order whose only run failed.
+const (
+	StandingOrderActive    = "ACTIVE"
+	StandingOrderPaused    = "PAUSED"
+	StandingOrderCancelled = "CANCELLED"
+	StandingOrderCompleted = "COMPLETED"
+	StandingOrderFailed    = "FAILED"
+)
+
+var (
+	ErrStandingOrderNotActive = errors.New("standing order is not active")
+	ErrStandingOrderNotPaused = errors.New("standing order is not paused")
+	ErrStandingOrderFinished  = errors.New("standing order has already finished")
+	ErrInvalidSchedule        = errors.New("invalid standing order schedule")
+)
+
+// RetryPolicy controls how a run that failed for lack of funds is retried:
+// up to MaxAttempts runs in total, Interval apart.
+type RetryPolicy struct {
+	MaxAttempts int
+	Interval    time.Duration
+}
+
+// StandingOrder is a one-off or recurring TRANSFER or PAYMENT executed by the
+// scheduler. NextRunAt is when it is due next, and is nil once the order has
+// no runs left. Attempts counts the failed attempts of the current run.
+type StandingOrder struct {
+	ID                uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID            uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	TransactionType   string     `json:"transaction_type" gorm:"size:20;not null"`
+	Currency          string     `json:"currency" gorm:"size:3;not null;default:IDR"`
+	Amount            Money      `json:"amount" gorm:"not null"`
+	RecipientID       *uuid.UUID `json:"recipient_id,omitempty" gorm:"type:char(36)"`
+	Description       string     `json:"description"`
+	Schedule          string     `json:"schedule" gorm:"size:20;not null"`
+	CronExpression    string     `json:"cron_expression,omitempty" gorm:"size:100"`
+	StartAt           time.Time  `json:"start_at" gorm:"not null"`
+	EndAt             *time.Time `json:"end_at,omitempty"`
+	NextRunAt         *time.Time `json:"next_run_at,omitempty" gorm:"index:idx_standing_orders_due,priority:2"`
+	Status            string     `json:"status" gorm:"size:20;not null;default:ACTIVE;index:idx_standing_orders_due,priority:1"`
+	Attempts          int        `json:"attempts" gorm:"not null;default:0"`
+	RunCount          int        `json:"run_count" gorm:"not null;default:0"`
+	LastRunAt         *time.Time `json:"last_run_at,omitempty"`
+	LastTransactionID *uuid.UUID `json:"last_transaction_id,omitempty" gorm:"type:char(36)"`
+	LastError         string     `json:"last_error,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// ValidateSchedule checks the schedule and cron expression.
+func (o *StandingOrder) ValidateSchedule() error {
+	switch o.Schedule {
+	case ScheduleOnce, ScheduleDaily, ScheduleWeekly, ScheduleMonthly:
+		if o.CronExpression != "" {
+			return ErrInvalidSchedule
+		}
+	case ScheduleCron:
+		if _, err := ParseCron(o.CronExpression); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidSchedule
+	}
+	if o.EndAt != nil && o.EndAt.Before(o.StartAt) {
+		return ErrInvalidSchedule
+	}
+	return nil
+}
+
+// FirstRun returns when the order runs first: StartAt, or for a cron
+// schedule the first match from StartAt on.
+func (o *StandingOrder) FirstRun() (time.Time, bool) {
+	if o.Schedule == ScheduleCron {
+		return o.NextRunAfter(o.StartAt.Add(-time.Nanosecond))
+	}
+	return o.StartAt, o.withinEnd(o.StartAt)
+}
+
+// NextRunAfter returns the first scheduled run strictly after t. It returns
+// false when the order has no run after t.
+func (o *StandingOrder) NextRunAfter(t time.Time) (time.Time, bool) {
+	var next time.Time
+	switch o.Schedule {
+	case ScheduleOnce:
+		return time.Time{}, false
+	case ScheduleDaily:
+		next = o.nthRunAfter(t, func(n int) time.Time { return o.StartAt.AddDate(0, 0, n) })
+	case ScheduleWeekly:
+		next = o.nthRunAfter(t, func(n int) time.Time { return o.StartAt.AddDate(0, 0, 7*n) })
+	case ScheduleMonthly:
+		next = o.nthRunAfter(t, func(n int) time.Time { return addMonthsClamped(o.StartAt, n) })
+	case ScheduleCron:
+		schedule, err := ParseCron(o.CronExpression)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if t.Before(o.StartAt) {
+			t = o.StartAt.Add(-time.Nanosecond)
+		}
+		if next = schedule.Next(t); next.IsZero() {
+			return time.Time{}, false
+		}
+	default:
+		return time.Time{}, false
+	}
+	return next, o.withinEnd(next)
+}
+
+// nthRunAfter returns the first of StartAt, run(1), run(2)... after t. The
+// search starts near t so long running orders don't walk every past run.
+func (o *StandingOrder) nthRunAfter(t time.Time, run func(n int) time.Time) time.Time {
+	if t.Before(o.StartAt) {
+		return o.StartAt
+	}
+	n := 0
+	if second := run(1); second.After(o.StartAt) {
+		n = int(t.Sub(o.StartAt) / second.Sub(o.StartAt))
+	}
+	for n > 0 && run(n-1).After(t) {
+		n--
+	}
+	for !run(n).After(t) {
+		n++
+	}
+	return run(n)
+}
+
+func (o *StandingOrder) withinEnd(t time.Time) bool {
+	return o.EndAt == nil || !t.After(*o.EndAt)
+}
+
+// addMonthsClamped adds months to t, keeping its day of month unless the
+// target month is shorter, in which case the last day of that month is used.
+// A run on the 31st falls on the 30th in April and the 28th or 29th in
+// February.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func (o *StandingOrder) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
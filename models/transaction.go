@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,29 +10,53 @@ import (
 
 const (
 	TOPUP, TRANSFER, PAYMENT, SUCCESS, DEBIT, CREDIT string = "TOPUP", "TRANSFER", "PAYMENT", "SUCCESS", "DEBIT", "CREDIT"
+	REFUND, REVERSAL                                 string = "REFUND", "REVERSAL"
+	PARTIALLY_REFUNDED, REFUNDED, REVERSED           string = "PARTIALLY_REFUNDED", "REFUNDED", "REVERSED"
 )
 
 type Transaction struct {
-	ID              uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
-	UserID          uuid.UUID  `json:"user_id" gorm:"type:char(36);not null"`
-	Type            string     `json:"type" gorm:"not null"`
-	TransactionType string     `json:"transaction_type" gorm:"not null"`
-	Amount          float64    `json:"amount" gorm:"not null"`
-	BalanceBefore   float64    `json:"balance_before" gorm:"not null"`
-	BalanceAfter    float64    `json:"balance_after" gorm:"not null"`
-	RecipientID     *uuid.UUID `json:"recipient_id,omitempty" gorm:"type:char(36)"`
-	Description     string     `json:"description"`
-	ReferenceNumber string     `json:"reference_number" gorm:"unique;not null"`
-	Status          string     `json:"status" gorm:"not null"`
-	CreatedAt       time.Time  `json:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at"`
-	User            User       `json:"-" gorm:"foreignKey:UserID"`
-	Recipient       *User      `json:"-" gorm:"foreignKey:RecipientID"`
+	ID                    uuid.UUID  `json:"id" gorm:"type:char(36);primary_key;index:idx_transactions_user_created_id,priority:3"`
+	UserID                uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index:idx_transactions_user_created_id,priority:1"`
+	Type                  string     `json:"type" gorm:"not null"`
+	TransactionType       string     `json:"transaction_type" gorm:"not null"`
+	Currency              string     `json:"currency" gorm:"size:3;not null;default:IDR"`
+	Amount                Money      `json:"amount" gorm:"not null"`
+	BalanceBefore         Money      `json:"balance_before" gorm:"not null"`
+	BalanceAfter          Money      `json:"balance_after" gorm:"not null"`
+	RecipientID           *uuid.UUID `json:"recipient_id,omitempty" gorm:"type:char(36)"`
+	CounterpartyID        *uuid.UUID `json:"counterparty_id,omitempty" gorm:"type:char(36);index"`
+	Description           string     `json:"description"`
+	ReferenceNumber       string     `json:"reference_number" gorm:"unique;not null"`
+	Status                string     `json:"status" gorm:"not null"`
+	RefundedAmount        Money      `json:"refunded_amount" gorm:"not null;default:0"`
+	Fee                   Money      `json:"fee" gorm:"not null;default:0"`
+	OriginalTransactionID *uuid.UUID `json:"original_transaction_id,omitempty" gorm:"type:char(36);index"`
+	MerchantID            *uuid.UUID `json:"merchant_id,omitempty" gorm:"type:char(36);index"`
+	CreatedAt             time.Time  `json:"created_at" gorm:"index:idx_transactions_user_created_id,priority:2"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	User                  User       `json:"-" gorm:"foreignKey:UserID"`
+	Recipient             *User      `json:"-" gorm:"foreignKey:RecipientID"`
+	// FeeBreakdown is set on a transaction just created, for the response
+	FeeBreakdown *FeeBreakdown `json:"-" gorm:"-"`
+}
+
+// RefundableAmount returns how much of the transaction can still be refunded.
+func (t *Transaction) RefundableAmount() Money {
+	return t.Amount - t.RefundedAmount
 }
 
 func (t *Transaction) BeforeCreate(tx *gorm.DB) error {
 	if t.ID == uuid.Nil {
 		t.ID = uuid.New()
 	}
+	if t.ReferenceNumber == "" {
+		t.ReferenceNumber = NewReferenceNumber()
+	}
 	return nil
 }
+
+// NewReferenceNumber returns a unique, human readable transaction reference.
+func NewReferenceNumber() string {
+	suffix := strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", ""))[:12]
+	return "TRX" + time.Now().Format("20060102150405") + suffix
+}
@@ -1,22 +1,59 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser  = "USER"
+	RoleAdmin = "ADMIN"
+)
+
 type User struct {
-	ID          uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
-	FirstName   string    `json:"first_name" gorm:"not null"`
-	LastName    string    `json:"last_name" gorm:"not null"`
-	PhoneNumber string    `json:"phone_number" gorm:"unique;not null"`
-	Address     string    `json:"address" gorm:"not null"`
-	Pin         string    `json:"-" gorm:"not null"`
-	Balance     float64   `json:"balance" gorm:"default:0"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID            uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	FirstName     string     `json:"first_name" gorm:"not null"`
+	LastName      string     `json:"last_name" gorm:"not null"`
+	PhoneNumber   string     `json:"phone_number" gorm:"unique;not null"`
+	Address       string     `json:"address" gorm:"not null"`
+	Pin           string     `json:"-" gorm:"not null"`
+	Role          string     `json:"-" gorm:"size:20;not null;default:USER"`
+	KYCTier       string     `json:"kyc_tier" gorm:"column:kyc_tier;size:20;not null;default:UNVERIFIED"`
+	KYCStatus     string     `json:"kyc_status" gorm:"column:kyc_status;size:20;not null;default:NONE"`
+	KYCReviewedAt *time.Time `json:"kyc_reviewed_at,omitempty" gorm:"column:kyc_reviewed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// MaskedName returns the user's name with all but the first letter of each
+// part hidden, e.g. "J*** D**", for showing a recipient before a transfer.
+func (u *User) MaskedName() string {
+	parts := []string{}
+	for _, part := range strings.Fields(u.FirstName + " " + u.LastName) {
+		runes := []rune(part)
+		parts = append(parts, string(runes[0])+strings.Repeat("*", len(runes)-1))
+	}
+	return strings.Join(parts, " ")
+}
+
+// MaskedPhoneNumber returns the user's phone number with all but its last
+// four digits hidden, e.g. "*********7890", so a preview confirms the
+// recipient without revealing their number.
+func (u *User) MaskedPhoneNumber() string {
+	runes := []rune(u.PhoneNumber)
+	visible := 4
+	if len(runes) <= visible {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-visible) + string(runes[len(runes)-visible:])
+}
+
+// IsAdmin reports whether the user may call admin-only endpoints.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) error {
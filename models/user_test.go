@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserMaskedName(t *testing.T) {
+	assert.Equal(t, "J*** D**", (&User{FirstName: "John", LastName: "Doe"}).MaskedName())
+	assert.Equal(t, "M*** J*** S****", (&User{FirstName: "Mary Jane", LastName: "Smith"}).MaskedName())
+	assert.Equal(t, "Ö*** A", (&User{FirstName: "Özil", LastName: "A"}).MaskedName())
+}
+
+func TestUserMaskedPhoneNumber(t *testing.T) {
+	assert.Equal(t, "**********7890", (&User{PhoneNumber: "+6281234567890"}).MaskedPhoneNumber())
+	assert.Equal(t, "***", (&User{PhoneNumber: "123"}).MaskedPhoneNumber())
+}
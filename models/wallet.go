@@ -0,0 +1,34 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWalletNotFound = errors.New("wallet not found")
+	ErrWalletExists   = errors.New("wallet already exists")
+)
+
+// Wallet holds a user's balance in one currency. Every user has a wallet in
+// the default currency; wallets in other currencies are opened on request.
+// Balance is a cached value that must equal the ledger balance of the
+// wallet's account.
+type Wallet struct {
+	ID        uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:char(36);not null;uniqueIndex:idx_wallets_user_currency"`
+	Currency  string    `json:"currency" gorm:"size:3;not null;uniqueIndex:idx_wallets_user_currency"`
+	Balance   Money     `json:"balance" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (w *Wallet) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
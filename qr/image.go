@@ -0,0 +1,25 @@
+package qr
+
+import (
+	"errors"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// PNG image sizes in pixels
+const (
+	DefaultImageSize = 256
+	MinImageSize     = 128
+	MaxImageSize     = 1024
+)
+
+var ErrInvalidImageSize = errors.New("QR image size is out of range")
+
+// PNG renders payload as a square PNG QR code of size pixels with medium
+// (15%) error correction.
+func PNG(payload string, size int) ([]byte, error) {
+	if size < MinImageSize || size > MaxImageSize {
+		return nil, ErrInvalidImageSize
+	}
+	return qrcode.Encode(payload, qrcode.Medium, size)
+}
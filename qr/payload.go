@@ -0,0 +1,226 @@
+package qr
+
+import (
+	"errors"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// MerchantGUID identifies our merchant account information template, so
+// wallets can tell our QR codes from other schemes'
+const MerchantGUID = "COM.EWALLETAPI"
+
+// Data object tags of the merchant presented QR payload
+const (
+	tagFormatIndicator  = "00"
+	tagInitiationMethod = "01"
+	tagMerchantAccount  = "26"
+	tagCategoryCode     = "52"
+	tagCurrency         = "53"
+	tagAmount           = "54"
+	tagCountryCode      = "58"
+	tagMerchantName     = "59"
+	tagMerchantCity     = "60"
+	tagAdditionalData   = "62"
+)
+
+// Sub-tags of the merchant account information and additional data templates
+const (
+	subTagGUID       = "00"
+	subTagMerchantID = "01"
+	subTagExpiresAt  = "02"
+	subTagReference  = "05"
+)
+
+// Point of initiation methods: a static code can be paid many times with an
+// amount the payer enters, a dynamic code once for a fixed amount
+const (
+	initiationStatic  = "11"
+	initiationDynamic = "12"
+)
+
+const (
+	maxMerchantNameLength = 25
+	maxMerchantCityLength = 15
+)
+
+var (
+	ErrUnsupportedPayload = errors.New("QR payload is not an e-wallet merchant code")
+	ErrMissingField       = errors.New("QR payload is missing a required field")
+)
+
+// Payload is a merchant presented QR code. Static codes carry no amount,
+// reference or expiry; dynamic codes carry all three. Currency is the ISO
+// 4217 numeric code and Amount a plain decimal.
+type Payload struct {
+	Dynamic      bool
+	MerchantID   string
+	CategoryCode string
+	Currency     string
+	Amount       string
+	CountryCode  string
+	MerchantName string
+	MerchantCity string
+	Reference    string
+	ExpiresAt    time.Time
+}
+
+// Encode serializes the payload with its checksum. Merchant names and cities
+// are truncated to the lengths the format allows.
+func (p *Payload) Encode() (string, error) {
+	method := initiationStatic
+	if p.Dynamic {
+		method = initiationDynamic
+	}
+
+	accountFields := []Field{
+		{Tag: subTagGUID, Value: MerchantGUID},
+		{Tag: subTagMerchantID, Value: p.MerchantID},
+	}
+	if !p.ExpiresAt.IsZero() {
+		accountFields = append(accountFields, Field{Tag: subTagExpiresAt, Value: strconv.FormatInt(p.ExpiresAt.Unix(), 10)})
+	}
+	account, err := EncodeFields(accountFields)
+	if err != nil {
+		return "", err
+	}
+
+	fields := []Field{
+		{Tag: tagFormatIndicator, Value: "01"},
+		{Tag: tagInitiationMethod, Value: method},
+		{Tag: tagMerchantAccount, Value: account},
+		{Tag: tagCategoryCode, Value: p.CategoryCode},
+		{Tag: tagCurrency, Value: p.Currency},
+	}
+	if p.Amount != "" {
+		fields = append(fields, Field{Tag: tagAmount, Value: p.Amount})
+	}
+	fields = append(fields,
+		Field{Tag: tagCountryCode, Value: p.CountryCode},
+		Field{Tag: tagMerchantName, Value: truncate(p.MerchantName, maxMerchantNameLength)},
+		Field{Tag: tagMerchantCity, Value: truncate(p.MerchantCity, maxMerchantCityLength)},
+	)
+	if p.Reference != "" {
+		additional, err := EncodeFields([]Field{{Tag: subTagReference, Value: p.Reference}})
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, Field{Tag: tagAdditionalData, Value: additional})
+	}
+
+	encoded, err := EncodeFields(fields)
+	if err != nil {
+		return "", err
+	}
+	return Seal(encoded), nil
+}
+
+// Parse decodes and checks a scanned payload. Codes of other schemes are
+// rejected with ErrUnsupportedPayload.
+func Parse(payload string) (*Payload, error) {
+	body, err := Unseal(payload)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := DecodeFields(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 || fields[0].Tag != tagFormatIndicator || fields[0].Value != "01" {
+		return nil, ErrMalformedPayload
+	}
+
+	p := &Payload{}
+	foundAccount := false
+	for _, field := range fields[1:] {
+		switch field.Tag {
+		case tagInitiationMethod:
+			switch field.Value {
+			case initiationStatic:
+			case initiationDynamic:
+				p.Dynamic = true
+			default:
+				return nil, ErrMalformedPayload
+			}
+		case tagMerchantAccount:
+			found, err := p.parseMerchantAccount(field.Value)
+			if err != nil {
+				return nil, err
+			}
+			foundAccount = foundAccount || found
+		case tagCategoryCode:
+			p.CategoryCode = field.Value
+		case tagCurrency:
+			p.Currency = field.Value
+		case tagAmount:
+			p.Amount = field.Value
+		case tagCountryCode:
+			p.CountryCode = field.Value
+		case tagMerchantName:
+			p.MerchantName = field.Value
+		case tagMerchantCity:
+			p.MerchantCity = field.Value
+		case tagAdditionalData:
+			additional, err := DecodeFields(field.Value)
+			if err != nil {
+				return nil, err
+			}
+			for _, sub := range additional {
+				if sub.Tag == subTagReference {
+					p.Reference = sub.Value
+				}
+			}
+		}
+	}
+
+	if !foundAccount {
+		return nil, ErrUnsupportedPayload
+	}
+	if p.Currency == "" || p.CategoryCode == "" {
+		return nil, ErrMissingField
+	}
+	if p.Dynamic && (p.Amount == "" || p.Reference == "" || p.ExpiresAt.IsZero()) {
+		return nil, ErrMissingField
+	}
+	return p, nil
+}
+
+// parseMerchantAccount reads a merchant account information template,
+// reporting whether it is ours.
+func (p *Payload) parseMerchantAccount(value string) (bool, error) {
+	fields, err := DecodeFields(value)
+	if err != nil {
+		return false, err
+	}
+	if len(fields) == 0 || fields[0].Tag != subTagGUID || fields[0].Value != MerchantGUID {
+		// Another scheme's account; a code may list several
+		return false, nil
+	}
+
+	for _, field := range fields[1:] {
+		switch field.Tag {
+		case subTagMerchantID:
+			p.MerchantID = field.Value
+		case subTagExpiresAt:
+			unix, err := strconv.ParseInt(field.Value, 10, 64)
+			if err != nil {
+				return false, ErrMalformedPayload
+			}
+			p.ExpiresAt = time.Unix(unix, 0)
+		}
+	}
+	if p.MerchantID == "" {
+		return false, ErrMissingField
+	}
+	return true, nil
+}
+
+// truncate shortens s to at most max bytes without splitting a character.
+func truncate(s string, max int) string {
+	for len(s) > max {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
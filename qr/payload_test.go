@@ -0,0 +1,93 @@
+package qr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamicPayloadRoundTrip(t *testing.T) {
+	expiresAt := time.Unix(1704067200, 0)
+	payload := &Payload{
+		Dynamic:      true,
+		MerchantID:   "4f1e2d3c-1111-2222-3333-444455556666",
+		CategoryCode: "5812",
+		Currency:     "360",
+		Amount:       "45000.00",
+		CountryCode:  "ID",
+		MerchantName: "Warung Makan Sederhana Padang Jaya",
+		MerchantCity: "Jakarta Selatan Raya",
+		Reference:    "QR0123456789ABCDEF0123",
+		ExpiresAt:    expiresAt,
+	}
+
+	encoded, err := payload.Encode()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "000201010212"))
+
+	parsed, err := Parse(encoded)
+	assert.NoError(t, err)
+	assert.True(t, parsed.Dynamic)
+	assert.Equal(t, payload.MerchantID, parsed.MerchantID)
+	assert.Equal(t, "45000.00", parsed.Amount)
+	assert.Equal(t, payload.Reference, parsed.Reference)
+	assert.Equal(t, expiresAt.Unix(), parsed.ExpiresAt.Unix())
+
+	// Names and cities are cut to the lengths the format allows
+	assert.Equal(t, "Warung Makan Sederhana Pa", parsed.MerchantName)
+	assert.Equal(t, "Jakarta Selatan", parsed.MerchantCity)
+
+	// Any change breaks the checksum
+	_, err = Parse(strings.Replace(encoded, "45000.00", "15000.00", 1))
+	assert.Equal(t, ErrChecksumMismatch, err)
+}
+
+func TestStaticPayload(t *testing.T) {
+	payload := &Payload{
+		MerchantID:   "4f1e2d3c-1111-2222-3333-444455556666",
+		CategoryCode: "5812",
+		Currency:     "360",
+		CountryCode:  "ID",
+		MerchantName: "Warung",
+		MerchantCity: "Jakarta",
+	}
+
+	encoded, err := payload.Encode()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "000201010211"))
+
+	parsed, err := Parse(encoded)
+	assert.NoError(t, err)
+	assert.False(t, parsed.Dynamic)
+	assert.Empty(t, parsed.Amount)
+	assert.True(t, parsed.ExpiresAt.IsZero())
+}
+
+func TestParseRejectsOtherSchemes(t *testing.T) {
+	account, _ := EncodeFields([]Field{{Tag: "00", Value: "ID.CO.OTHER.WWW"}, {Tag: "01", Value: "936000001"}})
+	encoded, _ := EncodeFields([]Field{
+		{Tag: "00", Value: "01"},
+		{Tag: "01", Value: "11"},
+		{Tag: "26", Value: account},
+		{Tag: "52", Value: "5812"},
+		{Tag: "53", Value: "360"},
+		{Tag: "58", Value: "ID"},
+		{Tag: "59", Value: "Other"},
+		{Tag: "60", Value: "Jakarta"},
+	})
+
+	_, err := Parse(Seal(encoded))
+	assert.Equal(t, ErrUnsupportedPayload, err)
+}
+
+func TestPNG(t *testing.T) {
+	image, err := PNG(Seal("000201"), DefaultImageSize)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(image, []byte("\x89PNG")))
+
+	_, err = PNG(Seal("000201"), MaxImageSize+1)
+	assert.Equal(t, ErrInvalidImageSize, err)
+}
@@ -0,0 +1,106 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// TagCRC is the tag of the checksum that ends every payload
+const TagCRC = "63"
+
+var (
+	ErrMalformedPayload = errors.New("malformed QR payload")
+	ErrChecksumMismatch = errors.New("QR payload checksum does not match")
+	ErrValueTooLong     = errors.New("QR field value is longer than 99 characters")
+)
+
+// Field is one tag-length-value data object of an EMV QR payload. Tags are
+// two digits and lengths are two decimal digits, so values hold at most 99
+// characters.
+type Field struct {
+	Tag   string
+	Value string
+}
+
+// EncodeFields serializes fields in order without a checksum.
+func EncodeFields(fields []Field) (string, error) {
+	encoded := ""
+	for _, field := range fields {
+		if len(field.Tag) != 2 {
+			return "", ErrMalformedPayload
+		}
+		if len(field.Value) > 99 {
+			return "", ErrValueTooLong
+		}
+		encoded += fmt.Sprintf("%s%02d%s", field.Tag, len(field.Value), field.Value)
+	}
+	return encoded, nil
+}
+
+// DecodeFields parses a sequence of data objects.
+func DecodeFields(data string) ([]Field, error) {
+	fields := []Field{}
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, ErrMalformedPayload
+		}
+		tag := data[:2]
+		length, err := strconv.Atoi(data[2:4])
+		if err != nil || !isDigits(tag) || !isDigits(data[2:4]) || len(data) < 4+length {
+			return nil, ErrMalformedPayload
+		}
+		fields = append(fields, Field{Tag: tag, Value: data[4 : 4+length]})
+		data = data[4+length:]
+	}
+	return fields, nil
+}
+
+// Seal appends the CRC data object to an encoded payload. The checksum covers
+// the payload up to and including the CRC tag and length.
+func Seal(payload string) string {
+	payload += TagCRC + "04"
+	return payload + fmt.Sprintf("%04X", CRC16(payload))
+}
+
+// Unseal checks the trailing CRC data object of a payload and returns the
+// payload without it.
+func Unseal(payload string) (string, error) {
+	if len(payload) < 8 || payload[len(payload)-8:len(payload)-4] != TagCRC+"04" {
+		return "", ErrMalformedPayload
+	}
+	checksum, err := strconv.ParseUint(payload[len(payload)-4:], 16, 16)
+	if err != nil {
+		return "", ErrMalformedPayload
+	}
+	if uint16(checksum) != CRC16(payload[:len(payload)-4]) {
+		return "", ErrChecksumMismatch
+	}
+	return payload[:len(payload)-8], nil
+}
+
+// CRC16 computes the CRC-16/CCITT-FALSE checksum (polynomial 0x1021, initial
+// value 0xFFFF) that EMV QR payloads end with.
+func CRC16(data string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
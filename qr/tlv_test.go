@@ -0,0 +1,40 @@
+package qr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRC16(t *testing.T) {
+	// CRC-16/CCITT-FALSE check value
+	assert.Equal(t, uint16(0x29B1), CRC16("123456789"))
+}
+
+func TestFieldsRoundTrip(t *testing.T) {
+	encoded, err := EncodeFields([]Field{{Tag: "00", Value: "01"}, {Tag: "59", Value: "Warung Makan"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "0002015912Warung Makan", encoded)
+
+	fields, err := DecodeFields(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, []Field{{Tag: "00", Value: "01"}, {Tag: "59", Value: "Warung Makan"}}, fields)
+
+	// Lengths past the end of the data are rejected
+	_, err = DecodeFields("0005ab")
+	assert.Equal(t, ErrMalformedPayload, err)
+}
+
+func TestSealAndUnseal(t *testing.T) {
+	sealed := Seal("000201")
+	assert.Regexp(t, "^0002016304[0-9A-F]{4}$", sealed)
+
+	body, err := Unseal(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "000201", body)
+
+	_, err = Unseal("000202" + sealed[6:])
+	assert.Equal(t, ErrChecksumMismatch, err)
+	_, err = Unseal("000201")
+	assert.Equal(t, ErrMalformedPayload, err)
+}
@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"encoding/json"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Record stores an audit event. details is serialized as JSON.
+func (r *AuditRepository) Record(action string, userID *uuid.UUID, ipAddress string, details interface{}) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Create(&models.AuditLog{
+		UserID:    userID,
+		Action:    action,
+		IPAddress: ipAddress,
+		Details:   string(encoded),
+	}).Error
+}
+
+// FindByAction lists audit events of one kind, newest first.
+func (r *AuditRepository) FindByAction(action string) ([]models.AuditLog, error) {
+	var logs []models.AuditLog
+	if err := r.db.Where("action = ?", action).Order("created_at desc").Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CheckoutSessionRepository struct {
+	db *gorm.DB
+}
+
+func NewCheckoutSessionRepository(db *gorm.DB) *CheckoutSessionRepository {
+	return &CheckoutSessionRepository{db: db}
+}
+
+// Create opens a checkout session for an active merchant in its settlement
+// currency. When the session has line items its amount is their total; an
+// amount set as well must match it.
+func (r *CheckoutSessionRepository) Create(session *models.CheckoutSession) error {
+	merchant, err := NewMerchantRepository(r.db).FindByID(session.MerchantID)
+	if err != nil {
+		return err
+	}
+	if merchant.Status != models.MerchantActive {
+		return models.ErrMerchantNotActive
+	}
+
+	if len(session.LineItems) > 0 {
+		total, err := models.PriceLineItems(session.LineItems)
+		if err != nil {
+			return err
+		}
+		if session.Amount != 0 && session.Amount != total {
+			return models.ErrLineItemsMismatch
+		}
+		session.Amount = total
+	}
+	if session.Amount <= 0 {
+		return models.ErrAmountOutOfRange
+	}
+
+	session.Currency = merchant.SettlementCurrency
+	session.Status = models.CheckoutSessionOpen
+	return r.db.Create(session).Error
+}
+
+// Find returns a checkout session with its line items. Sessions are looked up
+// by ID alone so whoever holds the link can pay it.
+func (r *CheckoutSessionRepository) Find(sessionID uuid.UUID) (*models.CheckoutSession, error) {
+	var session models.CheckoutSession
+	if err := r.db.Preload("LineItems").First(&session, "id = ?", sessionID).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindForMerchant returns one of the merchant's checkout sessions with its
+// line items.
+func (r *CheckoutSessionRepository) FindForMerchant(sessionID, merchantID uuid.UUID) (*models.CheckoutSession, error) {
+	var session models.CheckoutSession
+	err := r.db.Preload("LineItems").
+		First(&session, "id = ? AND merchant_id = ?", sessionID, merchantID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetMerchantSessions returns a page of the merchant's checkout sessions with
+// their line items, newest first. status filters by the status as of now, so
+// OPEN excludes expired sessions.
+func (r *CheckoutSessionRepository) GetMerchantSessions(merchantID uuid.UUID, status string, now time.Time, page, limit int) ([]models.CheckoutSession, int64, error) {
+	var sessions []models.CheckoutSession
+	var total int64
+
+	query := r.db.Model(&models.CheckoutSession{}).Where("merchant_id = ?", merchantID)
+	switch status {
+	case "":
+	case models.CheckoutSessionOpen:
+		query = query.Where("status = ? AND expires_at > ?", models.CheckoutSessionOpen, now)
+	case models.CheckoutSessionExpired:
+		query = query.Where("status = ? AND expires_at <= ?", models.CheckoutSessionOpen, now)
+	default:
+		query = query.Where("status = ?", status)
+	}
+
+	query = query.Session(&gorm.Session{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("LineItems").
+		Order("created_at desc, id desc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return sessions, total, nil
+}
+
+// Cancel closes one of the merchant's open checkout sessions so it can no
+// longer be paid.
+func (r *CheckoutSessionRepository) Cancel(sessionID, merchantID uuid.UUID, now time.Time) (*models.CheckoutSession, error) {
+	var session models.CheckoutSession
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Set("gorm:query_option", "FOR UPDATE").
+			First(&session, "id = ? AND merchant_id = ?", sessionID, merchantID).Error
+		if err != nil {
+			return err
+		}
+		if err := session.CheckOpen(now); err != nil {
+			return err
+		}
+
+		session.Status = models.CheckoutSessionCancelled
+		session.CancelledAt = &now
+		return tx.Save(&session).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return r.FindForMerchant(sessionID, merchantID)
+}
+
+// Pay completes an open checkout session for whoever followed its link. If
+// TransactionRepository.PayMerchant refuses the payment the session stays
+// OPEN so the customer can retry; once paid it can't be paid again. It
+// returns the session, the payer's PAYMENT and the balances before and after.
+func (r *CheckoutSessionRepository) Pay(sessionID, payerID uuid.UUID, remarks string, now time.Time) (*models.CheckoutSession, *models.Transaction, models.Money, models.Money, error) {
+	var session models.CheckoutSession
+	var transaction *models.Transaction
+	var balanceBefore, balanceAfter models.Money
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&session, "id = ?", sessionID).Error; err != nil {
+			return err
+		}
+		if err := session.CheckOpen(now); err != nil {
+			return err
+		}
+
+		var err error
+		transactionRepo := NewTransactionRepository(tx)
+		transaction, balanceBefore, balanceAfter, err = transactionRepo.PayMerchant(payerID, session.MerchantID, session.Currency, session.Amount, remarks)
+		if err != nil {
+			return err
+		}
+
+		session.Status = models.CheckoutSessionPaid
+		session.PayerID = &payerID
+		session.TransactionID = &transaction.ID
+		session.PaidAt = &now
+		return tx.Save(&session).Error
+	})
+
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	paid, err := r.Find(sessionID)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	return paid, transaction, balanceBefore, balanceAfter, nil
+}
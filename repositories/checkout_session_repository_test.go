@@ -0,0 +1,160 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type CheckoutSessionRepositoryTestSuite struct {
+	suite.Suite
+	merchantFixture
+	repository *CheckoutSessionRepository
+}
+
+func (suite *CheckoutSessionRepositoryTestSuite) SetupTest() {
+	suite.merchantFixture = newMerchantFixture(suite.T(), &models.CheckoutSession{}, &models.CheckoutLineItem{})
+	suite.repository = &CheckoutSessionRepository{db: suite.db}
+}
+
+// openSession opens a session for one of the shop's orders, with the order
+// number as the client reference and in the redirect URLs.
+func (suite *CheckoutSessionRepositoryTestSuite) openSession(order string, amount int64, expiresAt time.Time) *models.CheckoutSession {
+	session := &models.CheckoutSession{
+		MerchantID:      suite.merchant.ID,
+		Amount:          models.NewMoneyFromMajor(amount),
+		ClientReference: order,
+		SuccessURL:      "https://shop.example.com/orders/" + order + "/success",
+		CancelURL:       "https://shop.example.com/orders/" + order + "/cancel",
+		ExpiresAt:       expiresAt,
+	}
+	assert.NoError(suite.T(), suite.repository.Create(session))
+	return session
+}
+
+func (suite *CheckoutSessionRepositoryTestSuite) TestCreateWithLineItems() {
+	session := &models.CheckoutSession{
+		MerchantID: suite.merchant.ID,
+		SuccessURL: "https://shop.example.com/success",
+		CancelURL:  "https://shop.example.com/cancel",
+		LineItems: []models.CheckoutLineItem{
+			{Name: "T-shirt", Quantity: 2, UnitAmount: models.NewMoneyFromMajor(75000)},
+			{Name: "Shipping", Quantity: 1, UnitAmount: models.NewMoneyFromMajor(15000)},
+		},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	assert.NoError(suite.T(), suite.repository.Create(session))
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(165000), session.Amount)
+	assert.Equal(suite.T(), models.DefaultCurrency, session.Currency)
+	assert.Equal(suite.T(), models.CheckoutSessionOpen, session.Status)
+
+	found, err := suite.repository.Find(session.ID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), found.LineItems, 2)
+
+	// An amount given with line items must match them
+	mismatched := &models.CheckoutSession{
+		MerchantID: suite.merchant.ID,
+		Amount:     models.NewMoneyFromMajor(1000),
+		LineItems:  []models.CheckoutLineItem{{Name: "T-shirt", Quantity: 1, UnitAmount: models.NewMoneyFromMajor(75000)}},
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	assert.Equal(suite.T(), models.ErrLineItemsMismatch, suite.repository.Create(mismatched))
+
+	assert.NoError(suite.T(), suite.db.Model(suite.merchant).Update("status", models.MerchantSuspended).Error)
+	err = suite.repository.Create(&models.CheckoutSession{MerchantID: suite.merchant.ID, Amount: models.NewMoneyFromMajor(1000), ExpiresAt: time.Now().Add(time.Hour)})
+	assert.Equal(suite.T(), models.ErrMerchantNotActive, err)
+}
+
+func (suite *CheckoutSessionRepositoryTestSuite) TestPayOnce() {
+	now := time.Now()
+	session := suite.openSession("ORD-1", 45000, now.Add(time.Hour))
+
+	paid, transaction, _, balanceAfter, err := suite.repository.Pay(session.ID, suite.payer.ID, "Order 1", now)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.CheckoutSessionPaid, paid.Status)
+	assert.Equal(suite.T(), transaction.ID, *paid.TransactionID)
+	assert.Equal(suite.T(), suite.payer.ID, *paid.PayerID)
+	assert.Equal(suite.T(), suite.merchant.ID, *transaction.MerchantID)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(45000), transaction.Amount)
+	assert.True(suite.T(), balanceAfter < models.NewMoneyFromMajor(100000))
+
+	_, _, _, _, err = suite.repository.Pay(session.ID, suite.payer.ID, "Order 1", now)
+	assert.Equal(suite.T(), models.ErrCheckoutSessionNotOpen, err)
+	_, err = suite.repository.Cancel(session.ID, suite.merchant.ID, now)
+	assert.Equal(suite.T(), models.ErrCheckoutSessionNotOpen, err)
+}
+
+func (suite *CheckoutSessionRepositoryTestSuite) TestPayRefused() {
+	now := time.Now()
+
+	expired := suite.openSession("ORD-2", 1000, now.Add(-time.Second))
+	_, _, _, _, err := suite.repository.Pay(expired.ID, suite.payer.ID, "Late", now)
+	assert.Equal(suite.T(), models.ErrCheckoutSessionExpired, err)
+
+	cancelled := suite.openSession("ORD-3", 1000, now.Add(time.Hour))
+	_, err = suite.repository.Cancel(cancelled.ID, suite.merchant.ID, now)
+	assert.NoError(suite.T(), err)
+	_, _, _, _, err = suite.repository.Pay(cancelled.ID, suite.payer.ID, "Cancelled", now)
+	assert.Equal(suite.T(), models.ErrCheckoutSessionNotOpen, err)
+
+	// A failed payment leaves the session open
+	tooMuch := suite.openSession("ORD-4", 500000, now.Add(time.Hour))
+	_, _, _, _, err = suite.repository.Pay(tooMuch.ID, suite.payer.ID, "Too much", now)
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+	session, err := suite.repository.FindForMerchant(tooMuch.ID, suite.merchant.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.CheckoutSessionOpen, session.Status)
+}
+
+func (suite *CheckoutSessionRepositoryTestSuite) TestCancel() {
+	now := time.Now()
+	session := suite.openSession("ORD-5", 1000, now.Add(time.Hour))
+
+	// Only the session's merchant can cancel it
+	other := &models.Merchant{OwnerID: suite.owner.ID, Name: "Other", CategoryCode: "5999", SettlementCurrency: models.DefaultCurrency}
+	assert.NoError(suite.T(), NewMerchantRepository(suite.db).Create(other))
+	_, err := suite.repository.Cancel(session.ID, other.ID, now)
+	assert.ErrorIs(suite.T(), err, gorm.ErrRecordNotFound)
+
+	cancelled, err := suite.repository.Cancel(session.ID, suite.merchant.ID, now)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.CheckoutSessionCancelled, cancelled.Status)
+	assert.NotNil(suite.T(), cancelled.CancelledAt)
+
+	expired := suite.openSession("ORD-6", 1000, now.Add(-time.Second))
+	_, err = suite.repository.Cancel(expired.ID, suite.merchant.ID, now)
+	assert.Equal(suite.T(), models.ErrCheckoutSessionExpired, err)
+}
+
+func (suite *CheckoutSessionRepositoryTestSuite) TestGetMerchantSessions() {
+	now := time.Now()
+	open := suite.openSession("ORD-7", 1000, now.Add(time.Hour))
+	expired := suite.openSession("ORD-8", 2000, now.Add(-time.Second))
+	cancelled := suite.openSession("ORD-9", 3000, now.Add(time.Hour))
+	_, err := suite.repository.Cancel(cancelled.ID, suite.merchant.ID, now)
+	assert.NoError(suite.T(), err)
+
+	sessions, total, err := suite.repository.GetMerchantSessions(suite.merchant.ID, "", now, 1, 2)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), total)
+	assert.Len(suite.T(), sessions, 2)
+
+	sessions, total, err = suite.repository.GetMerchantSessions(suite.merchant.ID, models.CheckoutSessionOpen, now, 1, 10)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), total)
+	assert.Equal(suite.T(), open.ID, sessions[0].ID)
+
+	sessions, _, err = suite.repository.GetMerchantSessions(suite.merchant.ID, models.CheckoutSessionExpired, now, 1, 10)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), sessions, 1)
+	assert.Equal(suite.T(), expired.ID, sessions[0].ID)
+}
+
+func TestCheckoutSessionRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(CheckoutSessionRepositoryTestSuite))
+}
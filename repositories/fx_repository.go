@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FXRepository struct {
+	db *gorm.DB
+}
+
+func NewFXRepository(db *gorm.DB) *FXRepository {
+	return &FXRepository{db: db}
+}
+
+// CreateQuote stores a quote for the user after checking that both of its
+// wallets are open.
+func (r *FXRepository) CreateQuote(quote *models.FXQuote) error {
+	wallets := NewWalletRepository(r.db)
+	for _, currency := range []string{quote.FromCurrency, quote.ToCurrency} {
+		if _, err := wallets.Find(quote.UserID, currency); err != nil {
+			return err
+		}
+	}
+	return r.db.Create(quote).Error
+}
+
+// FindQuote returns one of the user's quotes.
+func (r *FXRepository) FindQuote(quoteID, userID uuid.UUID) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	if err := r.db.First(&quote, "id = ? AND user_id = ?", quoteID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// FindTransactionQuote returns the quote a transaction was converted at.
+func (r *FXRepository) FindTransactionQuote(transactionID uuid.UUID) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	if err := r.db.First(&quote, "transaction_id = ?", transactionID).Error; err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+func (r *FXRepository) getQuoteForUpdate(tx *gorm.DB, quoteID, userID uuid.UUID) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&quote, "id = ? AND user_id = ?", quoteID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// Convert executes a quote: it debits the quoted amount from the source
+// wallet and credits the converted amount to the target wallet in one DB
+// transaction. It returns the used quote and the debit and credit rows.
+func (r *FXRepository) Convert(quoteID, userID uuid.UUID) (*models.FXQuote, *models.Transaction, *models.Transaction, error) {
+	var quote *models.FXQuote
+	var debit, credit models.Transaction
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the user before the quote, matching holds
+		transactionRepo := NewTransactionRepository(tx)
+		user, err := transactionRepo.getUserForUpdate(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		quote, err = r.getQuoteForUpdate(tx, quoteID, userID)
+		if err != nil {
+			return err
+		}
+		if quote.Status != models.FXQuoteActive {
+			return models.ErrFXQuoteNotActive
+		}
+		if quote.IsExpired(time.Now()) {
+			return models.ErrFXQuoteExpired
+		}
+
+		wallets := NewWalletRepository(tx)
+		fromWallet, err := wallets.getForUpdate(tx, userID, quote.FromCurrency)
+		if err != nil {
+			return err
+		}
+		toWallet, err := wallets.getForUpdate(tx, userID, quote.ToCurrency)
+		if err != nil {
+			return err
+		}
+
+		available, err := transactionRepo.availableBalance(tx, fromWallet)
+		if err != nil {
+			return err
+		}
+		if available < quote.FromAmount {
+			return models.ErrInvalidTransaction
+		}
+
+		toBalanceAfter := toWallet.Balance + quote.ToAmount
+		if !toBalanceAfter.IsValid() {
+			return models.ErrAmountOutOfRange
+		}
+
+		description := "Conversion " + quote.FromCurrency + " to " + quote.ToCurrency + " at " + quote.Rate.String()
+
+		debit = models.Transaction{
+			ID:              uuid.New(),
+			UserID:          userID,
+			Type:            models.DEBIT,
+			TransactionType: models.CONVERSION,
+			Currency:        quote.FromCurrency,
+			BalanceBefore:   fromWallet.Balance,
+			BalanceAfter:    fromWallet.Balance - quote.FromAmount,
+			Amount:          quote.FromAmount,
+			Status:          models.SUCCESS,
+			Description:     description,
+		}
+		credit = models.Transaction{
+			UserID:                userID,
+			Type:                  models.CREDIT,
+			TransactionType:       models.CONVERSION,
+			Currency:              quote.ToCurrency,
+			BalanceBefore:         toWallet.Balance,
+			BalanceAfter:          toBalanceAfter,
+			Amount:                quote.ToAmount,
+			Status:                models.SUCCESS,
+			Description:           description,
+			OriginalTransactionID: &debit.ID,
+		}
+
+		if err := tx.Model(fromWallet).Update("balance", debit.BalanceAfter).Error; err != nil {
+			return err
+		}
+		// The debited wallet is updated first so the balance limit sees the
+		// user's holdings after the conversion
+		if err := NewLimitRepository(tx).CheckBalance(user, toWallet, toBalanceAfter, models.LimitMaxBalance); err != nil {
+			return err
+		}
+		if err := tx.Model(toWallet).Update("balance", credit.BalanceAfter).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&debit).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&credit).Error; err != nil {
+			return err
+		}
+
+		// A journal never mixes currencies, so each leg balances against the
+		// FX position account of its own currency
+		ledger := NewLedgerRepository(tx)
+		fromAccount, err := ledger.UserAccount(userID, quote.FromCurrency)
+		if err != nil {
+			return err
+		}
+		fromPosition, err := ledger.SystemAccount(models.SystemAccountFXPosition, quote.FromCurrency)
+		if err != nil {
+			return err
+		}
+		toPosition, err := ledger.SystemAccount(models.SystemAccountFXPosition, quote.ToCurrency)
+		if err != nil {
+			return err
+		}
+		toAccount, err := ledger.UserAccount(userID, quote.ToCurrency)
+		if err != nil {
+			return err
+		}
+		if _, err := ledger.Move(models.JournalConversion, &debit.ID, description, fromAccount, fromPosition, quote.FromAmount); err != nil {
+			return err
+		}
+		if _, err := ledger.Move(models.JournalConversion, &credit.ID, description, toPosition, toAccount, quote.ToAmount); err != nil {
+			return err
+		}
+
+		quote.Status = models.FXQuoteUsed
+		quote.TransactionID = &debit.ID
+		return tx.Model(quote).Updates(map[string]interface{}{
+			"status":         quote.Status,
+			"transaction_id": quote.TransactionID,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return quote, &debit, &credit, nil
+}
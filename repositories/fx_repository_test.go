@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type FXRepositoryTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	repository   *FXRepository
+	transactions *TransactionRepository
+	user         *models.User
+}
+
+func (suite *FXRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.FXQuote{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &FXRepository{db: db}
+	suite.transactions = &TransactionRepository{db: db}
+
+	suite.user = &models.User{
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: "1234567890",
+		Address:     "123 Main St",
+		Pin:         "123456",
+		KYCTier:     models.KYCTierFull,
+	}
+	assert.NoError(suite.T(), NewUserRepository(db).Create(suite.user))
+
+	_, err = NewWalletRepository(db).Open(suite.user.ID, "USD")
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.TopUp(suite.user.ID, "USD", models.NewMoneyFromMajor(100))
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *FXRepositoryTestSuite) quote(amount models.Money, ttl time.Duration) *models.FXQuote {
+	quote, err := models.NewFXQuote(suite.user.ID, "USD", models.DefaultCurrency, amount, models.MustParseRate("16250"), 50, ttl)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.repository.CreateQuote(quote))
+	return quote
+}
+
+func (suite *FXRepositoryTestSuite) TestConvert() {
+	quote := suite.quote(models.MustParseMoney("40.50"), time.Minute)
+
+	used, debit, credit, err := suite.repository.Convert(quote.ID, suite.user.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.FXQuoteUsed, used.Status)
+	assert.Equal(suite.T(), debit.ID, *used.TransactionID)
+
+	assert.Equal(suite.T(), models.CONVERSION, debit.TransactionType)
+	assert.Equal(suite.T(), models.DEBIT, debit.Type)
+	assert.Equal(suite.T(), "USD", debit.Currency)
+	assert.Equal(suite.T(), models.MustParseMoney("40.50"), debit.Amount)
+	assert.Equal(suite.T(), models.MustParseMoney("59.50"), debit.BalanceAfter)
+
+	assert.Equal(suite.T(), models.CREDIT, credit.Type)
+	assert.Equal(suite.T(), models.DefaultCurrency, credit.Currency)
+	assert.Equal(suite.T(), models.MustParseMoney("654834.38"), credit.Amount)
+	assert.Equal(suite.T(), debit.ID, *credit.OriginalTransactionID)
+
+	wallets := NewWalletRepository(suite.db)
+	idr, err := wallets.Find(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.MustParseMoney("654834.38"), idr.Balance)
+
+	// Each currency balances against its own FX position account
+	ledger := NewLedgerRepository(suite.db)
+	for currency, want := range map[string]models.Money{
+		"USD":                  models.MustParseMoney("40.50"),
+		models.DefaultCurrency: models.MustParseMoney("-654834.38"),
+	} {
+		position, err := ledger.SystemAccount(models.SystemAccountFXPosition, currency)
+		assert.NoError(suite.T(), err)
+		balance, err := ledger.AccountBalance(position.ID)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), want, balance, currency)
+	}
+
+	mismatches, err := ledger.FindBalanceMismatches()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), mismatches)
+
+	// A quote can only be used once
+	_, _, _, err = suite.repository.Convert(quote.ID, suite.user.ID)
+	assert.Equal(suite.T(), models.ErrFXQuoteNotActive, err)
+}
+
+func (suite *FXRepositoryTestSuite) TestConvertRejectsExpiredQuote() {
+	quote := suite.quote(models.NewMoneyFromMajor(10), time.Minute)
+	assert.NoError(suite.T(), suite.db.Model(quote).Update("expires_at", time.Now().Add(-time.Second)).Error)
+
+	_, _, _, err := suite.repository.Convert(quote.ID, suite.user.ID)
+	assert.Equal(suite.T(), models.ErrFXQuoteExpired, err)
+
+	usd, err := NewWalletRepository(suite.db).Find(suite.user.ID, "USD")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(100), usd.Balance)
+}
+
+func (suite *FXRepositoryTestSuite) TestConvertNeedsBalanceAtExecution() {
+	quote := suite.quote(models.NewMoneyFromMajor(80), time.Minute)
+
+	// Spending after the quote leaves too little to convert
+	_, _, _, err := suite.transactions.Payment(suite.user.ID, "USD", models.NewMoneyFromMajor(30), "Book")
+	assert.NoError(suite.T(), err)
+
+	_, _, _, err = suite.repository.Convert(quote.ID, suite.user.ID)
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+
+	stored, err := suite.repository.FindQuote(quote.ID, suite.user.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.FXQuoteActive, stored.Status)
+}
+
+func (suite *FXRepositoryTestSuite) TestQuoteNeedsOpenWallets() {
+	quote, err := models.NewFXQuote(suite.user.ID, "USD", "EUR", models.NewMoneyFromMajor(10), models.MustParseRate("0.92"), 50, time.Minute)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.ErrWalletNotFound, suite.repository.CreateQuote(quote))
+}
+
+func (suite *FXRepositoryTestSuite) TestTransferConverted() {
+	recipient := &models.User{
+		FirstName:   "Jane",
+		LastName:    "Doe",
+		PhoneNumber: "0987654321",
+		Address:     "456 Main St",
+		Pin:         "123456",
+		KYCTier:     models.KYCTierBasic,
+	}
+	assert.NoError(suite.T(), NewUserRepository(suite.db).Create(recipient))
+
+	amount := models.MustParseMoney("40.50")
+	_, _, _, err := suite.transactions.Transfer(suite.user.ID, "USD", amount, recipient.ID, "Dinner")
+	assert.Equal(suite.T(), models.ErrCurrencyMismatch, err)
+
+	quote := func(from, to string, amount models.Money) (*models.FXQuote, error) {
+		return models.NewFXQuote(suite.user.ID, from, to, amount, models.MustParseRate("16250"), 50, time.Minute)
+	}
+	transaction, _, balanceAfter, err := suite.transactions.TransferConverted(suite.user.ID, "USD", amount, recipient.ID, "Dinner", quote)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "USD", transaction.Currency)
+	assert.Equal(suite.T(), models.MustParseMoney("59.50"), balanceAfter)
+
+	used, err := suite.repository.FindTransactionQuote(transaction.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.FXQuoteUsed, used.Status)
+	assert.Equal(suite.T(), suite.user.ID, used.UserID)
+
+	idr, err := NewWalletRepository(suite.db).Find(recipient.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.MustParseMoney("654834.38"), idr.Balance)
+
+	var credit models.Transaction
+	assert.NoError(suite.T(), suite.db.First(&credit, "user_id = ? AND reference_number = ?", recipient.ID, transaction.ID.String()).Error)
+	assert.Equal(suite.T(), models.DefaultCurrency, credit.Currency)
+	assert.Equal(suite.T(), models.MustParseMoney("654834.38"), credit.Amount)
+
+	mismatches, err := NewLedgerRepository(suite.db).FindBalanceMismatches()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), mismatches)
+
+	// The recipient never held USD, so the transfer can't be refunded in it
+	_, _, err = suite.transactions.Refund(transaction.ID, 0, "")
+	assert.Equal(suite.T(), models.ErrTransactionNotRefundable, err)
+}
+
+func TestFXRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(FXRepositoryTestSuite))
+}
@@ -0,0 +1,211 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type HoldRepository struct {
+	db *gorm.DB
+}
+
+func NewHoldRepository(db *gorm.DB) *HoldRepository {
+	return &HoldRepository{db: db}
+}
+
+func (r *HoldRepository) getHoldForUpdate(tx *gorm.DB, holdID, userID uuid.UUID) (*models.Hold, error) {
+	var hold models.Hold
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&hold, "id = ? AND user_id = ?", holdID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ActiveHoldsTotal sums the user's active holds in currency that have not
+// expired yet. Expired holds stop counting immediately, before the sweeper
+// marks them.
+func (r *HoldRepository) ActiveHoldsTotal(userID uuid.UUID, currency string) (models.Money, error) {
+	var total models.Money
+	err := r.db.Model(&models.Hold{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("user_id = ? AND currency = ? AND status = ? AND expires_at > ?", userID, currency, models.HoldActive, time.Now()).
+		Row().
+		Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// Create reserves amount of the user's available balance in currency until
+// ttl elapses.
+func (r *HoldRepository) Create(userID uuid.UUID, currency string, amount models.Money, remarks string, ttl time.Duration) (*models.Hold, error) {
+	var hold models.Hold
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		transactionRepo := NewTransactionRepository(tx)
+		user, err := transactionRepo.getUserForUpdate(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		wallet, err := NewWalletRepository(tx).getForUpdate(tx, userID, currency)
+		if err != nil {
+			return err
+		}
+
+		available, err := transactionRepo.availableBalance(tx, wallet)
+		if err != nil {
+			return err
+		}
+
+		// The payment fee is charged on capture, so make sure it is covered now
+		quote := transactionRepo.fees.Quote(models.PAYMENT, user.KYCTier, currency, amount)
+		if available < quote.Total {
+			return models.ErrInvalidTransaction
+		}
+
+		// The hold will be captured as a payment; refuse it up front if the
+		// payment limits wouldn't allow it
+		if err := NewLimitRepository(tx).Check(user, models.PAYMENT, currency, amount, time.Now()); err != nil {
+			return err
+		}
+
+		hold = models.Hold{
+			UserID:      userID,
+			Currency:    currency,
+			Amount:      amount,
+			Status:      models.HoldActive,
+			Description: remarks,
+			ExpiresAt:   time.Now().Add(ttl),
+		}
+		return tx.Create(&hold).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// Capture turns an active hold into a PAYMENT of amount, which may be smaller
+// than the held amount; the remainder is released. A zero amount captures the
+// full hold.
+func (r *HoldRepository) Capture(holdID, userID uuid.UUID, amount models.Money, remarks string) (*models.Hold, *models.Transaction, error) {
+	var hold *models.Hold
+	var transaction *models.Transaction
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the user before the hold, matching Create
+		transactionRepo := NewTransactionRepository(tx)
+		user, err := transactionRepo.getUserForUpdate(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		hold, err = r.getHoldForUpdate(tx, holdID, userID)
+		if err != nil {
+			return err
+		}
+		if hold.Status != models.HoldActive {
+			return models.ErrHoldNotActive
+		}
+		if hold.IsExpired(time.Now()) {
+			return models.ErrHoldExpired
+		}
+
+		if amount == 0 {
+			amount = hold.Amount
+		}
+		if amount > hold.Amount {
+			return models.ErrCaptureExceedsHold
+		}
+		if err := models.Currencies[hold.Currency].ValidateAmount(amount); err != nil {
+			return err
+		}
+		if remarks == "" {
+			remarks = hold.Description
+		}
+
+		// Release the hold first so the payment can spend the reserved funds
+		hold.Status = models.HoldCaptured
+		hold.CapturedAmount = amount
+		if err := tx.Model(hold).Updates(map[string]interface{}{
+			"status":          hold.Status,
+			"captured_amount": hold.CapturedAmount,
+		}).Error; err != nil {
+			return err
+		}
+
+		transaction, err = transactionRepo.debitPayment(tx, user, hold.Currency, amount, remarks, nil)
+		if err != nil {
+			return err
+		}
+
+		hold.TransactionID = &transaction.ID
+		return tx.Model(hold).Update("transaction_id", transaction.ID).Error
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return hold, transaction, nil
+}
+
+// Void releases an active hold without moving any money.
+func (r *HoldRepository) Void(holdID, userID uuid.UUID) (*models.Hold, error) {
+	var hold *models.Hold
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		hold, err = r.getHoldForUpdate(tx, holdID, userID)
+		if err != nil {
+			return err
+		}
+		if hold.Status != models.HoldActive {
+			return models.ErrHoldNotActive
+		}
+
+		hold.Status = models.HoldVoided
+		return tx.Model(hold).Update("status", hold.Status).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// FindByID returns one of the user's holds.
+func (r *HoldRepository) FindByID(holdID, userID uuid.UUID) (*models.Hold, error) {
+	var hold models.Hold
+	if err := r.db.First(&hold, "id = ? AND user_id = ?", holdID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// GetUserHolds lists the user's holds, newest first, optionally by status.
+func (r *HoldRepository) GetUserHolds(userID uuid.UUID, status string) ([]models.Hold, error) {
+	var holds []models.Hold
+	query := r.db.Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at desc").Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+// ExpireStale marks every active hold past its expiry as EXPIRED and returns
+// how many were updated.
+func (r *HoldRepository) ExpireStale(now time.Time) (int64, error) {
+	result := r.db.Model(&models.Hold{}).
+		Where("status = ? AND expires_at <= ?", models.HoldActive, now).
+		Update("status", models.HoldExpired)
+	return result.RowsAffected, result.Error
+}
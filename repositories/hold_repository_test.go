@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type HoldRepositoryTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	repository   *HoldRepository
+	transactions *TransactionRepository
+	user         *models.User
+}
+
+func (suite *HoldRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &HoldRepository{db: db}
+	suite.transactions = &TransactionRepository{db: db}
+
+	suite.user = &models.User{
+		ID:          uuid.New(),
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: "1234567890",
+		Address:     "123 Main St",
+		Pin:         "123456",
+		KYCTier:     models.KYCTierFull,
+	}
+	err = db.Create(suite.user).Error
+	assert.NoError(suite.T(), err)
+
+	_, _, _, err = suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000))
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *HoldRepositoryTestSuite) balance() models.Money {
+	var wallet models.Wallet
+	assert.NoError(suite.T(), suite.db.First(&wallet, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error)
+	return wallet.Balance
+}
+
+func (suite *HoldRepositoryTestSuite) TestHoldReducesAvailableButNotLedgerBalance() {
+	hold, err := suite.repository.Create(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(600), "Hotel", time.Hour)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.HoldActive, hold.Status)
+
+	held, err := suite.repository.ActiveHoldsTotal(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(600), held)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), suite.balance())
+
+	// Held funds can't be spent or held again
+	_, _, _, err = suite.transactions.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(500), "Shoes")
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+	_, err = suite.repository.Create(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(500), "Car", time.Hour)
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+
+	_, _, _, err = suite.transactions.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(400), "Shoes")
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *HoldRepositoryTestSuite) TestPartialCaptureReleasesRemainder() {
+	hold, err := suite.repository.Create(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(600), "Hotel", time.Hour)
+	assert.NoError(suite.T(), err)
+
+	captured, transaction, err := suite.repository.Capture(hold.ID, suite.user.ID, models.NewMoneyFromMajor(450), "")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.HoldCaptured, captured.Status)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(450), captured.CapturedAmount)
+	assert.Equal(suite.T(), transaction.ID, *captured.TransactionID)
+	assert.Equal(suite.T(), models.PAYMENT, transaction.TransactionType)
+	assert.Equal(suite.T(), "Hotel", transaction.Description)
+
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(550), suite.balance())
+	held, err := suite.repository.ActiveHoldsTotal(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.Money(0), held)
+
+	// A hold can only be captured once
+	_, _, err = suite.repository.Capture(hold.ID, suite.user.ID, 0, "")
+	assert.Equal(suite.T(), models.ErrHoldNotActive, err)
+
+	_, err = NewLedgerRepository(suite.db).VerifyWalletBalance(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *HoldRepositoryTestSuite) TestCaptureCannotExceedHold() {
+	hold, err := suite.repository.Create(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(100), "Taxi", time.Hour)
+	assert.NoError(suite.T(), err)
+
+	_, _, err = suite.repository.Capture(hold.ID, suite.user.ID, models.NewMoneyFromMajor(101), "")
+	assert.Equal(suite.T(), models.ErrCaptureExceedsHold, err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), suite.balance())
+}
+
+func (suite *HoldRepositoryTestSuite) TestVoidReleasesHold() {
+	hold, err := suite.repository.Create(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000), "Rental", time.Hour)
+	assert.NoError(suite.T(), err)
+
+	voided, err := suite.repository.Void(hold.ID, suite.user.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.HoldVoided, voided.Status)
+
+	_, _, err = suite.repository.Capture(hold.ID, suite.user.ID, 0, "")
+	assert.Equal(suite.T(), models.ErrHoldNotActive, err)
+	_, err = suite.repository.Void(hold.ID, suite.user.ID)
+	assert.Equal(suite.T(), models.ErrHoldNotActive, err)
+
+	_, _, _, err = suite.transactions.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000), "Everything")
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *HoldRepositoryTestSuite) TestHoldsAreScopedToOwner() {
+	hold, err := suite.repository.Create(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(10), "Snack", time.Hour)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.repository.Void(hold.ID, uuid.New())
+	assert.ErrorIs(suite.T(), err, gorm.ErrRecordNotFound)
+}
+
+func (suite *HoldRepositoryTestSuite) TestExpiredHoldsStopCountingAndAreSwept() {
+	hold, err := suite.repository.Create(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(300), "Parking", time.Hour)
+	assert.NoError(suite.T(), err)
+
+	// Backdate the expiry
+	err = suite.db.Model(hold).Update("expires_at", time.Now().Add(-time.Minute)).Error
+	assert.NoError(suite.T(), err)
+
+	held, err := suite.repository.ActiveHoldsTotal(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.Money(0), held)
+
+	_, _, err = suite.repository.Capture(hold.ID, suite.user.ID, 0, "")
+	assert.Equal(suite.T(), models.ErrHoldExpired, err)
+
+	expired, err := suite.repository.ExpireStale(time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), expired)
+
+	found, err := suite.repository.FindByID(hold.ID, suite.user.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.HoldExpired, found.Status)
+}
+
+func TestHoldRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(HoldRepositoryTestSuite))
+}
@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// FindByKey returns the stored record for a user's key, or
+// gorm.ErrRecordNotFound when the key has not been used yet.
+func (r *IdempotencyRepository) FindByKey(userID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := r.db.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Claim inserts the key before the request is processed. The unique index on
+// (user_id, key) makes a concurrent retry block until the first request
+// commits and then fail, so only one of them ever moves money.
+func (r *IdempotencyRepository) Claim(record *models.IdempotencyKey) error {
+	return r.db.Create(record).Error
+}
+
+// SaveResponse stores the response that will be replayed for retries.
+func (r *IdempotencyRepository) SaveResponse(record *models.IdempotencyKey, code int, body []byte) error {
+	record.ResponseCode = code
+	record.ResponseBody = string(body)
+	return r.db.Model(record).Updates(map[string]interface{}{
+		"response_code": code,
+		"response_body": record.ResponseBody,
+	}).Error
+}
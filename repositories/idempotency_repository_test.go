@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type IdempotencyRepositoryTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	repository *IdempotencyRepository
+	user       *models.User
+}
+
+func (suite *IdempotencyRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.IdempotencyKey{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &IdempotencyRepository{db: db}
+
+	suite.user = &models.User{
+		ID:          uuid.New(),
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: "1234567890",
+		Address:     "123 Main St",
+		Pin:         "123456",
+		KYCTier:     models.KYCTierFull,
+	}
+	err = db.Create(suite.user).Error
+	assert.NoError(suite.T(), err)
+
+	err = db.Create(&models.Wallet{UserID: suite.user.ID, Currency: models.DefaultCurrency, Balance: models.NewMoneyFromMajor(1000)}).Error
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *IdempotencyRepositoryTestSuite) TestClaimAndSaveResponse() {
+	record := &models.IdempotencyKey{
+		UserID:      suite.user.ID,
+		Key:         "key-1",
+		Endpoint:    "POST /api/v1/transactions/topup",
+		RequestHash: "hash",
+	}
+	assert.NoError(suite.T(), suite.repository.Claim(record))
+	assert.NoError(suite.T(), suite.repository.SaveResponse(record, 200, []byte(`{"status":"SUCCESS"}`)))
+
+	found, err := suite.repository.FindByKey(suite.user.ID, "key-1")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 200, found.ResponseCode)
+	assert.Equal(suite.T(), `{"status":"SUCCESS"}`, found.ResponseBody)
+	assert.Equal(suite.T(), "hash", found.RequestHash)
+}
+
+func (suite *IdempotencyRepositoryTestSuite) TestClaimSameKeyTwiceFails() {
+	first := &models.IdempotencyKey{UserID: suite.user.ID, Key: "key-1", Endpoint: "e", RequestHash: "a"}
+	second := &models.IdempotencyKey{UserID: suite.user.ID, Key: "key-1", Endpoint: "e", RequestHash: "b"}
+
+	assert.NoError(suite.T(), suite.repository.Claim(first))
+	assert.Error(suite.T(), suite.repository.Claim(second))
+
+	// The same key is independent per user
+	other := &models.IdempotencyKey{UserID: uuid.New(), Key: "key-1", Endpoint: "e", RequestHash: "a"}
+	assert.NoError(suite.T(), suite.repository.Claim(other))
+}
+
+func (suite *IdempotencyRepositoryTestSuite) TestFindByKeyNotFound() {
+	_, err := suite.repository.FindByKey(suite.user.ID, "missing")
+	assert.True(suite.T(), errors.Is(err, gorm.ErrRecordNotFound))
+}
+
+func (suite *IdempotencyRepositoryTestSuite) TestRollbackDiscardsKeyAndMoneyMovement() {
+	errAbort := errors.New("abort")
+
+	err := suite.db.Transaction(func(tx *gorm.DB) error {
+		record := &models.IdempotencyKey{UserID: suite.user.ID, Key: "key-1", Endpoint: "e", RequestHash: "a"}
+		if err := NewIdempotencyRepository(tx).Claim(record); err != nil {
+			return err
+		}
+		if _, _, _, err := NewTransactionRepository(tx).TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(500)); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.Equal(suite.T(), errAbort, err)
+
+	_, err = suite.repository.FindByKey(suite.user.ID, "key-1")
+	assert.True(suite.T(), errors.Is(err, gorm.ErrRecordNotFound))
+
+	var wallet models.Wallet
+	assert.NoError(suite.T(), suite.db.First(&wallet, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), wallet.Balance)
+
+	var count int64
+	assert.NoError(suite.T(), suite.db.Model(&models.Transaction{}).Count(&count).Error)
+	assert.Equal(suite.T(), int64(0), count)
+}
+
+func TestIdempotencyRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(IdempotencyRepositoryTestSuite))
+}
@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type KYCRepository struct {
+	db *gorm.DB
+}
+
+func NewKYCRepository(db *gorm.DB) *KYCRepository {
+	return &KYCRepository{db: db}
+}
+
+func (r *KYCRepository) getSubmissionForUpdate(tx *gorm.DB, submissionID uuid.UUID) (*models.KYCSubmission, error) {
+	var submission models.KYCSubmission
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// Submit stores a pending submission and moves the user to PENDING. A user
+// can only have one submission under review, and it must ask for a higher
+// tier than the one they have.
+func (r *KYCRepository) Submit(submission *models.KYCSubmission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		user, err := NewTransactionRepository(tx).getUserForUpdate(tx, submission.UserID)
+		if err != nil {
+			return err
+		}
+		if user.KYCStatus == models.KYCStatusPending {
+			return models.ErrKYCAlreadyPending
+		}
+		if !models.IsHigherKYCTier(submission.RequestedTier, user.KYCTier) {
+			return models.ErrKYCTierNotHigher
+		}
+
+		submission.Status = models.KYCStatusPending
+		if err := tx.Create(submission).Error; err != nil {
+			return err
+		}
+		return tx.Model(user).Update("kyc_status", models.KYCStatusPending).Error
+	})
+}
+
+// Approve accepts a pending submission and upgrades the user to the
+// requested tier.
+func (r *KYCRepository) Approve(submissionID, reviewerID uuid.UUID) (*models.KYCSubmission, error) {
+	return r.review(submissionID, reviewerID, models.KYCStatusApproved, "")
+}
+
+// Reject turns down a pending submission; the user keeps their tier and may
+// submit again.
+func (r *KYCRepository) Reject(submissionID, reviewerID uuid.UUID, reason string) (*models.KYCSubmission, error) {
+	return r.review(submissionID, reviewerID, models.KYCStatusRejected, reason)
+}
+
+func (r *KYCRepository) review(submissionID, reviewerID uuid.UUID, status, reason string) (*models.KYCSubmission, error) {
+	var submission *models.KYCSubmission
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		submission, err = r.getSubmissionForUpdate(tx, submissionID)
+		if err != nil {
+			return err
+		}
+		if submission.Status != models.KYCStatusPending {
+			return models.ErrKYCNotPending
+		}
+		if submission.UserID == reviewerID {
+			return models.ErrKYCSelfReview
+		}
+
+		user, err := NewTransactionRepository(tx).getUserForUpdate(tx, submission.UserID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		submission.Status = status
+		submission.RejectionReason = reason
+		submission.ReviewedBy = &reviewerID
+		submission.ReviewedAt = &now
+		if err := tx.Save(submission).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"kyc_status":      status,
+			"kyc_reviewed_at": now,
+		}
+		if status == models.KYCStatusApproved {
+			updates["kyc_tier"] = submission.RequestedTier
+		}
+		return tx.Model(user).Updates(updates).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+func (r *KYCRepository) FindByID(submissionID uuid.UUID) (*models.KYCSubmission, error) {
+	var submission models.KYCSubmission
+	if err := r.db.First(&submission, "id = ?", submissionID).Error; err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// GetUserSubmissions lists the user's submissions, newest first.
+func (r *KYCRepository) GetUserSubmissions(userID uuid.UUID) ([]models.KYCSubmission, error) {
+	var submissions []models.KYCSubmission
+	if err := r.db.Where("user_id = ?", userID).Order("created_at desc").Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+// GetSubmissions lists submissions for review, oldest first so the queue is
+// worked in order. An empty status lists all of them.
+func (r *KYCRepository) GetSubmissions(status string, page, limit int) ([]models.KYCSubmission, int64, error) {
+	var submissions []models.KYCSubmission
+	var total int64
+
+	query := r.db.Model(&models.KYCSubmission{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at asc").Offset(offset).Limit(limit).Find(&submissions).Error; err != nil {
+		return nil, 0, err
+	}
+	return submissions, total, nil
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type KYCRepositoryTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	repository *KYCRepository
+	user       *models.User
+	admin      *models.User
+}
+
+func (suite *KYCRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.KYCSubmission{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &KYCRepository{db: db}
+
+	suite.user = suite.createUser("1234567890")
+	suite.admin = suite.createUser("0987654321")
+}
+
+func (suite *KYCRepositoryTestSuite) createUser(phone string) *models.User {
+	user := &models.User{
+		ID:          uuid.New(),
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: phone,
+		Address:     "123 Main St",
+		Pin:         "123456",
+	}
+	assert.NoError(suite.T(), suite.db.Create(user).Error)
+	return user
+}
+
+func (suite *KYCRepositoryTestSuite) newSubmission(tier string) *models.KYCSubmission {
+	return &models.KYCSubmission{
+		UserID:           suite.user.ID,
+		RequestedTier:    tier,
+		DocumentType:     models.DocumentTypeNationalID,
+		DocumentNumber:   "3171234567890001",
+		FullName:         "John Doe",
+		DateOfBirth:      time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC),
+		DocumentImageKey: "kyc/document.jpg",
+		SelfieImageKey:   "kyc/selfie.jpg",
+	}
+}
+
+func (suite *KYCRepositoryTestSuite) reloadUser() *models.User {
+	var user models.User
+	assert.NoError(suite.T(), suite.db.First(&user, "id = ?", suite.user.ID).Error)
+	return &user
+}
+
+func (suite *KYCRepositoryTestSuite) TestSubmit() {
+	submission := suite.newSubmission(models.KYCTierBasic)
+	assert.NoError(suite.T(), suite.repository.Submit(submission))
+	assert.Equal(suite.T(), models.KYCStatusPending, submission.Status)
+
+	user := suite.reloadUser()
+	assert.Equal(suite.T(), models.KYCStatusPending, user.KYCStatus)
+	assert.Equal(suite.T(), models.KYCTierUnverified, user.KYCTier)
+
+	// Only one submission may be under review at a time
+	err := suite.repository.Submit(suite.newSubmission(models.KYCTierFull))
+	assert.Equal(suite.T(), models.ErrKYCAlreadyPending, err)
+}
+
+func (suite *KYCRepositoryTestSuite) TestSubmitRequiresHigherTier() {
+	err := suite.repository.Submit(suite.newSubmission(models.KYCTierUnverified))
+	assert.Equal(suite.T(), models.ErrKYCTierNotHigher, err)
+
+	err = suite.repository.Submit(suite.newSubmission("GOLD"))
+	assert.Equal(suite.T(), models.ErrKYCTierNotHigher, err)
+
+	assert.Equal(suite.T(), models.KYCStatusNone, suite.reloadUser().KYCStatus)
+}
+
+func (suite *KYCRepositoryTestSuite) TestApprove() {
+	submission := suite.newSubmission(models.KYCTierFull)
+	assert.NoError(suite.T(), suite.repository.Submit(submission))
+
+	approved, err := suite.repository.Approve(submission.ID, suite.admin.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.KYCStatusApproved, approved.Status)
+	assert.Equal(suite.T(), suite.admin.ID, *approved.ReviewedBy)
+	assert.NotNil(suite.T(), approved.ReviewedAt)
+
+	user := suite.reloadUser()
+	assert.Equal(suite.T(), models.KYCTierFull, user.KYCTier)
+	assert.Equal(suite.T(), models.KYCStatusApproved, user.KYCStatus)
+	assert.NotNil(suite.T(), user.KYCReviewedAt)
+
+	// A reviewed submission can't be reviewed again
+	_, err = suite.repository.Reject(submission.ID, suite.admin.ID, "too late")
+	assert.Equal(suite.T(), models.ErrKYCNotPending, err)
+
+	// Nor can the user ask for a tier they already have
+	err = suite.repository.Submit(suite.newSubmission(models.KYCTierBasic))
+	assert.Equal(suite.T(), models.ErrKYCTierNotHigher, err)
+}
+
+func (suite *KYCRepositoryTestSuite) TestRejectThenResubmit() {
+	submission := suite.newSubmission(models.KYCTierBasic)
+	assert.NoError(suite.T(), suite.repository.Submit(submission))
+
+	rejected, err := suite.repository.Reject(submission.ID, suite.admin.ID, "Document image is blurry")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.KYCStatusRejected, rejected.Status)
+	assert.Equal(suite.T(), "Document image is blurry", rejected.RejectionReason)
+
+	user := suite.reloadUser()
+	assert.Equal(suite.T(), models.KYCTierUnverified, user.KYCTier)
+	assert.Equal(suite.T(), models.KYCStatusRejected, user.KYCStatus)
+
+	assert.NoError(suite.T(), suite.repository.Submit(suite.newSubmission(models.KYCTierBasic)))
+
+	submissions, err := suite.repository.GetUserSubmissions(suite.user.ID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), submissions, 2)
+}
+
+func (suite *KYCRepositoryTestSuite) TestSelfReviewBlocked() {
+	submission := suite.newSubmission(models.KYCTierBasic)
+	assert.NoError(suite.T(), suite.repository.Submit(submission))
+
+	_, err := suite.repository.Approve(submission.ID, suite.user.ID)
+	assert.Equal(suite.T(), models.ErrKYCSelfReview, err)
+	assert.Equal(suite.T(), models.KYCTierUnverified, suite.reloadUser().KYCTier)
+}
+
+func (suite *KYCRepositoryTestSuite) TestGetSubmissions() {
+	first := suite.newSubmission(models.KYCTierBasic)
+	assert.NoError(suite.T(), suite.repository.Submit(first))
+	_, err := suite.repository.Reject(first.ID, suite.admin.ID, "Expired document")
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.repository.Submit(suite.newSubmission(models.KYCTierBasic)))
+
+	pending, total, err := suite.repository.GetSubmissions(models.KYCStatusPending, 1, 10)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), total)
+	assert.Len(suite.T(), pending, 1)
+
+	all, total, err := suite.repository.GetSubmissions("", 1, 10)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(2), total)
+	assert.Len(suite.T(), all, 2)
+}
+
+func TestKYCRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(KYCRepositoryTestSuite))
+}
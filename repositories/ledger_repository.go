@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// UserAccount returns the account of a user's wallet in currency, creating
+// it on first use. Callers moving money must already hold the user's row lock.
+func (r *LedgerRepository) UserAccount(userID uuid.UUID, currency string) (*models.LedgerAccount, error) {
+	return r.getOrCreateAccount(models.LedgerAccount{
+		Code:     models.UserAccountCode(userID, currency),
+		Type:     models.AccountTypeUser,
+		Currency: currency,
+		UserID:   &userID,
+		Name:     "User wallet",
+	})
+}
+
+// SystemAccount returns the system account with the given code in currency,
+// creating it on first use.
+func (r *LedgerRepository) SystemAccount(code, currency string) (*models.LedgerAccount, error) {
+	return r.getOrCreateAccount(models.LedgerAccount{
+		Code:     models.SystemAccountCode(code, currency),
+		Type:     models.AccountTypeSystem,
+		Currency: currency,
+		Name:     code,
+	})
+}
+
+// getOrCreateAccount finds the account with account's code, inserting it when
+// it doesn't exist yet. Two first postings to a new account can race, so the
+// insert skips an account created meanwhile and the account is read again with
+// a locking read, which sees rows committed after this DB transaction began.
+func (r *LedgerRepository) getOrCreateAccount(account models.LedgerAccount) (*models.LedgerAccount, error) {
+	var found models.LedgerAccount
+	err := r.db.Where("code = ?", account.Code).First(&found).Error
+	if err == nil {
+		return &found, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return nil, err
+	}
+	found = models.LedgerAccount{}
+	if err := r.db.Set("gorm:query_option", "FOR UPDATE").Where("code = ?", account.Code).First(&found).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// Post validates and records a journal entry with its postings.
+func (r *LedgerRepository) Post(entry *models.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	return r.db.Create(entry).Error
+}
+
+// Move posts a two-leg journal moving amount from one account to another in
+// the same currency.
+func (r *LedgerRepository) Move(kind string, transactionID *uuid.UUID, description string, from, to *models.LedgerAccount, amount models.Money) (*models.JournalEntry, error) {
+	if from.Currency != to.Currency {
+		return nil, models.ErrCurrencyMismatch
+	}
+	entry := &models.JournalEntry{
+		Kind:          kind,
+		TransactionID: transactionID,
+		Description:   description,
+		Postings: []models.Posting{
+			{AccountID: from.ID, Amount: -amount},
+			{AccountID: to.ID, Amount: amount},
+		},
+	}
+	if err := r.Post(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// PostOpeningBalance records a wallet's pre-ledger balance against the
+// opening balance system account.
+func (r *LedgerRepository) PostOpeningBalance(userID uuid.UUID, currency string, amount models.Money) (*models.JournalEntry, error) {
+	userAccount, err := r.UserAccount(userID, currency)
+	if err != nil {
+		return nil, err
+	}
+	openingAccount, err := r.SystemAccount(models.SystemAccountOpeningBalance, currency)
+	if err != nil {
+		return nil, err
+	}
+	return r.Move(models.JournalOpeningBalance, nil, "Opening balance", openingAccount, userAccount, amount)
+}
+
+// AccountBalance derives an account balance from its postings.
+func (r *LedgerRepository) AccountBalance(accountID uuid.UUID) (models.Money, error) {
+	var balance models.Money
+	err := r.db.Model(&models.Posting{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("account_id = ?", accountID).
+		Row().
+		Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// WalletBalance derives the balance of a user's wallet in currency from the
+// ledger.
+func (r *LedgerRepository) WalletBalance(userID uuid.UUID, currency string) (models.Money, error) {
+	var account models.LedgerAccount
+	err := r.db.Where("code = ?", models.UserAccountCode(userID, currency)).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return r.AccountBalance(account.ID)
+}
+
+// VerifyWalletBalance returns the ledger balance of a user's wallet in
+// currency and models.ErrBalanceMismatch when it differs from the cached
+// wallets.balance.
+func (r *LedgerRepository) VerifyWalletBalance(userID uuid.UUID, currency string) (models.Money, error) {
+	wallet, err := NewWalletRepository(r.db).Find(userID, currency)
+	if err != nil {
+		return 0, err
+	}
+
+	ledgerBalance, err := r.WalletBalance(userID, currency)
+	if err != nil {
+		return 0, err
+	}
+	if ledgerBalance != wallet.Balance {
+		return ledgerBalance, models.ErrBalanceMismatch
+	}
+	return ledgerBalance, nil
+}
+
+// FindBalanceMismatches lists every wallet whose cached balance does not
+// match the ledger.
+func (r *LedgerRepository) FindBalanceMismatches() ([]models.BalanceMismatch, error) {
+	var rows []models.BalanceMismatch
+	err := r.db.Table("wallets").
+		Select("wallets.user_id AS user_id, wallets.currency AS currency, wallets.balance AS cached_balance, COALESCE(SUM(postings.amount), 0) AS ledger_balance").
+		Joins("LEFT JOIN ledger_accounts ON ledger_accounts.user_id = wallets.user_id AND ledger_accounts.currency = wallets.currency").
+		Joins("LEFT JOIN postings ON postings.account_id = ledger_accounts.id").
+		Group("wallets.user_id, wallets.currency, wallets.balance").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := []models.BalanceMismatch{}
+	for _, row := range rows {
+		if row.CachedBalance != row.LedgerBalance {
+			mismatches = append(mismatches, row)
+		}
+	}
+	return mismatches, nil
+}
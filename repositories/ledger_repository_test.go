@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type LedgerRepositoryTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	repository   *LedgerRepository
+	transactions *TransactionRepository
+	user         *models.User
+}
+
+func (suite *LedgerRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &LedgerRepository{db: db}
+	suite.transactions = &TransactionRepository{db: db}
+
+	suite.user = &models.User{
+		ID:          uuid.New(),
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: "1234567890",
+		Address:     "123 Main St",
+		Pin:         "123456",
+		KYCTier:     models.KYCTierFull,
+	}
+	err = db.Create(suite.user).Error
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *LedgerRepositoryTestSuite) TestPostRejectsUnbalancedJournal() {
+	a, err := suite.repository.SystemAccount(models.SystemAccountTopUpFunding, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	b, err := suite.repository.UserAccount(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+
+	err = suite.repository.Post(&models.JournalEntry{
+		Kind: models.JournalTopUp,
+		Postings: []models.Posting{
+			{AccountID: a.ID, Amount: -100},
+			{AccountID: b.ID, Amount: 99},
+		},
+	})
+	assert.Equal(suite.T(), models.ErrUnbalancedJournal, err)
+
+	err = suite.repository.Post(&models.JournalEntry{
+		Kind:     models.JournalTopUp,
+		Postings: []models.Posting{{AccountID: a.ID, Amount: 0}, {AccountID: b.ID, Amount: 0}},
+	})
+	assert.Equal(suite.T(), models.ErrInvalidPosting, err)
+
+	var count int64
+	assert.NoError(suite.T(), suite.db.Model(&models.Posting{}).Count(&count).Error)
+	assert.Equal(suite.T(), int64(0), count)
+}
+
+func (suite *LedgerRepositoryTestSuite) TestAccountsAreCreatedOnce() {
+	first, err := suite.repository.UserAccount(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	second, err := suite.repository.UserAccount(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), first.ID, second.ID)
+	assert.Equal(suite.T(), models.AccountTypeUser, first.Type)
+
+	// A system account is created in a new currency on first use
+	fx, err := suite.repository.SystemAccount(models.SystemAccountFXPosition, "USD")
+	assert.NoError(suite.T(), err)
+	var stored models.LedgerAccount
+	assert.NoError(suite.T(), suite.db.First(&stored, "code = ?", models.SystemAccountCode(models.SystemAccountFXPosition, "USD")).Error)
+	assert.Equal(suite.T(), stored.ID, fx.ID)
+	assert.Equal(suite.T(), "USD", fx.Currency)
+}
+
+func (suite *LedgerRepositoryTestSuite) TestTransactionsPostBalancedJournals() {
+	recipient := &models.User{
+		ID:          uuid.New(),
+		FirstName:   "Jane",
+		LastName:    "Doe",
+		PhoneNumber: "0987654321",
+		Address:     "456 Main St",
+		Pin:         "123456",
+		KYCTier:     models.KYCTierFull,
+	}
+	assert.NoError(suite.T(), suite.db.Create(recipient).Error)
+
+	_, _, _, err := suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.MustParseMoney("100.00"))
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.Payment(suite.user.ID, models.DefaultCurrency, models.MustParseMoney("30.25"), "Coffee")
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.Transfer(suite.user.ID, models.DefaultCurrency, models.MustParseMoney("19.75"), recipient.ID, "Lunch")
+	assert.NoError(suite.T(), err)
+
+	// Every journal sums to zero, so all postings do too
+	var entries []models.JournalEntry
+	assert.NoError(suite.T(), suite.db.Preload("Postings").Find(&entries).Error)
+	assert.Len(suite.T(), entries, 3)
+	for _, entry := range entries {
+		assert.NoError(suite.T(), entry.Validate())
+		assert.NotNil(suite.T(), entry.TransactionID)
+	}
+
+	senderBalance, err := suite.repository.VerifyWalletBalance(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.MustParseMoney("50.00"), senderBalance)
+
+	recipientBalance, err := suite.repository.VerifyWalletBalance(recipient.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.MustParseMoney("19.75"), recipientBalance)
+
+	funding, err := suite.repository.SystemAccount(models.SystemAccountTopUpFunding, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	fundingBalance, err := suite.repository.AccountBalance(funding.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.MustParseMoney("-100.00"), fundingBalance)
+
+	settlement, err := suite.repository.SystemAccount(models.SystemAccountPaymentSettlement, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	settlementBalance, err := suite.repository.AccountBalance(settlement.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.MustParseMoney("30.25"), settlementBalance)
+}
+
+func (suite *LedgerRepositoryTestSuite) TestFailedPaymentPostsNothing() {
+	_, _, _, err := suite.transactions.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(10), "Too much")
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+
+	var count int64
+	assert.NoError(suite.T(), suite.db.Model(&models.JournalEntry{}).Count(&count).Error)
+	assert.Equal(suite.T(), int64(0), count)
+}
+
+func (suite *LedgerRepositoryTestSuite) TestDetectsBalanceMismatch() {
+	_, _, _, err := suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(50))
+	assert.NoError(suite.T(), err)
+
+	// Tamper with the cached balance outside of the ledger
+	err = suite.db.Model(&models.Wallet{}).Where("user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Update("balance", models.NewMoneyFromMajor(80)).Error
+	assert.NoError(suite.T(), err)
+
+	ledgerBalance, err := suite.repository.VerifyWalletBalance(suite.user.ID, models.DefaultCurrency)
+	assert.Equal(suite.T(), models.ErrBalanceMismatch, err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(50), ledgerBalance)
+
+	mismatches, err := suite.repository.FindBalanceMismatches()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), mismatches, 1)
+	assert.Equal(suite.T(), suite.user.ID, mismatches[0].UserID)
+	assert.Equal(suite.T(), models.DefaultCurrency, mismatches[0].Currency)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(80), mismatches[0].CachedBalance)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(50), mismatches[0].LedgerBalance)
+}
+
+func TestLedgerRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(LedgerRepositoryTestSuite))
+}
@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// limitDirection is the side of the user's rows that counts towards each
+// limited transaction type: money coming in for top ups, going out otherwise.
+var limitDirection = map[string]string{
+	models.TOPUP:    models.CREDIT,
+	models.PAYMENT:  models.DEBIT,
+	models.TRANSFER: models.DEBIT,
+}
+
+type LimitRepository struct {
+	db *gorm.DB
+}
+
+func NewLimitRepository(db *gorm.DB) *LimitRepository {
+	return &LimitRepository{db: db}
+}
+
+// currencyTotal is one currency's share of a sum over several currencies
+type currencyTotal struct {
+	Currency string
+	Total    models.Money
+}
+
+// Used sums the user's transactions of a limited type since the given time,
+// in the default currency with every currency at its limit rate. Reversed
+// transactions don't count.
+func (r *LimitRepository) Used(userID uuid.UUID, transactionType string, since time.Time) (models.Money, error) {
+	var totals []currencyTotal
+	err := r.db.Model(&models.Transaction{}).
+		Select("currency, COALESCE(SUM(amount), 0) AS total").
+		Where("user_id = ? AND transaction_type = ? AND type = ? AND status <> ? AND created_at >= ?",
+			userID, transactionType, limitDirection[transactionType], models.REVERSED, since).
+		Group("currency").
+		Scan(&totals).Error
+	if err != nil {
+		return 0, err
+	}
+	return limitValueOf(totals)
+}
+
+// holdings values the user's wallets at their limit rates, leaving out the
+// wallet in exceptCurrency if it is set.
+func (r *LimitRepository) holdings(userID uuid.UUID, exceptCurrency string) (models.Money, error) {
+	var totals []currencyTotal
+	err := r.db.Model(&models.Wallet{}).
+		Select("currency, balance AS total").
+		Where("user_id = ? AND currency <> ?", userID, exceptCurrency).
+		Scan(&totals).Error
+	if err != nil {
+		return 0, err
+	}
+	return limitValueOf(totals)
+}
+
+func limitValueOf(totals []currencyTotal) (models.Money, error) {
+	var sum models.Money
+	for _, total := range totals {
+		value, err := models.LimitValue(total.Currency, total.Total)
+		if err != nil {
+			return 0, err
+		}
+		sum += value
+	}
+	return sum, nil
+}
+
+// Check returns a *models.LimitExceededError when the user may not make a
+// transaction of amount in currency under their tier's limits. Limits are set
+// in the default currency and other currencies count at their limit rate, so
+// Remaining is in the default currency. It must run in the same DB
+// transaction as the movement, with the user locked.
+func (r *LimitRepository) Check(user *models.User, transactionType, currency string, amount models.Money, now time.Time) error {
+	value, err := models.LimitValue(currency, amount)
+	if err != nil {
+		return err
+	}
+
+	summary, err := r.transactionSummary(user, transactionType, now)
+	if err != nil {
+		return err
+	}
+
+	exceeded := func(limit string, remaining models.Money) error {
+		return &models.LimitExceededError{Limit: limit, TransactionType: transactionType, Remaining: remaining}
+	}
+	switch {
+	case value > summary.PerTransaction:
+		return exceeded(models.LimitPerTransaction, summary.PerTransaction)
+	case value > summary.Daily.Remaining:
+		return exceeded(models.LimitDaily, summary.Daily.Remaining)
+	case value > summary.Monthly.Remaining:
+		return exceeded(models.LimitMonthly, summary.Monthly.Remaining)
+	}
+	return nil
+}
+
+// CheckBalance returns a *models.LimitExceededError when balanceAfter in
+// wallet would put the user's holdings over their tier's maximum balance.
+// Every wallet counts at its limit rate; the user's other wallets are read as
+// they stand in the DB transaction, so a move between the user's own wallets
+// must update the source wallet first. limit is the limit kind to report.
+func (r *LimitRepository) CheckBalance(user *models.User, wallet *models.Wallet, balanceAfter models.Money, limit string) error {
+	others, err := r.holdings(user.ID, wallet.Currency)
+	if err != nil {
+		return err
+	}
+	before, err := models.LimitValue(wallet.Currency, wallet.Balance)
+	if err != nil {
+		return err
+	}
+	after, err := models.LimitValue(wallet.Currency, balanceAfter)
+	if err != nil {
+		return err
+	}
+
+	maxBalance := models.LimitsForTier(user.KYCTier).MaxBalance
+	if others+after > maxBalance {
+		return &models.LimitExceededError{Limit: limit, Remaining: headroom(maxBalance, others+before)}
+	}
+	return nil
+}
+
+// Summary reports the user's limits and how much of each is left, with their
+// holdings in every currency counting towards the maximum balance.
+func (r *LimitRepository) Summary(user *models.User, now time.Time) (*models.LimitSummary, error) {
+	balance, err := r.holdings(user.ID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	limits := models.LimitsForTier(user.KYCTier)
+	summary := &models.LimitSummary{
+		Tier:            user.KYCTier,
+		Currency:        models.DefaultCurrency,
+		MaxBalance:      limits.MaxBalance,
+		Balance:         balance,
+		BalanceHeadroom: headroom(limits.MaxBalance, balance),
+		Transactions:    make(map[string]models.TransactionLimitSummary, len(models.LimitedTransactionTypes)),
+	}
+
+	for _, transactionType := range models.LimitedTransactionTypes {
+		transaction, err := r.transactionSummary(user, transactionType, now)
+		if err != nil {
+			return nil, err
+		}
+		// A top up is also capped by the balance headroom
+		if transactionType == models.TOPUP && summary.BalanceHeadroom < transaction.MaxAmount {
+			transaction.MaxAmount = summary.BalanceHeadroom
+		}
+		summary.Transactions[transactionType] = *transaction
+	}
+	return summary, nil
+}
+
+func (r *LimitRepository) transactionSummary(user *models.User, transactionType string, now time.Time) (*models.TransactionLimitSummary, error) {
+	limit := models.LimitsForTier(user.KYCTier).Transactions[transactionType]
+
+	daily, err := r.usage(user.ID, transactionType, limit.Daily, startOfDay(now))
+	if err != nil {
+		return nil, err
+	}
+	monthly, err := r.usage(user.ID, transactionType, limit.Monthly, startOfMonth(now))
+	if err != nil {
+		return nil, err
+	}
+
+	maxAmount := limit.PerTransaction
+	for _, remaining := range []models.Money{daily.Remaining, monthly.Remaining} {
+		if remaining < maxAmount {
+			maxAmount = remaining
+		}
+	}
+
+	return &models.TransactionLimitSummary{
+		PerTransaction: limit.PerTransaction,
+		Daily:          daily,
+		Monthly:        monthly,
+		MaxAmount:      maxAmount,
+	}, nil
+}
+
+func (r *LimitRepository) usage(userID uuid.UUID, transactionType string, limit models.Money, since time.Time) (models.LimitUsage, error) {
+	used, err := r.Used(userID, transactionType, since)
+	if err != nil {
+		return models.LimitUsage{}, err
+	}
+	return models.LimitUsage{Limit: limit, Used: used, Remaining: headroom(limit, used)}, nil
+}
+
+func headroom(limit, used models.Money) models.Money {
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type LimitRepositoryTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	repository   *LimitRepository
+	transactions *TransactionRepository
+	user         *models.User
+	recipient    *models.User
+}
+
+func (suite *LimitRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.FXQuote{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &LimitRepository{db: db}
+	suite.transactions = &TransactionRepository{db: db}
+
+	suite.user = suite.createUser("1234567890", models.KYCTierBasic)
+	suite.recipient = suite.createUser("0987654321", models.KYCTierUnverified)
+}
+
+func (suite *LimitRepositoryTestSuite) createUser(phone, tier string) *models.User {
+	user := &models.User{
+		ID:          uuid.New(),
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: phone,
+		Address:     "123 Main St",
+		Pin:         "123456",
+		KYCTier:     tier,
+	}
+	assert.NoError(suite.T(), suite.db.Create(user).Error)
+	return user
+}
+
+func (suite *LimitRepositoryTestSuite) assertLimit(err error, limit string, remaining models.Money) {
+	var exceeded *models.LimitExceededError
+	if assert.True(suite.T(), errors.As(err, &exceeded), "expected LimitExceededError, got %v", err) {
+		assert.True(suite.T(), errors.Is(err, models.ErrLimitExceeded))
+		assert.Equal(suite.T(), limit, exceeded.Limit)
+		assert.Equal(suite.T(), remaining, exceeded.Remaining)
+	}
+}
+
+func (suite *LimitRepositoryTestSuite) TestPerTransactionLimit() {
+	_, _, _, err := suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(5000001))
+	suite.assertLimit(err, models.LimitPerTransaction, models.NewMoneyFromMajor(5000000))
+
+	_, _, _, err = suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(5000000))
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *LimitRepositoryTestSuite) TestDailyLimitAccumulates() {
+	_, _, _, err := suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(5000000))
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(5000000))
+	assert.NoError(suite.T(), err)
+
+	for i := 0; i < 2; i++ {
+		_, _, _, err = suite.transactions.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(2000000), suite.recipient.ID, "")
+		if i == 0 {
+			assert.NoError(suite.T(), err)
+		}
+	}
+	// The second transfer is over the unverified recipient's balance cap
+	suite.assertLimit(err, models.LimitRecipientMaxBalance, 0)
+
+	other := suite.createUser("5555555555", models.KYCTierFull)
+	_, _, _, err = suite.transactions.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(2500000), other.ID, "")
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000000), other.ID, "")
+	suite.assertLimit(err, models.LimitDaily, models.NewMoneyFromMajor(500000))
+
+	// Yesterday's transfers don't count against today
+	err = suite.db.Model(&models.Transaction{}).
+		Where("user_id = ? AND transaction_type = ?", suite.user.ID, models.TRANSFER).
+		Update("created_at", time.Now().AddDate(0, 0, -1)).Error
+	assert.NoError(suite.T(), err)
+
+	used, err := suite.repository.Used(suite.user.ID, models.TRANSFER, startOfDay(time.Now()))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.Money(0), used)
+}
+
+func (suite *LimitRepositoryTestSuite) TestMaxBalanceOnTopUp() {
+	_, _, _, err := suite.transactions.TopUp(suite.recipient.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000000))
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.TopUp(suite.recipient.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000000))
+	assert.NoError(suite.T(), err)
+
+	_, _, _, err = suite.transactions.TopUp(suite.recipient.ID, models.DefaultCurrency, models.MustParseMoney("0.01"))
+	suite.assertLimit(err, models.LimitMaxBalance, 0)
+}
+
+func (suite *LimitRepositoryTestSuite) TestUnverifiedUsersCannotTransfer() {
+	_, _, _, err := suite.transactions.TopUp(suite.recipient.ID, models.DefaultCurrency, models.NewMoneyFromMajor(100))
+	assert.NoError(suite.T(), err)
+
+	_, _, _, err = suite.transactions.Transfer(suite.recipient.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1), suite.user.ID, "")
+	suite.assertLimit(err, models.LimitPerTransaction, 0)
+}
+
+func (suite *LimitRepositoryTestSuite) TestHoldsAreCheckedAgainstPaymentLimits() {
+	_, _, _, err := suite.transactions.TopUp(suite.recipient.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000000))
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.TopUp(suite.recipient.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000000))
+	assert.NoError(suite.T(), err)
+
+	_, err = NewHoldRepository(suite.db).Create(suite.recipient.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1500000), "Hotel", time.Hour)
+	suite.assertLimit(err, models.LimitPerTransaction, models.NewMoneyFromMajor(1000000))
+}
+
+func (suite *LimitRepositoryTestSuite) TestRefundIsCheckedAgainstMaxBalance() {
+	_, _, _, err := suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(3000000))
+	assert.NoError(suite.T(), err)
+	transfer, _, _, err := suite.transactions.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000), suite.recipient.ID, "Lunch")
+	assert.NoError(suite.T(), err)
+
+	// Downgraded below what they already hold, the refund can't be credited
+	assert.NoError(suite.T(), suite.db.Model(suite.user).Update("kyc_tier", models.KYCTierUnverified).Error)
+	_, _, err = suite.transactions.Refund(transfer.ID, 0, "")
+	suite.assertLimit(err, models.LimitMaxBalance, 0)
+}
+
+func (suite *LimitRepositoryTestSuite) TestSummary() {
+	_, _, _, err := suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(3000000))
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(250), "Groceries")
+	assert.NoError(suite.T(), err)
+
+	var user models.User
+	assert.NoError(suite.T(), suite.db.First(&user, "id = ?", suite.user.ID).Error)
+
+	summary, err := suite.repository.Summary(&user, time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.KYCTierBasic, summary.Tier)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(10000000), summary.MaxBalance)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(7000250), summary.BalanceHeadroom)
+
+	topUp := summary.Transactions[models.TOPUP]
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(3000000), topUp.Daily.Used)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(7000000), topUp.Daily.Remaining)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(37000000), topUp.Monthly.Remaining)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(5000000), topUp.MaxAmount)
+
+	payment := summary.Transactions[models.PAYMENT]
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(250), payment.Daily.Used)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(5000000), payment.MaxAmount)
+}
+
+func (suite *LimitRepositoryTestSuite) TestOtherCurrenciesCountTowardsLimits() {
+	user := suite.createUser("1122334455", models.KYCTierFull)
+	_, err := NewWalletRepository(suite.db).Open(user.ID, "USD")
+	assert.NoError(suite.T(), err)
+
+	// 17,000 IDR per USD, so USD 600 is IDR 10,200,000
+	_, _, _, err = suite.transactions.TopUp(user.ID, "USD", models.NewMoneyFromMajor(600))
+	suite.assertLimit(err, models.LimitPerTransaction, models.NewMoneyFromMajor(10000000))
+
+	_, _, _, err = suite.transactions.TopUp(user.ID, "USD", models.NewMoneyFromMajor(500))
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.TopUp(user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(10000000))
+	assert.NoError(suite.T(), err)
+
+	_, _, _, err = suite.transactions.Payment(user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(5000000), "Rent")
+	assert.NoError(suite.T(), err)
+
+	// IDR 8,500,000 and IDR 10,000,000 topped up today
+	_, _, _, err = suite.transactions.TopUp(user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(2000000))
+	suite.assertLimit(err, models.LimitDaily, models.NewMoneyFromMajor(1500000))
+
+	summary, err := suite.repository.Summary(user, time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(6500000), summary.BalanceHeadroom)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(18500000), summary.Transactions[models.TOPUP].Daily.Used)
+}
+
+func (suite *LimitRepositoryTestSuite) TestBalanceLimitCountsEveryWallet() {
+	user := suite.createUser("1122334455", models.KYCTierFull)
+	_, err := NewWalletRepository(suite.db).Open(user.ID, "USD")
+	assert.NoError(suite.T(), err)
+
+	_, _, _, err = suite.transactions.TopUp(user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(10000000))
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.TopUp(user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(9000000))
+	assert.NoError(suite.T(), err)
+
+	// IDR 19,000,000 held leaves IDR 1,000,000, less than USD 100
+	_, _, _, err = suite.transactions.TopUp(user.ID, "USD", models.NewMoneyFromMajor(100))
+	suite.assertLimit(err, models.LimitMaxBalance, models.NewMoneyFromMajor(1000000))
+
+	_, _, _, err = suite.transactions.TopUp(user.ID, "USD", models.NewMoneyFromMajor(50))
+	assert.NoError(suite.T(), err)
+}
+
+func TestLimitRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(LimitRepositoryTestSuite))
+}
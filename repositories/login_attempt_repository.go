@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+// Find returns the attempt record for a key, or an empty record when the key
+// has no recent failures.
+func (r *LoginAttemptRepository) Find(scope, key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.db.Where("scope = ? AND attempt_key = ?", scope, key).First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.LoginAttempt{Scope: scope, Key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// RecordFailure counts a failed login. When the count reaches the policy's
+// maximum the key is locked for an exponentially growing duration and the
+// count starts over. lockedNow reports whether this failure caused a lockout.
+func (r *LoginAttemptRepository) RecordFailure(scope, key string, policy models.LockoutPolicy, now time.Time) (attempt *models.LoginAttempt, lockedNow bool, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, then lock it
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginAttempt{Scope: scope, Key: key}).Error; err != nil {
+			return err
+		}
+
+		attempt = &models.LoginAttempt{}
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("scope = ? AND attempt_key = ?", scope, key).
+			First(attempt).Error; err != nil {
+			return err
+		}
+
+		// Forget stale failures and backoff
+		if attempt.LastFailedAt != nil && now.Sub(*attempt.LastFailedAt) > policy.ResetAfter {
+			attempt.FailedCount = 0
+			attempt.LockoutCount = 0
+		}
+
+		attempt.FailedCount++
+		attempt.LastFailedAt = &now
+
+		if attempt.FailedCount >= policy.MaxAttempts {
+			attempt.LockoutCount++
+			lockedUntil := now.Add(policy.LockoutDuration(attempt.LockoutCount))
+			attempt.LockedUntil = &lockedUntil
+			attempt.FailedCount = 0
+			lockedNow = true
+		}
+
+		return tx.Model(attempt).Updates(map[string]interface{}{
+			"failed_count":   attempt.FailedCount,
+			"lockout_count":  attempt.LockoutCount,
+			"last_failed_at": attempt.LastFailedAt,
+			"locked_until":   attempt.LockedUntil,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+	return attempt, lockedNow, nil
+}
+
+// Reset clears the failures and backoff of a key after a successful login.
+func (r *LoginAttemptRepository) Reset(scope, key string) error {
+	return r.db.Where("scope = ? AND attempt_key = ?", scope, key).Delete(&models.LoginAttempt{}).Error
+}
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepositoryTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	repository *LoginAttemptRepository
+	policy     models.LockoutPolicy
+	now        time.Time
+}
+
+func (suite *LoginAttemptRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.LoginAttempt{}, &models.AuditLog{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &LoginAttemptRepository{db: db}
+	suite.policy = models.LockoutPolicy{
+		MaxAttempts:  3,
+		BaseDuration: 15 * time.Minute,
+		MaxDuration:  time.Hour,
+		ResetAfter:   24 * time.Hour,
+	}
+	suite.now = time.Now()
+}
+
+func (suite *LoginAttemptRepositoryTestSuite) fail(key string, at time.Time) (*models.LoginAttempt, bool) {
+	attempt, lockedNow, err := suite.repository.RecordFailure(models.LoginScopePhone, key, suite.policy, at)
+	assert.NoError(suite.T(), err)
+	return attempt, lockedNow
+}
+
+func (suite *LoginAttemptRepositoryTestSuite) TestLocksAfterMaxAttempts() {
+	attempt, lockedNow := suite.fail("1234567890", suite.now)
+	assert.False(suite.T(), lockedNow)
+	assert.Equal(suite.T(), 1, attempt.FailedCount)
+
+	suite.fail("1234567890", suite.now)
+	attempt, lockedNow = suite.fail("1234567890", suite.now)
+	assert.True(suite.T(), lockedNow)
+	assert.True(suite.T(), attempt.IsLocked(suite.now))
+	assert.Equal(suite.T(), suite.now.Add(15*time.Minute).Unix(), attempt.LockedUntil.Unix())
+
+	found, err := suite.repository.Find(models.LoginScopePhone, "1234567890")
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), found.IsLocked(suite.now))
+	assert.False(suite.T(), found.IsLocked(suite.now.Add(16*time.Minute)))
+
+	// Other keys and scopes are unaffected
+	other, err := suite.repository.Find(models.LoginScopeIP, "1234567890")
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), other.IsLocked(suite.now))
+}
+
+func (suite *LoginAttemptRepositoryTestSuite) TestLockoutBacksOffExponentially() {
+	at := suite.now
+	var durations []time.Duration
+	for lockout := 0; lockout < 4; lockout++ {
+		var attempt *models.LoginAttempt
+		for i := 0; i < suite.policy.MaxAttempts; i++ {
+			attempt, _ = suite.fail("1234567890", at)
+		}
+		durations = append(durations, attempt.LockedUntil.Sub(at))
+		at = *attempt.LockedUntil
+	}
+
+	assert.Equal(suite.T(), []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour, time.Hour}, durations)
+}
+
+func (suite *LoginAttemptRepositoryTestSuite) TestResetClearsFailures() {
+	suite.fail("1234567890", suite.now)
+	suite.fail("1234567890", suite.now)
+	assert.NoError(suite.T(), suite.repository.Reset(models.LoginScopePhone, "1234567890"))
+
+	attempt, lockedNow := suite.fail("1234567890", suite.now)
+	assert.False(suite.T(), lockedNow)
+	assert.Equal(suite.T(), 1, attempt.FailedCount)
+	assert.Equal(suite.T(), 0, attempt.LockoutCount)
+}
+
+func (suite *LoginAttemptRepositoryTestSuite) TestStaleFailuresAreForgotten() {
+	suite.fail("1234567890", suite.now)
+	suite.fail("1234567890", suite.now)
+
+	attempt, lockedNow := suite.fail("1234567890", suite.now.Add(25*time.Hour))
+	assert.False(suite.T(), lockedNow)
+	assert.Equal(suite.T(), 1, attempt.FailedCount)
+}
+
+func (suite *LoginAttemptRepositoryTestSuite) TestAuditRecord() {
+	audit := NewAuditRepository(suite.db)
+	err := audit.Record(models.AuditLoginLockout, nil, "10.0.0.1", map[string]string{"scope": models.LoginScopeIP})
+	assert.NoError(suite.T(), err)
+
+	logs, err := audit.FindByAction(models.AuditLoginLockout)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), logs, 1)
+	assert.Equal(suite.T(), "10.0.0.1", logs[0].IPAddress)
+	assert.JSONEq(suite.T(), `{"scope":"IP"}`, logs[0].Details)
+}
+
+func TestLoginAttemptRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(LoginAttemptRepositoryTestSuite))
+}
@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"strings"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxActiveAPIKeys caps the usable API keys of one merchant, counting rotated
+// keys until they expire
+const maxActiveAPIKeys = 10
+
+type MerchantAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewMerchantAPIKeyRepository(db *gorm.DB) *MerchantAPIKeyRepository {
+	return &MerchantAPIKeyRepository{db: db}
+}
+
+// Create stores a new API key for key.MerchantID with the given scopes. The
+// caller sets its KeyID and sealed secret.
+func (r *MerchantAPIKeyRepository) Create(key *models.MerchantAPIKey, scopes []string, now time.Time) error {
+	scopes, err := models.NormalizeScopes(scopes)
+	if err != nil {
+		return err
+	}
+	key.Scopes = strings.Join(scopes, ",")
+	key.Status = models.APIKeyActive
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the merchant so concurrent creates can't exceed the cap
+		var merchant models.Merchant
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&merchant, "id = ?", key.MerchantID).Error; err != nil {
+			return err
+		}
+		if err := r.checkKeyLimit(tx, key.MerchantID, now); err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+}
+
+func (r *MerchantAPIKeyRepository) checkKeyLimit(tx *gorm.DB, merchantID uuid.UUID, now time.Time) error {
+	var active int64
+	err := tx.Model(&models.MerchantAPIKey{}).
+		Where("merchant_id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)", merchantID, models.APIKeyActive, now).
+		Count(&active).Error
+	if err != nil {
+		return err
+	}
+	if active >= maxActiveAPIKeys {
+		return models.ErrMerchantKeyLimit
+	}
+	return nil
+}
+
+// GetMerchantKeys lists the merchant's API keys, newest first.
+func (r *MerchantAPIKeyRepository) GetMerchantKeys(merchantID uuid.UUID) ([]models.MerchantAPIKey, error) {
+	var keys []models.MerchantAPIKey
+	if err := r.db.Where("merchant_id = ?", merchantID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Find returns one of the merchant's API keys by its public key ID.
+func (r *MerchantAPIKeyRepository) Find(merchantID uuid.UUID, keyID string) (*models.MerchantAPIKey, error) {
+	var key models.MerchantAPIKey
+	if err := r.db.First(&key, "merchant_id = ? AND key_id = ?", merchantID, keyID).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FindByKeyID returns the API key a request was signed with.
+func (r *MerchantAPIKeyRepository) FindByKeyID(keyID string) (*models.MerchantAPIKey, error) {
+	var key models.MerchantAPIKey
+	if err := r.db.First(&key, "key_id = ?", keyID).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// getUsableForUpdate locks one of the merchant's keys that may still sign
+// requests.
+func (r *MerchantAPIKeyRepository) getUsableForUpdate(tx *gorm.DB, merchantID uuid.UUID, keyID string, now time.Time) (*models.MerchantAPIKey, error) {
+	var key models.MerchantAPIKey
+	err := tx.Set("gorm:query_option", "FOR UPDATE").
+		First(&key, "merchant_id = ? AND key_id = ?", merchantID, keyID).Error
+	if err != nil {
+		return nil, err
+	}
+	if !key.IsUsable(now) {
+		return nil, models.ErrAPIKeyNotActive
+	}
+	return &key, nil
+}
+
+// Revoke stops a key from signing requests straight away.
+func (r *MerchantAPIKeyRepository) Revoke(merchantID uuid.UUID, keyID string, now time.Time) (*models.MerchantAPIKey, error) {
+	var key *models.MerchantAPIKey
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		key, err = r.getUsableForUpdate(tx, merchantID, keyID, now)
+		if err != nil {
+			return err
+		}
+		key.Status = models.APIKeyRevoked
+		key.RevokedAt = &now
+		return tx.Save(key).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Rotate stores next, whose KeyID and sealed secret the caller sets, with
+// the same name and scopes as an active key, and lets the old key keep
+// working for grace so clients can switch over. A zero grace expires the old
+// key straight away. The new key replaces the old one, so it is not held to
+// the active key cap. It returns the old key.
+func (r *MerchantAPIKeyRepository) Rotate(merchantID uuid.UUID, keyID string, next *models.MerchantAPIKey, grace time.Duration, now time.Time) (*models.MerchantAPIKey, error) {
+	var old *models.MerchantAPIKey
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		old, err = r.getUsableForUpdate(tx, merchantID, keyID, now)
+		if err != nil {
+			return err
+		}
+
+		expiresAt := now.Add(grace)
+		if old.ExpiresAt != nil && old.ExpiresAt.Before(expiresAt) {
+			expiresAt = *old.ExpiresAt
+		}
+		old.ExpiresAt = &expiresAt
+		if err := tx.Save(old).Error; err != nil {
+			return err
+		}
+
+		next.MerchantID = merchantID
+		next.Name = old.Name
+		next.Scopes = old.Scopes
+		next.Status = models.APIKeyActive
+		next.RotatedFrom = &old.ID
+		return tx.Create(next).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// RecordUsage logs a request signed with the key and marks the key as used.
+func (r *MerchantAPIKeyRepository) RecordUsage(usage *models.MerchantAPIKeyUsage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(usage).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.MerchantAPIKey{}).
+			Where("id = ?", usage.APIKeyID).
+			Update("last_used_at", usage.CreatedAt).Error
+	})
+}
+
+// GetUsage returns a page of the requests signed with a key, newest first.
+func (r *MerchantAPIKeyRepository) GetUsage(apiKeyID uuid.UUID, page, limit int) ([]models.MerchantAPIKeyUsage, int64, error) {
+	var usage []models.MerchantAPIKeyUsage
+	var total int64
+
+	query := r.db.Model(&models.MerchantAPIKeyUsage{}).Where("api_key_id = ?", apiKeyID).Session(&gorm.Session{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Order("created_at desc, id desc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&usage).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return usage, total, nil
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type MerchantAPIKeyRepositoryTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	repository *MerchantAPIKeyRepository
+	merchant   *models.Merchant
+}
+
+func (suite *MerchantAPIKeyRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.Merchant{}, &models.MerchantAPIKey{}, &models.MerchantAPIKeyUsage{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &MerchantAPIKeyRepository{db: db}
+
+	owner := &models.User{FirstName: "Warung", LastName: "Owner", PhoneNumber: "1234567890", Address: "123 Main St", Pin: "123456", KYCTier: models.KYCTierFull}
+	assert.NoError(suite.T(), NewUserRepository(db).Create(owner))
+
+	suite.merchant = &models.Merchant{
+		OwnerID:            owner.ID,
+		Name:               "Warung Makan",
+		CategoryCode:       "5812",
+		SettlementCurrency: models.DefaultCurrency,
+	}
+	assert.NoError(suite.T(), NewMerchantRepository(db).Create(suite.merchant))
+}
+
+// newKey returns an unsaved key of the suite's merchant with a fresh key ID.
+func (suite *MerchantAPIKeyRepositoryTestSuite) newKey(name string) *models.MerchantAPIKey {
+	keyID, err := models.NewAPIKeyID()
+	assert.NoError(suite.T(), err)
+	return &models.MerchantAPIKey{MerchantID: suite.merchant.ID, KeyID: keyID, Name: name, SecretCiphertext: "sealed"}
+}
+
+func (suite *MerchantAPIKeyRepositoryTestSuite) createKey(name string, now time.Time) *models.MerchantAPIKey {
+	key := suite.newKey(name)
+	assert.NoError(suite.T(), suite.repository.Create(key, []string{models.ScopePaymentsRead}, now))
+	return key
+}
+
+func (suite *MerchantAPIKeyRepositoryTestSuite) TestCreateNormalizesScopes() {
+	key := suite.newKey("POS")
+	err := suite.repository.Create(key, []string{models.ScopeRefundsWrite, models.ScopePaymentsRead, models.ScopeRefundsWrite}, time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Regexp(suite.T(), "^mk_[0-9a-f]{32}$", key.KeyID)
+	assert.Equal(suite.T(), "payments:read,refunds:write", key.Scopes)
+	assert.True(suite.T(), key.HasScope(models.ScopePaymentsRead))
+
+	err = suite.repository.Create(suite.newKey("POS"), []string{"admin"}, time.Now())
+	assert.Equal(suite.T(), models.ErrInvalidScope, err)
+}
+
+func (suite *MerchantAPIKeyRepositoryTestSuite) TestActiveKeyLimit() {
+	now := time.Now()
+	for i := 0; i < maxActiveAPIKeys; i++ {
+		suite.createKey("", now)
+	}
+	err := suite.repository.Create(suite.newKey(""), []string{models.ScopePaymentsRead}, now)
+	assert.Equal(suite.T(), models.ErrMerchantKeyLimit, err)
+
+	// Revoked keys don't count
+	keys, err := suite.repository.GetMerchantKeys(suite.merchant.ID)
+	assert.NoError(suite.T(), err)
+	_, err = suite.repository.Revoke(suite.merchant.ID, keys[0].KeyID, now)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.repository.Create(suite.newKey(""), []string{models.ScopePaymentsRead}, now))
+}
+
+func (suite *MerchantAPIKeyRepositoryTestSuite) TestRotateKeepsOldKeyDuringGrace() {
+	now := time.Now()
+	key := suite.createKey("POS", now)
+
+	next := suite.newKey("")
+	old, err := suite.repository.Rotate(suite.merchant.ID, key.KeyID, next, time.Hour, now)
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), key.KeyID, next.KeyID)
+	assert.Equal(suite.T(), key.ID, *next.RotatedFrom)
+	assert.Equal(suite.T(), "POS", next.Name)
+	assert.Equal(suite.T(), key.Scopes, next.Scopes)
+
+	assert.True(suite.T(), old.IsUsable(now.Add(59*time.Minute)))
+	assert.False(suite.T(), old.IsUsable(now.Add(time.Hour)))
+	assert.Equal(suite.T(), models.APIKeyExpired, old.CurrentStatus(now.Add(time.Hour)))
+
+	// An expired key can't be rotated or revoked again
+	_, err = suite.repository.Rotate(suite.merchant.ID, key.KeyID, suite.newKey(""), time.Hour, now.Add(2*time.Hour))
+	assert.Equal(suite.T(), models.ErrAPIKeyNotActive, err)
+	_, err = suite.repository.Revoke(suite.merchant.ID, key.KeyID, now.Add(2*time.Hour))
+	assert.Equal(suite.T(), models.ErrAPIKeyNotActive, err)
+}
+
+func (suite *MerchantAPIKeyRepositoryTestSuite) TestRevoke() {
+	now := time.Now()
+	key := suite.createKey("POS", now)
+
+	revoked, err := suite.repository.Revoke(suite.merchant.ID, key.KeyID, now)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.APIKeyRevoked, revoked.Status)
+	assert.False(suite.T(), revoked.IsUsable(now))
+
+	_, err = suite.repository.Revoke(suite.merchant.ID, key.KeyID, now)
+	assert.Equal(suite.T(), models.ErrAPIKeyNotActive, err)
+}
+
+func (suite *MerchantAPIKeyRepositoryTestSuite) TestRecordUsage() {
+	now := time.Now()
+	key := suite.createKey("POS", now)
+
+	for i := 0; i < 3; i++ {
+		err := suite.repository.RecordUsage(&models.MerchantAPIKeyUsage{
+			APIKeyID:   key.ID,
+			Method:     "GET",
+			Path:       "/api/v1/merchant-api/payments",
+			StatusCode: 200,
+			CreatedAt:  now.Add(time.Duration(i) * time.Second),
+		})
+		assert.NoError(suite.T(), err)
+	}
+
+	usage, total, err := suite.repository.GetUsage(key.ID, 1, 2)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), total)
+	assert.Len(suite.T(), usage, 2)
+	assert.True(suite.T(), usage[0].CreatedAt.After(usage[1].CreatedAt))
+
+	key, err = suite.repository.FindByKeyID(key.KeyID)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), key.LastUsedAt)
+}
+
+func TestMerchantAPIKeyRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(MerchantAPIKeyRepositoryTestSuite))
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// merchantFixture is an active IDR merchant owned by a fully verified user,
+// and a payer with 100,000 to spend at it.
+type merchantFixture struct {
+	db       *gorm.DB
+	owner    *models.User
+	payer    *models.User
+	merchant *models.Merchant
+}
+
+// newMerchantFixture sets up a merchantFixture in a fresh in-memory database
+// with the payment schema plus the given models.
+func newMerchantFixture(t *testing.T, schema ...interface{}) merchantFixture {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	// Migrate the schema
+	tables := []interface{}{&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.Merchant{}}
+	assert.NoError(t, db.AutoMigrate(append(tables, schema...)...))
+
+	fixture := merchantFixture{db: db}
+	userRepo := NewUserRepository(db)
+	fixture.owner = &models.User{FirstName: "Warung", LastName: "Owner", PhoneNumber: "1234567890", Address: "123 Main St", Pin: "123456", KYCTier: models.KYCTierFull}
+	fixture.payer = &models.User{FirstName: "John", LastName: "Doe", PhoneNumber: "0987654321", Address: "123 Main St", Pin: "123456"}
+	assert.NoError(t, userRepo.Create(fixture.owner))
+	assert.NoError(t, userRepo.Create(fixture.payer))
+
+	fixture.merchant = &models.Merchant{
+		OwnerID:            fixture.owner.ID,
+		Name:               "Warung Makan",
+		CategoryCode:       "5812",
+		SettlementCurrency: models.DefaultCurrency,
+	}
+	assert.NoError(t, NewMerchantRepository(db).Create(fixture.merchant))
+
+	_, _, _, err = NewTransactionRepository(db).TopUp(fixture.payer.ID, models.DefaultCurrency, models.NewMoneyFromMajor(100000))
+	assert.NoError(t, err)
+	return fixture
+}
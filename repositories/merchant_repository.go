@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MerchantRepository struct {
+	db *gorm.DB
+}
+
+func NewMerchantRepository(db *gorm.DB) *MerchantRepository {
+	return &MerchantRepository{db: db}
+}
+
+// Create registers a merchant. The owner must have full KYC and a wallet in
+// the settlement currency.
+func (r *MerchantRepository) Create(merchant *models.Merchant) error {
+	if err := models.ValidateCategoryCode(merchant.CategoryCode); err != nil {
+		return err
+	}
+
+	owner, err := NewUserRepository(r.db).FindByID(merchant.OwnerID)
+	if err != nil {
+		return err
+	}
+	if owner.KYCTier != models.KYCTierFull {
+		return models.ErrMerchantRequiresKYC
+	}
+	if _, err := NewWalletRepository(r.db).Find(owner.ID, merchant.SettlementCurrency); err != nil {
+		return err
+	}
+
+	merchant.Status = models.MerchantActive
+	return r.db.Create(merchant).Error
+}
+
+// FindByID returns any merchant, e.g. one a user is about to pay.
+func (r *MerchantRepository) FindByID(merchantID uuid.UUID) (*models.Merchant, error) {
+	var merchant models.Merchant
+	if err := r.db.First(&merchant, "id = ?", merchantID).Error; err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// FindOwned returns one of the owner's merchants.
+func (r *MerchantRepository) FindOwned(merchantID, ownerID uuid.UUID) (*models.Merchant, error) {
+	var merchant models.Merchant
+	if err := r.db.First(&merchant, "id = ? AND owner_id = ?", merchantID, ownerID).Error; err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// GetOwnerMerchants lists the owner's merchants, oldest first.
+func (r *MerchantRepository) GetOwnerMerchants(ownerID uuid.UUID) ([]models.Merchant, error) {
+	var merchants []models.Merchant
+	if err := r.db.Where("owner_id = ?", ownerID).Order("created_at").Find(&merchants).Error; err != nil {
+		return nil, err
+	}
+	return merchants, nil
+}
+
+// receivedPayments selects the payers' PAYMENT rows of a merchant, which
+// track how much of each payment has been refunded.
+func (r *MerchantRepository) receivedPayments(merchantID uuid.UUID, from, to time.Time) *gorm.DB {
+	query := r.db.Model(&models.Transaction{}).
+		Where("merchant_id = ? AND transaction_type = ? AND type = ?", merchantID, models.PAYMENT, models.DEBIT)
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+	return query
+}
+
+// GetPayments returns a page of the payments the merchant received between
+// from (inclusive) and to (exclusive), newest first. Zero times leave that
+// end open.
+func (r *MerchantRepository) GetPayments(merchantID uuid.UUID, from, to time.Time, page, limit int) ([]models.Transaction, int64, error) {
+	var payments []models.Transaction
+	var total int64
+
+	query := r.receivedPayments(merchantID, from, to).Session(&gorm.Session{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Order("created_at desc, id desc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&payments).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return payments, total, nil
+}
+
+// DailyTotals sums the payments the merchant received between from
+// (inclusive) and to (exclusive) per day in loc, oldest day first. Every day
+// in the range is listed, with zero totals when nothing was paid. The sums are
+// taken in SQL, with each payment put in its day by comparing created_at to
+// the day boundaries in loc.
+func (r *MerchantRepository) DailyTotals(merchantID uuid.UUID, from, to time.Time, loc *time.Location) ([]models.MerchantDailyTotal, error) {
+	totals := []models.MerchantDailyTotal{}
+	day := "CASE"
+	var boundaries []interface{}
+	for start := from.In(loc); start.Before(to); {
+		totals = append(totals, models.MerchantDailyTotal{Date: start.Format("2006-01-02")})
+		start = time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, loc)
+		day += fmt.Sprintf(" WHEN created_at < ? THEN %d", len(totals)-1)
+		boundaries = append(boundaries, start)
+	}
+	if len(totals) == 0 {
+		return totals, nil
+	}
+
+	var sums []struct {
+		Day      int
+		Count    int
+		Gross    models.Money
+		Refunded models.Money
+	}
+	err := r.receivedPayments(merchantID, from, to).
+		Select(day+" END AS day, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS gross, COALESCE(SUM(refunded_amount), 0) AS refunded", boundaries...).
+		Group("day").
+		Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sum := range sums {
+		total := &totals[sum.Day]
+		total.Count = sum.Count
+		total.Gross = sum.Gross
+		total.Refunded = sum.Refunded
+		total.Net = sum.Gross - sum.Refunded
+	}
+	return totals, nil
+}
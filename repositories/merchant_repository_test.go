@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type MerchantRepositoryTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	repository   *MerchantRepository
+	transactions *TransactionRepository
+	owner        *models.User
+	payer        *models.User
+	merchant     *models.Merchant
+}
+
+func (suite *MerchantRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.FXQuote{}, &models.Merchant{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &MerchantRepository{db: db}
+	suite.transactions = NewTransactionRepository(db)
+
+	userRepo := NewUserRepository(db)
+	suite.owner = &models.User{FirstName: "Warung", LastName: "Owner", PhoneNumber: "1234567890", Address: "123 Main St", Pin: "123456", KYCTier: models.KYCTierFull}
+	suite.payer = &models.User{FirstName: "John", LastName: "Doe", PhoneNumber: "0987654321", Address: "123 Main St", Pin: "123456"}
+	assert.NoError(suite.T(), userRepo.Create(suite.owner))
+	assert.NoError(suite.T(), userRepo.Create(suite.payer))
+
+	suite.merchant = &models.Merchant{
+		OwnerID:            suite.owner.ID,
+		Name:               "Warung Makan",
+		CategoryCode:       "5812",
+		SettlementCurrency: models.DefaultCurrency,
+	}
+	assert.NoError(suite.T(), suite.repository.Create(suite.merchant))
+
+	_, _, _, err = suite.transactions.TopUp(suite.payer.ID, models.DefaultCurrency, models.NewMoneyFromMajor(500000))
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *MerchantRepositoryTestSuite) ownerBalance() models.Money {
+	wallet, err := NewWalletRepository(suite.db).Find(suite.owner.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	return wallet.Balance
+}
+
+func (suite *MerchantRepositoryTestSuite) TestPaymentCreditsMerchant() {
+	payment, _, _, err := suite.transactions.PayMerchant(suite.payer.ID, suite.merchant.ID, models.DefaultCurrency, models.NewMoneyFromMajor(45000), "Lunch")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.merchant.ID, *payment.MerchantID)
+	assert.Equal(suite.T(), suite.owner.ID, *payment.RecipientID)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(45000), suite.ownerBalance())
+
+	var credit models.Transaction
+	assert.NoError(suite.T(), suite.db.First(&credit, "user_id = ? AND type = ?", suite.owner.ID, models.CREDIT).Error)
+	assert.Equal(suite.T(), models.PAYMENT, credit.TransactionType)
+	assert.Equal(suite.T(), suite.merchant.ID, *credit.MerchantID)
+
+	// The merchant refunds from its settlement wallet
+	original, err := suite.transactions.FindRefundable(credit.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), payment.ID, original.ID)
+	_, _, err = suite.transactions.Refund(payment.ID, models.NewMoneyFromMajor(5000), "")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(40000), suite.ownerBalance())
+
+	mismatches, err := NewLedgerRepository(suite.db).FindBalanceMismatches()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), mismatches)
+}
+
+func (suite *MerchantRepositoryTestSuite) TestPaymentIgnoresOwnerMaxBalance() {
+	maxBalance := models.LimitsForTier(models.KYCTierFull).MaxBalance
+	assert.NoError(suite.T(), suite.db.Model(&models.Wallet{}).Where("user_id = ?", suite.owner.ID).Update("balance", maxBalance).Error)
+
+	_, _, _, err := suite.transactions.PayMerchant(suite.payer.ID, suite.merchant.ID, models.DefaultCurrency, models.NewMoneyFromMajor(45000), "Lunch")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), maxBalance+models.NewMoneyFromMajor(45000), suite.ownerBalance())
+}
+
+func (suite *MerchantRepositoryTestSuite) TestPaymentsAndDailyTotals() {
+	for _, amount := range []int64{10000, 20000} {
+		_, _, _, err := suite.transactions.PayMerchant(suite.payer.ID, suite.merchant.ID, models.DefaultCurrency, models.NewMoneyFromMajor(amount), "Lunch")
+		assert.NoError(suite.T(), err)
+	}
+	// Payments without a merchant are not the merchant's
+	_, _, _, err := suite.transactions.Payment(suite.payer.ID, models.DefaultCurrency, models.NewMoneyFromMajor(5000), "Other")
+	assert.NoError(suite.T(), err)
+
+	payments, total, err := suite.repository.GetPayments(suite.merchant.ID, time.Time{}, time.Time{}, 1, 10)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(2), total)
+	assert.Len(suite.T(), payments, 2)
+	_, _, err = suite.transactions.Refund(payments[0].ID, 0, "")
+	assert.NoError(suite.T(), err)
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	totals, err := suite.repository.DailyTotals(suite.merchant.ID, today.AddDate(0, 0, -1), today.AddDate(0, 0, 2), time.Local)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), totals, 3)
+	assert.Equal(suite.T(), today.AddDate(0, 0, -1).Format("2006-01-02"), totals[0].Date)
+	assert.Equal(suite.T(), 0, totals[0].Count)
+	assert.Equal(suite.T(), models.Money(0), totals[0].Gross)
+	assert.Equal(suite.T(), today.Format("2006-01-02"), totals[1].Date)
+	assert.Equal(suite.T(), 2, totals[1].Count)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(30000), totals[1].Gross)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(20000), totals[1].Refunded)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(10000), totals[1].Net)
+	assert.Equal(suite.T(), 0, totals[2].Count)
+
+	// A range in the middle of a day still reports that day
+	totals, err = suite.repository.DailyTotals(suite.merchant.ID, today.Add(-12*time.Hour), today, time.Local)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), totals, 1)
+	assert.Equal(suite.T(), 0, totals[0].Count)
+}
+
+func (suite *MerchantRepositoryTestSuite) TestPaymentRules() {
+	_, _, _, err := suite.transactions.PayMerchant(suite.owner.ID, suite.merchant.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000), "Self")
+	assert.Equal(suite.T(), models.ErrPayOwnMerchant, err)
+
+	_, _, _, err = suite.transactions.PayMerchant(suite.payer.ID, suite.merchant.ID, "USD", models.NewMoneyFromMajor(10), "Wrong currency")
+	assert.Equal(suite.T(), models.ErrCurrencyMismatch, err)
+
+	assert.NoError(suite.T(), suite.db.Model(suite.merchant).Update("status", models.MerchantSuspended).Error)
+	_, _, _, err = suite.transactions.PayMerchant(suite.payer.ID, suite.merchant.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000), "Suspended")
+	assert.Equal(suite.T(), models.ErrMerchantNotActive, err)
+}
+
+func (suite *MerchantRepositoryTestSuite) TestRegistrationRequiresFullKYC() {
+	merchant := &models.Merchant{OwnerID: suite.payer.ID, Name: "Toko", CategoryCode: "5411", SettlementCurrency: models.DefaultCurrency}
+	assert.Equal(suite.T(), models.ErrMerchantRequiresKYC, suite.repository.Create(merchant))
+
+	merchant.OwnerID = suite.owner.ID
+	merchant.CategoryCode = "54A1"
+	assert.Equal(suite.T(), models.ErrInvalidCategoryCode, suite.repository.Create(merchant))
+
+	merchant.CategoryCode = "5411"
+	merchant.SettlementCurrency = "USD"
+	assert.Equal(suite.T(), models.ErrWalletNotFound, suite.repository.Create(merchant))
+}
+
+func TestMerchantRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(MerchantRepositoryTestSuite))
+}
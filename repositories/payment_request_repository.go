@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Payment request directions as seen by the listing user
+const (
+	PaymentRequestsIncoming = "incoming"
+	PaymentRequestsOutgoing = "outgoing"
+)
+
+type PaymentRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRequestRepository(db *gorm.DB) *PaymentRequestRepository {
+	return &PaymentRequestRepository{db: db}
+}
+
+// Create records a payment request. The requester must hold a wallet in its
+// currency to receive the money; the payer's balance is only checked when the
+// request is accepted.
+func (r *PaymentRequestRepository) Create(request *models.PaymentRequest) error {
+	if request.PayerID == request.RequesterID {
+		return models.ErrSelfTransfer
+	}
+	if _, err := NewUserRepository(r.db).FindByID(request.PayerID); err != nil {
+		return err
+	}
+	if _, err := NewWalletRepository(r.db).Find(request.RequesterID, request.Currency); err != nil {
+		return err
+	}
+
+	request.Status = models.PaymentRequestPending
+	return r.db.Create(request).Error
+}
+
+// Find returns a payment request the user sent or received.
+func (r *PaymentRequestRepository) Find(requestID, userID uuid.UUID) (*models.PaymentRequest, error) {
+	var request models.PaymentRequest
+	err := r.db.Where("requester_id = ? OR payer_id = ?", userID, userID).
+		First(&request, "id = ?", requestID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetUserRequests lists the payment requests the user sent, received, or
+// both when direction is empty, newest first. status filters by the status
+// as of now, so PENDING excludes expired requests.
+func (r *PaymentRequestRepository) GetUserRequests(userID uuid.UUID, direction, status string, now time.Time) ([]models.PaymentRequest, error) {
+	var requests []models.PaymentRequest
+
+	query := r.db.Model(&models.PaymentRequest{})
+	switch direction {
+	case PaymentRequestsIncoming:
+		query = query.Where("payer_id = ?", userID)
+	case PaymentRequestsOutgoing:
+		query = query.Where("requester_id = ?", userID)
+	default:
+		query = query.Where("requester_id = ? OR payer_id = ?", userID, userID)
+	}
+
+	switch status {
+	case "":
+	case models.PaymentRequestPending:
+		query = query.Where("status = ? AND expires_at > ?", models.PaymentRequestPending, now)
+	case models.PaymentRequestExpired:
+		query = query.Where("status = ? AND expires_at <= ?", models.PaymentRequestPending, now)
+	default:
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("created_at desc").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *PaymentRequestRepository) getForUpdate(tx *gorm.DB, requestID, payerID uuid.UUID) (*models.PaymentRequest, error) {
+	var request models.PaymentRequest
+	err := tx.Set("gorm:query_option", "FOR UPDATE").
+		First(&request, "id = ? AND payer_id = ?", requestID, payerID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// respond locks a request addressed to the payer and checks it can still be
+// answered at now.
+func (r *PaymentRequestRepository) respond(tx *gorm.DB, requestID, payerID uuid.UUID, now time.Time) (*models.PaymentRequest, error) {
+	request, err := r.getForUpdate(tx, requestID, payerID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != models.PaymentRequestPending {
+		return nil, models.ErrPaymentRequestNotPending
+	}
+	if request.IsExpired(now) {
+		return nil, models.ErrPaymentRequestExpired
+	}
+	return request, nil
+}
+
+// Accept pays a request with a Transfer of its amount from the payer to the
+// requester, so it fails wherever that transfer would, e.g. when the payer
+// can't cover the transfer fee. Paying a split bill share also records it on
+// the bill.
+func (r *PaymentRequestRepository) Accept(requestID, payerID uuid.UUID, now time.Time) (*models.PaymentRequest, *models.Transaction, error) {
+	var request *models.PaymentRequest
+	var transaction *models.Transaction
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		request, err = r.respond(tx, requestID, payerID, now)
+		if err != nil {
+			return err
+		}
+
+		transactionRepo := NewTransactionRepository(tx)
+		transaction, _, _, err = transactionRepo.Transfer(payerID, request.Currency, request.Amount, request.RequesterID, request.Description)
+		if err != nil {
+			return err
+		}
+
+		request.Status = models.PaymentRequestAccepted
+		request.TransactionID = &transaction.ID
+		request.RespondedAt = &now
+		if err := tx.Save(request).Error; err != nil {
+			return err
+		}
+
+		if request.SplitBillID != nil {
+			return NewSplitBillRepository(tx).recordResponse(tx, *request.SplitBillID, request.Amount, now)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return request, transaction, nil
+}
+
+// Decline refuses a request without moving money. Declining a split bill
+// share may close the bill as PARTIALLY_COLLECTED.
+func (r *PaymentRequestRepository) Decline(requestID, payerID uuid.UUID, now time.Time) (*models.PaymentRequest, error) {
+	var request *models.PaymentRequest
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		request, err = r.respond(tx, requestID, payerID, now)
+		if err != nil {
+			return err
+		}
+
+		request.Status = models.PaymentRequestDeclined
+		request.RespondedAt = &now
+		if err := tx.Save(request).Error; err != nil {
+			return err
+		}
+
+		if request.SplitBillID != nil {
+			return NewSplitBillRepository(tx).recordResponse(tx, *request.SplitBillID, 0, now)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
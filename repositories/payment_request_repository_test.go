@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type PaymentRequestRepositoryTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	repository *PaymentRequestRepository
+	requester  *models.User
+	payer      *models.User
+	now        time.Time
+}
+
+func (suite *PaymentRequestRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.PaymentRequest{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &PaymentRequestRepository{db: db}
+	suite.now = time.Now()
+
+	userRepo := NewUserRepository(db)
+	suite.requester = &models.User{FirstName: "Jane", LastName: "Doe", PhoneNumber: "0987654321", Address: "123 Main St", Pin: "123456"}
+	suite.payer = &models.User{FirstName: "John", LastName: "Doe", PhoneNumber: "1234567890", Address: "123 Main St", Pin: "123456", KYCTier: models.KYCTierBasic}
+	assert.NoError(suite.T(), userRepo.Create(suite.requester))
+	assert.NoError(suite.T(), userRepo.Create(suite.payer))
+
+	_, _, _, err = NewTransactionRepository(db).TopUp(suite.payer.ID, models.DefaultCurrency, models.NewMoneyFromMajor(100000))
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *PaymentRequestRepositoryTestSuite) createRequest(amount models.Money) *models.PaymentRequest {
+	request := &models.PaymentRequest{
+		RequesterID: suite.requester.ID,
+		PayerID:     suite.payer.ID,
+		Currency:    models.DefaultCurrency,
+		Amount:      amount,
+		Description: "Dinner",
+		ExpiresAt:   suite.now.Add(time.Hour),
+	}
+	assert.NoError(suite.T(), suite.repository.Create(request))
+	return request
+}
+
+func (suite *PaymentRequestRepositoryTestSuite) TestAccept() {
+	request := suite.createRequest(models.NewMoneyFromMajor(40000))
+
+	// Only the payer can accept
+	_, _, err := suite.repository.Accept(request.ID, suite.requester.ID, suite.now)
+	assert.Equal(suite.T(), gorm.ErrRecordNotFound, err)
+
+	accepted, transaction, err := suite.repository.Accept(request.ID, suite.payer.ID, suite.now)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.PaymentRequestAccepted, accepted.Status)
+	assert.Equal(suite.T(), transaction.ID, *accepted.TransactionID)
+	assert.Equal(suite.T(), models.TRANSFER, transaction.TransactionType)
+	assert.Equal(suite.T(), suite.requester.ID, *transaction.RecipientID)
+
+	wallet, err := NewWalletRepository(suite.db).Find(suite.requester.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(40000), wallet.Balance)
+
+	_, _, err = suite.repository.Accept(request.ID, suite.payer.ID, suite.now)
+	assert.Equal(suite.T(), models.ErrPaymentRequestNotPending, err)
+}
+
+func (suite *PaymentRequestRepositoryTestSuite) TestAcceptNeedsBalance() {
+	request := suite.createRequest(models.NewMoneyFromMajor(150000))
+
+	_, _, err := suite.repository.Accept(request.ID, suite.payer.ID, suite.now)
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+
+	// The request stays pending so it can be accepted after a top up
+	stored, err := suite.repository.Find(request.ID, suite.payer.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.PaymentRequestPending, stored.Status)
+}
+
+func (suite *PaymentRequestRepositoryTestSuite) TestDeclineAndExpiry() {
+	declined := suite.createRequest(models.NewMoneyFromMajor(1000))
+	request, err := suite.repository.Decline(declined.ID, suite.payer.ID, suite.now)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.PaymentRequestDeclined, request.Status)
+
+	expired := suite.createRequest(models.NewMoneyFromMajor(2000))
+	later := suite.now.Add(2 * time.Hour)
+	_, _, err = suite.repository.Accept(expired.ID, suite.payer.ID, later)
+	assert.Equal(suite.T(), models.ErrPaymentRequestExpired, err)
+	assert.Equal(suite.T(), models.PaymentRequestExpired, expired.CurrentStatus(later))
+
+	suite.createRequest(models.NewMoneyFromMajor(3000))
+
+	requests, err := suite.repository.GetUserRequests(suite.payer.ID, PaymentRequestsIncoming, models.PaymentRequestPending, suite.now)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), requests, 2)
+
+	requests, err = suite.repository.GetUserRequests(suite.payer.ID, PaymentRequestsIncoming, models.PaymentRequestPending, later)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), requests)
+
+	requests, err = suite.repository.GetUserRequests(suite.requester.ID, PaymentRequestsOutgoing, models.PaymentRequestDeclined, suite.now)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), requests, 1)
+	assert.Equal(suite.T(), declined.ID, requests[0].ID)
+
+	requests, err = suite.repository.GetUserRequests(suite.requester.ID, PaymentRequestsIncoming, "", suite.now)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), requests)
+
+	requests, err = suite.repository.GetUserRequests(suite.requester.ID, "", "", suite.now)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), requests, 3)
+}
+
+func (suite *PaymentRequestRepositoryTestSuite) TestCreateValidates() {
+	request := &models.PaymentRequest{
+		RequesterID: suite.requester.ID,
+		PayerID:     suite.requester.ID,
+		Currency:    models.DefaultCurrency,
+		Amount:      models.NewMoneyFromMajor(100),
+		ExpiresAt:   suite.now.Add(time.Hour),
+	}
+	assert.Equal(suite.T(), models.ErrSelfTransfer, suite.repository.Create(request))
+
+	request.PayerID = suite.payer.ID
+	request.Currency = "USD"
+	assert.Equal(suite.T(), models.ErrWalletNotFound, suite.repository.Create(request))
+}
+
+func TestPaymentRequestRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(PaymentRequestRepositoryTestSuite))
+}
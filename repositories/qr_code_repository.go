@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type QRCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewQRCodeRepository(db *gorm.DB) *QRCodeRepository {
+	return &QRCodeRepository{db: db}
+}
+
+// Create issues a dynamic QR code for an active merchant in its settlement
+// currency.
+func (r *QRCodeRepository) Create(code *models.QRCode) error {
+	merchant, err := NewMerchantRepository(r.db).FindByID(code.MerchantID)
+	if err != nil {
+		return err
+	}
+	if merchant.Status != models.MerchantActive {
+		return models.ErrMerchantNotActive
+	}
+
+	code.Currency = merchant.SettlementCurrency
+	code.Status = models.QRCodePending
+	return r.db.Create(code).Error
+}
+
+// Find returns one of the merchant's QR codes.
+func (r *QRCodeRepository) Find(codeID, merchantID uuid.UUID) (*models.QRCode, error) {
+	var code models.QRCode
+	if err := r.db.First(&code, "id = ? AND merchant_id = ?", codeID, merchantID).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// FindByReference returns the QR code a scanned payload refers to.
+func (r *QRCodeRepository) FindByReference(reference string) (*models.QRCode, error) {
+	var code models.QRCode
+	if err := r.db.First(&code, "reference = ?", reference).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// Pay charges the scanning user for a pending dynamic QR code. The merchant
+// is paid through TransactionRepository.PayMerchant in the code's currency,
+// and a code that is paid is never reused. It returns the code, the payer's
+// PAYMENT and the balances before and after.
+func (r *QRCodeRepository) Pay(codeID, payerID uuid.UUID, remarks string, now time.Time) (*models.QRCode, *models.Transaction, models.Money, models.Money, error) {
+	var code models.QRCode
+	var transaction *models.Transaction
+	var balanceBefore, balanceAfter models.Money
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&code, "id = ?", codeID).Error; err != nil {
+			return err
+		}
+		if code.Status != models.QRCodePending {
+			return models.ErrQRCodeNotPending
+		}
+		if code.IsExpired(now) {
+			return models.ErrQRCodeExpired
+		}
+
+		var err error
+		transactionRepo := NewTransactionRepository(tx)
+		transaction, balanceBefore, balanceAfter, err = transactionRepo.PayMerchant(payerID, code.MerchantID, code.Currency, code.Amount, remarks)
+		if err != nil {
+			return err
+		}
+
+		code.Status = models.QRCodePaid
+		code.PayerID = &payerID
+		code.TransactionID = &transaction.ID
+		code.PaidAt = &now
+		return tx.Save(&code).Error
+	})
+
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	return &code, transaction, balanceBefore, balanceAfter, nil
+}
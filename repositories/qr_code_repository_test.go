@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type QRCodeRepositoryTestSuite struct {
+	suite.Suite
+	merchantFixture
+	repository *QRCodeRepository
+}
+
+func (suite *QRCodeRepositoryTestSuite) SetupTest() {
+	suite.merchantFixture = newMerchantFixture(suite.T(), &models.QRCode{})
+	suite.repository = &QRCodeRepository{db: suite.db}
+}
+
+func (suite *QRCodeRepositoryTestSuite) createCode(amount int64, expiresAt time.Time) *models.QRCode {
+	code := &models.QRCode{MerchantID: suite.merchant.ID, Amount: models.NewMoneyFromMajor(amount), Description: "Table 4", ExpiresAt: expiresAt}
+	assert.NoError(suite.T(), suite.repository.Create(code))
+	return code
+}
+
+func (suite *QRCodeRepositoryTestSuite) TestCreate() {
+	code := suite.createCode(45000, time.Now().Add(15*time.Minute))
+	assert.Regexp(suite.T(), "^QR[0-9A-F]{20}$", code.Reference)
+	assert.Equal(suite.T(), models.DefaultCurrency, code.Currency)
+	assert.Equal(suite.T(), models.QRCodePending, code.Status)
+
+	found, err := suite.repository.FindByReference(code.Reference)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), code.ID, found.ID)
+
+	assert.NoError(suite.T(), suite.db.Model(suite.merchant).Update("status", models.MerchantSuspended).Error)
+	err = suite.repository.Create(&models.QRCode{MerchantID: suite.merchant.ID, Amount: models.NewMoneyFromMajor(1000), ExpiresAt: time.Now().Add(time.Minute)})
+	assert.Equal(suite.T(), models.ErrMerchantNotActive, err)
+}
+
+func (suite *QRCodeRepositoryTestSuite) TestPayOnce() {
+	now := time.Now()
+	code := suite.createCode(45000, now.Add(15*time.Minute))
+
+	paid, transaction, _, balanceAfter, err := suite.repository.Pay(code.ID, suite.payer.ID, "Table 4", now)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.QRCodePaid, paid.Status)
+	assert.Equal(suite.T(), transaction.ID, *paid.TransactionID)
+	assert.Equal(suite.T(), suite.payer.ID, *paid.PayerID)
+	assert.Equal(suite.T(), suite.merchant.ID, *transaction.MerchantID)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(45000), transaction.Amount)
+	assert.True(suite.T(), balanceAfter < models.NewMoneyFromMajor(100000))
+
+	_, _, _, _, err = suite.repository.Pay(code.ID, suite.payer.ID, "Table 4", now)
+	assert.Equal(suite.T(), models.ErrQRCodeNotPending, err)
+}
+
+func (suite *QRCodeRepositoryTestSuite) TestPayRefused() {
+	now := time.Now()
+
+	expired := suite.createCode(1000, now.Add(-time.Second))
+	assert.Equal(suite.T(), models.QRCodeExpired, expired.CurrentStatus(now))
+	_, _, _, _, err := suite.repository.Pay(expired.ID, suite.payer.ID, "Late", now)
+	assert.Equal(suite.T(), models.ErrQRCodeExpired, err)
+
+	// A failed payment leaves the code pending
+	tooMuch := suite.createCode(500000, now.Add(time.Minute))
+	_, _, _, _, err = suite.repository.Pay(tooMuch.ID, suite.payer.ID, "Too much", now)
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+	code, err := suite.repository.Find(tooMuch.ID, suite.merchant.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.QRCodePending, code.Status)
+}
+
+func TestQRCodeRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(QRCodeRepositoryTestSuite))
+}
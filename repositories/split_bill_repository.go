@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SplitParticipant is another user's share of a split bill.
+type SplitParticipant struct {
+	UserID uuid.UUID
+	Amount models.Money
+}
+
+type SplitBillRepository struct {
+	db *gorm.DB
+}
+
+func NewSplitBillRepository(db *gorm.DB) *SplitBillRepository {
+	return &SplitBillRepository{db: db}
+}
+
+// FindSplittablePayment returns one of the owner's payments that can be
+// split, i.e. one not yet split or fully refunded.
+func (r *SplitBillRepository) FindSplittablePayment(ownerID, transactionID uuid.UUID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.First(&transaction, "id = ? AND user_id = ?", transactionID, ownerID).Error; err != nil {
+		return nil, err
+	}
+	if transaction.TransactionType != models.PAYMENT || transaction.RefundableAmount() <= 0 {
+		return nil, models.ErrTransactionNotSplittable
+	}
+
+	var count int64
+	if err := r.db.Model(&models.SplitBill{}).Where("transaction_id = ?", transactionID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, models.ErrTransactionAlreadySplit
+	}
+	return &transaction, nil
+}
+
+// Create records a split bill and sends every participant a payment request
+// for their share, expiring after ttl. The participants' shares and the
+// owner's must add up to the bill's total.
+func (r *SplitBillRepository) Create(bill *models.SplitBill, participants []SplitParticipant, ttl time.Duration) error {
+	total := bill.OwnerAmount
+	for _, participant := range participants {
+		if participant.Amount <= 0 {
+			return models.ErrShareTooSmall
+		}
+		total += participant.Amount
+	}
+	if len(participants) == 0 || total != bill.TotalAmount {
+		return models.ErrInvalidSplit
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if bill.TransactionID != nil {
+			if _, err := NewSplitBillRepository(tx).FindSplittablePayment(bill.OwnerID, *bill.TransactionID); err != nil {
+				return err
+			}
+		}
+
+		bill.Status = models.SplitBillOpen
+		bill.CollectedAmount = 0
+		if err := tx.Omit("Requests").Create(bill).Error; err != nil {
+			return err
+		}
+
+		requestRepo := NewPaymentRequestRepository(tx)
+		expiresAt := time.Now().Add(ttl)
+		bill.Requests = make([]models.PaymentRequest, 0, len(participants))
+		for _, participant := range participants {
+			request := models.PaymentRequest{
+				RequesterID: bill.OwnerID,
+				PayerID:     participant.UserID,
+				Currency:    bill.Currency,
+				Amount:      participant.Amount,
+				Description: bill.Description,
+				SplitBillID: &bill.ID,
+				ExpiresAt:   expiresAt,
+			}
+			if err := requestRepo.Create(&request); err != nil {
+				return err
+			}
+			bill.Requests = append(bill.Requests, request)
+		}
+		return nil
+	})
+}
+
+// Find returns one of the owner's split bills with its payment requests.
+func (r *SplitBillRepository) Find(billID, ownerID uuid.UUID) (*models.SplitBill, error) {
+	var bill models.SplitBill
+	err := r.db.Preload("Requests", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		First(&bill, "id = ? AND owner_id = ?", billID, ownerID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &bill, nil
+}
+
+// GetUserBills lists the owner's split bills with their payment requests,
+// newest first, optionally by their status as of now.
+func (r *SplitBillRepository) GetUserBills(ownerID uuid.UUID, status string, now time.Time) ([]models.SplitBill, error) {
+	var bills []models.SplitBill
+	query := r.db.Preload("Requests", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		Where("owner_id = ?", ownerID)
+	switch status {
+	case "":
+	case models.SplitBillOpen, models.SplitBillPartiallyCollected:
+		// An open bill may be partially collected by now
+		query = query.Where("status IN ?", []string{models.SplitBillOpen, models.SplitBillPartiallyCollected})
+	default:
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at desc").Find(&bills).Error; err != nil {
+		return nil, err
+	}
+
+	if status == "" {
+		return bills, nil
+	}
+	matching := []models.SplitBill{}
+	for _, bill := range bills {
+		if bill.CurrentStatus(now) == status {
+			matching = append(matching, bill)
+		}
+	}
+	return matching, nil
+}
+
+// recordResponse updates a bill after one of its payment requests was
+// accepted, adding amount, or declined, with amount zero. The bill becomes
+// COLLECTED once every share has been paid, or PARTIALLY_COLLECTED once no
+// share is left pending. It runs inside the transaction answering the
+// request, after the request is saved.
+func (r *SplitBillRepository) recordResponse(tx *gorm.DB, billID uuid.UUID, amount models.Money, now time.Time) error {
+	var bill models.SplitBill
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&bill, "id = ?", billID).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("split_bill_id = ?", billID).Find(&bill.Requests).Error; err != nil {
+		return err
+	}
+
+	bill.CollectedAmount += amount
+	if bill.CollectedAmount >= bill.AmountToCollect() {
+		bill.Status = models.SplitBillCollected
+	} else {
+		bill.Status = bill.CurrentStatus(now)
+	}
+	return tx.Model(&bill).Updates(map[string]interface{}{
+		"collected_amount": bill.CollectedAmount,
+		"status":           bill.Status,
+	}).Error
+}
@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type SplitBillRepositoryTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	repository *SplitBillRepository
+	owner      *models.User
+	friends    []*models.User
+	payment    *models.Transaction
+}
+
+func (suite *SplitBillRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.PaymentRequest{}, &models.SplitBill{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &SplitBillRepository{db: db}
+
+	userRepo := NewUserRepository(db)
+	transactions := NewTransactionRepository(db)
+	suite.owner = &models.User{FirstName: "John", LastName: "Doe", PhoneNumber: "1234567890", Address: "123 Main St", Pin: "123456"}
+	assert.NoError(suite.T(), userRepo.Create(suite.owner))
+	suite.friends = nil
+	for _, phone := range []string{"0987654321", "0987654322"} {
+		friend := &models.User{FirstName: "Jane", LastName: "Doe", PhoneNumber: phone, Address: "123 Main St", Pin: "123456", KYCTier: models.KYCTierBasic}
+		assert.NoError(suite.T(), userRepo.Create(friend))
+		_, _, _, err = transactions.TopUp(friend.ID, models.DefaultCurrency, models.NewMoneyFromMajor(150000))
+		assert.NoError(suite.T(), err)
+		suite.friends = append(suite.friends, friend)
+	}
+
+	_, _, _, err = transactions.TopUp(suite.owner.ID, models.DefaultCurrency, models.NewMoneyFromMajor(500000))
+	assert.NoError(suite.T(), err)
+	suite.payment, _, _, err = transactions.Payment(suite.owner.ID, models.DefaultCurrency, models.NewMoneyFromMajor(300000), "Dinner")
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *SplitBillRepositoryTestSuite) createBill() *models.SplitBill {
+	bill := &models.SplitBill{
+		OwnerID:       suite.owner.ID,
+		TransactionID: &suite.payment.ID,
+		Currency:      models.DefaultCurrency,
+		TotalAmount:   suite.payment.Amount,
+		OwnerAmount:   models.NewMoneyFromMajor(100000),
+		Method:        models.SplitEqual,
+		Description:   "Dinner",
+	}
+	participants := []SplitParticipant{
+		{UserID: suite.friends[0].ID, Amount: models.NewMoneyFromMajor(100000)},
+		{UserID: suite.friends[1].ID, Amount: models.NewMoneyFromMajor(100000)},
+	}
+	assert.NoError(suite.T(), suite.repository.Create(bill, participants, time.Hour))
+	return bill
+}
+
+func (suite *SplitBillRepositoryTestSuite) TestSettleUntilCollected() {
+	bill := suite.createBill()
+	assert.Len(suite.T(), bill.Requests, 2)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(200000), bill.AmountToCollect())
+
+	requests := NewPaymentRequestRepository(suite.db)
+	now := time.Now()
+
+	_, _, err := requests.Accept(bill.Requests[0].ID, suite.friends[0].ID, now)
+	assert.NoError(suite.T(), err)
+	stored, err := suite.repository.Find(bill.ID, suite.owner.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(100000), stored.CollectedAmount)
+	assert.Equal(suite.T(), models.SplitBillOpen, stored.Status)
+
+	_, _, err = requests.Accept(bill.Requests[1].ID, suite.friends[1].ID, now)
+	assert.NoError(suite.T(), err)
+	stored, err = suite.repository.Find(bill.ID, suite.owner.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(200000), stored.CollectedAmount)
+	assert.Equal(suite.T(), models.SplitBillCollected, stored.Status)
+	for _, request := range stored.Requests {
+		assert.Equal(suite.T(), models.PaymentRequestAccepted, request.Status)
+	}
+
+	wallet, err := NewWalletRepository(suite.db).Find(suite.owner.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(400000), wallet.Balance)
+}
+
+func (suite *SplitBillRepositoryTestSuite) TestDeclinedShareClosesBill() {
+	bill := suite.createBill()
+	requests := NewPaymentRequestRepository(suite.db)
+	now := time.Now()
+
+	_, err := requests.Decline(bill.Requests[0].ID, suite.friends[0].ID, now)
+	assert.NoError(suite.T(), err)
+	stored, err := suite.repository.Find(bill.ID, suite.owner.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.SplitBillOpen, stored.Status)
+
+	_, _, err = requests.Accept(bill.Requests[1].ID, suite.friends[1].ID, now)
+	assert.NoError(suite.T(), err)
+	stored, err = suite.repository.Find(bill.ID, suite.owner.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.SplitBillPartiallyCollected, stored.Status)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(100000), stored.CollectedAmount)
+
+	uncollected := stored.UncollectedRequests(now)
+	if assert.Len(suite.T(), uncollected, 1) {
+		assert.Equal(suite.T(), suite.friends[0].ID, uncollected[0].PayerID)
+		assert.Equal(suite.T(), models.PaymentRequestDeclined, uncollected[0].Status)
+	}
+}
+
+func (suite *SplitBillRepositoryTestSuite) TestExpiredSharesCloseBill() {
+	bill := suite.createBill()
+	_, _, err := NewPaymentRequestRepository(suite.db).Accept(bill.Requests[0].ID, suite.friends[0].ID, time.Now())
+	assert.NoError(suite.T(), err)
+
+	// Expired requests aren't updated, so the bill is only reported closed
+	later := time.Now().Add(2 * time.Hour)
+	stored, err := suite.repository.Find(bill.ID, suite.owner.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.SplitBillOpen, stored.Status)
+	assert.Equal(suite.T(), models.SplitBillPartiallyCollected, stored.CurrentStatus(later))
+	assert.Len(suite.T(), stored.UncollectedRequests(later), 1)
+
+	open, err := suite.repository.GetUserBills(suite.owner.ID, models.SplitBillOpen, later)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), open)
+	closed, err := suite.repository.GetUserBills(suite.owner.ID, models.SplitBillPartiallyCollected, later)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), closed, 1)
+}
+
+func (suite *SplitBillRepositoryTestSuite) TestPaymentCanOnlyBeSplitOnce() {
+	suite.createBill()
+
+	_, err := suite.repository.FindSplittablePayment(suite.owner.ID, suite.payment.ID)
+	assert.Equal(suite.T(), models.ErrTransactionAlreadySplit, err)
+
+	// Other users cannot split someone else's payment
+	_, err = suite.repository.FindSplittablePayment(suite.friends[0].ID, suite.payment.ID)
+	assert.Equal(suite.T(), gorm.ErrRecordNotFound, err)
+}
+
+func (suite *SplitBillRepositoryTestSuite) TestCreateNeedsSharesToAddUp() {
+	bill := &models.SplitBill{
+		OwnerID:     suite.owner.ID,
+		Currency:    models.DefaultCurrency,
+		TotalAmount: models.NewMoneyFromMajor(1000),
+		Method:      models.SplitExact,
+	}
+	participants := []SplitParticipant{{UserID: suite.friends[0].ID, Amount: models.NewMoneyFromMajor(900)}}
+	assert.Equal(suite.T(), models.ErrInvalidSplit, suite.repository.Create(bill, participants, time.Hour))
+
+	var count int64
+	assert.NoError(suite.T(), suite.db.Model(&models.PaymentRequest{}).Count(&count).Error)
+	assert.Zero(suite.T(), count)
+}
+
+func TestSplitBillRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(SplitBillRepositoryTestSuite))
+}
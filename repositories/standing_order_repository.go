@@ -0,0 +1,269 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type StandingOrderRepository struct {
+	db *gorm.DB
+}
+
+func NewStandingOrderRepository(db *gorm.DB) *StandingOrderRepository {
+	return &StandingOrderRepository{db: db}
+}
+
+// Create schedules a standing order. The user must hold a wallet in its
+// currency, and a transfer's recipient must exist.
+func (r *StandingOrderRepository) Create(order *models.StandingOrder) error {
+	if err := order.ValidateSchedule(); err != nil {
+		return err
+	}
+	firstRun, ok := order.FirstRun()
+	if !ok {
+		return models.ErrInvalidSchedule
+	}
+
+	if _, err := NewWalletRepository(r.db).Find(order.UserID, order.Currency); err != nil {
+		return err
+	}
+	if order.TransactionType == models.TRANSFER {
+		if order.RecipientID == nil {
+			return gorm.ErrRecordNotFound
+		}
+		if *order.RecipientID == order.UserID {
+			return models.ErrSelfTransfer
+		}
+		if _, err := NewUserRepository(r.db).FindByID(*order.RecipientID); err != nil {
+			return err
+		}
+	}
+
+	order.Status = models.StandingOrderActive
+	order.NextRunAt = &firstRun
+	return r.db.Create(order).Error
+}
+
+// GetUserOrders lists the user's standing orders, newest first, optionally
+// only those with the given status.
+func (r *StandingOrderRepository) GetUserOrders(userID uuid.UUID, status string) ([]models.StandingOrder, error) {
+	var orders []models.StandingOrder
+	query := r.db.Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// Find returns one of the user's standing orders.
+func (r *StandingOrderRepository) Find(orderID, userID uuid.UUID) (*models.StandingOrder, error) {
+	var order models.StandingOrder
+	if err := r.db.First(&order, "id = ? AND user_id = ?", orderID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *StandingOrderRepository) getForUpdate(tx *gorm.DB, orderID uuid.UUID) (*models.StandingOrder, error) {
+	var order models.StandingOrder
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&order, "id = ?", orderID).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// update locks one of the user's orders, applies change and saves it.
+func (r *StandingOrderRepository) update(orderID, userID uuid.UUID, change func(order *models.StandingOrder) error) (*models.StandingOrder, error) {
+	var order *models.StandingOrder
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = r.getForUpdate(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.UserID != userID {
+			return gorm.ErrRecordNotFound
+		}
+		if err := change(order); err != nil {
+			return err
+		}
+		return tx.Save(order).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Pause stops an active order from running until it is resumed.
+func (r *StandingOrderRepository) Pause(orderID, userID uuid.UUID) (*models.StandingOrder, error) {
+	return r.update(orderID, userID, func(order *models.StandingOrder) error {
+		if order.Status != models.StandingOrderActive {
+			return models.ErrStandingOrderNotActive
+		}
+		order.Status = models.StandingOrderPaused
+		return nil
+	})
+}
+
+// Resume reactivates a paused order. Recurring runs missed while it was
+// paused are skipped; a one-off order that is past due runs straight away.
+func (r *StandingOrderRepository) Resume(orderID, userID uuid.UUID, now time.Time) (*models.StandingOrder, error) {
+	return r.update(orderID, userID, func(order *models.StandingOrder) error {
+		if order.Status != models.StandingOrderPaused {
+			return models.ErrStandingOrderNotPaused
+		}
+		order.Status = models.StandingOrderActive
+		order.Attempts = 0
+		if order.NextRunAt != nil && order.NextRunAt.After(now) {
+			return nil
+		}
+		if order.Schedule == models.ScheduleOnce {
+			order.NextRunAt = &now
+			return nil
+		}
+		advanceStandingOrder(order, now)
+		return nil
+	})
+}
+
+// Cancel stops an active or paused order for good.
+func (r *StandingOrderRepository) Cancel(orderID, userID uuid.UUID) (*models.StandingOrder, error) {
+	return r.update(orderID, userID, func(order *models.StandingOrder) error {
+		if order.Status != models.StandingOrderActive && order.Status != models.StandingOrderPaused {
+			return models.ErrStandingOrderFinished
+		}
+		order.Status = models.StandingOrderCancelled
+		order.NextRunAt = nil
+		return nil
+	})
+}
+
+// DueOrders returns the IDs of up to limit active orders due at now, the
+// longest overdue first.
+func (r *StandingOrderRepository) DueOrders(now time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&models.StandingOrder{}).
+		Where("status = ? AND next_run_at <= ?", models.StandingOrderActive, now).
+		Order("next_run_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Execute runs a due order through TransactionRepository.Transfer or
+// Payment and schedules its next run, in one DB transaction. A run refused
+// for lack of funds is retried per retry; a run refused for any other
+// business reason is skipped. Both are recorded on the order rather than
+// returned. It returns models.ErrStandingOrderNotActive when the order is
+// not due, e.g. because another worker ran it first.
+func (r *StandingOrderRepository) Execute(orderID uuid.UUID, now time.Time, retry models.RetryPolicy) (*models.StandingOrder, *models.Transaction, error) {
+	var order *models.StandingOrder
+	var transaction *models.Transaction
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = r.getForUpdate(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.Status != models.StandingOrderActive || order.NextRunAt == nil || order.NextRunAt.After(now) {
+			return models.ErrStandingOrderNotActive
+		}
+
+		// The movement runs in a nested transaction, so a refused run rolls
+		// back on its own while the order is still updated
+		transactionRepo := NewTransactionRepository(tx)
+		var runErr error
+		switch order.TransactionType {
+		case models.TRANSFER:
+			if order.RecipientID == nil {
+				runErr = gorm.ErrRecordNotFound
+				break
+			}
+			transaction, _, _, runErr = transactionRepo.Transfer(order.UserID, order.Currency, order.Amount, *order.RecipientID, order.Description)
+		case models.PAYMENT:
+			transaction, _, _, runErr = transactionRepo.Payment(order.UserID, order.Currency, order.Amount, order.Description)
+		default:
+			runErr = models.ErrInvalidTransaction
+		}
+		if runErr != nil && !isRefusedRun(runErr) {
+			return runErr
+		}
+
+		recordStandingOrderRun(order, transaction, runErr, now, retry)
+		return tx.Save(order).Error
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return order, transaction, nil
+}
+
+// isRefusedRun reports whether a run failed for a business reason, as
+// opposed to an infrastructure error worth retrying on the next tick.
+func isRefusedRun(err error) bool {
+	var exceeded *models.LimitExceededError
+	switch {
+	case errors.As(err, &exceeded), errors.Is(err, gorm.ErrRecordNotFound):
+		return true
+	}
+	switch err {
+	case models.ErrInvalidTransaction, models.ErrAmountOutOfRange, models.ErrSelfTransfer,
+		models.ErrWalletNotFound, models.ErrCurrencyMismatch:
+		return true
+	}
+	return false
+}
+
+func recordStandingOrderRun(order *models.StandingOrder, transaction *models.Transaction, runErr error, now time.Time, retry models.RetryPolicy) {
+	order.LastRunAt = &now
+
+	if runErr == nil {
+		order.RunCount++
+		order.Attempts = 0
+		order.LastTransactionID = &transaction.ID
+		order.LastError = ""
+		advanceStandingOrder(order, now)
+		return
+	}
+
+	order.Attempts++
+	order.LastError = runErr.Error()
+	if runErr == models.ErrInvalidTransaction && order.Attempts < retry.MaxAttempts {
+		next := now.Add(retry.Interval)
+		order.NextRunAt = &next
+		return
+	}
+
+	// Give up on this run
+	order.Attempts = 0
+	if order.Schedule == models.ScheduleOnce {
+		order.Status = models.StandingOrderFailed
+		order.NextRunAt = nil
+		return
+	}
+	advanceStandingOrder(order, now)
+}
+
+// advanceStandingOrder schedules the first run after now, completing the
+// order when there is none.
+func advanceStandingOrder(order *models.StandingOrder, now time.Time) {
+	next, ok := order.NextRunAfter(now)
+	if !ok {
+		order.Status = models.StandingOrderCompleted
+		order.NextRunAt = nil
+		return
+	}
+	order.NextRunAt = &next
+}
@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type StandingOrderRepositoryTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	repository *StandingOrderRepository
+	payer      *models.User
+	payee      *models.User
+	start      time.Time
+	retry      models.RetryPolicy
+}
+
+func (suite *StandingOrderRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.StandingOrder{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &StandingOrderRepository{db: db}
+	suite.start = time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	suite.retry = models.RetryPolicy{MaxAttempts: 2, Interval: time.Hour}
+
+	userRepo := NewUserRepository(db)
+	suite.payer = &models.User{FirstName: "John", LastName: "Doe", PhoneNumber: "1234567890", Address: "123 Main St", Pin: "123456", KYCTier: models.KYCTierBasic}
+	suite.payee = &models.User{FirstName: "Jane", LastName: "Doe", PhoneNumber: "0987654321", Address: "123 Main St", Pin: "123456"}
+	assert.NoError(suite.T(), userRepo.Create(suite.payer))
+	assert.NoError(suite.T(), userRepo.Create(suite.payee))
+
+	_, _, _, err = NewTransactionRepository(db).TopUp(suite.payer.ID, models.DefaultCurrency, models.NewMoneyFromMajor(250000))
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *StandingOrderRepositoryTestSuite) createOrder(schedule string) *models.StandingOrder {
+	order := &models.StandingOrder{
+		UserID:          suite.payer.ID,
+		TransactionType: models.TRANSFER,
+		Currency:        models.DefaultCurrency,
+		Amount:          models.NewMoneyFromMajor(100000),
+		RecipientID:     &suite.payee.ID,
+		Description:     "Rent",
+		Schedule:        schedule,
+		StartAt:         suite.start,
+	}
+	assert.NoError(suite.T(), suite.repository.Create(order))
+	return order
+}
+
+func (suite *StandingOrderRepositoryTestSuite) balance(user *models.User) models.Money {
+	wallet, err := NewWalletRepository(suite.db).Find(user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	return wallet.Balance
+}
+
+func (suite *StandingOrderRepositoryTestSuite) TestExecuteMonthlyTransfer() {
+	order := suite.createOrder(models.ScheduleMonthly)
+	assert.Equal(suite.T(), suite.start, *order.NextRunAt)
+
+	due, err := suite.repository.DueOrders(suite.start.Add(-time.Second), 10)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), due)
+	due, err = suite.repository.DueOrders(suite.start, 10)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), order.ID, due[0])
+
+	executed, transaction, err := suite.repository.Execute(order.ID, suite.start, suite.retry)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.TRANSFER, transaction.TransactionType)
+	assert.Equal(suite.T(), transaction.ID, *executed.LastTransactionID)
+	assert.Equal(suite.T(), 1, executed.RunCount)
+	assert.Equal(suite.T(), time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC), *executed.NextRunAt)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(100000), suite.balance(suite.payee))
+
+	// Running again before the next due date does nothing
+	_, _, err = suite.repository.Execute(order.ID, suite.start, suite.retry)
+	assert.Equal(suite.T(), models.ErrStandingOrderNotActive, err)
+}
+
+func (suite *StandingOrderRepositoryTestSuite) TestExecuteRetriesInsufficientFunds() {
+	order := suite.createOrder(models.ScheduleMonthly)
+	order.Amount = models.NewMoneyFromMajor(300000)
+	assert.NoError(suite.T(), suite.db.Save(order).Error)
+
+	executed, transaction, err := suite.repository.Execute(order.ID, suite.start, suite.retry)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), transaction)
+	assert.Equal(suite.T(), 1, executed.Attempts)
+	assert.Equal(suite.T(), models.ErrInvalidTransaction.Error(), executed.LastError)
+	assert.Equal(suite.T(), suite.start.Add(time.Hour), *executed.NextRunAt)
+
+	// The last attempt fails too, so this month's run is skipped
+	retryAt := suite.start.Add(time.Hour)
+	executed, _, err = suite.repository.Execute(order.ID, retryAt, suite.retry)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, executed.Attempts)
+	assert.Equal(suite.T(), 0, executed.RunCount)
+	assert.Equal(suite.T(), models.StandingOrderActive, executed.Status)
+	assert.Equal(suite.T(), time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC), *executed.NextRunAt)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(250000), suite.balance(suite.payer))
+}
+
+func (suite *StandingOrderRepositoryTestSuite) TestOneOffOrder() {
+	order := suite.createOrder(models.ScheduleOnce)
+	executed, _, err := suite.repository.Execute(order.ID, suite.start, suite.retry)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.StandingOrderCompleted, executed.Status)
+	assert.Nil(suite.T(), executed.NextRunAt)
+
+	failing := suite.createOrder(models.ScheduleOnce)
+	failing.Amount = models.NewMoneyFromMajor(200000)
+	assert.NoError(suite.T(), suite.db.Save(failing).Error)
+	for _, at := range []time.Time{suite.start, suite.start.Add(time.Hour)} {
+		executed, _, err = suite.repository.Execute(failing.ID, at, suite.retry)
+		assert.NoError(suite.T(), err)
+	}
+	assert.Equal(suite.T(), models.StandingOrderFailed, executed.Status)
+	assert.Nil(suite.T(), executed.NextRunAt)
+}
+
+func (suite *StandingOrderRepositoryTestSuite) TestPauseResumeCancel() {
+	order := suite.createOrder(models.ScheduleMonthly)
+
+	paused, err := suite.repository.Pause(order.ID, suite.payer.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.StandingOrderPaused, paused.Status)
+
+	_, _, err = suite.repository.Execute(order.ID, suite.start, suite.retry)
+	assert.Equal(suite.T(), models.ErrStandingOrderNotActive, err)
+
+	// Other users cannot touch the order
+	_, err = suite.repository.Resume(order.ID, suite.payee.ID, suite.start)
+	assert.Equal(suite.T(), gorm.ErrRecordNotFound, err)
+
+	// Runs missed while paused are skipped
+	resumed, err := suite.repository.Resume(order.ID, suite.payer.ID, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.StandingOrderActive, resumed.Status)
+	assert.Equal(suite.T(), time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC), *resumed.NextRunAt)
+
+	cancelled, err := suite.repository.Cancel(order.ID, suite.payer.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.StandingOrderCancelled, cancelled.Status)
+	assert.Nil(suite.T(), cancelled.NextRunAt)
+
+	_, err = suite.repository.Cancel(order.ID, suite.payer.ID)
+	assert.Equal(suite.T(), models.ErrStandingOrderFinished, err)
+	_, err = suite.repository.Pause(order.ID, suite.payer.ID)
+	assert.Equal(suite.T(), models.ErrStandingOrderNotActive, err)
+}
+
+func (suite *StandingOrderRepositoryTestSuite) TestCreateValidates() {
+	order := &models.StandingOrder{
+		UserID:          suite.payer.ID,
+		TransactionType: models.TRANSFER,
+		Currency:        models.DefaultCurrency,
+		Amount:          models.NewMoneyFromMajor(100),
+		RecipientID:     &suite.payer.ID,
+		Schedule:        models.ScheduleDaily,
+		StartAt:         suite.start,
+	}
+	assert.Equal(suite.T(), models.ErrSelfTransfer, suite.repository.Create(order))
+
+	order.RecipientID = &suite.payee.ID
+	order.Currency = "USD"
+	assert.Equal(suite.T(), models.ErrWalletNotFound, suite.repository.Create(order))
+}
+
+func TestStandingOrderRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(StandingOrderRepositoryTestSuite))
+}
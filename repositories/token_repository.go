@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+// StartSession creates a token family together with its first refresh token.
+func (r *TokenRepository) StartSession(userID uuid.UUID, expiresAt time.Time) (*models.RefreshToken, error) {
+	var token *models.RefreshToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		family := &models.TokenFamily{UserID: userID}
+		if err := tx.Create(family).Error; err != nil {
+			return err
+		}
+
+		token = &models.RefreshToken{FamilyID: family.ID, UserID: userID, ExpiresAt: expiresAt}
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Rotate exchanges the refresh token with the given jti for a new one in the
+// same family. A token that was already exchanged revokes the family and
+// returns ErrRefreshTokenReused.
+func (r *TokenRepository) Rotate(jti uuid.UUID, expiresAt time.Time, now time.Time) (*models.RefreshToken, error) {
+	var next *models.RefreshToken
+	reused := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		err := tx.Set("gorm:query_option", "FOR UPDATE").First(&current, "id = ?", jti).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		family, err := r.findFamily(tx, current.FamilyID)
+		if err != nil {
+			return err
+		}
+		if family.IsRevoked() {
+			return ErrSessionRevoked
+		}
+
+		if current.UsedAt != nil {
+			// Commit the revocation, then report the reuse
+			reused = true
+			return revokeFamilies(tx.Where("id = ?", family.ID), now)
+		}
+		if !now.Before(current.ExpiresAt) {
+			return ErrRefreshTokenInvalid
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		next = &models.RefreshToken{FamilyID: current.FamilyID, UserID: current.UserID, ExpiresAt: expiresAt}
+		return tx.Create(next).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return next, nil
+}
+
+// FindByID returns the refresh token record with the given jti.
+func (r *TokenRepository) FindByID(jti uuid.UUID) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.First(&token, "id = ?", jti).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// IsSessionActive reports whether the family exists, belongs to the user
+// and has not been revoked.
+func (r *TokenRepository) IsSessionActive(familyID, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.TokenFamily{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", familyID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// RevokeSession logs out a single session of the user.
+func (r *TokenRepository) RevokeSession(familyID, userID uuid.UUID) error {
+	return revokeFamilies(r.db.Where("id = ? AND user_id = ?", familyID, userID), time.Now())
+}
+
+// RevokeAllSessions logs the user out everywhere and returns how many
+// sessions were revoked.
+func (r *TokenRepository) RevokeAllSessions(userID uuid.UUID) (int64, error) {
+	result := r.db.Model(&models.TokenFamily{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+func (r *TokenRepository) findFamily(tx *gorm.DB, familyID uuid.UUID) (*models.TokenFamily, error) {
+	var family models.TokenFamily
+	if err := tx.First(&family, "id = ?", familyID).Error; err != nil {
+		return nil, err
+	}
+	return &family, nil
+}
+
+func revokeFamilies(scope *gorm.DB, now time.Time) error {
+	return scope.Model(&models.TokenFamily{}).Where("revoked_at IS NULL").Update("revoked_at", now).Error
+}
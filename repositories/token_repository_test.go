@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type TokenRepositoryTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	repository *TokenRepository
+	userID     uuid.UUID
+	expiresAt  time.Time
+}
+
+func (suite *TokenRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.TokenFamily{}, &models.RefreshToken{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &TokenRepository{db: db}
+	suite.userID = uuid.New()
+	suite.expiresAt = time.Now().Add(time.Hour)
+}
+
+func (suite *TokenRepositoryTestSuite) TestRotateIssuesNewTokenInSameFamily() {
+	first, err := suite.repository.StartSession(suite.userID, suite.expiresAt)
+	assert.NoError(suite.T(), err)
+
+	second, err := suite.repository.Rotate(first.ID, suite.expiresAt, time.Now())
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), first.ID, second.ID)
+	assert.Equal(suite.T(), first.FamilyID, second.FamilyID)
+
+	active, err := suite.repository.IsSessionActive(first.FamilyID, suite.userID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), active)
+}
+
+func (suite *TokenRepositoryTestSuite) TestReuseRevokesFamily() {
+	first, err := suite.repository.StartSession(suite.userID, suite.expiresAt)
+	assert.NoError(suite.T(), err)
+	second, err := suite.repository.Rotate(first.ID, suite.expiresAt, time.Now())
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.repository.Rotate(first.ID, suite.expiresAt, time.Now())
+	assert.Equal(suite.T(), ErrRefreshTokenReused, err)
+
+	// The legitimate latest token is now dead too
+	_, err = suite.repository.Rotate(second.ID, suite.expiresAt, time.Now())
+	assert.Equal(suite.T(), ErrSessionRevoked, err)
+
+	active, err := suite.repository.IsSessionActive(first.FamilyID, suite.userID)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), active)
+}
+
+func (suite *TokenRepositoryTestSuite) TestRotateRejectsUnknownAndExpired() {
+	_, err := suite.repository.Rotate(uuid.New(), suite.expiresAt, time.Now())
+	assert.Equal(suite.T(), ErrRefreshTokenInvalid, err)
+
+	token, err := suite.repository.StartSession(suite.userID, time.Now().Add(-time.Minute))
+	assert.NoError(suite.T(), err)
+	_, err = suite.repository.Rotate(token.ID, suite.expiresAt, time.Now())
+	assert.Equal(suite.T(), ErrRefreshTokenInvalid, err)
+}
+
+func (suite *TokenRepositoryTestSuite) TestRevokeSessionOnlyAffectsThatSession() {
+	phone, err := suite.repository.StartSession(suite.userID, suite.expiresAt)
+	assert.NoError(suite.T(), err)
+	laptop, err := suite.repository.StartSession(suite.userID, suite.expiresAt)
+	assert.NoError(suite.T(), err)
+
+	// Another user can't log out someone else's session
+	assert.NoError(suite.T(), suite.repository.RevokeSession(phone.FamilyID, uuid.New()))
+	active, err := suite.repository.IsSessionActive(phone.FamilyID, suite.userID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), active)
+
+	assert.NoError(suite.T(), suite.repository.RevokeSession(phone.FamilyID, suite.userID))
+
+	_, err = suite.repository.Rotate(phone.ID, suite.expiresAt, time.Now())
+	assert.Equal(suite.T(), ErrSessionRevoked, err)
+	_, err = suite.repository.Rotate(laptop.ID, suite.expiresAt, time.Now())
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *TokenRepositoryTestSuite) TestRevokeAllSessions() {
+	first, err := suite.repository.StartSession(suite.userID, suite.expiresAt)
+	assert.NoError(suite.T(), err)
+	_, err = suite.repository.StartSession(suite.userID, suite.expiresAt)
+	assert.NoError(suite.T(), err)
+	other, err := suite.repository.StartSession(uuid.New(), suite.expiresAt)
+	assert.NoError(suite.T(), err)
+
+	revoked, err := suite.repository.RevokeAllSessions(suite.userID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(2), revoked)
+
+	active, err := suite.repository.IsSessionActive(first.FamilyID, suite.userID)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), active)
+	active, err = suite.repository.IsSessionActive(other.FamilyID, other.UserID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), active)
+}
+
+func TestTokenRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(TokenRepositoryTestSuite))
+}
@@ -1,6 +1,9 @@
 package repositories
 
 import (
+	"strings"
+	"time"
+
 	"github.com/denys89/ewallet-api/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -8,10 +11,12 @@ import (
 
 type TransactionRepository struct {
 	db *gorm.DB
+	// fees prices payments and transfers; nil charges no fees
+	fees *models.FeeSchedule
 }
 
 func NewTransactionRepository(db *gorm.DB) *TransactionRepository {
-	return &TransactionRepository{db: db}
+	return &TransactionRepository{db: db, fees: models.Fees}
 }
 
 func (r *TransactionRepository) getUserForUpdate(tx *gorm.DB, userID uuid.UUID) (*models.User, error) {
@@ -22,37 +27,152 @@ func (r *TransactionRepository) getUserForUpdate(tx *gorm.DB, userID uuid.UUID)
 	return &user, nil
 }
 
-func (r *TransactionRepository) GetUserTransactions(userID uuid.UUID, page, limit int) ([]models.Transaction, error) {
+// availableBalance returns a locked wallet's balance minus the active holds
+// in its currency.
+func (r *TransactionRepository) availableBalance(tx *gorm.DB, wallet *models.Wallet) (models.Money, error) {
+	held, err := NewHoldRepository(tx).ActiveHoldsTotal(wallet.UserID, wallet.Currency)
+	if err != nil {
+		return 0, err
+	}
+	return wallet.Balance - held, nil
+}
+
+// GetUserTransactions returns one page of the user's own transaction rows
+// matching filter, newest first, together with the total number of matches.
+func (r *TransactionRepository) GetUserTransactions(userID uuid.UUID, filter models.TransactionFilter, page, limit int) ([]models.Transaction, int64, error) {
 	var transactions []models.Transaction
+	var total int64
 	offset := (page - 1) * limit
 
-	err := r.db.Where("user_id = ? OR recipient_id = ?", userID, userID).
-		Order("created_at desc").
+	query := applyTransactionFilter(r.db.Model(&models.Transaction{}).Where("user_id = ?", userID), filter).
+		Session(&gorm.Session{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Order("created_at desc, id desc").
 		Offset(offset).
 		Limit(limit).
 		Find(&transactions).Error
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+// GetUserTransactionsByCursor returns up to limit of the user's transactions
+// matching filter that come after cursor in (created_at, id) order, always
+// sorted newest first. A nil cursor starts at the newest transaction. hasMore
+// reports whether further rows exist in the cursor's direction. Unlike offset
+// paging, rows inserted between requests never shift the page boundaries.
+func (r *TransactionRepository) GetUserTransactionsByCursor(userID uuid.UUID, filter models.TransactionFilter, cursor *models.TransactionCursor, limit int) ([]models.Transaction, bool, error) {
+	var transactions []models.Transaction
+
+	query := applyTransactionFilter(r.db.Where("user_id = ?", userID), filter)
+	order := "created_at desc, id desc"
+
+	if cursor != nil {
+		if cursor.Direction == models.CursorPrev {
+			query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+			order = "created_at asc, id asc"
+		} else {
+			query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		}
+	}
+
+	if err := query.Order(order).Limit(limit + 1).Find(&transactions).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(transactions) > limit
+	if hasMore {
+		transactions = transactions[:limit]
+	}
+
+	if cursor != nil && cursor.Direction == models.CursorPrev {
+		for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
+			transactions[i], transactions[j] = transactions[j], transactions[i]
+		}
+	}
+
+	return transactions, hasMore, nil
+}
+
+// applyTransactionFilter adds the WHERE clauses for every set filter field.
+func applyTransactionFilter(query *gorm.DB, filter models.TransactionFilter) *gorm.DB {
+	if !filter.StartDate.IsZero() {
+		query = query.Where("created_at >= ?", filter.StartDate)
+	}
+	if !filter.EndDate.IsZero() {
+		// End date is inclusive of the whole day
+		query = query.Where("created_at < ?", filter.EndDate.AddDate(0, 0, 1))
 	}
-	return transactions, nil
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Currency != "" {
+		query = query.Where("currency = ?", filter.Currency)
+	}
+	if filter.TransactionType != "" {
+		query = query.Where("transaction_type = ?", filter.TransactionType)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.MinAmount > 0 {
+		query = query.Where("amount >= ?", filter.MinAmount)
+	}
+	if filter.MaxAmount > 0 {
+		query = query.Where("amount <= ?", filter.MaxAmount)
+	}
+	if filter.Counterparty != "" {
+		query = query.Where("counterparty_id IN (?)",
+			query.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Select("id").
+				Where("id = ? OR phone_number = ?", filter.Counterparty, filter.Counterparty))
+	}
+	if filter.Search != "" {
+		query = query.Where("description LIKE ? ESCAPE '!'", "%"+escapeLike(filter.Search)+"%")
+	}
+	return query
 }
 
-func (r *TransactionRepository) TopUp(userID uuid.UUID, amount float64) (uuid.UUID, float64, float64, error) {
+// escapeLike escapes LIKE wildcards using '!' as the escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func (r *TransactionRepository) TopUp(userID uuid.UUID, currency string, amount models.Money) (uuid.UUID, models.Money, models.Money, error) {
 	var transactionID uuid.UUID
-	var balanceBefore, balanceAfter float64
+	var balanceBefore, balanceAfter models.Money
 
 	err := r.db.Transaction(func(tx *gorm.DB) error {
 		user, err := r.getUserForUpdate(tx, userID)
 		if err != nil {
 			return err
 		}
+		wallet, err := NewWalletRepository(tx).getForUpdate(tx, userID, currency)
+		if err != nil {
+			return err
+		}
 
-		balanceBefore = user.Balance
+		balanceBefore = wallet.Balance
 		balanceAfter = balanceBefore + amount
+		if !balanceAfter.IsValid() {
+			return models.ErrAmountOutOfRange
+		}
+
+		limits := NewLimitRepository(tx)
+		if err := limits.CheckBalance(user, wallet, balanceAfter, models.LimitMaxBalance); err != nil {
+			return err
+		}
+		if err := limits.Check(user, models.TOPUP, currency, amount, time.Now()); err != nil {
+			return err
+		}
 
-		// Update user balance
-		if err := tx.Model(user).Update("balance", balanceAfter).Error; err != nil {
+		// Update wallet balance
+		if err := tx.Model(wallet).Update("balance", balanceAfter).Error; err != nil {
 			return err
 		}
 
@@ -62,6 +182,7 @@ func (r *TransactionRepository) TopUp(userID uuid.UUID, amount float64) (uuid.UU
 			UserID:          userID,
 			Type:            models.CREDIT,
 			TransactionType: models.TOPUP,
+			Currency:        currency,
 			BalanceBefore:   balanceBefore,
 			BalanceAfter:    balanceAfter,
 			Amount:          amount,
@@ -73,6 +194,20 @@ func (r *TransactionRepository) TopUp(userID uuid.UUID, amount float64) (uuid.UU
 			return err
 		}
 
+		// Post the balanced journal: funding -> user wallet
+		ledger := NewLedgerRepository(tx)
+		userAccount, err := ledger.UserAccount(userID, currency)
+		if err != nil {
+			return err
+		}
+		fundingAccount, err := ledger.SystemAccount(models.SystemAccountTopUpFunding, currency)
+		if err != nil {
+			return err
+		}
+		if _, err := ledger.Move(models.JournalTopUp, &transaction.ID, transaction.Description, fundingAccount, userAccount, amount); err != nil {
+			return err
+		}
+
 		transactionID = transaction.ID
 		return nil
 	})
@@ -84,9 +219,8 @@ func (r *TransactionRepository) TopUp(userID uuid.UUID, amount float64) (uuid.UU
 	return transactionID, balanceBefore, balanceAfter, nil
 }
 
-func (r *TransactionRepository) Payment(userID uuid.UUID, amount float64, remarks string) (*models.Transaction, float64, float64, error) {
-	var transaction models.Transaction
-	var balanceBefore, balanceAfter float64
+func (r *TransactionRepository) Payment(userID uuid.UUID, currency string, amount models.Money, remarks string) (*models.Transaction, models.Money, models.Money, error) {
+	var transaction *models.Transaction
 
 	err := r.db.Transaction(func(tx *gorm.DB) error {
 		user, err := r.getUserForUpdate(tx, userID)
@@ -94,48 +228,257 @@ func (r *TransactionRepository) Payment(userID uuid.UUID, amount float64, remark
 			return err
 		}
 
-		balanceBefore = user.Balance
-		if balanceBefore < amount {
-			return models.ErrInvalidTransaction
-		}
+		transaction, err = r.debitPayment(tx, user, currency, amount, remarks, nil)
+		return err
+	})
 
-		balanceAfter = balanceBefore - amount
+	if err != nil {
+		return nil, 0, 0, err
+	}
 
-		// Update user balance
-		if err := tx.Model(user).Update("balance", balanceAfter).Error; err != nil {
-			return err
-		}
+	return transaction, transaction.BalanceBefore, transaction.BalanceAfter - transaction.Fee, nil
+}
 
-		// Create transaction record
-		transaction = models.Transaction{
-			ID:              uuid.New(),
-			UserID:          userID,
-			Type:            models.DEBIT,
-			TransactionType: models.PAYMENT,
-			Amount:          amount,
-			BalanceBefore:   balanceBefore,
-			BalanceAfter:    balanceAfter,
-			Description:     remarks,
-			Status:          models.SUCCESS,
+// PayMerchant pays a merchant, crediting the owner's settlement wallet. The
+// payment must be in the merchant's settlement currency.
+func (r *TransactionRepository) PayMerchant(userID, merchantID uuid.UUID, currency string, amount models.Money, remarks string) (*models.Transaction, models.Money, models.Money, error) {
+	var transaction *models.Transaction
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var merchant models.Merchant
+		if err := tx.First(&merchant, "id = ?", merchantID).Error; err != nil {
+			return err
 		}
 
-		if err := tx.Create(&transaction).Error; err != nil {
+		user, err := r.getUserForUpdate(tx, userID)
+		if err != nil {
 			return err
 		}
 
-		return nil
+		transaction, err = r.debitPayment(tx, user, currency, amount, remarks, &merchant)
+		return err
 	})
 
 	if err != nil {
 		return nil, 0, 0, err
 	}
 
-	return &transaction, balanceBefore, balanceAfter, nil
+	return transaction, transaction.BalanceBefore, transaction.BalanceAfter - transaction.Fee, nil
+}
+
+// debitPayment moves amount from a locked user's wallet in currency to
+// payment settlement, or to the merchant's settlement wallet when merchant is
+// set, and records the PAYMENT transaction, plus its fee if one applies.
+func (r *TransactionRepository) debitPayment(tx *gorm.DB, user *models.User, currency string, amount models.Money, remarks string, merchant *models.Merchant) (*models.Transaction, error) {
+	if merchant != nil {
+		if merchant.Status != models.MerchantActive {
+			return nil, models.ErrMerchantNotActive
+		}
+		if merchant.OwnerID == user.ID {
+			return nil, models.ErrPayOwnMerchant
+		}
+		if merchant.SettlementCurrency != currency {
+			return nil, models.ErrCurrencyMismatch
+		}
+	}
+
+	wallet, err := NewWalletRepository(tx).getForUpdate(tx, user.ID, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := r.fees.Quote(models.PAYMENT, user.KYCTier, currency, amount)
+
+	available, err := r.availableBalance(tx, wallet)
+	if err != nil {
+		return nil, err
+	}
+	if available < quote.Total {
+		return nil, models.ErrInvalidTransaction
+	}
+	if err := NewLimitRepository(tx).Check(user, models.PAYMENT, currency, amount, time.Now()); err != nil {
+		return nil, err
+	}
+
+	balanceBefore := wallet.Balance
+	balanceAfter := balanceBefore - amount
+
+	// Update wallet balance
+	if err := tx.Model(wallet).Update("balance", balanceAfter).Error; err != nil {
+		return nil, err
+	}
+
+	// Create transaction record
+	transaction := &models.Transaction{
+		ID:              uuid.New(),
+		UserID:          user.ID,
+		Type:            models.DEBIT,
+		TransactionType: models.PAYMENT,
+		Currency:        currency,
+		Amount:          amount,
+		BalanceBefore:   balanceBefore,
+		BalanceAfter:    balanceAfter,
+		Description:     remarks,
+		Status:          models.SUCCESS,
+		Fee:             quote.Fee,
+		FeeBreakdown:    &quote,
+	}
+	if merchant != nil {
+		transaction.RecipientID = &merchant.OwnerID
+		transaction.CounterpartyID = &merchant.OwnerID
+		transaction.MerchantID = &merchant.ID
+	}
+
+	if err := tx.Create(transaction).Error; err != nil {
+		return nil, err
+	}
+
+	// Post the balanced journal: user wallet -> payment settlement, or the
+	// merchant's settlement wallet
+	ledger := NewLedgerRepository(tx)
+	userAccount, err := ledger.UserAccount(user.ID, currency)
+	if err != nil {
+		return nil, err
+	}
+	var settlementAccount *models.LedgerAccount
+	if merchant != nil {
+		settlementAccount, err = r.creditMerchant(tx, merchant, user, transaction)
+	} else {
+		settlementAccount, err = ledger.SystemAccount(models.SystemAccountPaymentSettlement, currency)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ledger.Move(models.JournalPayment, &transaction.ID, remarks, userAccount, settlementAccount, amount); err != nil {
+		return nil, err
+	}
+
+	if err := r.chargeFee(tx, wallet, transaction, "Payment fee"); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// creditMerchant credits a payment to the merchant owner's settlement wallet
+// and records the owner's PAYMENT CREDIT row, which references the payer's
+// row like the recipient's row of a transfer. It returns the ledger account
+// to post the payment to. Takings are not held to the owner's personal
+// maximum balance, or a busy merchant would stop accepting payments once the
+// owner reached their tier's cap.
+func (r *TransactionRepository) creditMerchant(tx *gorm.DB, merchant *models.Merchant, payer *models.User, payment *models.Transaction) (*models.LedgerAccount, error) {
+	owner, err := r.getUserForUpdate(tx, merchant.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := NewWalletRepository(tx).getForUpdate(tx, owner.ID, payment.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	balanceBefore := wallet.Balance
+	balanceAfter := balanceBefore + payment.Amount
+	if !balanceAfter.IsValid() {
+		return nil, models.ErrAmountOutOfRange
+	}
+	if err := tx.Model(wallet).Update("balance", balanceAfter).Error; err != nil {
+		return nil, err
+	}
+
+	credit := models.Transaction{
+		ID:              uuid.New(),
+		UserID:          owner.ID,
+		Type:            models.CREDIT,
+		TransactionType: models.PAYMENT,
+		Currency:        payment.Currency,
+		BalanceBefore:   balanceBefore,
+		BalanceAfter:    balanceAfter,
+		Amount:          payment.Amount,
+		Status:          models.SUCCESS,
+		ReferenceNumber: payment.ID.String(),
+		Description:     payment.Description,
+		CounterpartyID:  &payer.ID,
+		MerchantID:      &merchant.ID,
+	}
+	if err := tx.Create(&credit).Error; err != nil {
+		return nil, err
+	}
+
+	return NewLedgerRepository(tx).UserAccount(owner.ID, payment.Currency)
+}
+
+// chargeFee debits the fee of a transaction just created from the payer's
+// locked wallet to fee revenue, recorded as a separate FEE transaction
+// linked to it. It does nothing when the transaction is free.
+func (r *TransactionRepository) chargeFee(tx *gorm.DB, wallet *models.Wallet, charged *models.Transaction, description string) error {
+	if charged.Fee == 0 {
+		return nil
+	}
+
+	balanceBefore := charged.BalanceAfter
+	balanceAfter := balanceBefore - charged.Fee
+	if err := tx.Model(wallet).Update("balance", balanceAfter).Error; err != nil {
+		return err
+	}
+
+	fee := &models.Transaction{
+		ID:                    uuid.New(),
+		UserID:                wallet.UserID,
+		Type:                  models.DEBIT,
+		TransactionType:       models.FEE,
+		Currency:              charged.Currency,
+		Amount:                charged.Fee,
+		BalanceBefore:         balanceBefore,
+		BalanceAfter:          balanceAfter,
+		Description:           description,
+		Status:                models.SUCCESS,
+		OriginalTransactionID: &charged.ID,
+	}
+	if err := tx.Create(fee).Error; err != nil {
+		return err
+	}
+
+	// Post the balanced journal: payer wallet -> fee revenue
+	ledger := NewLedgerRepository(tx)
+	payerAccount, err := ledger.UserAccount(wallet.UserID, charged.Currency)
+	if err != nil {
+		return err
+	}
+	revenueAccount, err := ledger.SystemAccount(models.SystemAccountFeeRevenue, charged.Currency)
+	if err != nil {
+		return err
+	}
+	_, err = ledger.Move(models.JournalFee, &fee.ID, description, payerAccount, revenueAccount, charged.Fee)
+	return err
+}
+
+// ConversionQuoter prices converting amount from one currency into another
+// for a transfer. It runs inside the transfer's DB transaction.
+type ConversionQuoter func(from, to string, amount models.Money) (*models.FXQuote, error)
+
+// Transfer moves amount from the sender's wallet to the recipient's wallet in
+// the same currency. It fails with models.ErrCurrencyMismatch when the
+// recipient has no wallet in that currency.
+func (r *TransactionRepository) Transfer(userID uuid.UUID, currency string, amount models.Money, recipientID uuid.UUID, remarks string) (*models.Transaction, models.Money, models.Money, error) {
+	return r.transfer(userID, currency, amount, recipientID, remarks, nil)
+}
+
+// TransferConverted is Transfer for a recipient who may not hold currency:
+// the amount is then quoted with quote and converted into the recipient's
+// default currency wallet in the same DB transaction. The used quote is
+// stored against the sender's debit. Fees and the sender's limits apply to
+// amount in currency.
+func (r *TransactionRepository) TransferConverted(userID uuid.UUID, currency string, amount models.Money, recipientID uuid.UUID, remarks string, quote ConversionQuoter) (*models.Transaction, models.Money, models.Money, error) {
+	return r.transfer(userID, currency, amount, recipientID, remarks, quote)
 }
 
-func (r *TransactionRepository) Transfer(userID uuid.UUID, amount float64, targetUser string, remarks string) (*models.Transaction, float64, float64, error) {
+func (r *TransactionRepository) transfer(userID uuid.UUID, currency string, amount models.Money, recipientID uuid.UUID, remarks string, quoteConversion ConversionQuoter) (*models.Transaction, models.Money, models.Money, error) {
 	var transaction models.Transaction
-	var balanceBefore, balanceAfter float64
+	var balanceBefore, balanceAfter models.Money
+
+	if recipientID == userID {
+		return nil, 0, 0, models.ErrSelfTransfer
+	}
 
 	err := r.db.Transaction(func(tx *gorm.DB) error {
 		// Get sender with lock
@@ -145,20 +488,60 @@ func (r *TransactionRepository) Transfer(userID uuid.UUID, amount float64, targe
 		}
 
 		// Get recipient with lock
-		recipient, err := r.getUserForUpdate(tx, uuid.MustParse(targetUser))
+		recipient, err := r.getUserForUpdate(tx, recipientID)
+		if err != nil {
+			return err
+		}
+
+		wallets := NewWalletRepository(tx)
+		senderWallet, err := wallets.getForUpdate(tx, sender.ID, currency)
 		if err != nil {
 			return err
 		}
+		recipientWallet, err := wallets.getForUpdate(tx, recipient.ID, currency)
+		var conversion *models.FXQuote
+		if err == models.ErrWalletNotFound && quoteConversion != nil && currency != models.DefaultCurrency {
+			recipientWallet, err = wallets.getForUpdate(tx, recipient.ID, models.DefaultCurrency)
+			if err != nil {
+				return err
+			}
+			conversion, err = quoteConversion(currency, models.DefaultCurrency, amount)
+			if err != nil {
+				return err
+			}
+		}
+		if err == models.ErrWalletNotFound {
+			return models.ErrCurrencyMismatch
+		}
+		if err != nil {
+			return err
+		}
+		credited := amount
+		if conversion != nil {
+			credited = conversion.ToAmount
+		}
+
+		quote := r.fees.Quote(models.TRANSFER, sender.KYCTier, currency, amount)
 
-		balanceBefore = sender.Balance
-		if balanceBefore < amount {
+		available, err := r.availableBalance(tx, senderWallet)
+		if err != nil {
+			return err
+		}
+		if available < quote.Total {
 			return models.ErrInvalidTransaction
 		}
 
+		limits := NewLimitRepository(tx)
+		if err := limits.Check(sender, models.TRANSFER, currency, amount, time.Now()); err != nil {
+			return err
+		}
+
+		balanceBefore = senderWallet.Balance
+
 		balanceAfter = balanceBefore - amount
 
 		// Update sender's balance
-		if err := tx.Model(sender).Update("balance", balanceAfter).Error; err != nil {
+		if err := tx.Model(senderWallet).Update("balance", balanceAfter).Error; err != nil {
 			return err
 		}
 
@@ -169,23 +552,42 @@ func (r *TransactionRepository) Transfer(userID uuid.UUID, amount float64, targe
 			UserID:          userID,
 			Type:            models.DEBIT,
 			TransactionType: models.TRANSFER,
+			Currency:        currency,
 			BalanceBefore:   balanceBefore,
 			BalanceAfter:    balanceAfter,
 			Amount:          amount,
 			Status:          models.SUCCESS,
 			Description:     remarks,
 			RecipientID:     &recipient.ID,
+			CounterpartyID:  &recipient.ID,
+			Fee:             quote.Fee,
+			FeeBreakdown:    &quote,
 		}
 
 		if err := tx.Create(&transaction).Error; err != nil {
 			return err
 		}
 
+		if conversion != nil {
+			conversion.UserID = sender.ID
+			conversion.Status = models.FXQuoteUsed
+			conversion.TransactionID = &transaction.ID
+			if err := tx.Create(conversion).Error; err != nil {
+				return err
+			}
+		}
+
 		// Update recipient's balance
-		recipientBalanceBefore := recipient.Balance
-		recipientBalanceAfter := recipientBalanceBefore + amount
+		recipientBalanceBefore := recipientWallet.Balance
+		recipientBalanceAfter := recipientBalanceBefore + credited
+		if !recipientBalanceAfter.IsValid() {
+			return models.ErrAmountOutOfRange
+		}
+		if err := limits.CheckBalance(recipient, recipientWallet, recipientBalanceAfter, models.LimitRecipientMaxBalance); err != nil {
+			return err
+		}
 
-		if err := tx.Model(recipient).Update("balance", recipientBalanceAfter).Error; err != nil {
+		if err := tx.Model(recipientWallet).Update("balance", recipientBalanceAfter).Error; err != nil {
 			return err
 		}
 
@@ -195,18 +597,43 @@ func (r *TransactionRepository) Transfer(userID uuid.UUID, amount float64, targe
 			UserID:          recipient.ID,
 			Type:            models.CREDIT,
 			TransactionType: models.TRANSFER,
+			Currency:        recipientWallet.Currency,
 			BalanceBefore:   recipientBalanceBefore,
 			BalanceAfter:    recipientBalanceAfter,
-			Amount:          amount,
+			Amount:          credited,
 			Status:          models.SUCCESS,
 			ReferenceNumber: senderTransID.String(),
 			Description:     remarks,
+			CounterpartyID:  &sender.ID,
 		}
 
 		if err := tx.Create(&recipientTrans).Error; err != nil {
 			return err
 		}
 
+		// Post the balanced journal: sender wallet -> recipient wallet
+		ledger := NewLedgerRepository(tx)
+		senderAccount, err := ledger.UserAccount(sender.ID, currency)
+		if err != nil {
+			return err
+		}
+		recipientAccount, err := ledger.UserAccount(recipient.ID, recipientWallet.Currency)
+		if err != nil {
+			return err
+		}
+		if conversion == nil {
+			if _, err := ledger.Move(models.JournalTransfer, &transaction.ID, remarks, senderAccount, recipientAccount, amount); err != nil {
+				return err
+			}
+		} else if err := r.postConvertedTransfer(ledger, &transaction, conversion, senderAccount, recipientAccount); err != nil {
+			return err
+		}
+
+		if err := r.chargeFee(tx, senderWallet, &transaction, "Transfer fee"); err != nil {
+			return err
+		}
+		balanceAfter -= transaction.Fee
+
 		return nil
 	})
 
@@ -216,3 +643,254 @@ func (r *TransactionRepository) Transfer(userID uuid.UUID, amount float64, targe
 
 	return &transaction, balanceBefore, balanceAfter, nil
 }
+
+// postConvertedTransfer journals a converted transfer. A journal never mixes
+// currencies, so each leg balances against the FX position account of its
+// own currency, as conversions between a user's own wallets do.
+func (r *TransactionRepository) postConvertedTransfer(ledger *LedgerRepository, transaction *models.Transaction, conversion *models.FXQuote, senderAccount, recipientAccount *models.LedgerAccount) error {
+	fromPosition, err := ledger.SystemAccount(models.SystemAccountFXPosition, conversion.FromCurrency)
+	if err != nil {
+		return err
+	}
+	toPosition, err := ledger.SystemAccount(models.SystemAccountFXPosition, conversion.ToCurrency)
+	if err != nil {
+		return err
+	}
+	if _, err := ledger.Move(models.JournalTransfer, &transaction.ID, transaction.Description, senderAccount, fromPosition, conversion.FromAmount); err != nil {
+		return err
+	}
+	_, err = ledger.Move(models.JournalConversion, &transaction.ID, transaction.Description, toPosition, recipientAccount, conversion.ToAmount)
+	return err
+}
+
+func (r *TransactionRepository) getTransactionForUpdate(tx *gorm.DB, transactionID uuid.UUID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&transaction, "id = ?", transactionID).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func isRefundable(t *models.Transaction) bool {
+	if t.Type != models.DEBIT {
+		return false
+	}
+	if t.TransactionType != models.PAYMENT && t.TransactionType != models.TRANSFER {
+		return false
+	}
+	return t.Status == models.SUCCESS || t.Status == models.PARTIALLY_REFUNDED
+}
+
+// FindRefundable returns the transaction a refund of transactionID applies to.
+// The recipient's CREDIT row of a transfer or merchant payment resolves to the
+// payer's DEBIT row, which is where the refunded amount is tracked.
+func (r *TransactionRepository) FindRefundable(transactionID uuid.UUID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.First(&transaction, "id = ?", transactionID).Error; err != nil {
+		return nil, err
+	}
+
+	if transaction.Type == models.CREDIT && (transaction.TransactionType == models.TRANSFER || transaction.MerchantID != nil) {
+		var original models.Transaction
+		if err := r.db.First(&original, "id = ?", transaction.ReferenceNumber).Error; err != nil {
+			return nil, err
+		}
+		transaction = original
+	}
+
+	if !isRefundable(&transaction) {
+		return nil, models.ErrTransactionNotRefundable
+	}
+	return &transaction, nil
+}
+
+// Refund returns part or all of a PAYMENT or TRANSFER to the payer. A zero
+// amount refunds everything that has not been refunded yet. Transfers are
+// refunded from the recipient's wallet, merchant payments from the merchant's
+// settlement wallet and other payments from payment settlement.
+// It returns the updated original and the payer's CREDIT refund record.
+func (r *TransactionRepository) Refund(transactionID uuid.UUID, amount models.Money, remarks string) (*models.Transaction, *models.Transaction, error) {
+	return r.compensate(transactionID, amount, models.REFUND, remarks)
+}
+
+// Reverse undoes the unrefunded remainder of a PAYMENT or TRANSFER and marks
+// the original as REVERSED.
+func (r *TransactionRepository) Reverse(transactionID uuid.UUID, remarks string) (*models.Transaction, *models.Transaction, error) {
+	return r.compensate(transactionID, 0, models.REVERSAL, remarks)
+}
+
+func (r *TransactionRepository) compensate(transactionID uuid.UUID, amount models.Money, kind string, remarks string) (*models.Transaction, *models.Transaction, error) {
+	var original *models.Transaction
+	var refund models.Transaction
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		original, err = r.getTransactionForUpdate(tx, transactionID)
+		if err != nil {
+			return err
+		}
+		if !isRefundable(original) {
+			return models.ErrTransactionNotRefundable
+		}
+		// A converted transfer credited the recipient in another currency, so
+		// it can't be taken back from them in this one
+		var conversions int64
+		if err := tx.Model(&models.FXQuote{}).Where("transaction_id = ?", original.ID).Count(&conversions).Error; err != nil {
+			return err
+		}
+		if conversions > 0 {
+			return models.ErrTransactionNotRefundable
+		}
+
+		remaining := original.RefundableAmount()
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return models.ErrRefundExceedsAmount
+		}
+		if err := models.Currencies[original.Currency].ValidateAmount(amount); err != nil {
+			return err
+		}
+
+		if remarks == "" {
+			remarks = "Refund of " + original.ReferenceNumber
+			if kind == models.REVERSAL {
+				remarks = "Reversal of " + original.ReferenceNumber
+			}
+		}
+
+		// Lock payer first, matching the sender-then-recipient order of Transfer
+		payer, err := r.getUserForUpdate(tx, original.UserID)
+		if err != nil {
+			return err
+		}
+
+		wallets := NewWalletRepository(tx)
+		ledger := NewLedgerRepository(tx)
+		var source *models.LedgerAccount
+		var counterpartyID *uuid.UUID
+
+		if original.RecipientID != nil {
+			recipient, err := r.getUserForUpdate(tx, *original.RecipientID)
+			if err != nil {
+				return err
+			}
+			recipientWallet, err := wallets.getForUpdate(tx, recipient.ID, original.Currency)
+			if err != nil {
+				return err
+			}
+			available, err := r.availableBalance(tx, recipientWallet)
+			if err != nil {
+				return err
+			}
+			if available < amount {
+				return models.ErrInvalidTransaction
+			}
+
+			recipientBalanceBefore := recipientWallet.Balance
+			recipientBalanceAfter := recipientBalanceBefore - amount
+			if err := tx.Model(recipientWallet).Update("balance", recipientBalanceAfter).Error; err != nil {
+				return err
+			}
+
+			recipientTrans := models.Transaction{
+				ID:                    uuid.New(),
+				UserID:                recipient.ID,
+				Type:                  models.DEBIT,
+				TransactionType:       kind,
+				Currency:              original.Currency,
+				BalanceBefore:         recipientBalanceBefore,
+				BalanceAfter:          recipientBalanceAfter,
+				Amount:                amount,
+				Status:                models.SUCCESS,
+				Description:           remarks,
+				RecipientID:           &payer.ID,
+				CounterpartyID:        &payer.ID,
+				OriginalTransactionID: &original.ID,
+				MerchantID:            original.MerchantID,
+			}
+			if err := tx.Create(&recipientTrans).Error; err != nil {
+				return err
+			}
+
+			if source, err = ledger.UserAccount(recipient.ID, original.Currency); err != nil {
+				return err
+			}
+			counterpartyID = &recipient.ID
+		} else {
+			if source, err = ledger.SystemAccount(models.SystemAccountPaymentSettlement, original.Currency); err != nil {
+				return err
+			}
+		}
+
+		payerWallet, err := wallets.getForUpdate(tx, payer.ID, original.Currency)
+		if err != nil {
+			return err
+		}
+		payerBalanceBefore := payerWallet.Balance
+		payerBalanceAfter := payerBalanceBefore + amount
+		if !payerBalanceAfter.IsValid() {
+			return models.ErrAmountOutOfRange
+		}
+		if err := NewLimitRepository(tx).CheckBalance(payer, payerWallet, payerBalanceAfter, models.LimitMaxBalance); err != nil {
+			return err
+		}
+		if err := tx.Model(payerWallet).Update("balance", payerBalanceAfter).Error; err != nil {
+			return err
+		}
+
+		refund = models.Transaction{
+			ID:                    uuid.New(),
+			UserID:                payer.ID,
+			Type:                  models.CREDIT,
+			TransactionType:       kind,
+			Currency:              original.Currency,
+			BalanceBefore:         payerBalanceBefore,
+			BalanceAfter:          payerBalanceAfter,
+			Amount:                amount,
+			Status:                models.SUCCESS,
+			Description:           remarks,
+			CounterpartyID:        counterpartyID,
+			OriginalTransactionID: &original.ID,
+			MerchantID:            original.MerchantID,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+
+		payerAccount, err := ledger.UserAccount(payer.ID, original.Currency)
+		if err != nil {
+			return err
+		}
+		journalKind := models.JournalRefund
+		if kind == models.REVERSAL {
+			journalKind = models.JournalReversal
+		}
+		if _, err := ledger.Move(journalKind, &refund.ID, remarks, source, payerAccount, amount); err != nil {
+			return err
+		}
+
+		// Track the refunded amount on the original so it can't be over-refunded
+		original.RefundedAmount += amount
+		switch {
+		case kind == models.REVERSAL:
+			original.Status = models.REVERSED
+		case original.RefundableAmount() == 0:
+			original.Status = models.REFUNDED
+		default:
+			original.Status = models.PARTIALLY_REFUNDED
+		}
+
+		return tx.Model(original).Updates(map[string]interface{}{
+			"refunded_amount": original.RefundedAmount,
+			"status":          original.Status,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return original, &refund, nil
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"testing"
+	"time"
 
 	"github.com/denys89/ewallet-api/models"
 	"github.com/google/uuid"
@@ -23,7 +24,7 @@ func (suite *TransactionRepositoryTestSuite) SetupTest() {
 	assert.NoError(suite.T(), err)
 
 	// Migrate the schema
-	err = db.AutoMigrate(&models.User{}, &models.Transaction{})
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.FXQuote{})
 	assert.NoError(suite.T(), err)
 
 	suite.db = db
@@ -37,56 +38,63 @@ func (suite *TransactionRepositoryTestSuite) SetupTest() {
 		PhoneNumber: "1234567890",
 		Address:     "123 Main St",
 		Pin:         "123456",
-		Balance:     1000,
+		KYCTier:     models.KYCTierFull,
 	}
 	err = db.Create(suite.user).Error
 	assert.NoError(suite.T(), err)
+
+	opening := models.NewMoneyFromMajor(1000)
+	err = db.Create(&models.Wallet{UserID: suite.user.ID, Currency: models.DefaultCurrency, Balance: opening}).Error
+	assert.NoError(suite.T(), err)
+
+	_, err = NewLedgerRepository(db).PostOpeningBalance(suite.user.ID, models.DefaultCurrency, opening)
+	assert.NoError(suite.T(), err)
 }
 
 func (suite *TransactionRepositoryTestSuite) TestTopUp() {
-	amount := float64(500)
-	transactionID, balanceBefore, balanceAfter, err := suite.repository.TopUp(suite.user.ID, amount)
+	amount := models.NewMoneyFromMajor(500)
+	transactionID, balanceBefore, balanceAfter, err := suite.repository.TopUp(suite.user.ID, models.DefaultCurrency, amount)
 
 	assert.NoError(suite.T(), err)
 	assert.NotNil(suite.T(), transactionID)
-	assert.Equal(suite.T(), float64(1000), balanceBefore)
-	assert.Equal(suite.T(), float64(1500), balanceAfter)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), balanceBefore)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1500), balanceAfter)
 
 	// Verify transaction was created
 	var transaction models.Transaction
 	err = suite.db.First(&transaction, "id = ?", transactionID).Error
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), "TOPUP", transaction.Type)
+	assert.Equal(suite.T(), models.TOPUP, transaction.TransactionType)
 	assert.Equal(suite.T(), amount, transaction.Amount)
 
 	// Verify user balance was updated
-	var user models.User
-	err = suite.db.First(&user, "id = ?", suite.user.ID).Error
+	var wallet models.Wallet
+	err = suite.db.First(&wallet, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), float64(1500), user.Balance)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1500), wallet.Balance)
 }
 
 func (suite *TransactionRepositoryTestSuite) TestPayment() {
-	amount := float64(300)
-	transactionID, balanceBefore, balanceAfter, err := suite.repository.Payment(suite.user.ID, amount, "Test payment")
+	amount := models.NewMoneyFromMajor(300)
+	payment, balanceBefore, balanceAfter, err := suite.repository.Payment(suite.user.ID, models.DefaultCurrency, amount, "Test payment")
 
 	assert.NoError(suite.T(), err)
-	assert.NotNil(suite.T(), transactionID)
-	assert.Equal(suite.T(), float64(1000), balanceBefore)
-	assert.Equal(suite.T(), float64(700), balanceAfter)
+	assert.NotNil(suite.T(), payment)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), balanceBefore)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(700), balanceAfter)
 
 	// Verify transaction was created
 	var transaction models.Transaction
-	err = suite.db.First(&transaction, "id = ?", transactionID).Error
+	err = suite.db.First(&transaction, "id = ?", payment.ID).Error
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), "PAYMENT", transaction.Type)
+	assert.Equal(suite.T(), models.PAYMENT, transaction.TransactionType)
 	assert.Equal(suite.T(), amount, transaction.Amount)
 
 	// Verify user balance was updated
-	var user models.User
-	err = suite.db.First(&user, "id = ?", suite.user.ID).Error
+	var wallet models.Wallet
+	err = suite.db.First(&wallet, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), float64(700), user.Balance)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(700), wallet.Balance)
 }
 
 func (suite *TransactionRepositoryTestSuite) TestGetUserTransactions() {
@@ -96,17 +104,17 @@ func (suite *TransactionRepositoryTestSuite) TestGetUserTransactions() {
 			ID:            uuid.New(),
 			UserID:        suite.user.ID,
 			Type:          "TOPUP",
-			Amount:        500,
-			BalanceBefore: 1000,
-			BalanceAfter:  1500,
+			Amount:        models.NewMoneyFromMajor(500),
+			BalanceBefore: models.NewMoneyFromMajor(1000),
+			BalanceAfter:  models.NewMoneyFromMajor(1500),
 		},
 		{
 			ID:            uuid.New(),
 			UserID:        suite.user.ID,
 			Type:          "PAYMENT",
-			Amount:        200,
-			BalanceBefore: 1500,
-			BalanceAfter:  1300,
+			Amount:        models.NewMoneyFromMajor(200),
+			BalanceBefore: models.NewMoneyFromMajor(1500),
+			BalanceAfter:  models.NewMoneyFromMajor(1300),
 		},
 	}
 
@@ -116,21 +124,391 @@ func (suite *TransactionRepositoryTestSuite) TestGetUserTransactions() {
 	}
 
 	// Test pagination
-	result, err := suite.repository.GetUserTransactions(suite.user.ID, 1, 10)
+	result, total, err := suite.repository.GetUserTransactions(suite.user.ID, models.TransactionFilter{}, 1, 10)
 	assert.NoError(suite.T(), err)
 	assert.Len(suite.T(), result, 2)
+	assert.Equal(suite.T(), int64(2), total)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestGetUserTransactionsFilters() {
+	recipient := suite.createRecipient()
+
+	_, _, _, err := suite.repository.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(50))
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.repository.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(20), "Coffee 100% arabica")
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.repository.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(300), recipient.ID, "Rent share")
+	assert.NoError(suite.T(), err)
+
+	// Pin the rows to fixed days in a fixed zone so the date filters don't
+	// depend on when or where the test runs, then move the top up into last
+	// month
+	wib := time.FixedZone("WIB", 7*60*60)
+	today := time.Date(2024, time.March, 15, 0, 0, 0, 0, wib)
+	err = suite.db.Model(&models.Transaction{}).
+		Where("user_id = ?", suite.user.ID).
+		Update("created_at", today.Add(9*time.Hour)).Error
+	assert.NoError(suite.T(), err)
+	err = suite.db.Model(&models.Transaction{}).
+		Where("user_id = ? AND transaction_type = ?", suite.user.ID, models.TOPUP).
+		Update("created_at", time.Date(2024, time.February, 15, 9, 0, 0, 0, wib)).Error
+	assert.NoError(suite.T(), err)
+
+	count := func(filter models.TransactionFilter) int64 {
+		result, total, err := suite.repository.GetUserTransactions(suite.user.ID, filter, 1, 10)
+		assert.NoError(suite.T(), err)
+		assert.Len(suite.T(), result, int(total))
+		return total
+	}
+
+	assert.Equal(suite.T(), int64(3), count(models.TransactionFilter{}))
+	assert.Equal(suite.T(), int64(1), count(models.TransactionFilter{Type: models.CREDIT}))
+	assert.Equal(suite.T(), int64(2), count(models.TransactionFilter{Type: models.DEBIT}))
+	assert.Equal(suite.T(), int64(1), count(models.TransactionFilter{TransactionType: models.TRANSFER}))
+	assert.Equal(suite.T(), int64(3), count(models.TransactionFilter{Status: models.SUCCESS}))
+	assert.Equal(suite.T(), int64(1), count(models.TransactionFilter{MinAmount: models.NewMoneyFromMajor(100)}))
+	assert.Equal(suite.T(), int64(2), count(models.TransactionFilter{MaxAmount: models.NewMoneyFromMajor(50)}))
+	assert.Equal(suite.T(), int64(1), count(models.TransactionFilter{Counterparty: recipient.ID.String()}))
+	assert.Equal(suite.T(), int64(1), count(models.TransactionFilter{Counterparty: recipient.PhoneNumber}))
+	assert.Equal(suite.T(), int64(0), count(models.TransactionFilter{Counterparty: "+0000000000"}))
+	assert.Equal(suite.T(), int64(1), count(models.TransactionFilter{Search: "rent"}))
+	assert.Equal(suite.T(), int64(1), count(models.TransactionFilter{Search: "100%"}))
+	assert.Equal(suite.T(), int64(0), count(models.TransactionFilter{Search: "0%a"}))
+
+	assert.Equal(suite.T(), int64(2), count(models.TransactionFilter{StartDate: today}))
+	assert.Equal(suite.T(), int64(0), count(models.TransactionFilter{StartDate: today.AddDate(0, 0, 1)}))
+	assert.Equal(suite.T(), int64(1), count(models.TransactionFilter{EndDate: today.AddDate(0, 0, -7)}))
+	// The end date covers the whole day
+	assert.Equal(suite.T(), int64(3), count(models.TransactionFilter{EndDate: today}))
+
+	// The recipient only sees their own CREDIT row, with the sender as counterparty
+	result, total, err := suite.repository.GetUserTransactions(recipient.ID, models.TransactionFilter{Counterparty: suite.user.PhoneNumber}, 1, 10)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), total)
+	assert.Equal(suite.T(), models.CREDIT, result[0].Type)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestGetUserTransactionsByCursor() {
+	// Many rows share a timestamp so the id tie-breaker matters
+	createdAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 25; i++ {
+		transaction := models.Transaction{
+			UserID:          suite.user.ID,
+			Type:            models.CREDIT,
+			TransactionType: models.TOPUP,
+			Amount:          models.NewMoneyFromMajor(int64(i + 1)),
+			Status:          models.SUCCESS,
+			CreatedAt:       createdAt.Add(time.Duration(i/5) * time.Second),
+		}
+		assert.NoError(suite.T(), suite.db.Create(&transaction).Error)
+	}
+
+	seen := map[uuid.UUID]bool{}
+	var cursor *models.TransactionCursor
+	var pages [][]models.Transaction
+	for {
+		page, hasMore, err := suite.repository.GetUserTransactionsByCursor(suite.user.ID, models.TransactionFilter{}, cursor, 10)
+		assert.NoError(suite.T(), err)
+		pages = append(pages, page)
+		for _, t := range page {
+			assert.False(suite.T(), seen[t.ID], "duplicate row")
+			seen[t.ID] = true
+		}
+
+		// A new transaction arriving mid-walk must not shift later pages
+		if len(pages) == 1 {
+			_, _, _, err := suite.repository.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1))
+			assert.NoError(suite.T(), err)
+		}
+
+		if !hasMore {
+			break
+		}
+		cursor = models.NewTransactionCursor(&page[len(page)-1], models.CursorNext)
+	}
+
+	assert.Len(suite.T(), pages, 3)
+	assert.Len(suite.T(), pages[2], 5)
+	assert.Len(suite.T(), seen, 25)
+
+	// Rows are newest first within and across pages
+	for _, page := range pages {
+		for i := 1; i < len(page); i++ {
+			assert.False(suite.T(), page[i].CreatedAt.After(page[i-1].CreatedAt))
+		}
+	}
+
+	// Walking back from the last page returns the middle page unchanged
+	prev := models.NewTransactionCursor(&pages[2][0], models.CursorPrev)
+	page, hasMore, err := suite.repository.GetUserTransactionsByCursor(suite.user.ID, models.TransactionFilter{}, prev, 10)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), hasMore)
+	assert.Equal(suite.T(), pages[1], page)
 }
 
 func (suite *TransactionRepositoryTestSuite) TestPaymentInsufficientBalance() {
-	amount := float64(2000) // More than current balance
-	_, _, _, err := suite.repository.Payment(suite.user.ID, amount, "Test payment")
+	amount := models.NewMoneyFromMajor(2000) // More than current balance
+	_, _, _, err := suite.repository.Payment(suite.user.ID, models.DefaultCurrency, amount, "Test payment")
 	assert.Error(suite.T(), err)
 
 	// Verify user balance remains unchanged
-	var user models.User
-	err = suite.db.First(&user, "id = ?", suite.user.ID).Error
+	var wallet models.Wallet
+	err = suite.db.First(&wallet, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), wallet.Balance)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestRepeatedSmallTopUpsDoNotDrift() {
+	tenCents := models.MustParseMoney("0.10")
+	for i := 0; i < 1000; i++ {
+		_, _, _, err := suite.repository.TopUp(suite.user.ID, models.DefaultCurrency, tenCents)
+		assert.NoError(suite.T(), err)
+	}
+
+	var wallet models.Wallet
+	err := suite.db.First(&wallet, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.MustParseMoney("1100.00"), wallet.Balance)
+
+	// Every row must chain exactly from the previous balance
+	var transactions []models.Transaction
+	err = suite.db.Where("user_id = ?", suite.user.ID).Find(&transactions).Error
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), transactions, 1000)
+
+	var total models.Money
+	for _, t := range transactions {
+		assert.Equal(suite.T(), t.BalanceBefore+t.Amount, t.BalanceAfter)
+		total += t.Amount
+	}
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(100), total)
+
+	ledgerBalance, err := NewLedgerRepository(suite.db).VerifyWalletBalance(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), wallet.Balance, ledgerBalance)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestTransferConservesMoney() {
+	recipient := &models.User{
+		ID:          uuid.New(),
+		FirstName:   "Jane",
+		LastName:    "Doe",
+		PhoneNumber: "0987654321",
+		Address:     "456 Main St",
+		Pin:         "123456",
+		KYCTier:     models.KYCTierFull,
+	}
+	assert.NoError(suite.T(), suite.db.Create(recipient).Error)
+
+	amount := models.MustParseMoney("0.07")
+	for i := 0; i < 300; i++ {
+		_, _, _, err := suite.repository.Transfer(suite.user.ID, models.DefaultCurrency, amount, recipient.ID, "split")
+		assert.NoError(suite.T(), err)
+	}
+
+	var sender, receiver models.Wallet
+	assert.NoError(suite.T(), suite.db.First(&sender, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error)
+	assert.NoError(suite.T(), suite.db.First(&receiver, "user_id = ? AND currency = ?", recipient.ID, models.DefaultCurrency).Error)
+
+	assert.Equal(suite.T(), models.MustParseMoney("979.00"), sender.Balance)
+	assert.Equal(suite.T(), models.MustParseMoney("21.00"), receiver.Balance)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), sender.Balance+receiver.Balance)
+
+	mismatches, err := NewLedgerRepository(suite.db).FindBalanceMismatches()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), mismatches)
+}
+
+func (suite *TransactionRepositoryTestSuite) createRecipient() *models.User {
+	recipient := &models.User{
+		ID:          uuid.New(),
+		FirstName:   "Jane",
+		LastName:    "Doe",
+		PhoneNumber: "0987654321",
+		Address:     "456 Main St",
+		Pin:         "123456",
+		KYCTier:     models.KYCTierFull,
+	}
+	assert.NoError(suite.T(), suite.db.Create(recipient).Error)
+	return recipient
+}
+
+func (suite *TransactionRepositoryTestSuite) TestTransferToSelfIsRejected() {
+	_, _, _, err := suite.repository.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(10), suite.user.ID, "Me")
+	assert.Equal(suite.T(), models.ErrSelfTransfer, err)
+
+	var wallet models.Wallet
+	assert.NoError(suite.T(), suite.db.First(&wallet, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), wallet.Balance)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestTransferToUnknownRecipient() {
+	_, _, _, err := suite.repository.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(10), uuid.New(), "Nobody")
+	assert.ErrorIs(suite.T(), err, gorm.ErrRecordNotFound)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestRefundTransferPartiallyThenFully() {
+	recipient := suite.createRecipient()
+	transfer, _, _, err := suite.repository.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(100), recipient.ID, "Dinner")
+	assert.NoError(suite.T(), err)
+
+	original, refund, err := suite.repository.Refund(transfer.ID, models.NewMoneyFromMajor(40), "")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.PARTIALLY_REFUNDED, original.Status)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(40), original.RefundedAmount)
+	assert.Equal(suite.T(), models.CREDIT, refund.Type)
+	assert.Equal(suite.T(), models.REFUND, refund.TransactionType)
+	assert.Equal(suite.T(), transfer.ID, *refund.OriginalTransactionID)
+
+	// A zero amount refunds the remainder
+	original, refund, err = suite.repository.Refund(transfer.ID, 0, "")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.REFUNDED, original.Status)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(60), refund.Amount)
+
+	var sender, receiver models.Wallet
+	assert.NoError(suite.T(), suite.db.First(&sender, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error)
+	assert.NoError(suite.T(), suite.db.First(&receiver, "user_id = ? AND currency = ?", recipient.ID, models.DefaultCurrency).Error)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), sender.Balance)
+	assert.Equal(suite.T(), models.Money(0), receiver.Balance)
+
+	// Nothing is left to refund
+	_, _, err = suite.repository.Refund(transfer.ID, 0, "")
+	assert.Equal(suite.T(), models.ErrTransactionNotRefundable, err)
+
+	mismatches, err := NewLedgerRepository(suite.db).FindBalanceMismatches()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), mismatches)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestRefundCannotExceedRemainingAmount() {
+	payment, _, _, err := suite.repository.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(100), "Shoes")
+	assert.NoError(suite.T(), err)
+
+	_, _, err = suite.repository.Refund(payment.ID, models.NewMoneyFromMajor(70), "")
+	assert.NoError(suite.T(), err)
+
+	_, _, err = suite.repository.Refund(payment.ID, models.NewMoneyFromMajor(31), "")
+	assert.Equal(suite.T(), models.ErrRefundExceedsAmount, err)
+
+	var original models.Transaction
+	assert.NoError(suite.T(), suite.db.First(&original, "id = ?", payment.ID).Error)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(70), original.RefundedAmount)
+
+	var wallet models.Wallet
+	assert.NoError(suite.T(), suite.db.First(&wallet, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(970), wallet.Balance)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestRefundTransferFailsWhenRecipientSpentFunds() {
+	recipient := suite.createRecipient()
+	transfer, _, _, err := suite.repository.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(100), recipient.ID, "Dinner")
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.repository.Payment(recipient.ID, models.DefaultCurrency, models.NewMoneyFromMajor(80), "Spent")
+	assert.NoError(suite.T(), err)
+
+	_, _, err = suite.repository.Refund(transfer.ID, 0, "")
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestReverseMarksOriginalReversed() {
+	payment, _, _, err := suite.repository.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(100), "Shoes")
+	assert.NoError(suite.T(), err)
+	_, _, err = suite.repository.Refund(payment.ID, models.NewMoneyFromMajor(25), "")
+	assert.NoError(suite.T(), err)
+
+	original, reversal, err := suite.repository.Reverse(payment.ID, "")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.REVERSED, original.Status)
+	assert.Equal(suite.T(), models.REVERSAL, reversal.TransactionType)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(75), reversal.Amount)
+
+	_, _, err = suite.repository.Reverse(payment.ID, "")
+	assert.Equal(suite.T(), models.ErrTransactionNotRefundable, err)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestFindRefundableResolvesRecipientCreditRow() {
+	recipient := suite.createRecipient()
+	transfer, _, _, err := suite.repository.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(10), recipient.ID, "Coffee")
+	assert.NoError(suite.T(), err)
+
+	var credit models.Transaction
+	assert.NoError(suite.T(), suite.db.First(&credit, "user_id = ? AND type = ?", recipient.ID, models.CREDIT).Error)
+
+	found, err := suite.repository.FindRefundable(credit.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), transfer.ID, found.ID)
+
+	// Top ups are not refundable
+	topUpID, _, _, err := suite.repository.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(10))
+	assert.NoError(suite.T(), err)
+	_, err = suite.repository.FindRefundable(topUpID)
+	assert.Equal(suite.T(), models.ErrTransactionNotRefundable, err)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestTransferChargesFee() {
+	suite.repository.fees = &models.FeeSchedule{Rules: []models.FeeRule{
+		{TransactionType: models.TRANSFER, Flat: models.NewMoneyFromMajor(1), BasisPoints: 100},
+	}}
+	recipient := suite.createRecipient()
+
+	transfer, balanceBefore, balanceAfter, err := suite.repository.Transfer(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(100), recipient.ID, "Rent")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), balanceBefore)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(898), balanceAfter)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(2), transfer.Fee)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1), transfer.FeeBreakdown.FlatFee)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1), transfer.FeeBreakdown.PercentageFee)
+
+	// The fee is its own transaction, linked to the transfer
+	var fee models.Transaction
+	assert.NoError(suite.T(), suite.db.First(&fee, "transaction_type = ?", models.FEE).Error)
+	assert.Equal(suite.T(), suite.user.ID, fee.UserID)
+	assert.Equal(suite.T(), models.DEBIT, fee.Type)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(2), fee.Amount)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(900), fee.BalanceBefore)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(898), fee.BalanceAfter)
+	assert.Equal(suite.T(), transfer.ID, *fee.OriginalTransactionID)
+
+	// The recipient gets the full amount and the fee goes to revenue
+	var receiver models.Wallet
+	assert.NoError(suite.T(), suite.db.First(&receiver, "user_id = ? AND currency = ?", recipient.ID, models.DefaultCurrency).Error)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(100), receiver.Balance)
+
+	ledger := NewLedgerRepository(suite.db)
+	revenue, err := ledger.SystemAccount(models.SystemAccountFeeRevenue, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	revenueBalance, err := ledger.AccountBalance(revenue.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(2), revenueBalance)
+
+	mismatches, err := ledger.FindBalanceMismatches()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), mismatches)
+
+	// A refund returns the amount but not the fee
+	original, _, err := suite.repository.Refund(transfer.ID, 0, "")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.REFUNDED, original.Status)
+
+	var sender models.Wallet
+	assert.NoError(suite.T(), suite.db.First(&sender, "user_id = ? AND currency = ?", suite.user.ID, models.DefaultCurrency).Error)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(998), sender.Balance)
+}
+
+func (suite *TransactionRepositoryTestSuite) TestPaymentFeeNeedsBalance() {
+	suite.repository.fees = &models.FeeSchedule{Rules: []models.FeeRule{
+		{TransactionType: models.PAYMENT, KYCTier: models.KYCTierFull, Flat: models.NewMoneyFromMajor(5)},
+	}}
+
+	// The whole balance can't be spent when a fee is due on top
+	_, _, _, err := suite.repository.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000), "Everything")
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+
+	payment, _, balanceAfter, err := suite.repository.Payment(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(995), "Almost everything")
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), float64(1000), user.Balance)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(5), payment.Fee)
+	assert.Equal(suite.T(), models.Money(0), balanceAfter)
 }
 
 func TestTransactionRepositoryTestSuite(t *testing.T) {
@@ -32,7 +32,13 @@ func (r *UserRepository) Create(user *models.User) error {
 		return err
 	}
 
-	return r.db.Create(user).Error
+	// Every user starts with an empty wallet in the default currency
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Wallet{UserID: user.ID, Currency: models.DefaultCurrency}).Error
+	})
 }
 
 func (r *UserRepository) FindByPhoneNumber(phoneNumber string) (*models.User, error) {
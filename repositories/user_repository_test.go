@@ -22,7 +22,7 @@ func (suite *UserRepositoryTestSuite) SetupTest() {
 	assert.NoError(suite.T(), err)
 
 	// Migrate the schema
-	err = db.AutoMigrate(&models.User{})
+	err = db.AutoMigrate(&models.User{}, &models.Wallet{})
 	assert.NoError(suite.T(), err)
 
 	suite.db = db
@@ -37,7 +37,6 @@ func (suite *UserRepositoryTestSuite) TestCreate() {
 		PhoneNumber: "1234567890",
 		Address:     "123 Main St",
 		Pin:         "123456",
-		Balance:     0,
 	}
 
 	err := suite.repository.Create(user)
@@ -59,7 +58,6 @@ func (suite *UserRepositoryTestSuite) TestFindByID() {
 		PhoneNumber: "1234567890",
 		Address:     "123 Main St",
 		Pin:         "123456",
-		Balance:     0,
 	}
 
 	err := suite.repository.Create(user)
@@ -79,7 +77,6 @@ func (suite *UserRepositoryTestSuite) TestFindByPhoneNumber() {
 		PhoneNumber: "1234567890",
 		Address:     "123 Main St",
 		Pin:         "123456",
-		Balance:     0,
 	}
 
 	err := suite.repository.Create(user)
@@ -99,7 +96,6 @@ func (suite *UserRepositoryTestSuite) TestUpdate() {
 		PhoneNumber: "1234567890",
 		Address:     "123 Main St",
 		Pin:         "123456",
-		Balance:     0,
 	}
 
 	err := suite.repository.Create(user)
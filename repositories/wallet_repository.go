@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WalletRepository struct {
+	db *gorm.DB
+}
+
+func NewWalletRepository(db *gorm.DB) *WalletRepository {
+	return &WalletRepository{db: db}
+}
+
+// getForUpdate locks the user's wallet in currency. The default currency
+// wallet is created on first use; wallets in other currencies must have been
+// opened. Callers must already hold the user's row lock.
+func (r *WalletRepository) getForUpdate(tx *gorm.DB, userID uuid.UUID, currency string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := tx.Set("gorm:query_option", "FOR UPDATE").First(&wallet, "user_id = ? AND currency = ?", userID, currency).Error
+	if err == nil {
+		return &wallet, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if currency != models.DefaultCurrency {
+		return nil, models.ErrWalletNotFound
+	}
+
+	wallet = models.Wallet{UserID: userID, Currency: currency}
+	if err := tx.Create(&wallet).Error; err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// Open creates the user's wallet in currency. Any tier may open one: what is
+// held and moved in it counts towards the same tier limits as the default
+// currency wallet.
+func (r *WalletRepository) Open(userID uuid.UUID, currency string) (*models.Wallet, error) {
+	var wallet models.Wallet
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := NewTransactionRepository(tx).getUserForUpdate(tx, userID); err != nil {
+			return err
+		}
+
+		err := tx.First(&wallet, "user_id = ? AND currency = ?", userID, currency).Error
+		if err == nil {
+			return models.ErrWalletExists
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		wallet = models.Wallet{UserID: userID, Currency: currency}
+		return tx.Create(&wallet).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// Find returns the user's wallet in currency. A default currency wallet that
+// has not been used yet is returned empty.
+func (r *WalletRepository) Find(userID uuid.UUID, currency string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.First(&wallet, "user_id = ? AND currency = ?", userID, currency).Error
+	if err == nil {
+		return &wallet, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if currency != models.DefaultCurrency {
+		return nil, models.ErrWalletNotFound
+	}
+	return &models.Wallet{UserID: userID, Currency: currency}, nil
+}
+
+// GetUserWallets lists the user's wallets, the default currency first and
+// the rest by currency code.
+func (r *WalletRepository) GetUserWallets(userID uuid.UUID) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	if err := r.db.Where("user_id = ?", userID).Order("currency").Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+
+	result := []models.Wallet{{UserID: userID, Currency: models.DefaultCurrency}}
+	for _, wallet := range wallets {
+		if wallet.Currency == models.DefaultCurrency {
+			result[0] = wallet
+		} else {
+			result = append(result, wallet)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type WalletRepositoryTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	repository   *WalletRepository
+	transactions *TransactionRepository
+	user         *models.User
+	recipient    *models.User
+}
+
+func (suite *WalletRepositoryTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(suite.T(), err)
+
+	// Migrate the schema
+	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.LedgerAccount{}, &models.JournalEntry{}, &models.Posting{}, &models.Hold{}, &models.Wallet{}, &models.FXQuote{})
+	assert.NoError(suite.T(), err)
+
+	suite.db = db
+	suite.repository = &WalletRepository{db: db}
+	suite.transactions = &TransactionRepository{db: db}
+
+	suite.user = suite.createUser("1234567890", models.KYCTierFull)
+	suite.recipient = suite.createUser("0987654321", models.KYCTierFull)
+}
+
+func (suite *WalletRepositoryTestSuite) createUser(phone, tier string) *models.User {
+	user := &models.User{
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: phone,
+		Address:     "123 Main St",
+		Pin:         "123456",
+		KYCTier:     tier,
+	}
+	assert.NoError(suite.T(), NewUserRepository(suite.db).Create(user))
+	return user
+}
+
+func (suite *WalletRepositoryTestSuite) TestNewUserHasDefaultWallet() {
+	wallets, err := suite.repository.GetUserWallets(suite.user.ID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), wallets, 1)
+	assert.Equal(suite.T(), models.DefaultCurrency, wallets[0].Currency)
+	assert.NotEqual(suite.T(), uuid.Nil, wallets[0].ID)
+
+	_, err = suite.repository.Open(suite.user.ID, models.DefaultCurrency)
+	assert.Equal(suite.T(), models.ErrWalletExists, err)
+}
+
+func (suite *WalletRepositoryTestSuite) TestOpenForeignWallet() {
+	// Any tier may open one; its limits apply to the wallet at limit rates
+	basic := suite.createUser("1112223333", models.KYCTierBasic)
+	_, err := suite.repository.Open(basic.ID, "USD")
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.TopUp(basic.ID, "USD", models.NewMoneyFromMajor(400))
+	var exceeded *models.LimitExceededError
+	if assert.True(suite.T(), errors.As(err, &exceeded), "expected LimitExceededError, got %v", err) {
+		assert.Equal(suite.T(), models.LimitPerTransaction, exceeded.Limit)
+	}
+
+	wallet, err := suite.repository.Open(suite.user.ID, "USD")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "USD", wallet.Currency)
+	assert.Equal(suite.T(), models.Money(0), wallet.Balance)
+
+	_, err = suite.repository.Open(suite.user.ID, "USD")
+	assert.Equal(suite.T(), models.ErrWalletExists, err)
+
+	wallets, err := suite.repository.GetUserWallets(suite.user.ID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), wallets, 2)
+	assert.Equal(suite.T(), models.DefaultCurrency, wallets[0].Currency)
+	assert.Equal(suite.T(), "USD", wallets[1].Currency)
+}
+
+func (suite *WalletRepositoryTestSuite) TestMoneyNeedsAnOpenWallet() {
+	_, _, _, err := suite.transactions.TopUp(suite.user.ID, "USD", models.NewMoneyFromMajor(10))
+	assert.Equal(suite.T(), models.ErrWalletNotFound, err)
+
+	_, err = suite.repository.Find(suite.user.ID, "USD")
+	assert.Equal(suite.T(), models.ErrWalletNotFound, err)
+}
+
+func (suite *WalletRepositoryTestSuite) TestWalletsAreSeparate() {
+	_, err := suite.repository.Open(suite.user.ID, "USD")
+	assert.NoError(suite.T(), err)
+
+	_, _, _, err = suite.transactions.TopUp(suite.user.ID, models.DefaultCurrency, models.NewMoneyFromMajor(1000))
+	assert.NoError(suite.T(), err)
+	_, balanceBefore, balanceAfter, err := suite.transactions.TopUp(suite.user.ID, "USD", models.MustParseMoney("25.50"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.Money(0), balanceBefore)
+	assert.Equal(suite.T(), models.MustParseMoney("25.50"), balanceAfter)
+
+	// A USD payment can't be paid from the IDR balance
+	_, _, _, err = suite.transactions.Payment(suite.user.ID, "USD", models.NewMoneyFromMajor(30), "Book")
+	assert.Equal(suite.T(), models.ErrInvalidTransaction, err)
+	payment, _, _, err := suite.transactions.Payment(suite.user.ID, "USD", models.NewMoneyFromMajor(20), "Book")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "USD", payment.Currency)
+
+	idr, err := suite.repository.Find(suite.user.ID, models.DefaultCurrency)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(1000), idr.Balance)
+	usd, err := suite.repository.Find(suite.user.ID, "USD")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.MustParseMoney("5.50"), usd.Balance)
+
+	ledger := NewLedgerRepository(suite.db)
+	ledgerBalance, err := ledger.VerifyWalletBalance(suite.user.ID, "USD")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.MustParseMoney("5.50"), ledgerBalance)
+
+	mismatches, err := ledger.FindBalanceMismatches()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), mismatches)
+}
+
+func (suite *WalletRepositoryTestSuite) TestTransferNeedsMatchingWallet() {
+	_, err := suite.repository.Open(suite.user.ID, "USD")
+	assert.NoError(suite.T(), err)
+	_, _, _, err = suite.transactions.TopUp(suite.user.ID, "USD", models.NewMoneyFromMajor(100))
+	assert.NoError(suite.T(), err)
+
+	_, _, _, err = suite.transactions.Transfer(suite.user.ID, "USD", models.NewMoneyFromMajor(40), suite.recipient.ID, "Dinner")
+	assert.Equal(suite.T(), models.ErrCurrencyMismatch, err)
+
+	_, err = suite.repository.Open(suite.recipient.ID, "USD")
+	assert.NoError(suite.T(), err)
+	transfer, _, balanceAfter, err := suite.transactions.Transfer(suite.user.ID, "USD", models.NewMoneyFromMajor(40), suite.recipient.ID, "Dinner")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "USD", transfer.Currency)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(60), balanceAfter)
+
+	received, err := suite.repository.Find(suite.recipient.ID, "USD")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(40), received.Balance)
+
+	// The refund goes back to the USD wallet
+	_, refund, err := suite.transactions.Refund(transfer.ID, 0, "")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "USD", refund.Currency)
+	sent, err := suite.repository.Find(suite.user.ID, "USD")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.NewMoneyFromMajor(100), sent.Balance)
+}
+
+func (suite *WalletRepositoryTestSuite) TestRefundKeepsCurrencyPrecision() {
+	for _, user := range []*models.User{suite.user, suite.recipient} {
+		_, err := suite.repository.Open(user.ID, "JPY")
+		assert.NoError(suite.T(), err)
+	}
+	_, _, _, err := suite.transactions.TopUp(suite.user.ID, "JPY", models.NewMoneyFromMajor(5000))
+	assert.NoError(suite.T(), err)
+	transfer, _, _, err := suite.transactions.Transfer(suite.user.ID, "JPY", models.NewMoneyFromMajor(1000), suite.recipient.ID, "Ramen")
+	assert.NoError(suite.T(), err)
+
+	_, _, err = suite.transactions.Refund(transfer.ID, models.MustParseMoney("100.50"), "")
+	assert.Equal(suite.T(), models.ErrCurrencyPrecision, err)
+	_, _, err = suite.transactions.Refund(transfer.ID, models.NewMoneyFromMajor(100), "")
+	assert.NoError(suite.T(), err)
+}
+
+func TestWalletRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(WalletRepositoryTestSuite))
+}
@@ -1,14 +1,17 @@
 package routes
 
 import (
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/denys89/ewallet-api/auth"
 	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
 	"github.com/denys89/ewallet-api/models"
 	"github.com/denys89/ewallet-api/repositories"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -39,7 +42,6 @@ func Register(c *gin.Context) {
 		PhoneNumber: req.PhoneNumber,
 		Address:     req.Address,
 		Pin:         string(hashedPin),
-		Balance:     0,
 	}
 
 	userRepo := repositories.NewUserRepository(config.DB)
@@ -70,41 +72,26 @@ type LoginRequest struct {
 	Pin         string `json:"pin" binding:"required,len=6"`
 }
 
-// generateTokens creates both access and refresh tokens
-func generateTokens(userID string, phoneNumber string) (string, string, error) {
-	cfg := config.Get()
-
-	// Generate access token
-	accessTokenExp := time.Now().Add(cfg.JWTExpirationHours * time.Hour)
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"phone":   phoneNumber,
-		"exp":     accessTokenExp.Unix(),
-		"iat":     time.Now().Unix(),
-		"type":    "access",
-	})
-
-	accessTokenString, err := accessToken.SignedString([]byte(cfg.JWTSecret))
+// generateTokens creates both access and refresh tokens for a session. The
+// access token carries the session's family ID and the refresh token the
+// jti of its server-side record.
+func generateTokens(tokens *auth.TokenService, userID uuid.UUID, phoneNumber string, session *models.RefreshToken) (gin.H, error) {
+	accessToken, err := tokens.IssueAccess(userID, phoneNumber, session.FamilyID)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	// Generate refresh token
-	refreshTokenExp := time.Now().Add(cfg.RefreshTokenExpirationDays * 24 * time.Hour)
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"phone":   phoneNumber,
-		"exp":     refreshTokenExp.Unix(),
-		"iat":     time.Now().Unix(),
-		"type":    "refresh",
-	})
-
-	refreshTokenString, err := refreshToken.SignedString([]byte(cfg.RefreshTokenSecret))
+	refreshToken, err := tokens.IssueRefresh(userID, phoneNumber, session.ID, session.ExpiresAt)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return accessTokenString, refreshTokenString, nil
+	return gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(tokens.AccessTTL().Seconds()),
+	}, nil
 }
 
 func Login(c *gin.Context) {
@@ -114,11 +101,24 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	guard := newLoginGuard(c, req.PhoneNumber)
+	lockedUntil, err := guard.lockedUntil()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
+		return
+	}
+	if lockedUntil != nil {
+		status, body := lockedResponse(*lockedUntil, guard.now)
+		respondLoginFailure(c, status, body)
+		return
+	}
+
 	userRepo := repositories.NewUserRepository(config.DB)
 	user, err := userRepo.FindByPhoneNumber(req.PhoneNumber)
 	if err != nil {
 		if err == repositories.ErrInvalidCredentials {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Phone Number and PIN doesn't match"})
+			status, body := guard.fail(nil)
+			respondLoginFailure(c, status, body)
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
@@ -126,11 +126,24 @@ func Login(c *gin.Context) {
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Pin), []byte(req.Pin)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Phone Number and PIN doesn't match"})
+		status, body := guard.fail(&user.ID)
+		respondLoginFailure(c, status, body)
+		return
+	}
+
+	if err := guard.succeed(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
+		return
+	}
+
+	tokens := auth.NewTokenService(config.Get(), auth.Keys)
+	session, err := repositories.NewTokenRepository(config.DB).StartSession(user.ID, tokens.RefreshExpiry())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
 
-	accessToken, refreshToken, err := generateTokens(user.ID.String(), user.PhoneNumber)
+	result, err := generateTokens(tokens, user.ID, user.PhoneNumber, session)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
@@ -138,11 +151,7 @@ func Login(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": "SUCCESS",
-		"result": gin.H{
-			"access_token":  accessToken,
-			"refresh_token": refreshToken,
-			"expires_in":    int(config.Get().JWTExpirationHours * 3600),
-		},
+		"result": result,
 	})
 }
 
@@ -159,63 +168,99 @@ func RefreshToken(c *gin.Context) {
 	}
 
 	// Parse and validate the refresh token
-	token, err := jwt.Parse(req.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(config.Get().RefreshTokenSecret), nil
-	})
-
+	tokens := auth.NewTokenService(config.Get(), auth.Keys)
+	claims, err := tokens.Parse(req.RefreshToken, auth.TokenTypeRefresh)
 	if err != nil {
+		if err == auth.ErrInvalidTokenType {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token type"})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
 
-	if !token.Valid {
+	jti, err := claims.TokenID()
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
 
-	// Extract claims
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+	// Exchange the refresh token; each one is only accepted once
+	tokenRepo := repositories.NewTokenRepository(config.DB)
+	session, err := tokenRepo.Rotate(jti, tokens.RefreshExpiry(), time.Now())
+	if err != nil {
+		switch err {
+		case repositories.ErrRefreshTokenReused:
+			auditRefreshTokenReuse(c, tokenRepo, jti)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used, please log in again"})
+		case repositories.ErrRefreshTokenInvalid, repositories.ErrSessionRevoked:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new tokens"})
+		}
 		return
 	}
 
-	// Verify token type
-	tokenType, ok := claims["type"].(string)
-	if !ok || tokenType != "refresh" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token type"})
+	// Generate new tokens for the user the session belongs to
+	result, err := generateTokens(tokens, session.UserID, claims.Phone, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new tokens"})
 		return
 	}
 
-	// Get user information from claims
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": result,
+	})
+}
+
+// auditRefreshTokenReuse records that a used refresh token was presented
+// again, which means it was most likely stolen.
+func auditRefreshTokenReuse(c *gin.Context, tokenRepo *repositories.TokenRepository, jti uuid.UUID) {
+	token, err := tokenRepo.FindByID(jti)
+	if err != nil {
+		log.Printf("failed to load reused refresh token %s: %v", jti, err)
 		return
 	}
 
-	phoneNumber, ok := claims["phone"].(string)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid phone number in token"})
+	err = repositories.NewAuditRepository(config.DB).Record(models.AuditRefreshTokenReuse, &token.UserID, c.ClientIP(), gin.H{
+		"jti":       token.ID,
+		"family_id": token.FamilyID,
+	})
+	if err != nil {
+		log.Printf("failed to audit refresh token reuse for %s: %v", jti, err)
+	}
+}
+
+// Logout revokes the session the access token belongs to.
+func Logout(c *gin.Context) {
+	userID, _ := c.Get(middleware.UserIDKey)
+	sessionID, _ := c.Get(middleware.SessionIDKey)
+
+	tokenRepo := repositories.NewTokenRepository(config.DB)
+	if err := tokenRepo.RevokeSession(sessionID.(uuid.UUID), userID.(uuid.UUID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
 		return
 	}
 
-	// Generate new tokens
-	accessToken, refreshToken, err := generateTokens(userID, phoneNumber)
+	c.JSON(http.StatusOK, gin.H{"status": "SUCCESS"})
+}
+
+// LogoutAll revokes every session of the user.
+func LogoutAll(c *gin.Context) {
+	userID, _ := c.Get(middleware.UserIDKey)
+
+	tokenRepo := repositories.NewTokenRepository(config.DB)
+	revoked, err := tokenRepo.RevokeAllSessions(userID.(uuid.UUID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new tokens"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": "SUCCESS",
 		"result": gin.H{
-			"access_token":  accessToken,
-			"refresh_token": refreshToken,
-			"expires_in":    int(config.Get().JWTExpirationHours * 3600),
+			"revoked_sessions": revoked,
 		},
 	})
 }
@@ -0,0 +1,399 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CheckoutLineItemRequest struct {
+	Name       string       `json:"name" binding:"required,max=100"`
+	Quantity   int64        `json:"quantity" binding:"required,gt=0,lte=10000"`
+	UnitAmount models.Money `json:"unit_amount" binding:"required,gt=0"`
+}
+
+type CreateCheckoutSessionRequest struct {
+	// Amount may be left out when line items are given, and must match
+	// their total otherwise
+	Amount          models.Money              `json:"amount" binding:"omitempty,gt=0"`
+	Currency        string                    `json:"currency,omitempty"`
+	LineItems       []CheckoutLineItemRequest `json:"line_items" binding:"omitempty,max=100,dive"`
+	Description     string                    `json:"remarks,omitempty"`
+	ClientReference string                    `json:"client_reference" binding:"omitempty,max=100"`
+	SuccessURL      string                    `json:"success_url" binding:"required,max=2048"`
+	CancelURL       string                    `json:"cancel_url" binding:"required,max=2048"`
+	// ExpiresIn is the session lifetime in seconds; defaults to
+	// CHECKOUT_SESSION_DEFAULT_TTL
+	ExpiresIn int `json:"expires_in" binding:"omitempty,gt=0"`
+}
+
+// CreateCheckoutSession opens a hosted checkout session for one payment to
+// one of the caller's merchants
+func CreateCheckoutSession(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	var req CreateCheckoutSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Amount == 0 && len(req.LineItems) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount or line items are required"})
+		return
+	}
+	if req.Currency != "" && strings.ToUpper(req.Currency) != merchant.SettlementCurrency {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Merchant only accepts " + merchant.SettlementCurrency})
+		return
+	}
+	if !isRedirectURL(req.SuccessURL) || !isRedirectURL(req.CancelURL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "success_url and cancel_url must be absolute http or https URLs"})
+		return
+	}
+
+	amounts := []models.Money{req.Amount}
+	lineItems := []models.CheckoutLineItem{}
+	for _, item := range req.LineItems {
+		amounts = append(amounts, item.UnitAmount)
+		lineItems = append(lineItems, models.CheckoutLineItem{
+			Name:       strings.TrimSpace(item.Name),
+			Quantity:   item.Quantity,
+			UnitAmount: item.UnitAmount,
+		})
+	}
+	for _, amount := range amounts {
+		if currency, code, body := resolveCurrency(merchant.SettlementCurrency, amount); currency == "" {
+			c.JSON(code, body)
+			return
+		}
+	}
+
+	cfg := config.Get()
+	ttl, ok := expiryTTL(req.ExpiresIn, cfg.CheckoutSessionDefaultTTL, cfg.CheckoutSessionMaxTTL)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Checkout session expiry is too long"})
+		return
+	}
+
+	session := &models.CheckoutSession{
+		MerchantID:      merchant.ID,
+		Amount:          req.Amount,
+		Description:     req.Description,
+		ClientReference: req.ClientReference,
+		SuccessURL:      req.SuccessURL,
+		CancelURL:       req.CancelURL,
+		LineItems:       lineItems,
+		ExpiresAt:       time.Now().Add(ttl),
+	}
+
+	sessionRepo := repositories.NewCheckoutSessionRepository(config.DB)
+	if err := sessionRepo.Create(session); err != nil {
+		log.Printf("Create checkout session error: %v", err)
+		code, body := checkoutSessionErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "SUCCESS",
+		"result": checkoutSessionResponse(session),
+	})
+}
+
+// GetCheckoutSessions lists the checkout sessions of one of the caller's
+// merchants, newest first
+func GetCheckoutSessions(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.CheckoutSessionOpen, models.CheckoutSessionPaid, models.CheckoutSessionExpired, models.CheckoutSessionCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid checkout session status"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	sessionRepo := repositories.NewCheckoutSessionRepository(config.DB)
+	sessions, total, err := sessionRepo.GetMerchantSessions(merchant.ID, status, time.Now(), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch checkout sessions"})
+		return
+	}
+
+	sessionResponses := []gin.H{}
+	for i := range sessions {
+		sessionResponses = append(sessionResponses, checkoutSessionResponse(&sessions[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": sessionResponses,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetMerchantCheckoutSession returns one of the caller's merchants' checkout
+// sessions
+func GetMerchantCheckoutSession(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid checkout session ID"})
+		return
+	}
+
+	sessionRepo := repositories.NewCheckoutSessionRepository(config.DB)
+	session, err := sessionRepo.FindForMerchant(sessionID, merchant.ID)
+	if err != nil {
+		code, body := checkoutSessionErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": checkoutSessionResponse(session),
+	})
+}
+
+// CancelCheckoutSession closes one of the caller's merchants' open checkout
+// sessions so it can no longer be paid
+func CancelCheckoutSession(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid checkout session ID"})
+		return
+	}
+
+	sessionRepo := repositories.NewCheckoutSessionRepository(config.DB)
+	session, err := sessionRepo.Cancel(sessionID, merchant.ID, time.Now())
+	if err != nil {
+		log.Printf("Cancel checkout session error: %v", err)
+		code, body := checkoutSessionErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": checkoutSessionResponse(session),
+	})
+}
+
+// GetCheckoutSession returns a checkout session to the customer the link was
+// sent to, with the merchant they are paying
+func GetCheckoutSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid checkout session ID"})
+		return
+	}
+
+	sessionRepo := repositories.NewCheckoutSessionRepository(config.DB)
+	session, err := sessionRepo.Find(sessionID)
+	if err != nil {
+		code, body := checkoutSessionErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	merchantRepo := repositories.NewMerchantRepository(config.DB)
+	merchant, err := merchantRepo.FindByID(session.MerchantID)
+	if err != nil {
+		code, body := merchantErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	result := checkoutSessionResponse(session)
+	result["merchant"] = gin.H{
+		"merchant_id":   merchant.ID,
+		"name":          merchant.Name,
+		"category_code": merchant.CategoryCode,
+		"city":          merchant.City,
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": result,
+	})
+}
+
+// PayCheckoutSession completes an open checkout session with a payment from
+// the user to its merchant. The customer should then be sent to
+// redirect_url, the session's success URL.
+func PayCheckoutSession(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid checkout session ID"})
+		return
+	}
+
+	sessionRepo := repositories.NewCheckoutSessionRepository(config.DB)
+	session, err := sessionRepo.Find(sessionID)
+	if err != nil {
+		code, body := checkoutSessionErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+	merchantRepo := repositories.NewMerchantRepository(config.DB)
+	merchant, err := merchantRepo.FindByID(session.MerchantID)
+	if err != nil {
+		code, body := merchantErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	remarks := session.Description
+	if remarks == "" {
+		remarks = "Checkout payment to " + merchant.Name
+	}
+
+	respondIdempotent(c, userID, nil, func(db *gorm.DB) (int, gin.H) {
+		sessionRepo := repositories.NewCheckoutSessionRepository(db)
+		session, transaction, balanceBefore, balanceAfter, err := sessionRepo.Pay(sessionID, userID, remarks, time.Now())
+		if err != nil {
+			log.Printf("Checkout payment error: %v", err)
+			return checkoutPaymentErrorResponse(err, merchant)
+		}
+
+		return http.StatusOK, gin.H{
+			"status": "SUCCESS",
+			"result": gin.H{
+				"checkout_session": checkoutSessionResponse(session),
+				"payment_id":       transaction.ID,
+				"amount":           transaction.Amount,
+				"currency":         transaction.Currency,
+				"balance_before":   balanceBefore,
+				"balance_after":    balanceAfter,
+				"fee":              transaction.FeeBreakdown,
+				"remark":           transaction.Description,
+				"created_at":       transaction.CreatedAt.Format("2006-01-02 15:04:05"),
+				"redirect_url":     session.SuccessURL,
+			},
+		}
+	})
+}
+
+// isRedirectURL reports whether raw is an absolute http or https URL a
+// customer can be sent to after checkout.
+func isRedirectURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func checkoutSessionResponse(session *models.CheckoutSession) gin.H {
+	formatTime := func(t *time.Time) interface{} {
+		if t == nil {
+			return nil
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+
+	var checkoutURL interface{}
+	if baseURL := config.Get().CheckoutBaseURL; baseURL != "" {
+		checkoutURL = strings.TrimRight(baseURL, "/") + "/" + session.ID.String()
+	}
+
+	lineItems := []gin.H{}
+	for _, item := range session.LineItems {
+		lineItems = append(lineItems, gin.H{
+			"name":        item.Name,
+			"quantity":    item.Quantity,
+			"unit_amount": item.UnitAmount,
+			"amount":      item.Amount,
+		})
+	}
+
+	return gin.H{
+		"checkout_session_id": session.ID,
+		"merchant_id":         session.MerchantID,
+		"amount":              session.Amount,
+		"currency":            session.Currency,
+		"line_items":          lineItems,
+		"remarks":             session.Description,
+		"client_reference":    session.ClientReference,
+		"status":              session.CurrentStatus(time.Now()),
+		"url":                 checkoutURL,
+		"success_url":         session.SuccessURL,
+		"cancel_url":          session.CancelURL,
+		"payer_id":            session.PayerID,
+		"transaction_id":      session.TransactionID,
+		"expires_at":          session.ExpiresAt.Format("2006-01-02 15:04:05"),
+		"paid_at":             formatTime(session.PaidAt),
+		"cancelled_at":        formatTime(session.CancelledAt),
+		"created_date":        session.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func checkoutSessionErrorResponse(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "Checkout session not found"}
+	case err == models.ErrMerchantNotActive:
+		return http.StatusUnprocessableEntity, gin.H{"error": "Merchant is not accepting payments"}
+	case err == models.ErrLineItemsMismatch:
+		return http.StatusBadRequest, gin.H{"error": "Amount does not match the line items"}
+	case err == models.ErrAmountOutOfRange:
+		return http.StatusBadRequest, gin.H{"error": "Checkout amount is out of range"}
+	case err == models.ErrCheckoutSessionNotOpen:
+		return http.StatusConflict, gin.H{"error": "Checkout session has already been paid or cancelled"}
+	case err == models.ErrCheckoutSessionExpired:
+		return http.StatusConflict, gin.H{"error": "Checkout session has expired"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process checkout session"}
+	}
+}
+
+func checkoutPaymentErrorResponse(err error, merchant *models.Merchant) (int, gin.H) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), err == models.ErrCheckoutSessionNotOpen, err == models.ErrCheckoutSessionExpired:
+		return checkoutSessionErrorResponse(err)
+	}
+	return paymentErrorResponse(err, merchant)
+}
@@ -0,0 +1,241 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/fx"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FXQuoteRequest struct {
+	FromCurrency string       `json:"from_currency" binding:"required"`
+	ToCurrency   string       `json:"to_currency" binding:"required"`
+	Amount       models.Money `json:"amount" binding:"required,gt=0"`
+}
+
+type ConversionRequest struct {
+	QuoteID string `json:"quote_id" binding:"required"`
+}
+
+// GetFXRate shows the mid-market rate between two currencies and the rate a
+// conversion would get after the spread
+func GetFXRate(rates fx.RateProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to, code, body := resolveCurrencyPair(c.Query("from"), c.Query("to"))
+		if from == "" {
+			c.JSON(code, body)
+			return
+		}
+
+		midRate, err := rates.Rate(c.Request.Context(), from, to)
+		if err != nil {
+			code, body := fxErrorResponse(err)
+			c.JSON(code, body)
+			return
+		}
+
+		spread := config.Get().FXSpreadBasisPoints
+		c.JSON(http.StatusOK, gin.H{
+			"status": "SUCCESS",
+			"result": gin.H{
+				"from_currency":       from,
+				"to_currency":         to,
+				"mid_rate":            midRate,
+				"rate":                midRate.LessBasisPoints(spread),
+				"spread_basis_points": spread,
+			},
+		})
+	}
+}
+
+// CreateFXQuote locks the current rate for converting an amount between two
+// of the user's wallets for FX_QUOTE_TTL
+func CreateFXQuote(rates fx.RateProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+		var req FXQuoteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		from, to, code, body := resolveCurrencyPair(req.FromCurrency, req.ToCurrency)
+		if from == "" {
+			c.JSON(code, body)
+			return
+		}
+		if _, code, body := resolveCurrency(from, req.Amount); code != 0 {
+			c.JSON(code, body)
+			return
+		}
+
+		midRate, err := rates.Rate(c.Request.Context(), from, to)
+		if err != nil {
+			log.Printf("FX rate error: %v", err)
+			code, body := fxErrorResponse(err)
+			c.JSON(code, body)
+			return
+		}
+
+		cfg := config.Get()
+		quote, err := models.NewFXQuote(userID, from, to, req.Amount, midRate, cfg.FXSpreadBasisPoints, cfg.FXQuoteTTL)
+		if err == nil {
+			err = repositories.NewFXRepository(config.DB).CreateQuote(quote)
+		}
+		if err != nil {
+			log.Printf("Create FX quote error: %v", err)
+			code, body := fxErrorResponse(err)
+			c.JSON(code, body)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"status": "SUCCESS",
+			"result": fxQuoteResponse(quote),
+		})
+	}
+}
+
+// GetFXQuote shows one of the user's quotes
+func GetFXQuote(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	quoteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quote ID"})
+		return
+	}
+
+	quote, err := repositories.NewFXRepository(config.DB).FindQuote(quoteID, userID)
+	if err != nil {
+		code, body := fxErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": fxQuoteResponse(quote),
+	})
+}
+
+// ConvertCurrency executes a quote, moving money between two of the user's
+// wallets at the quoted rate
+func ConvertCurrency(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req ConversionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	quoteID, err := uuid.Parse(req.QuoteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quote ID"})
+		return
+	}
+
+	respondIdempotent(c, userID, req, func(db *gorm.DB) (int, gin.H) {
+		fxRepo := repositories.NewFXRepository(db)
+		quote, debit, credit, err := fxRepo.Convert(quoteID, userID)
+		if err != nil {
+			log.Printf("Conversion error: %v", err)
+			return fxErrorResponse(err)
+		}
+
+		return http.StatusOK, gin.H{
+			"status": "SUCCESS",
+			"result": gin.H{
+				"conversion_id":       debit.ID,
+				"quote_id":            quote.ID,
+				"from_currency":       quote.FromCurrency,
+				"to_currency":         quote.ToCurrency,
+				"from_amount":         debit.Amount,
+				"to_amount":           credit.Amount,
+				"rate":                quote.Rate,
+				"from_balance_before": debit.BalanceBefore,
+				"from_balance_after":  debit.BalanceAfter,
+				"to_balance_before":   credit.BalanceBefore,
+				"to_balance_after":    credit.BalanceAfter,
+				"created_date":        debit.CreatedAt.Format("2006-01-02 15:04:05"),
+			},
+		}
+	})
+}
+
+func fxQuoteResponse(quote *models.FXQuote) gin.H {
+	now := time.Now()
+	expiresIn := int(quote.ExpiresAt.Sub(now).Seconds())
+	if expiresIn < 0 {
+		expiresIn = 0
+	}
+	return gin.H{
+		"quote_id":            quote.ID,
+		"from_currency":       quote.FromCurrency,
+		"to_currency":         quote.ToCurrency,
+		"from_amount":         quote.FromAmount,
+		"to_amount":           quote.ToAmount,
+		"mid_rate":            quote.MidRate,
+		"rate":                quote.Rate,
+		"spread_basis_points": quote.SpreadBasisPoints,
+		"status":              quote.CurrentStatus(now),
+		"transaction_id":      quote.TransactionID,
+		"expires_at":          quote.ExpiresAt.Format("2006-01-02 15:04:05"),
+		"expires_in":          expiresIn,
+		"created_date":        quote.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// resolveCurrencyPair checks the currencies of a conversion. On failure it
+// returns empty codes and the error response.
+func resolveCurrencyPair(fromCode, toCode string) (string, string, int, gin.H) {
+	from, err := models.LookupCurrency(fromCode)
+	if err != nil {
+		return "", "", http.StatusBadRequest, gin.H{"error": "Unsupported currency"}
+	}
+	to, err := models.LookupCurrency(toCode)
+	if err != nil {
+		return "", "", http.StatusBadRequest, gin.H{"error": "Unsupported currency"}
+	}
+	if from.Code == to.Code {
+		return "", "", http.StatusBadRequest, gin.H{"error": "Cannot convert a currency to itself"}
+	}
+	return from.Code, to.Code, 0, nil
+}
+
+func fxErrorResponse(err error) (int, gin.H) {
+	if code, body, ok := limitExceededResponse(err); ok {
+		return code, body
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "Quote not found"}
+	case err == fx.ErrRateNotAvailable:
+		return http.StatusServiceUnavailable, gin.H{"error": "Exchange rate not available"}
+	case err == models.ErrWalletNotFound:
+		return http.StatusNotFound, gin.H{"error": "Wallet not found"}
+	case err == models.ErrConversionTooSmall:
+		return http.StatusBadRequest, gin.H{"error": "Amount is too small to convert"}
+	case err == models.ErrAmountOutOfRange:
+		return http.StatusBadRequest, gin.H{"error": "Converted amount is out of range"}
+	case err == models.ErrInvalidTransaction:
+		return http.StatusBadRequest, gin.H{"error": "Balance is not enough"}
+	case err == models.ErrFXQuoteNotActive:
+		return http.StatusConflict, gin.H{"error": "Quote has already been used"}
+	case err == models.ErrFXQuoteExpired:
+		return http.StatusConflict, gin.H{"error": "Quote has expired"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process conversion"}
+	}
+}
@@ -0,0 +1,216 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreateHoldRequest struct {
+	Amount      models.Money `json:"amount" binding:"required,gt=0"`
+	Currency    string       `json:"currency,omitempty"`
+	Description string       `json:"remarks" binding:"required"`
+	// ExpiresIn is the hold lifetime in seconds; defaults to HOLD_DEFAULT_TTL
+	ExpiresIn int `json:"expires_in" binding:"omitempty,gt=0"`
+}
+
+type CaptureHoldRequest struct {
+	// Amount is optional; when omitted the full held amount is captured
+	Amount      models.Money `json:"amount" binding:"omitempty,gt=0"`
+	Description string       `json:"remarks,omitempty"`
+}
+
+// CreateHold reserves funds for a later capture or void
+func CreateHold(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req CreateHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, code, body := resolveCurrency(req.Currency, req.Amount)
+	if currency == "" {
+		c.JSON(code, body)
+		return
+	}
+
+	cfg := config.Get()
+	ttl, ok := expiryTTL(req.ExpiresIn, cfg.HoldDefaultTTL, cfg.HoldMaxTTL)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Hold expiry is too long"})
+		return
+	}
+
+	respondIdempotent(c, userID, req, func(db *gorm.DB) (int, gin.H) {
+		holdRepo := repositories.NewHoldRepository(db)
+		hold, err := holdRepo.Create(userID, currency, req.Amount, req.Description, ttl)
+		if err != nil {
+			log.Printf("Create hold error: %v", err)
+			return holdErrorResponse(err)
+		}
+		return http.StatusCreated, gin.H{
+			"status": "SUCCESS",
+			"result": holdResponse(hold),
+		}
+	})
+}
+
+// GetHolds lists the user's holds, optionally filtered by ?status=
+func GetHolds(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	status := c.Query("status")
+	switch status {
+	case "", models.HoldActive, models.HoldCaptured, models.HoldVoided, models.HoldExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold status"})
+		return
+	}
+
+	holdRepo := repositories.NewHoldRepository(config.DB)
+	holds, err := holdRepo.GetUserHolds(userID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch holds"})
+		return
+	}
+
+	holdResponses := []gin.H{}
+	for i := range holds {
+		holdResponses = append(holdResponses, holdResponse(&holds[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": holdResponses,
+	})
+}
+
+// CaptureHold turns a hold into a payment, releasing any uncaptured remainder
+func CaptureHold(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	holdID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold ID"})
+		return
+	}
+
+	var req CaptureHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondIdempotent(c, userID, req, func(db *gorm.DB) (int, gin.H) {
+		holdRepo := repositories.NewHoldRepository(db)
+		hold, transaction, err := holdRepo.Capture(holdID, userID, req.Amount, req.Description)
+		if err != nil {
+			log.Printf("Capture hold error: %v", err)
+			return holdErrorResponse(err)
+		}
+
+		return http.StatusOK, gin.H{
+			"status": "SUCCESS",
+			"result": gin.H{
+				"hold":           holdResponse(hold),
+				"payment_id":     transaction.ID,
+				"amount":         transaction.Amount,
+				"currency":       transaction.Currency,
+				"balance_before": transaction.BalanceBefore,
+				"balance_after":  transaction.BalanceAfter - transaction.Fee,
+				"fee":            transaction.FeeBreakdown,
+				"remark":         transaction.Description,
+				"created_at":     transaction.CreatedAt.Format("2006-01-02 15:04:05"),
+			},
+		}
+	})
+}
+
+// VoidHold releases a hold without moving money
+func VoidHold(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	holdID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold ID"})
+		return
+	}
+
+	holdRepo := repositories.NewHoldRepository(config.DB)
+	hold, err := holdRepo.Void(holdID, userID)
+	if err != nil {
+		log.Printf("Void hold error: %v", err)
+		code, body := holdErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": holdResponse(hold),
+	})
+}
+
+// expiryTTL returns the lifetime asked for by expiresIn seconds, or defaultTTL
+// when expiresIn is zero. ok is false when that is longer than maxTTL; the
+// comparison is made in seconds, where a huge expiresIn can't overflow.
+func expiryTTL(expiresIn int, defaultTTL, maxTTL time.Duration) (ttl time.Duration, ok bool) {
+	if expiresIn == 0 {
+		return defaultTTL, defaultTTL <= maxTTL
+	}
+	if time.Duration(expiresIn) > maxTTL/time.Second {
+		return 0, false
+	}
+	return time.Duration(expiresIn) * time.Second, true
+}
+
+func holdResponse(hold *models.Hold) gin.H {
+	return gin.H{
+		"hold_id":         hold.ID,
+		"amount":          hold.Amount,
+		"currency":        hold.Currency,
+		"captured_amount": hold.CapturedAmount,
+		"status":          hold.Status,
+		"remarks":         hold.Description,
+		"transaction_id":  hold.TransactionID,
+		"expires_at":      hold.ExpiresAt.Format("2006-01-02 15:04:05"),
+		"created_date":    hold.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func holdErrorResponse(err error) (int, gin.H) {
+	if code, body, ok := limitExceededResponse(err); ok {
+		return code, body
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "Hold not found"}
+	case err == models.ErrInvalidTransaction:
+		return http.StatusBadRequest, gin.H{"error": "Balance is not enough"}
+	case err == models.ErrWalletNotFound:
+		return http.StatusNotFound, gin.H{"error": "Wallet not found"}
+	case err == models.ErrHoldNotActive:
+		return http.StatusConflict, gin.H{"error": "Hold is no longer active"}
+	case err == models.ErrHoldExpired:
+		return http.StatusConflict, gin.H{"error": "Hold has expired"}
+	case err == models.ErrCaptureExceedsHold:
+		return http.StatusBadRequest, gin.H{"error": "Capture amount exceeds held amount"}
+	case err == models.ErrCurrencyPrecision:
+		return http.StatusBadRequest, gin.H{"error": "Amount has more decimal places than the currency allows"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process hold"}
+	}
+}
@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	jsonContentType          = "application/json; charset=utf-8"
+)
+
+var errIdempotentRequestFailed = errors.New("idempotent request failed")
+
+// idempotentHandler performs the money movement against db and returns the
+// HTTP status and body to send to the client.
+type idempotentHandler func(db *gorm.DB) (int, gin.H)
+
+// respondIdempotent runs handle and writes its response. When the request
+// carries an Idempotency-Key header, the key is claimed, the handler runs and
+// its response is stored in one database transaction, so the repository's own
+// db.Transaction blocks nest inside it and either everything commits or
+// nothing does. Retries with the same key and body replay the stored response;
+// retries with a different body are rejected. Only successful responses are
+// stored, so a failed request can be retried with the same key.
+func respondIdempotent(c *gin.Context, userID uuid.UUID, req interface{}, handle idempotentHandler) {
+	key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+	if key == "" {
+		code, body := handle(config.DB)
+		c.JSON(code, body)
+		return
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+		return
+	}
+
+	// The concrete path, not the route pattern, so that the same key sent to
+	// /transactions/:id/refund for two different transactions doesn't match
+	endpoint := c.Request.Method + " " + c.Request.URL.Path
+	requestHash, err := hashRequest(endpoint, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+
+	idempotencyRepo := repositories.NewIdempotencyRepository(config.DB)
+	existing, err := idempotencyRepo.FindByKey(userID, key)
+	if err == nil {
+		replayIdempotentResponse(c, existing, requestHash)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+
+	record := &models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Endpoint:    endpoint,
+		RequestHash: requestHash,
+	}
+
+	var code int
+	var body gin.H
+	var encoded []byte
+	claimed := false
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		txRepo := repositories.NewIdempotencyRepository(tx)
+		if err := txRepo.Claim(record); err != nil {
+			return err
+		}
+		claimed = true
+
+		code, body = handle(tx)
+		if code < 200 || code >= 300 {
+			return errIdempotentRequestFailed
+		}
+
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return err
+		}
+		return txRepo.SaveResponse(record, code, encoded)
+	})
+
+	switch {
+	case err == nil:
+		c.Data(code, jsonContentType, encoded)
+	case err == errIdempotentRequestFailed:
+		c.JSON(code, body)
+	case !claimed:
+		// A concurrent request with the same key committed first
+		existing, findErr := idempotencyRepo.FindByKey(userID, key)
+		if findErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+			return
+		}
+		replayIdempotentResponse(c, existing, requestHash)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, record *models.IdempotencyKey, requestHash string) {
+	if record.RequestHash != requestHash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key has already been used with a different request"})
+		return
+	}
+	if record.ResponseCode == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+		return
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(record.ResponseCode, jsonContentType, []byte(record.ResponseBody))
+}
+
+// hashRequest fingerprints the endpoint and the bound request body, so that
+// formatting differences in the raw JSON do not count as a different request.
+func hashRequest(endpoint string, req interface{}) (string, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(endpoint+"\n"), payload...))
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/denys89/ewallet-api/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys that verify access tokens, including keys
+// scheduled to take over signing.
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.Keys.JWKS(time.Now()))
+}
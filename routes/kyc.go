@@ -0,0 +1,443 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/denys89/ewallet-api/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// KYCSubmissionRequest is the form part of a multipart KYC submission. The
+// document_image and selfie_image files are read separately.
+type KYCSubmissionRequest struct {
+	RequestedTier  string    `form:"requested_tier" binding:"required,oneof=BASIC FULL"`
+	DocumentType   string    `form:"document_type" binding:"required,oneof=NATIONAL_ID PASSPORT DRIVING_LICENSE"`
+	DocumentNumber string    `form:"document_number" binding:"required,max=50"`
+	FullName       string    `form:"full_name" binding:"required,max=255"`
+	DateOfBirth    time.Time `form:"date_of_birth" binding:"required" time_format:"2006-01-02"`
+}
+
+type RejectKYCRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// kycImageTypes maps the accepted image content types to file extensions
+var kycImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+var (
+	errUploadTooLarge   = errors.New("file is too large")
+	errUploadNotAnImage = errors.New("file must be a JPEG or PNG image")
+)
+
+// SubmitKYC uploads identity document images and asks for a KYC tier upgrade
+func SubmitKYC(store storage.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+		maxSize := config.Get().KYCMaxUploadSize
+
+		// Two images plus room for the form fields
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 2*maxSize+1<<20)
+
+		var req KYCSubmissionRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		documentFile, err := c.FormFile("document_image")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "document_image is required"})
+			return
+		}
+		selfieFile, err := c.FormFile("selfie_image")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "selfie_image is required"})
+			return
+		}
+
+		submission := &models.KYCSubmission{
+			ID:             uuid.New(),
+			UserID:         userID,
+			RequestedTier:  req.RequestedTier,
+			DocumentType:   req.DocumentType,
+			DocumentNumber: req.DocumentNumber,
+			FullName:       req.FullName,
+			DateOfBirth:    req.DateOfBirth,
+		}
+
+		// Upload the images first; they are removed again if the
+		// submission can't be stored
+		prefix := fmt.Sprintf("kyc/%s/%s/", userID, submission.ID)
+		var uploaded []string
+		cleanup := func() {
+			for _, key := range uploaded {
+				if err := store.Delete(c.Request.Context(), key); err != nil {
+					log.Printf("failed to delete KYC upload %s: %v", key, err)
+				}
+			}
+		}
+
+		for _, upload := range []struct {
+			name string
+			file *multipart.FileHeader
+			key  *string
+		}{
+			{"document", documentFile, &submission.DocumentImageKey},
+			{"selfie", selfieFile, &submission.SelfieImageKey},
+		} {
+			key, err := uploadKYCImage(c, store, prefix+upload.name, upload.file, maxSize)
+			if err != nil {
+				cleanup()
+				if err == errUploadTooLarge || err == errUploadNotAnImage {
+					c.JSON(http.StatusBadRequest, gin.H{"error": upload.name + "_image: " + err.Error()})
+					return
+				}
+				log.Printf("KYC upload error: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store documents"})
+				return
+			}
+			*upload.key = key
+			uploaded = append(uploaded, key)
+		}
+
+		kycRepo := repositories.NewKYCRepository(config.DB)
+		if err := kycRepo.Submit(submission); err != nil {
+			cleanup()
+			switch {
+			case err == models.ErrKYCAlreadyPending:
+				c.JSON(http.StatusConflict, gin.H{"error": "A KYC submission is already under review"})
+			case err == models.ErrKYCTierNotHigher:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Requested tier must be higher than your current tier"})
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			default:
+				log.Printf("KYC submit error: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit KYC"})
+			}
+			return
+		}
+
+		auditKYC(c, models.AuditKYCSubmitted, submission)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"status": "SUCCESS",
+			"result": kycSubmissionResponse(submission),
+		})
+	}
+}
+
+// uploadKYCImage checks that the file is a small enough JPEG or PNG and
+// stores it under key plus the matching extension.
+func uploadKYCImage(c *gin.Context, store storage.BlobStore, key string, file *multipart.FileHeader, maxSize int64) (string, error) {
+	if file.Size > maxSize {
+		return "", errUploadTooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Sniff the real content type rather than trusting the client
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+
+	ext, ok := kycImageTypes[http.DetectContentType(head)]
+	if !ok {
+		return "", errUploadNotAnImage
+	}
+
+	key += ext
+	if err := store.Put(c.Request.Context(), key, io.MultiReader(bytes.NewReader(head), f)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// GetKYCStatus shows the user's tier, verification status and submissions
+func GetKYCStatus(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	userRepo := repositories.NewUserRepository(config.DB)
+	user, err := userRepo.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	kycRepo := repositories.NewKYCRepository(config.DB)
+	submissions, err := kycRepo.GetUserSubmissions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch KYC status"})
+		return
+	}
+
+	submissionResponses := []gin.H{}
+	for i := range submissions {
+		submissionResponses = append(submissionResponses, kycSubmissionResponse(&submissions[i]))
+	}
+
+	result := gin.H{
+		"kyc_tier":    user.KYCTier,
+		"kyc_status":  user.KYCStatus,
+		"submissions": submissionResponses,
+	}
+	if user.KYCReviewedAt != nil {
+		result["kyc_reviewed_at"] = user.KYCReviewedAt.Format("2006-01-02 15:04:05")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": result,
+	})
+}
+
+// ListKYCSubmissions is the admin review queue, filtered by ?status=
+// (PENDING by default, ALL for everything)
+func ListKYCSubmissions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	status := c.DefaultQuery("status", models.KYCStatusPending)
+	switch status {
+	case "ALL":
+		status = ""
+	case models.KYCStatusPending, models.KYCStatusApproved, models.KYCStatusRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	kycRepo := repositories.NewKYCRepository(config.DB)
+	submissions, total, err := kycRepo.GetSubmissions(status, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch KYC submissions"})
+		return
+	}
+
+	submissionResponses := []gin.H{}
+	for i := range submissions {
+		response := kycSubmissionResponse(&submissions[i])
+		response["user_id"] = submissions[i].UserID
+		response["document_image_url"] = kycDocumentURL(&submissions[i], "document")
+		response["selfie_image_url"] = kycDocumentURL(&submissions[i], "selfie")
+		submissionResponses = append(submissionResponses, response)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": submissionResponses,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetKYCDocument streams a submission's document or selfie image to an admin
+func GetKYCDocument(store storage.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		submission, ok := findKYCSubmission(c)
+		if !ok {
+			return
+		}
+
+		var key string
+		switch c.Param("kind") {
+		case "document":
+			key = submission.DocumentImageKey
+		case "selfie":
+			key = submission.SelfieImageKey
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+
+		blob, err := store.Get(c.Request.Context(), key)
+		if err != nil {
+			if err == storage.ErrNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read document"})
+			return
+		}
+		defer blob.Close()
+
+		contentType := "application/octet-stream"
+		for mimeType, ext := range kycImageTypes {
+			if path.Ext(key) == ext {
+				contentType = mimeType
+			}
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.DataFromReader(http.StatusOK, -1, contentType, blob, nil)
+	}
+}
+
+// ApproveKYC upgrades the user to the tier they asked for
+func ApproveKYC(c *gin.Context) {
+	reviewerID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	submissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+
+	kycRepo := repositories.NewKYCRepository(config.DB)
+	submission, err := kycRepo.Approve(submissionID, reviewerID)
+	if err != nil {
+		code, body := kycReviewErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	auditKYC(c, models.AuditKYCApproved, submission)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": kycSubmissionResponse(submission),
+	})
+}
+
+// RejectKYC turns a submission down with a reason shown to the user
+func RejectKYC(c *gin.Context) {
+	reviewerID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	submissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+
+	var req RejectKYCRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	kycRepo := repositories.NewKYCRepository(config.DB)
+	submission, err := kycRepo.Reject(submissionID, reviewerID, req.Reason)
+	if err != nil {
+		code, body := kycReviewErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	auditKYC(c, models.AuditKYCRejected, submission)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": kycSubmissionResponse(submission),
+	})
+}
+
+func findKYCSubmission(c *gin.Context) (*models.KYCSubmission, bool) {
+	submissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return nil, false
+	}
+
+	kycRepo := repositories.NewKYCRepository(config.DB)
+	submission, err := kycRepo.FindByID(submissionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "KYC submission not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch KYC submission"})
+		return nil, false
+	}
+	return submission, true
+}
+
+func kycReviewErrorResponse(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "KYC submission not found"}
+	case err == models.ErrKYCNotPending:
+		return http.StatusConflict, gin.H{"error": "KYC submission has already been reviewed"}
+	case err == models.ErrKYCSelfReview:
+		return http.StatusForbidden, gin.H{"error": "You cannot review your own KYC submission"}
+	default:
+		log.Printf("KYC review error: %v", err)
+		return http.StatusInternalServerError, gin.H{"error": "Failed to review KYC submission"}
+	}
+}
+
+func auditKYC(c *gin.Context, action string, submission *models.KYCSubmission) {
+	details := gin.H{
+		"submission_id":  submission.ID,
+		"requested_tier": submission.RequestedTier,
+		"status":         submission.Status,
+	}
+	if submission.ReviewedBy != nil {
+		details["reviewed_by"] = submission.ReviewedBy
+	}
+	if submission.RejectionReason != "" {
+		details["rejection_reason"] = submission.RejectionReason
+	}
+
+	err := repositories.NewAuditRepository(config.DB).Record(action, &submission.UserID, c.ClientIP(), details)
+	if err != nil {
+		log.Printf("failed to audit %s for KYC submission %s: %v", action, submission.ID, err)
+	}
+}
+
+func kycDocumentURL(submission *models.KYCSubmission, kind string) string {
+	return fmt.Sprintf("/api/v1/admin/kyc/submissions/%s/documents/%s", submission.ID, kind)
+}
+
+func kycSubmissionResponse(submission *models.KYCSubmission) gin.H {
+	response := gin.H{
+		"submission_id":   submission.ID,
+		"requested_tier":  submission.RequestedTier,
+		"document_type":   submission.DocumentType,
+		"document_number": submission.DocumentNumber,
+		"full_name":       submission.FullName,
+		"date_of_birth":   submission.DateOfBirth.Format("2006-01-02"),
+		"status":          submission.Status,
+		"created_date":    submission.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if submission.RejectionReason != "" {
+		response["rejection_reason"] = submission.RejectionReason
+	}
+	if submission.ReviewedAt != nil {
+		response["reviewed_at"] = submission.ReviewedAt.Format("2006-01-02 15:04:05")
+	}
+	return response
+}
@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListLedgerMismatches lists every wallet whose cached balance has drifted
+// from its ledger account
+func ListLedgerMismatches(c *gin.Context) {
+	ledgerRepo := repositories.NewLedgerRepository(config.DB)
+	mismatches, err := ledgerRepo.FindBalanceMismatches()
+	if err != nil {
+		log.Printf("Ledger reconciliation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": mismatches,
+	})
+}
+
+// VerifyWalletLedger compares one wallet's cached balance with its ledger
+// account
+func VerifyWalletLedger(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	currency, err := models.LookupCurrency(c.Param("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+
+	walletRepo := repositories.NewWalletRepository(config.DB)
+	wallet, err := walletRepo.Find(userID, currency.Code)
+	if err == models.ErrWalletNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify wallet"})
+		return
+	}
+
+	ledgerRepo := repositories.NewLedgerRepository(config.DB)
+	ledgerBalance, err := ledgerRepo.VerifyWalletBalance(userID, currency.Code)
+	if err != nil && err != models.ErrBalanceMismatch {
+		log.Printf("Verify wallet ledger error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify wallet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": gin.H{
+			"user_id":        userID,
+			"currency":       currency.Code,
+			"cached_balance": wallet.Balance,
+			"ledger_balance": ledgerBalance,
+			"matches":        err == nil,
+		},
+	})
+}
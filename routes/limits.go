@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetLimits shows the user's KYC tier limits and how much of each is left
+func GetLimits(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	userRepo := repositories.NewUserRepository(config.DB)
+	user, err := userRepo.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	limitRepo := repositories.NewLimitRepository(config.DB)
+	summary, err := limitRepo.Summary(user, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch limits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": summary,
+	})
+}
+
+// limitExceededResponse builds the LIMIT_EXCEEDED response when err is a
+// tier limit error.
+func limitExceededResponse(err error) (int, gin.H, bool) {
+	var exceeded *models.LimitExceededError
+	if !errors.As(err, &exceeded) {
+		return 0, nil, false
+	}
+
+	body := gin.H{
+		"error":     "Transaction limit exceeded",
+		"code":      "LIMIT_EXCEEDED",
+		"limit":     exceeded.Limit,
+		"remaining": exceeded.Remaining,
+		"currency":  models.DefaultCurrency,
+	}
+	if exceeded.TransactionType != "" {
+		body["transaction_type"] = exceeded.TransactionType
+	}
+	return http.StatusUnprocessableEntity, body, true
+}
@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// loginGuard tracks failed logins for one request's phone number and client
+// IP and locks either out once it has failed too often.
+type loginGuard struct {
+	attempts *repositories.LoginAttemptRepository
+	audit    *repositories.AuditRepository
+	phone    string
+	ip       string
+	now      time.Time
+}
+
+func newLoginGuard(c *gin.Context, phoneNumber string) *loginGuard {
+	return &loginGuard{
+		attempts: repositories.NewLoginAttemptRepository(config.DB),
+		audit:    repositories.NewAuditRepository(config.DB),
+		phone:    phoneNumber,
+		ip:       c.ClientIP(),
+		now:      time.Now(),
+	}
+}
+
+func (g *loginGuard) policy(scope string) models.LockoutPolicy {
+	cfg := config.Get()
+	policy := models.LockoutPolicy{
+		MaxAttempts:  cfg.MaxLoginAttempts,
+		BaseDuration: cfg.LoginLockoutDuration.Duration(),
+		MaxDuration:  cfg.LoginLockoutMaxDuration,
+		ResetAfter:   cfg.LoginLockoutMaxDuration,
+	}
+	// Many users can share one address, so IPs get a larger allowance
+	if scope == models.LoginScopeIP {
+		policy.MaxAttempts = cfg.MaxLoginAttemptsPerIP
+	}
+	return policy
+}
+
+func (g *loginGuard) key(scope string) string {
+	if scope == models.LoginScopeIP {
+		return g.ip
+	}
+	return g.phone
+}
+
+// lockedUntil returns when the lockout covering the phone number or IP ends,
+// or nil when neither is locked.
+func (g *loginGuard) lockedUntil() (*time.Time, error) {
+	var until *time.Time
+	for _, scope := range []string{models.LoginScopePhone, models.LoginScopeIP} {
+		attempt, err := g.attempts.Find(scope, g.key(scope))
+		if err != nil {
+			return nil, err
+		}
+		if attempt.IsLocked(g.now) && (until == nil || attempt.LockedUntil.After(*until)) {
+			until = attempt.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// fail records a failed login and returns the response to send. userID is set
+// when the phone number belongs to a user.
+func (g *loginGuard) fail(userID *uuid.UUID) (int, gin.H) {
+	var until *time.Time
+	remaining := math.MaxInt
+
+	for _, scope := range []string{models.LoginScopePhone, models.LoginScopeIP} {
+		policy := g.policy(scope)
+		attempt, lockedNow, err := g.attempts.RecordFailure(scope, g.key(scope), policy, g.now)
+		if err != nil {
+			return http.StatusInternalServerError, gin.H{"error": "Failed to process login"}
+		}
+
+		if lockedNow {
+			g.auditLockout(userID, attempt)
+		}
+		if attempt.IsLocked(g.now) {
+			if until == nil || attempt.LockedUntil.After(*until) {
+				until = attempt.LockedUntil
+			}
+		} else if left := policy.MaxAttempts - attempt.FailedCount; left < remaining {
+			remaining = left
+		}
+	}
+
+	if until != nil {
+		return lockedResponse(*until, g.now)
+	}
+	return http.StatusUnauthorized, gin.H{
+		"error":              "Phone Number and PIN doesn't match",
+		"remaining_attempts": remaining,
+	}
+}
+
+// succeed clears the phone number's failures. The IP's counter is left to
+// expire so that logging into one account can't reset guesses at another.
+func (g *loginGuard) succeed() error {
+	return g.attempts.Reset(models.LoginScopePhone, g.phone)
+}
+
+func (g *loginGuard) auditLockout(userID *uuid.UUID, attempt *models.LoginAttempt) {
+	err := g.audit.Record(models.AuditLoginLockout, userID, g.ip, gin.H{
+		"scope":         attempt.Scope,
+		"key":           attempt.Key,
+		"lockout_count": attempt.LockoutCount,
+		"locked_until":  attempt.LockedUntil.Format("2006-01-02 15:04:05"),
+	})
+	if err != nil {
+		log.Printf("failed to audit login lockout for %s %s: %v", attempt.Scope, attempt.Key, err)
+	}
+}
+
+func lockedResponse(until, now time.Time) (int, gin.H) {
+	retryAfter := int(math.Ceil(until.Sub(now).Seconds()))
+	return http.StatusTooManyRequests, gin.H{
+		"error":        "Too many failed login attempts, please try again later",
+		"locked":       true,
+		"locked_until": until.Format("2006-01-02 15:04:05"),
+		"retry_after":  retryAfter,
+	}
+}
+
+// respondLoginFailure writes a failed login response, adding a Retry-After
+// header when the client is locked out.
+func respondLoginFailure(c *gin.Context, status int, body gin.H) {
+	if retryAfter, ok := body["retry_after"].(int); ok {
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+	}
+	c.JSON(status, body)
+}
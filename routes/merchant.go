@@ -0,0 +1,295 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxMerchantReportDays caps the range of a daily totals report
+const maxMerchantReportDays = 366
+
+type RegisterMerchantRequest struct {
+	Name               string `json:"name" binding:"required,max=100"`
+	CategoryCode       string `json:"category_code" binding:"required,len=4,numeric"`
+	Email              string `json:"email" binding:"omitempty,email"`
+	PhoneNumber        string `json:"phone_number" binding:"omitempty,max=20"`
+	Address            string `json:"address,omitempty"`
+	City               string `json:"city" binding:"omitempty,max=100"`
+	SettlementCurrency string `json:"settlement_currency,omitempty"`
+}
+
+// RegisterMerchant creates a merchant owned by the caller, settling into the
+// caller's wallet in the settlement currency
+func RegisterMerchant(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req RegisterMerchantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, code, body := resolveCurrency(req.SettlementCurrency, 0)
+	if currency == "" {
+		c.JSON(code, body)
+		return
+	}
+
+	merchant := &models.Merchant{
+		OwnerID:            userID,
+		Name:               strings.TrimSpace(req.Name),
+		CategoryCode:       req.CategoryCode,
+		Email:              req.Email,
+		PhoneNumber:        req.PhoneNumber,
+		Address:            req.Address,
+		City:               req.City,
+		SettlementCurrency: currency,
+	}
+
+	merchantRepo := repositories.NewMerchantRepository(config.DB)
+	if err := merchantRepo.Create(merchant); err != nil {
+		log.Printf("Register merchant error: %v", err)
+		code, body := merchantErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "SUCCESS",
+		"result": merchantResponse(merchant),
+	})
+}
+
+// GetMerchants lists the caller's merchants
+func GetMerchants(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	merchantRepo := repositories.NewMerchantRepository(config.DB)
+	merchants, err := merchantRepo.GetOwnerMerchants(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch merchants"})
+		return
+	}
+
+	merchantResponses := []gin.H{}
+	for i := range merchants {
+		merchantResponses = append(merchantResponses, merchantResponse(&merchants[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": merchantResponses,
+	})
+}
+
+// GetMerchant returns one of the caller's merchants
+func GetMerchant(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": merchantResponse(merchant),
+	})
+}
+
+// GetMerchantPayments lists the payments one of the caller's merchants
+// received, optionally between ?from= and ?to= (inclusive dates)
+func GetMerchantPayments(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	from, to, ok := merchantReportRange(c, time.Time{})
+	if !ok {
+		return
+	}
+
+	merchantRepo := repositories.NewMerchantRepository(config.DB)
+	payments, total, err := merchantRepo.GetPayments(merchant.ID, from, to, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+		return
+	}
+
+	paymentResponses := []gin.H{}
+	for _, payment := range payments {
+		paymentResponses = append(paymentResponses, gin.H{
+			"payment_id":       payment.ID,
+			"reference_number": payment.ReferenceNumber,
+			"payer_id":         payment.UserID,
+			"amount":           payment.Amount,
+			"currency":         payment.Currency,
+			"refunded_amount":  payment.RefundedAmount,
+			"status":           payment.Status,
+			"remarks":          payment.Description,
+			"created_date":     payment.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": paymentResponses,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetMerchantDailyTotals sums one of the caller's merchants' payments per
+// day between ?from= and ?to= (inclusive dates), the last 30 days by default
+func GetMerchantDailyTotals(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	from, to, ok := merchantReportRange(c, today.AddDate(0, 0, -29))
+	if !ok {
+		return
+	}
+	if to.IsZero() {
+		to = today.AddDate(0, 0, 1)
+	}
+	if to.Sub(from) > maxMerchantReportDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date range is too long"})
+		return
+	}
+
+	merchantRepo := repositories.NewMerchantRepository(config.DB)
+	totals, err := merchantRepo.DailyTotals(merchant.ID, from, to, time.Local)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily totals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": gin.H{
+			"merchant_id": merchant.ID,
+			"currency":    merchant.SettlementCurrency,
+			"from":        from.Format("2006-01-02"),
+			"to":          to.AddDate(0, 0, -1).Format("2006-01-02"),
+			"days":        totals,
+		},
+	})
+}
+
+// ownedMerchant loads the caller's merchant named by the :id parameter, or the
+// merchant whose API key signed the request, writing the error response when
+// it can't.
+func ownedMerchant(c *gin.Context) (*models.Merchant, bool) {
+	merchantRepo := repositories.NewMerchantRepository(config.DB)
+	if merchantID, ok := c.Get(middleware.MerchantIDKey); ok {
+		merchant, err := merchantRepo.FindByID(merchantID.(uuid.UUID))
+		if err != nil {
+			code, body := merchantErrorResponse(err)
+			c.JSON(code, body)
+			return nil, false
+		}
+		return merchant, true
+	}
+
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return nil, false
+	}
+
+	merchant, err := merchantRepo.FindOwned(merchantID, userID)
+	if err != nil {
+		code, body := merchantErrorResponse(err)
+		c.JSON(code, body)
+		return nil, false
+	}
+	return merchant, true
+}
+
+// merchantReportRange parses ?from= and ?to= as inclusive local dates and
+// returns them as a half-open range. from defaults to defaultFrom; a zero to
+// leaves the range open.
+func merchantReportRange(c *gin.Context, defaultFrom time.Time) (time.Time, time.Time, bool) {
+	from, to := defaultFrom, time.Time{}
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD)"})
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD)"})
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+func merchantResponse(merchant *models.Merchant) gin.H {
+	return gin.H{
+		"merchant_id":         merchant.ID,
+		"name":                merchant.Name,
+		"category_code":       merchant.CategoryCode,
+		"email":               merchant.Email,
+		"phone_number":        merchant.PhoneNumber,
+		"address":             merchant.Address,
+		"city":                merchant.City,
+		"settlement_currency": merchant.SettlementCurrency,
+		"status":              merchant.Status,
+		"created_date":        merchant.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func merchantErrorResponse(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "Merchant not found"}
+	case err == models.ErrMerchantRequiresKYC:
+		return http.StatusForbidden, gin.H{"error": "Merchant accounts require full KYC verification"}
+	case err == models.ErrInvalidCategoryCode:
+		return http.StatusBadRequest, gin.H{"error": "Merchant category code must be four digits"}
+	case err == models.ErrWalletNotFound:
+		return http.StatusNotFound, gin.H{"error": "Open a wallet in the settlement currency first"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process merchant"}
+	}
+}
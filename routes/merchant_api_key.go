@@ -0,0 +1,249 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denys89/ewallet-api/auth"
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateMerchantAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"omitempty,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+// CreateMerchantAPIKey issues an API key for one of the caller's merchants.
+// The signing secret is only returned here and when the key is rotated.
+func CreateMerchantAPIKey(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	var req CreateMerchantAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, secret, err := newMerchantAPIKey()
+	if err != nil {
+		log.Printf("Generate merchant API key error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process API key"})
+		return
+	}
+	key.MerchantID = merchant.ID
+	key.Name = strings.TrimSpace(req.Name)
+
+	keyRepo := repositories.NewMerchantAPIKeyRepository(config.DB)
+	if err := keyRepo.Create(key, req.Scopes, time.Now()); err != nil {
+		log.Printf("Create merchant API key error: %v", err)
+		code, body := merchantAPIKeyErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	result := merchantAPIKeyResponse(key)
+	result["secret"] = secret
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "SUCCESS",
+		"result": result,
+	})
+}
+
+// GetMerchantAPIKeys lists the API keys of one of the caller's merchants
+func GetMerchantAPIKeys(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	keyRepo := repositories.NewMerchantAPIKeyRepository(config.DB)
+	keys, err := keyRepo.GetMerchantKeys(merchant.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	keyResponses := []gin.H{}
+	for i := range keys {
+		keyResponses = append(keyResponses, merchantAPIKeyResponse(&keys[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": keyResponses,
+	})
+}
+
+// RotateMerchantAPIKey replaces an API key with a new one with the same
+// scopes. The old key keeps working for the configured grace period.
+func RotateMerchantAPIKey(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	key, secret, err := newMerchantAPIKey()
+	if err != nil {
+		log.Printf("Generate merchant API key error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process API key"})
+		return
+	}
+
+	keyRepo := repositories.NewMerchantAPIKeyRepository(config.DB)
+	old, err := keyRepo.Rotate(merchant.ID, c.Param("key_id"), key, config.Get().MerchantAPIKeyRotationGrace, time.Now())
+	if err != nil {
+		log.Printf("Rotate merchant API key error: %v", err)
+		code, body := merchantAPIKeyErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	result := merchantAPIKeyResponse(key)
+	result["secret"] = secret
+	result["previous_key"] = merchantAPIKeyResponse(old)
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "SUCCESS",
+		"result": result,
+	})
+}
+
+// RevokeMerchantAPIKey stops an API key from signing requests straight away
+func RevokeMerchantAPIKey(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	keyRepo := repositories.NewMerchantAPIKeyRepository(config.DB)
+	key, err := keyRepo.Revoke(merchant.ID, c.Param("key_id"), time.Now())
+	if err != nil {
+		log.Printf("Revoke merchant API key error: %v", err)
+		code, body := merchantAPIKeyErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": merchantAPIKeyResponse(key),
+	})
+}
+
+// GetMerchantAPIKeyUsage lists the requests made with one of the caller's
+// merchant API keys, newest first
+func GetMerchantAPIKeyUsage(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	keyRepo := repositories.NewMerchantAPIKeyRepository(config.DB)
+	key, err := keyRepo.Find(merchant.ID, c.Param("key_id"))
+	if err != nil {
+		code, body := merchantAPIKeyErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	usage, total, err := keyRepo.GetUsage(key.ID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API key usage"})
+		return
+	}
+
+	usageResponses := []gin.H{}
+	for _, request := range usage {
+		usageResponses = append(usageResponses, gin.H{
+			"method":       request.Method,
+			"path":         request.Path,
+			"status_code":  request.StatusCode,
+			"ip_address":   request.IPAddress,
+			"created_date": request.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": usageResponses,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// newMerchantAPIKey generates the key ID and signing secret of a new API key,
+// returning the key with its secret sealed and the secret to show once.
+func newMerchantAPIKey() (*models.MerchantAPIKey, string, error) {
+	keyID, err := models.NewAPIKeyID()
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := auth.NewAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+	sealed, err := auth.SealAPIKeySecret(config.Get().MerchantAPIKeySecret, keyID, secret)
+	if err != nil {
+		return nil, "", err
+	}
+	return &models.MerchantAPIKey{KeyID: keyID, SecretCiphertext: sealed}, secret, nil
+}
+
+func merchantAPIKeyResponse(key *models.MerchantAPIKey) gin.H {
+	formatTime := func(t *time.Time) interface{} {
+		if t == nil {
+			return nil
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+
+	return gin.H{
+		"key_id":       key.KeyID,
+		"merchant_id":  key.MerchantID,
+		"name":         key.Name,
+		"scopes":       key.ScopeList(),
+		"status":       key.CurrentStatus(time.Now()),
+		"expires_at":   formatTime(key.ExpiresAt),
+		"last_used_at": formatTime(key.LastUsedAt),
+		"revoked_at":   formatTime(key.RevokedAt),
+		"created_date": key.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func merchantAPIKeyErrorResponse(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "API key not found"}
+	case err == models.ErrInvalidScope:
+		return http.StatusBadRequest, gin.H{"error": "Scopes must be one or more of " + strings.Join(models.APIKeyScopes, ", ")}
+	case err == models.ErrAPIKeyNotActive:
+		return http.StatusConflict, gin.H{"error": "API key has been revoked or has expired"}
+	case err == models.ErrMerchantKeyLimit:
+		return http.StatusConflict, gin.H{"error": "Merchant has too many active API keys"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process API key"}
+	}
+}
@@ -0,0 +1,263 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreatePaymentRequestRequest struct {
+	Amount      models.Money `json:"amount" binding:"required,gt=0"`
+	Currency    string       `json:"currency,omitempty"`
+	PayerID     string       `json:"target_user,omitempty"`
+	PhoneNumber string       `json:"phone_number,omitempty"`
+	Description string       `json:"remarks,omitempty"`
+	// ExpiresIn is the request lifetime in seconds; defaults to
+	// PAYMENT_REQUEST_DEFAULT_TTL
+	ExpiresIn int `json:"expires_in" binding:"omitempty,gt=0"`
+}
+
+// CreatePaymentRequest asks another user to pay the caller
+func CreatePaymentRequest(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req CreatePaymentRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, code, body := resolveCurrency(req.Currency, req.Amount)
+	if currency == "" {
+		c.JSON(code, body)
+		return
+	}
+
+	payer, code, body := resolveRecipient(userID, TransferRequest{
+		RecipientID: req.PayerID,
+		PhoneNumber: req.PhoneNumber,
+	})
+	if payer == nil {
+		c.JSON(code, body)
+		return
+	}
+
+	cfg := config.Get()
+	ttl, ok := expiryTTL(req.ExpiresIn, cfg.PaymentRequestDefaultTTL, cfg.PaymentRequestMaxTTL)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment request expiry is too long"})
+		return
+	}
+
+	request := &models.PaymentRequest{
+		RequesterID: userID,
+		PayerID:     payer.ID,
+		Currency:    currency,
+		Amount:      req.Amount,
+		Description: req.Description,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+
+	requestRepo := repositories.NewPaymentRequestRepository(config.DB)
+	if err := requestRepo.Create(request); err != nil {
+		log.Printf("Create payment request error: %v", err)
+		code, body := paymentRequestErrorResponse(err, currency)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "SUCCESS",
+		"result": paymentRequestResponse(request, userID),
+	})
+}
+
+// GetPaymentRequests lists the payment requests the user sent or received,
+// optionally filtered by ?direction=incoming|outgoing and ?status=
+func GetPaymentRequests(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	direction := c.Query("direction")
+	switch direction {
+	case "", repositories.PaymentRequestsIncoming, repositories.PaymentRequestsOutgoing:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment request direction"})
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.PaymentRequestPending, models.PaymentRequestAccepted, models.PaymentRequestDeclined, models.PaymentRequestExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment request status"})
+		return
+	}
+
+	requestRepo := repositories.NewPaymentRequestRepository(config.DB)
+	requests, err := requestRepo.GetUserRequests(userID, direction, status, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment requests"})
+		return
+	}
+
+	requestResponses := []gin.H{}
+	for i := range requests {
+		requestResponses = append(requestResponses, paymentRequestResponse(&requests[i], userID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": requestResponses,
+	})
+}
+
+// GetPaymentRequest returns a payment request the user sent or received
+func GetPaymentRequest(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment request ID"})
+		return
+	}
+
+	requestRepo := repositories.NewPaymentRequestRepository(config.DB)
+	request, err := requestRepo.Find(requestID, userID)
+	if err != nil {
+		code, body := paymentRequestErrorResponse(err, "")
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": paymentRequestResponse(request, userID),
+	})
+}
+
+// AcceptPaymentRequest pays a request addressed to the user with a transfer
+// to the requester
+func AcceptPaymentRequest(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment request ID"})
+		return
+	}
+
+	respondIdempotent(c, userID, nil, func(db *gorm.DB) (int, gin.H) {
+		requestRepo := repositories.NewPaymentRequestRepository(db)
+		request, transaction, err := requestRepo.Accept(requestID, userID, time.Now())
+		if err != nil {
+			log.Printf("Accept payment request error: %v", err)
+			return paymentRequestErrorResponse(err, "")
+		}
+
+		return http.StatusOK, gin.H{
+			"status": "SUCCESS",
+			"result": gin.H{
+				"payment_request": paymentRequestResponse(request, userID),
+				"transfer_id":     transaction.ID,
+				"amount":          transaction.Amount,
+				"currency":        transaction.Currency,
+				"balance_before":  transaction.BalanceBefore,
+				"balance_after":   transaction.BalanceAfter - transaction.Fee,
+				"fee":             transaction.FeeBreakdown,
+				"remarks":         transaction.Description,
+				"created_date":    transaction.CreatedAt.Format("2006-01-02 15:04:05"),
+			},
+		}
+	})
+}
+
+// DeclinePaymentRequest refuses a request addressed to the user
+func DeclinePaymentRequest(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment request ID"})
+		return
+	}
+
+	requestRepo := repositories.NewPaymentRequestRepository(config.DB)
+	request, err := requestRepo.Decline(requestID, userID, time.Now())
+	if err != nil {
+		log.Printf("Decline payment request error: %v", err)
+		code, body := paymentRequestErrorResponse(err, "")
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": paymentRequestResponse(request, userID),
+	})
+}
+
+func paymentRequestResponse(request *models.PaymentRequest, userID uuid.UUID) gin.H {
+	direction := repositories.PaymentRequestsOutgoing
+	if request.PayerID == userID {
+		direction = repositories.PaymentRequestsIncoming
+	}
+
+	var respondedAt interface{}
+	if request.RespondedAt != nil {
+		respondedAt = request.RespondedAt.Format("2006-01-02 15:04:05")
+	}
+
+	return gin.H{
+		"payment_request_id": request.ID,
+		"direction":          direction,
+		"requester_id":       request.RequesterID,
+		"payer_id":           request.PayerID,
+		"amount":             request.Amount,
+		"currency":           request.Currency,
+		"remarks":            request.Description,
+		"status":             request.CurrentStatus(time.Now()),
+		"transaction_id":     request.TransactionID,
+		"split_bill_id":      request.SplitBillID,
+		"expires_at":         request.ExpiresAt.Format("2006-01-02 15:04:05"),
+		"responded_at":       respondedAt,
+		"created_date":       request.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func paymentRequestErrorResponse(err error, currency string) (int, gin.H) {
+	if code, body, ok := limitExceededResponse(err); ok {
+		return code, body
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "Payment request not found"}
+	case err == models.ErrWalletNotFound && currency != "":
+		return http.StatusNotFound, gin.H{"error": "You have no " + currency + " wallet"}
+	case err == models.ErrWalletNotFound:
+		return http.StatusNotFound, gin.H{"error": "Wallet not found"}
+	case err == models.ErrCurrencyMismatch:
+		return http.StatusUnprocessableEntity, gin.H{"error": "Requester can no longer receive this currency"}
+	case err == models.ErrSelfTransfer:
+		return http.StatusBadRequest, gin.H{"error": "Cannot request money from yourself"}
+	case err == models.ErrInvalidTransaction:
+		return http.StatusBadRequest, gin.H{"error": "Balance is not enough"}
+	case err == models.ErrAmountOutOfRange:
+		return http.StatusBadRequest, gin.H{"error": "Requester balance limit exceeded"}
+	case err == models.ErrPaymentRequestNotPending:
+		return http.StatusConflict, gin.H{"error": "Payment request is no longer pending"}
+	case err == models.ErrPaymentRequestExpired:
+		return http.StatusConflict, gin.H{"error": "Payment request has expired"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process payment request"}
+	}
+}
@@ -0,0 +1,477 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/qr"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreateQRCodeRequest struct {
+	Amount      models.Money `json:"amount" binding:"required,gt=0"`
+	Description string       `json:"remarks,omitempty"`
+	// ExpiresIn is the code lifetime in seconds; defaults to
+	// QR_CODE_DEFAULT_TTL
+	ExpiresIn int `json:"expires_in" binding:"omitempty,gt=0"`
+}
+
+type ScanQRRequest struct {
+	Payload string `json:"payload" binding:"required"`
+}
+
+type QRPaymentRequest struct {
+	Payload string `json:"payload" binding:"required"`
+	// Amount is entered by the payer for static codes and must be left out
+	// for dynamic ones
+	Amount      models.Money `json:"amount" binding:"omitempty,gt=0"`
+	Description string       `json:"remarks,omitempty"`
+}
+
+// scannedQR is a decoded payload checked against the merchant and, for
+// dynamic codes, the issued QR code.
+type scannedQR struct {
+	payload  *qr.Payload
+	merchant *models.Merchant
+	code     *models.QRCode
+}
+
+// CreateQRCode issues a dynamic QR code for one payment to one of the
+// caller's merchants
+func CreateQRCode(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	var req CreateQRCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, code, body := resolveCurrency(merchant.SettlementCurrency, req.Amount)
+	if currency == "" {
+		c.JSON(code, body)
+		return
+	}
+
+	cfg := config.Get()
+	ttl, ok := expiryTTL(req.ExpiresIn, cfg.QRCodeDefaultTTL, cfg.QRCodeMaxTTL)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "QR code expiry is too long"})
+		return
+	}
+
+	qrCode := &models.QRCode{
+		MerchantID:  merchant.ID,
+		Amount:      req.Amount,
+		Description: req.Description,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+
+	codeRepo := repositories.NewQRCodeRepository(config.DB)
+	if err := codeRepo.Create(qrCode); err != nil {
+		log.Printf("Create QR code error: %v", err)
+		code, body := qrCodeErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	result, err := qrCodeResponse(merchant, qrCode)
+	if err != nil {
+		log.Printf("Encode QR code error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode QR code"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "SUCCESS",
+		"result": result,
+	})
+}
+
+// GetQRCode returns one of the caller's merchants' dynamic QR codes
+func GetQRCode(c *gin.Context) {
+	merchant, qrCode, ok := ownedQRCode(c)
+	if !ok {
+		return
+	}
+
+	result, err := qrCodeResponse(merchant, qrCode)
+	if err != nil {
+		log.Printf("Encode QR code error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode QR code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": result,
+	})
+}
+
+// GetQRCodeImage renders a dynamic QR code as a PNG of ?size= pixels
+func GetQRCodeImage(c *gin.Context) {
+	merchant, qrCode, ok := ownedQRCode(c)
+	if !ok {
+		return
+	}
+	respondQRImage(c, merchant, qrCode)
+}
+
+// GetStaticQRCode returns the static QR code of one of the caller's
+// merchants, which payers pay with an amount they enter
+func GetStaticQRCode(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+
+	result, err := qrCodeResponse(merchant, nil)
+	if err != nil {
+		log.Printf("Encode QR code error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode QR code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": result,
+	})
+}
+
+// GetStaticQRCodeImage renders a merchant's static QR code as a PNG of
+// ?size= pixels
+func GetStaticQRCodeImage(c *gin.Context) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return
+	}
+	respondQRImage(c, merchant, nil)
+}
+
+// ScanQRCode decodes a scanned QR payload and checks it against the merchant,
+// so the payer can confirm the payment
+func ScanQRCode(c *gin.Context) {
+	var req ScanQRRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scanned, code, body := resolveQRPayload(req.Payload)
+	if scanned == nil {
+		c.JSON(code, body)
+		return
+	}
+
+	result := gin.H{
+		"type": "STATIC",
+		"merchant": gin.H{
+			"merchant_id":   scanned.merchant.ID,
+			"name":          scanned.merchant.Name,
+			"category_code": scanned.merchant.CategoryCode,
+			"city":          scanned.merchant.City,
+		},
+		"currency": scanned.merchant.SettlementCurrency,
+	}
+	if scanned.code != nil {
+		result["type"] = "DYNAMIC"
+		result["reference"] = scanned.code.Reference
+		result["amount"] = scanned.code.Amount
+		result["remarks"] = scanned.code.Description
+		result["status"] = scanned.code.CurrentStatus(time.Now())
+		result["expires_at"] = scanned.code.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": result,
+	})
+}
+
+// PayQRCode pays the merchant of a scanned QR payload. Dynamic codes are paid
+// for their amount and only once; static codes for the amount the payer
+// enters.
+func PayQRCode(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req QRPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scanned, code, body := resolveQRPayload(req.Payload)
+	if scanned == nil {
+		c.JSON(code, body)
+		return
+	}
+	merchant := scanned.merchant
+
+	if scanned.code != nil && req.Amount != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dynamic QR codes have a fixed amount"})
+		return
+	}
+	if scanned.code == nil {
+		if req.Amount == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount is required for static QR codes"})
+			return
+		}
+		if currency, code, body := resolveCurrency(merchant.SettlementCurrency, req.Amount); currency == "" {
+			c.JSON(code, body)
+			return
+		}
+	}
+
+	remarks := req.Description
+	if remarks == "" && scanned.code != nil {
+		remarks = scanned.code.Description
+	}
+	if remarks == "" {
+		remarks = "QR payment to " + merchant.Name
+	}
+
+	respondIdempotent(c, userID, req, func(db *gorm.DB) (int, gin.H) {
+		var transaction *models.Transaction
+		var balanceBefore, balanceAfter models.Money
+		var err error
+		if scanned.code != nil {
+			codeRepo := repositories.NewQRCodeRepository(db)
+			_, transaction, balanceBefore, balanceAfter, err = codeRepo.Pay(scanned.code.ID, userID, remarks, time.Now())
+		} else {
+			transactionRepo := repositories.NewTransactionRepository(db)
+			transaction, balanceBefore, balanceAfter, err = transactionRepo.PayMerchant(userID, merchant.ID, merchant.SettlementCurrency, req.Amount, remarks)
+		}
+		if err != nil {
+			log.Printf("QR payment error: %v", err)
+			return qrPaymentErrorResponse(err, merchant)
+		}
+
+		result := gin.H{
+			"payment_id":     transaction.ID,
+			"amount":         transaction.Amount,
+			"currency":       transaction.Currency,
+			"balance_before": balanceBefore,
+			"balance_after":  balanceAfter,
+			"fee":            transaction.FeeBreakdown,
+			"remark":         transaction.Description,
+			"created_at":     transaction.CreatedAt.Format("2006-01-02 15:04:05"),
+			"merchant": gin.H{
+				"merchant_id":   merchant.ID,
+				"name":          merchant.Name,
+				"category_code": merchant.CategoryCode,
+			},
+		}
+		if scanned.code != nil {
+			result["reference"] = scanned.code.Reference
+		}
+
+		return http.StatusOK, gin.H{
+			"status": "SUCCESS",
+			"result": result,
+		}
+	})
+}
+
+// resolveQRPayload decodes a scanned payload and checks it against the
+// merchant and, for dynamic codes, the issued code, so a payload that was
+// altered after it was issued is rejected.
+func resolveQRPayload(raw string) (*scannedQR, int, gin.H) {
+	payload, err := qr.Parse(raw)
+	if err != nil {
+		code, body := qrCodeErrorResponse(err)
+		return nil, code, body
+	}
+
+	merchantID, err := uuid.Parse(payload.MerchantID)
+	if err != nil {
+		return nil, http.StatusBadRequest, gin.H{"error": "Invalid merchant ID in QR code"}
+	}
+	merchantRepo := repositories.NewMerchantRepository(config.DB)
+	merchant, err := merchantRepo.FindByID(merchantID)
+	if err != nil {
+		return nil, http.StatusNotFound, gin.H{"error": "Merchant not found"}
+	}
+	if merchant.Status != models.MerchantActive {
+		return nil, http.StatusUnprocessableEntity, gin.H{"error": "Merchant is not accepting payments"}
+	}
+
+	currency, err := models.LookupNumericCurrency(payload.Currency)
+	if err != nil || currency.Code != merchant.SettlementCurrency {
+		return nil, http.StatusBadRequest, gin.H{"error": "QR code does not match the merchant"}
+	}
+
+	scanned := &scannedQR{payload: payload, merchant: merchant}
+	if !payload.Dynamic {
+		if payload.Amount != "" {
+			return nil, http.StatusBadRequest, gin.H{"error": "Static QR codes must not carry an amount"}
+		}
+		return scanned, 0, nil
+	}
+
+	codeRepo := repositories.NewQRCodeRepository(config.DB)
+	qrCode, err := codeRepo.FindByReference(payload.Reference)
+	if err != nil {
+		code, body := qrCodeErrorResponse(err)
+		return nil, code, body
+	}
+	amount, err := models.ParseMoney(payload.Amount)
+	if err != nil || qrCode.MerchantID != merchant.ID || amount != qrCode.Amount || payload.ExpiresAt.Unix() != qrCode.ExpiresAt.Unix() {
+		return nil, http.StatusBadRequest, gin.H{"error": "QR code does not match the issued code"}
+	}
+	scanned.code = qrCode
+	return scanned, 0, nil
+}
+
+// ownedQRCode loads the dynamic QR code named by the :qr_id parameter of the
+// caller's merchant, writing the error response when it can't.
+func ownedQRCode(c *gin.Context) (*models.Merchant, *models.QRCode, bool) {
+	merchant, ok := ownedMerchant(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	codeID, err := uuid.Parse(c.Param("qr_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid QR code ID"})
+		return nil, nil, false
+	}
+
+	codeRepo := repositories.NewQRCodeRepository(config.DB)
+	qrCode, err := codeRepo.Find(codeID, merchant.ID)
+	if err != nil {
+		code, body := qrCodeErrorResponse(err)
+		c.JSON(code, body)
+		return nil, nil, false
+	}
+	return merchant, qrCode, true
+}
+
+// merchantQRPayload encodes the static QR code of a merchant, or a dynamic
+// code when qrCode is set.
+func merchantQRPayload(merchant *models.Merchant, qrCode *models.QRCode) (string, error) {
+	currency, err := models.LookupCurrency(merchant.SettlementCurrency)
+	if err != nil {
+		return "", err
+	}
+
+	city := merchant.City
+	if city == "" {
+		city = config.Get().QRDefaultCity
+	}
+	payload := &qr.Payload{
+		MerchantID:   merchant.ID.String(),
+		CategoryCode: merchant.CategoryCode,
+		Currency:     currency.NumericCode,
+		CountryCode:  config.Get().QRCountryCode,
+		MerchantName: merchant.Name,
+		MerchantCity: city,
+	}
+	if qrCode != nil {
+		payload.Dynamic = true
+		payload.Amount = currency.Format(qrCode.Amount)
+		payload.Reference = qrCode.Reference
+		payload.ExpiresAt = qrCode.ExpiresAt
+	}
+	return payload.Encode()
+}
+
+func respondQRImage(c *gin.Context, merchant *models.Merchant, qrCode *models.QRCode) {
+	size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(qr.DefaultImageSize)))
+	if err != nil || size < qr.MinImageSize || size > qr.MaxImageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between " + strconv.Itoa(qr.MinImageSize) + " and " + strconv.Itoa(qr.MaxImageSize) + " pixels"})
+		return
+	}
+
+	payload, err := merchantQRPayload(merchant, qrCode)
+	if err != nil {
+		log.Printf("Encode QR code error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode QR code"})
+		return
+	}
+
+	image, err := qr.PNG(payload, size)
+	if err != nil {
+		log.Printf("Render QR code error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", image)
+}
+
+func qrCodeResponse(merchant *models.Merchant, qrCode *models.QRCode) (gin.H, error) {
+	payload, err := merchantQRPayload(merchant, qrCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if qrCode == nil {
+		return gin.H{
+			"type":        "STATIC",
+			"merchant_id": merchant.ID,
+			"currency":    merchant.SettlementCurrency,
+			"payload":     payload,
+		}, nil
+	}
+
+	var paidAt interface{}
+	if qrCode.PaidAt != nil {
+		paidAt = qrCode.PaidAt.Format("2006-01-02 15:04:05")
+	}
+	return gin.H{
+		"type":           "DYNAMIC",
+		"qr_code_id":     qrCode.ID,
+		"merchant_id":    merchant.ID,
+		"reference":      qrCode.Reference,
+		"amount":         qrCode.Amount,
+		"currency":       qrCode.Currency,
+		"remarks":        qrCode.Description,
+		"status":         qrCode.CurrentStatus(time.Now()),
+		"payer_id":       qrCode.PayerID,
+		"transaction_id": qrCode.TransactionID,
+		"payload":        payload,
+		"expires_at":     qrCode.ExpiresAt.Format("2006-01-02 15:04:05"),
+		"paid_at":        paidAt,
+		"created_date":   qrCode.CreatedAt.Format("2006-01-02 15:04:05"),
+	}, nil
+}
+
+func qrCodeErrorResponse(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "QR code not found"}
+	case err == qr.ErrChecksumMismatch:
+		return http.StatusBadRequest, gin.H{"error": "QR code checksum does not match"}
+	case err == qr.ErrMalformedPayload, err == qr.ErrMissingField:
+		return http.StatusBadRequest, gin.H{"error": "Invalid QR code"}
+	case err == qr.ErrUnsupportedPayload:
+		return http.StatusUnprocessableEntity, gin.H{"error": "QR code is not supported"}
+	case err == models.ErrMerchantNotActive:
+		return http.StatusUnprocessableEntity, gin.H{"error": "Merchant is not accepting payments"}
+	case err == models.ErrQRCodeNotPending:
+		return http.StatusConflict, gin.H{"error": "QR code has already been paid"}
+	case err == models.ErrQRCodeExpired:
+		return http.StatusConflict, gin.H{"error": "QR code has expired"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process QR code"}
+	}
+}
+
+func qrPaymentErrorResponse(err error, merchant *models.Merchant) (int, gin.H) {
+	switch err {
+	case models.ErrQRCodeNotPending, models.ErrQRCodeExpired:
+		return qrCodeErrorResponse(err)
+	}
+	return paymentErrorResponse(err, merchant)
+}
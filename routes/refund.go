@@ -0,0 +1,165 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RefundRequest struct {
+	// Amount is optional; when omitted the whole remaining amount is refunded
+	Amount      models.Money `json:"amount" binding:"omitempty,gt=0"`
+	Description string       `json:"remarks,omitempty"`
+}
+
+type ReversalRequest struct {
+	Description string `json:"remarks,omitempty"`
+}
+
+// RefundTransaction refunds a PAYMENT or TRANSFER in full or in part. Transfers
+// and merchant payments can be refunded by their recipient; other payments
+// settle to the platform and can only be refunded by an admin. Requests signed
+// with a merchant API key can only refund that merchant's payments.
+func RefundTransaction(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	// The body is optional; an empty body requests a full refund
+	var req RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transactionRepo := repositories.NewTransactionRepository(config.DB)
+	original, err := transactionRepo.FindRefundable(transactionID)
+	if err != nil {
+		respondRefundError(c, err)
+		return
+	}
+
+	if merchantID, ok := c.Get(middleware.MerchantIDKey); ok {
+		if original.MerchantID == nil || *original.MerchantID != merchantID.(uuid.UUID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+	}
+
+	userRepo := repositories.NewUserRepository(config.DB)
+	user, err := userRepo.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	isRecipient := original.RecipientID != nil && *original.RecipientID == userID
+	if !isRecipient && !user.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the recipient of this transaction can refund it"})
+		return
+	}
+
+	respondIdempotent(c, userID, req, func(db *gorm.DB) (int, gin.H) {
+		transactionRepo := repositories.NewTransactionRepository(db)
+		original, refund, err := transactionRepo.Refund(original.ID, req.Amount, req.Description)
+		if err != nil {
+			log.Printf("Refund error: %v", err)
+			return refundErrorResponse(err)
+		}
+		return http.StatusOK, refundResponse(original, refund)
+	})
+}
+
+// ReverseTransaction lets an admin undo the unrefunded remainder of a PAYMENT
+// or TRANSFER.
+func ReverseTransaction(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	var req ReversalRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transactionRepo := repositories.NewTransactionRepository(config.DB)
+	original, err := transactionRepo.FindRefundable(transactionID)
+	if err != nil {
+		respondRefundError(c, err)
+		return
+	}
+
+	respondIdempotent(c, userID, req, func(db *gorm.DB) (int, gin.H) {
+		transactionRepo := repositories.NewTransactionRepository(db)
+		original, reversal, err := transactionRepo.Reverse(original.ID, req.Description)
+		if err != nil {
+			log.Printf("Reversal error: %v", err)
+			return refundErrorResponse(err)
+		}
+		return http.StatusOK, refundResponse(original, reversal)
+	})
+}
+
+func refundResponse(original *models.Transaction, refund *models.Transaction) gin.H {
+	return gin.H{
+		"status": "SUCCESS",
+		"result": gin.H{
+			"refund_id":               refund.ID,
+			"type":                    refund.TransactionType,
+			"original_transaction_id": original.ID,
+			"amount":                  refund.Amount,
+			"currency":                refund.Currency,
+			"refunded_amount":         original.RefundedAmount,
+			"refundable_amount":       original.RefundableAmount(),
+			"original_status":         original.Status,
+			"remarks":                 refund.Description,
+			"created_date":            refund.CreatedAt.Format("2006-01-02 15:04:05"),
+		},
+	}
+}
+
+func respondRefundError(c *gin.Context, err error) {
+	code, body := refundErrorResponse(err)
+	c.JSON(code, body)
+}
+
+func refundErrorResponse(err error) (int, gin.H) {
+	if code, body, ok := limitExceededResponse(err); ok {
+		return code, body
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "Transaction not found"}
+	case err == models.ErrTransactionNotRefundable:
+		return http.StatusBadRequest, gin.H{"error": "Transaction cannot be refunded"}
+	case err == models.ErrRefundExceedsAmount:
+		return http.StatusBadRequest, gin.H{"error": "Refund amount exceeds refundable amount"}
+	case err == models.ErrCurrencyPrecision:
+		return http.StatusBadRequest, gin.H{"error": "Amount has more decimal places than the currency allows"}
+	case err == models.ErrInvalidTransaction:
+		return http.StatusBadRequest, gin.H{"error": "Balance is not enough"}
+	case err == models.ErrAmountOutOfRange:
+		return http.StatusBadRequest, gin.H{"error": "Balance limit exceeded"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process refund"}
+	}
+}
@@ -1,33 +1,168 @@
 package routes
 
 import (
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/fx"
 	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/storage"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine) {
+func SetupRoutes(router *gin.Engine, limits middleware.RateLimitStore, nonces middleware.NonceStore, blobs storage.BlobStore, rates fx.RateProvider) {
+	cfg := config.Get()
+
+	// Rate limiters: a per-IP bucket in front of everything, including
+	// authentication, a per-user bucket once the caller is known, and
+	// stricter ones for logins and money movement
+	perIP := middleware.IPRateLimitMiddleware(limits, "api", middleware.RateLimit{Limit: cfg.IPRateLimit, Period: cfg.IPRateLimitDuration.Duration()})
+	perUser := middleware.UserRateLimitMiddleware(limits, "api", middleware.RateLimit{Limit: cfg.RateLimit, Period: cfg.RateLimitDuration.Duration()})
+	login := middleware.IPRateLimitMiddleware(limits, "login", middleware.RateLimit{Limit: cfg.LoginRateLimit, Period: cfg.LoginRateLimitDuration.Duration()})
+	money := middleware.UserRateLimitMiddleware(limits, "money", middleware.RateLimit{Limit: cfg.MoneyRateLimit, Period: cfg.MoneyRateLimitDuration.Duration()})
+
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", JWKS)
+
 	// API v1 group
 	v1 := router.Group("/api/v1")
 	{
 		// Auth routes (no authentication required)
-		v1.POST("/auth/register", Register)
-		v1.POST("/auth/login", Login)
-		v1.POST("/auth/refresh-token", RefreshToken)
+		v1.POST("/auth/register", perIP, Register)
+		v1.POST("/auth/login", perIP, login, Login)
+		v1.POST("/auth/refresh-token", perIP, RefreshToken)
 
 		// Protected routes (authentication required)
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(perIP, middleware.AuthMiddleware(), perUser)
 		{
+			// Session routes
+			protected.POST("/auth/logout", Logout)
+			protected.POST("/auth/logout-all", LogoutAll)
+
 			// User routes
 			protected.GET("/user/profile", GetProfile)
 			protected.PUT("/user/profile", UpdateProfile)
 			protected.GET("/user/balance", GetBalance)
+			protected.GET("/user/limits", GetLimits)
+
+			// Wallet routes
+			protected.GET("/currencies", GetCurrencies)
+			protected.GET("/wallets", GetWallets)
+			protected.POST("/wallets", OpenWallet)
+
+			// Currency conversion routes
+			protected.GET("/fx/rates", GetFXRate(rates))
+			protected.POST("/fx/quotes", CreateFXQuote(rates))
+			protected.GET("/fx/quotes/:id", GetFXQuote)
+			protected.POST("/fx/conversions", money, ConvertCurrency)
+
+			// KYC routes
+			protected.GET("/kyc", GetKYCStatus)
+			protected.POST("/kyc/submissions", SubmitKYC(blobs))
 
 			// Transaction routes
 			protected.GET("/transactions", GetTransactionHistory)
-			protected.POST("/transactions/topup", TopUp)
-			protected.POST("/transactions/transfer", Transfer)
-			protected.POST("/transactions/payment", Payment)
+			protected.POST("/transactions/topup", money, TopUp)
+			protected.POST("/transactions/transfer", money, Transfer(rates))
+			protected.POST("/transactions/transfer/preview", PreviewTransfer)
+			protected.POST("/transactions/payment", money, Payment)
+			protected.POST("/transactions/:id/refund", money, RefundTransaction)
+
+			// Authorization hold routes
+			protected.GET("/holds", GetHolds)
+			protected.POST("/holds", money, CreateHold)
+			protected.POST("/holds/:id/capture", money, CaptureHold)
+			protected.POST("/holds/:id/void", VoidHold)
+
+			// Standing order routes
+			protected.GET("/standing-orders", GetStandingOrders)
+			protected.POST("/standing-orders", CreateStandingOrder)
+			protected.GET("/standing-orders/:id", GetStandingOrder)
+			protected.POST("/standing-orders/:id/pause", PauseStandingOrder)
+			protected.POST("/standing-orders/:id/resume", ResumeStandingOrder)
+			protected.POST("/standing-orders/:id/cancel", CancelStandingOrder)
+
+			// Payment request routes
+			protected.GET("/payment-requests", GetPaymentRequests)
+			protected.POST("/payment-requests", CreatePaymentRequest)
+			protected.GET("/payment-requests/:id", GetPaymentRequest)
+			protected.POST("/payment-requests/:id/accept", money, AcceptPaymentRequest)
+			protected.POST("/payment-requests/:id/decline", DeclinePaymentRequest)
+
+			// Split bill routes
+			protected.GET("/split-bills", GetSplitBills)
+			protected.POST("/split-bills", CreateSplitBill)
+			protected.GET("/split-bills/:id", GetSplitBill)
+
+			// Merchant routes
+			protected.GET("/merchants", GetMerchants)
+			protected.POST("/merchants", RegisterMerchant)
+			protected.GET("/merchants/:id", GetMerchant)
+			protected.GET("/merchants/:id/payments", GetMerchantPayments)
+			protected.GET("/merchants/:id/daily-totals", GetMerchantDailyTotals)
+
+			// Merchant QR code routes
+			protected.POST("/merchants/:id/qr-codes", CreateQRCode)
+			protected.GET("/merchants/:id/qr-codes/static", GetStaticQRCode)
+			protected.GET("/merchants/:id/qr-codes/static/image", GetStaticQRCodeImage)
+			protected.GET("/merchants/:id/qr-codes/:qr_id", GetQRCode)
+			protected.GET("/merchants/:id/qr-codes/:qr_id/image", GetQRCodeImage)
+
+			// QR payment routes
+			protected.POST("/qr-payments/scan", ScanQRCode)
+			protected.POST("/qr-payments", money, PayQRCode)
+
+			// Merchant checkout session routes
+			protected.GET("/merchants/:id/checkout-sessions", GetCheckoutSessions)
+			protected.POST("/merchants/:id/checkout-sessions", CreateCheckoutSession)
+			protected.GET("/merchants/:id/checkout-sessions/:session_id", GetMerchantCheckoutSession)
+			protected.POST("/merchants/:id/checkout-sessions/:session_id/cancel", CancelCheckoutSession)
+
+			// Checkout routes
+			protected.GET("/checkout-sessions/:id", GetCheckoutSession)
+			protected.POST("/checkout-sessions/:id/pay", money, PayCheckoutSession)
+
+			// Merchant API key routes
+			protected.GET("/merchants/:id/api-keys", GetMerchantAPIKeys)
+			protected.POST("/merchants/:id/api-keys", CreateMerchantAPIKey)
+			protected.POST("/merchants/:id/api-keys/:key_id/rotate", RotateMerchantAPIKey)
+			protected.POST("/merchants/:id/api-keys/:key_id/revoke", RevokeMerchantAPIKey)
+			protected.GET("/merchants/:id/api-keys/:key_id/usage", GetMerchantAPIKeyUsage)
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminMiddleware())
+			{
+				admin.POST("/transactions/:id/reverse", money, ReverseTransaction)
+
+				admin.GET("/ledger/mismatches", ListLedgerMismatches)
+				admin.GET("/users/:id/wallets/:currency/ledger", VerifyWalletLedger)
+
+				admin.GET("/kyc/submissions", ListKYCSubmissions)
+				admin.GET("/kyc/submissions/:id/documents/:kind", GetKYCDocument(blobs))
+				admin.POST("/kyc/submissions/:id/approve", ApproveKYC)
+				admin.POST("/kyc/submissions/:id/reject", RejectKYC)
+			}
+		}
+
+		// Merchant API routes (signed with a merchant API key instead of an
+		// access token)
+		merchantAPI := v1.Group("/merchant-api")
+		merchantAPI.Use(perIP, middleware.MerchantAuthMiddleware(nonces, cfg.MerchantAPIKeySecret, cfg.MerchantAPISignatureWindow), perUser)
+		{
+			merchantAPI.GET("/merchant", GetMerchant)
+			merchantAPI.GET("/payments", middleware.RequireScope(models.ScopePaymentsRead), GetMerchantPayments)
+			merchantAPI.GET("/daily-totals", middleware.RequireScope(models.ScopePaymentsRead), GetMerchantDailyTotals)
+			merchantAPI.POST("/transactions/:id/refund", middleware.RequireScope(models.ScopeRefundsWrite), money, RefundTransaction)
+			merchantAPI.POST("/qr-codes", middleware.RequireScope(models.ScopeQRWrite), CreateQRCode)
+			merchantAPI.GET("/qr-codes/static", middleware.RequireScope(models.ScopePaymentsRead), GetStaticQRCode)
+			merchantAPI.GET("/qr-codes/static/image", middleware.RequireScope(models.ScopePaymentsRead), GetStaticQRCodeImage)
+			merchantAPI.GET("/qr-codes/:qr_id", middleware.RequireScope(models.ScopePaymentsRead), GetQRCode)
+			merchantAPI.GET("/qr-codes/:qr_id/image", middleware.RequireScope(models.ScopePaymentsRead), GetQRCodeImage)
+			merchantAPI.GET("/checkout-sessions", middleware.RequireScope(models.ScopePaymentsRead), GetCheckoutSessions)
+			merchantAPI.POST("/checkout-sessions", middleware.RequireScope(models.ScopeCheckoutWrite), CreateCheckoutSession)
+			merchantAPI.GET("/checkout-sessions/:session_id", middleware.RequireScope(models.ScopePaymentsRead), GetMerchantCheckoutSession)
+			merchantAPI.POST("/checkout-sessions/:session_id/cancel", middleware.RequireScope(models.ScopeCheckoutWrite), CancelCheckoutSession)
 		}
 	}
 }
@@ -0,0 +1,328 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SplitParticipantRequest struct {
+	// Self marks the owner's own share, which is not requested from anyone
+	Self        bool         `json:"self,omitempty"`
+	RecipientID string       `json:"target_user,omitempty"`
+	PhoneNumber string       `json:"phone_number,omitempty"`
+	Shares      int64        `json:"shares" binding:"omitempty,gt=0,lte=1000"`
+	Amount      models.Money `json:"amount" binding:"omitempty,gt=0"`
+}
+
+type CreateSplitBillRequest struct {
+	// TransactionID is a PAYMENT of the caller to split; otherwise Amount is
+	// split
+	TransactionID string                    `json:"transaction_id,omitempty"`
+	Amount        models.Money              `json:"amount" binding:"omitempty,gt=0"`
+	Currency      string                    `json:"currency,omitempty"`
+	Description   string                    `json:"remarks,omitempty"`
+	Method        string                    `json:"method" binding:"required,oneof=EQUAL SHARES EXACT"`
+	Participants  []SplitParticipantRequest `json:"participants" binding:"required,min=1,max=50,dive"`
+	// ExpiresIn is the lifetime of the participants' payment requests in
+	// seconds; defaults to PAYMENT_REQUEST_DEFAULT_TTL
+	ExpiresIn int `json:"expires_in" binding:"omitempty,gt=0"`
+}
+
+// CreateSplitBill divides a payment or an amount between the caller and
+// other users, sending each of them a payment request for their share
+func CreateSplitBill(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req CreateSplitBillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if (req.TransactionID == "") == (req.Amount == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either transaction_id or amount is required"})
+		return
+	}
+
+	bill := &models.SplitBill{
+		OwnerID:     userID,
+		Method:      req.Method,
+		Description: req.Description,
+	}
+	splitRepo := repositories.NewSplitBillRepository(config.DB)
+
+	if req.TransactionID != "" {
+		transactionID, err := uuid.Parse(req.TransactionID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+			return
+		}
+		payment, err := splitRepo.FindSplittablePayment(userID, transactionID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		if err != nil {
+			code, body := splitBillErrorResponse(err)
+			c.JSON(code, body)
+			return
+		}
+		if req.Currency != "" && req.Currency != payment.Currency {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Currency does not match the payment"})
+			return
+		}
+		bill.TransactionID = &payment.ID
+		bill.Currency = payment.Currency
+		bill.TotalAmount = payment.RefundableAmount()
+		if bill.Description == "" {
+			bill.Description = payment.Description
+		}
+	} else {
+		currency, code, body := resolveCurrency(req.Currency, req.Amount)
+		if currency == "" {
+			c.JSON(code, body)
+			return
+		}
+		bill.Currency = currency
+		bill.TotalAmount = req.Amount
+	}
+
+	cfg := config.Get()
+	ttl, ok := expiryTTL(req.ExpiresIn, cfg.PaymentRequestDefaultTTL, cfg.PaymentRequestMaxTTL)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment request expiry is too long"})
+		return
+	}
+
+	amounts, code, body := splitShares(bill, req)
+	if amounts == nil {
+		c.JSON(code, body)
+		return
+	}
+
+	// Resolve the other participants and take the owner's share out
+	var participants []repositories.SplitParticipant
+	seen := map[uuid.UUID]bool{userID: true}
+	selfListed := false
+	for i, entry := range req.Participants {
+		if entry.Self {
+			if selfListed {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Each participant can only be listed once"})
+				return
+			}
+			selfListed = true
+			bill.OwnerAmount = amounts[i]
+			continue
+		}
+
+		participant, code, body := resolveRecipient(userID, TransferRequest{
+			RecipientID: entry.RecipientID,
+			PhoneNumber: entry.PhoneNumber,
+		})
+		if participant == nil {
+			c.JSON(code, body)
+			return
+		}
+		if seen[participant.ID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each participant can only be listed once"})
+			return
+		}
+		seen[participant.ID] = true
+		participants = append(participants, repositories.SplitParticipant{UserID: participant.ID, Amount: amounts[i]})
+	}
+	if len(participants) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one other participant is required"})
+		return
+	}
+
+	if err := splitRepo.Create(bill, participants, ttl); err != nil {
+		log.Printf("Create split bill error: %v", err)
+		code, body := splitBillErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "SUCCESS",
+		"result": splitBillResponse(bill),
+	})
+}
+
+// splitShares works out every participant's share of the bill's total, in
+// the order they were listed.
+func splitShares(bill *models.SplitBill, req CreateSplitBillRequest) ([]models.Money, int, gin.H) {
+	currency, err := models.LookupCurrency(bill.Currency)
+	if err != nil {
+		return nil, http.StatusBadRequest, gin.H{"error": "Unsupported currency"}
+	}
+
+	if req.Method == models.SplitExact {
+		amounts := make([]models.Money, len(req.Participants))
+		var sum models.Money
+		for i, entry := range req.Participants {
+			if entry.Amount == 0 {
+				return nil, http.StatusBadRequest, gin.H{"error": "amount is required for every participant"}
+			}
+			if currency.ValidateAmount(entry.Amount) != nil {
+				return nil, http.StatusBadRequest, gin.H{"error": "Amount has more decimal places than " + currency.Code + " allows"}
+			}
+			amounts[i] = entry.Amount
+			sum += entry.Amount
+		}
+		if sum != bill.TotalAmount {
+			return nil, http.StatusBadRequest, gin.H{"error": "Amounts must add up to " + bill.TotalAmount.String()}
+		}
+		return amounts, 0, nil
+	}
+
+	weights := make([]int64, len(req.Participants))
+	for i, entry := range req.Participants {
+		weights[i] = 1
+		if req.Method == models.SplitShares {
+			if entry.Shares == 0 {
+				return nil, http.StatusBadRequest, gin.H{"error": "shares is required for every participant"}
+			}
+			weights[i] = entry.Shares
+		}
+	}
+
+	amounts, err := models.SplitAmount(currency, bill.TotalAmount, weights)
+	if err != nil {
+		code, body := splitBillErrorResponse(err)
+		return nil, code, body
+	}
+	return amounts, 0, nil
+}
+
+// GetSplitBills lists the caller's split bills, optionally filtered by
+// ?status=
+func GetSplitBills(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	status := c.Query("status")
+	switch status {
+	case "", models.SplitBillOpen, models.SplitBillCollected, models.SplitBillPartiallyCollected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid split bill status"})
+		return
+	}
+
+	splitRepo := repositories.NewSplitBillRepository(config.DB)
+	bills, err := splitRepo.GetUserBills(userID, status, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch split bills"})
+		return
+	}
+
+	billResponses := []gin.H{}
+	for i := range bills {
+		billResponses = append(billResponses, splitBillResponse(&bills[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": billResponses,
+	})
+}
+
+// GetSplitBill returns one of the caller's split bills with every
+// participant's progress
+func GetSplitBill(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	billID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid split bill ID"})
+		return
+	}
+
+	splitRepo := repositories.NewSplitBillRepository(config.DB)
+	bill, err := splitRepo.Find(billID, userID)
+	if err != nil {
+		code, body := splitBillErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": splitBillResponse(bill),
+	})
+}
+
+func splitBillResponse(bill *models.SplitBill) gin.H {
+	now := time.Now()
+
+	participants := []gin.H{}
+	for _, request := range bill.Requests {
+		participants = append(participants, gin.H{
+			"payment_request_id": request.ID,
+			"user_id":            request.PayerID,
+			"amount":             request.Amount,
+			"status":             request.CurrentStatus(now),
+			"transaction_id":     request.TransactionID,
+			"expires_at":         request.ExpiresAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	uncollected := []gin.H{}
+	var uncollectedAmount models.Money
+	for _, request := range bill.UncollectedRequests(now) {
+		uncollected = append(uncollected, gin.H{
+			"payment_request_id": request.ID,
+			"user_id":            request.PayerID,
+			"amount":             request.Amount,
+			"status":             request.CurrentStatus(now),
+		})
+		uncollectedAmount += request.Amount
+	}
+
+	return gin.H{
+		"split_bill_id":      bill.ID,
+		"transaction_id":     bill.TransactionID,
+		"method":             bill.Method,
+		"currency":           bill.Currency,
+		"total_amount":       bill.TotalAmount,
+		"owner_amount":       bill.OwnerAmount,
+		"amount_to_collect":  bill.AmountToCollect(),
+		"collected_amount":   bill.CollectedAmount,
+		"remaining_amount":   bill.AmountToCollect() - bill.CollectedAmount,
+		"remarks":            bill.Description,
+		"status":             bill.CurrentStatus(now),
+		"participants":       participants,
+		"uncollected_amount": uncollectedAmount,
+		"uncollected_shares": uncollected,
+		"created_date":       bill.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func splitBillErrorResponse(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "Split bill not found"}
+	case err == models.ErrTransactionNotSplittable:
+		return http.StatusBadRequest, gin.H{"error": "Only your own payments that have not been fully refunded can be split"}
+	case err == models.ErrTransactionAlreadySplit:
+		return http.StatusConflict, gin.H{"error": "Payment has already been split"}
+	case err == models.ErrShareTooSmall:
+		return http.StatusBadRequest, gin.H{"error": "Amount is too small to split between this many participants"}
+	case err == models.ErrInvalidSplit:
+		return http.StatusBadRequest, gin.H{"error": "Shares must add up to the total"}
+	case err == models.ErrCurrencyPrecision:
+		return http.StatusBadRequest, gin.H{"error": "Amount has more decimal places than the currency allows"}
+	case err == models.ErrWalletNotFound:
+		return http.StatusNotFound, gin.H{"error": "Wallet not found"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process split bill"}
+	}
+}
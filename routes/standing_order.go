@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreateStandingOrderRequest struct {
+	TransactionType string       `json:"type" binding:"required,oneof=TRANSFER PAYMENT"`
+	Amount          models.Money `json:"amount" binding:"required,gt=0"`
+	Currency        string       `json:"currency,omitempty"`
+	RecipientID     string       `json:"target_user,omitempty"`
+	PhoneNumber     string       `json:"phone_number,omitempty"`
+	Description     string       `json:"remarks,omitempty"`
+	Schedule        string       `json:"schedule" binding:"required,oneof=ONCE DAILY WEEKLY MONTHLY CRON"`
+	CronExpression  string       `json:"cron,omitempty"`
+	// StartAt is the first run (RFC 3339); defaults to now. For CRON
+	// schedules it is when matching begins.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	EndAt   *time.Time `json:"end_at,omitempty"`
+}
+
+// CreateStandingOrder schedules a one-off or recurring transfer or payment
+func CreateStandingOrder(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req CreateStandingOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, code, body := resolveCurrency(req.Currency, req.Amount)
+	if currency == "" {
+		c.JSON(code, body)
+		return
+	}
+
+	order := &models.StandingOrder{
+		UserID:          userID,
+		TransactionType: req.TransactionType,
+		Currency:        currency,
+		Amount:          req.Amount,
+		Description:     req.Description,
+		Schedule:        req.Schedule,
+		CronExpression:  req.CronExpression,
+		StartAt:         time.Now(),
+		EndAt:           req.EndAt,
+	}
+
+	switch req.TransactionType {
+	case models.TRANSFER:
+		recipient, code, body := resolveRecipient(userID, TransferRequest{
+			RecipientID: req.RecipientID,
+			PhoneNumber: req.PhoneNumber,
+		})
+		if recipient == nil {
+			c.JSON(code, body)
+			return
+		}
+		order.RecipientID = &recipient.ID
+	case models.PAYMENT:
+		if req.RecipientID != "" || req.PhoneNumber != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Payments do not take a recipient"})
+			return
+		}
+		if req.Description == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Remarks are required for payments"})
+			return
+		}
+	}
+
+	if req.StartAt != nil {
+		if req.StartAt.Before(time.Now().Add(-time.Minute)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_at must not be in the past"})
+			return
+		}
+		order.StartAt = *req.StartAt
+	}
+
+	orderRepo := repositories.NewStandingOrderRepository(config.DB)
+	if err := orderRepo.Create(order); err != nil {
+		log.Printf("Create standing order error: %v", err)
+		code, body := standingOrderErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "SUCCESS",
+		"result": standingOrderResponse(order),
+	})
+}
+
+// GetStandingOrders lists the user's standing orders, optionally filtered by
+// ?status=
+func GetStandingOrders(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	status := c.Query("status")
+	switch status {
+	case "", models.StandingOrderActive, models.StandingOrderPaused, models.StandingOrderCancelled,
+		models.StandingOrderCompleted, models.StandingOrderFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid standing order status"})
+		return
+	}
+
+	orderRepo := repositories.NewStandingOrderRepository(config.DB)
+	orders, err := orderRepo.GetUserOrders(userID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch standing orders"})
+		return
+	}
+
+	orderResponses := []gin.H{}
+	for i := range orders {
+		orderResponses = append(orderResponses, standingOrderResponse(&orders[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": orderResponses,
+	})
+}
+
+// GetStandingOrder returns one of the user's standing orders
+func GetStandingOrder(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid standing order ID"})
+		return
+	}
+
+	orderRepo := repositories.NewStandingOrderRepository(config.DB)
+	order, err := orderRepo.Find(orderID, userID)
+	if err != nil {
+		code, body := standingOrderErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": standingOrderResponse(order),
+	})
+}
+
+// PauseStandingOrder stops an active order from running until it is resumed
+func PauseStandingOrder(c *gin.Context) {
+	changeStandingOrder(c, func(repo *repositories.StandingOrderRepository, orderID, userID uuid.UUID) (*models.StandingOrder, error) {
+		return repo.Pause(orderID, userID)
+	})
+}
+
+// ResumeStandingOrder reactivates a paused order, skipping recurring runs
+// missed while it was paused
+func ResumeStandingOrder(c *gin.Context) {
+	changeStandingOrder(c, func(repo *repositories.StandingOrderRepository, orderID, userID uuid.UUID) (*models.StandingOrder, error) {
+		return repo.Resume(orderID, userID, time.Now())
+	})
+}
+
+// CancelStandingOrder stops an active or paused order for good
+func CancelStandingOrder(c *gin.Context) {
+	changeStandingOrder(c, func(repo *repositories.StandingOrderRepository, orderID, userID uuid.UUID) (*models.StandingOrder, error) {
+		return repo.Cancel(orderID, userID)
+	})
+}
+
+func changeStandingOrder(c *gin.Context, change func(repo *repositories.StandingOrderRepository, orderID, userID uuid.UUID) (*models.StandingOrder, error)) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid standing order ID"})
+		return
+	}
+
+	order, err := change(repositories.NewStandingOrderRepository(config.DB), orderID, userID)
+	if err != nil {
+		log.Printf("Update standing order error: %v", err)
+		code, body := standingOrderErrorResponse(err)
+		c.JSON(code, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": standingOrderResponse(order),
+	})
+}
+
+func standingOrderResponse(order *models.StandingOrder) gin.H {
+	formatTime := func(t *time.Time) interface{} {
+		if t == nil {
+			return nil
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+
+	return gin.H{
+		"standing_order_id":   order.ID,
+		"type":                order.TransactionType,
+		"amount":              order.Amount,
+		"currency":            order.Currency,
+		"target_user":         order.RecipientID,
+		"remarks":             order.Description,
+		"schedule":            order.Schedule,
+		"cron":                order.CronExpression,
+		"status":              order.Status,
+		"start_at":            order.StartAt.Format("2006-01-02 15:04:05"),
+		"end_at":              formatTime(order.EndAt),
+		"next_run_at":         formatTime(order.NextRunAt),
+		"last_run_at":         formatTime(order.LastRunAt),
+		"last_transaction_id": order.LastTransactionID,
+		"last_error":          order.LastError,
+		"attempts":            order.Attempts,
+		"run_count":           order.RunCount,
+		"created_date":        order.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func standingOrderErrorResponse(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, models.ErrInvalidCron):
+		return http.StatusBadRequest, gin.H{"error": err.Error()}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "Standing order not found"}
+	case err == models.ErrInvalidSchedule:
+		return http.StatusBadRequest, gin.H{"error": "Invalid schedule"}
+	case err == models.ErrWalletNotFound:
+		return http.StatusNotFound, gin.H{"error": "Wallet not found"}
+	case err == models.ErrSelfTransfer:
+		return http.StatusBadRequest, gin.H{"error": "Cannot transfer to yourself"}
+	case err == models.ErrStandingOrderNotActive:
+		return http.StatusConflict, gin.H{"error": "Standing order is not active"}
+	case err == models.ErrStandingOrderNotPaused:
+		return http.StatusConflict, gin.H{"error": "Standing order is not paused"}
+	case err == models.ErrStandingOrderFinished:
+		return http.StatusConflict, gin.H{"error": "Standing order has already finished"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process standing order"}
+	}
+}
@@ -1,28 +1,50 @@
 package routes
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/fx"
 	"github.com/denys89/ewallet-api/middleware"
 	"github.com/denys89/ewallet-api/models"
 	"github.com/denys89/ewallet-api/repositories"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type TransactionRequest struct {
-	Amount      float64 `json:"amount" binding:"required,gt=0"`
-	RecipientID string  `json:"target_user,omitempty"`
-	Description string  `json:"remarks,omitempty"`
+	Amount      models.Money `json:"amount" binding:"required,gt=0"`
+	Currency    string       `json:"currency,omitempty"`
+	RecipientID string       `json:"target_user,omitempty"`
+	Description string       `json:"remarks,omitempty"`
+}
+
+// TransferRequest addresses the recipient either by user ID (target_user) or
+// by phone number; exactly one of them is required.
+type TransferRequest struct {
+	Amount      models.Money `json:"amount" binding:"required,gt=0"`
+	Currency    string       `json:"currency,omitempty"`
+	RecipientID string       `json:"target_user,omitempty"`
+	PhoneNumber string       `json:"phone_number,omitempty"`
+	Description string       `json:"remarks,omitempty"`
+	// Convert credits a recipient with no wallet in currency in their
+	// default currency wallet at the current FX rate
+	Convert bool `json:"convert,omitempty"`
 }
 
 type PaymentRequest struct {
-	Amount      float64 `json:"amount" binding:"required,gt=0"`
-	Description string  `json:"remarks" binding:"required"`
+	Amount      models.Money `json:"amount" binding:"required,gt=0"`
+	Currency    string       `json:"currency,omitempty"`
+	Description string       `json:"remarks" binding:"required"`
+	// MerchantID credits the payment to a merchant; currency then defaults
+	// to the merchant's settlement currency
+	MerchantID string `json:"merchant_id,omitempty"`
 }
 
 func TopUp(c *gin.Context) {
@@ -34,65 +56,257 @@ func TopUp(c *gin.Context) {
 		return
 	}
 
-	transactionRepo := repositories.NewTransactionRepository(config.DB)
-	transactionID, balanceBefore, balanceAfter, err := transactionRepo.TopUp(userID, req.Amount)
-	if err != nil {
-		log.Printf("Top-up error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process top-up"})
+	currency, code, body := resolveCurrency(req.Currency, req.Amount)
+	if currency == "" {
+		c.JSON(code, body)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "SUCCESS",
-		"result": gin.H{
-			"top_up_id":      transactionID,
-			"amount_top_up":  req.Amount,
-			"balance_before": balanceBefore,
-			"balance_after":  balanceAfter,
-			"created_date":   time.Now().Format("2006-01-02 15:04:05"),
-		},
+	respondIdempotent(c, userID, req, func(db *gorm.DB) (int, gin.H) {
+		transactionRepo := repositories.NewTransactionRepository(db)
+		transactionID, balanceBefore, balanceAfter, err := transactionRepo.TopUp(userID, currency, req.Amount)
+		if err != nil {
+			log.Printf("Top-up error: %v", err)
+			if code, body, ok := limitExceededResponse(err); ok {
+				return code, body
+			}
+			if err == models.ErrWalletNotFound {
+				return http.StatusNotFound, gin.H{"error": "Wallet not found"}
+			}
+			if err == models.ErrAmountOutOfRange {
+				return http.StatusBadRequest, gin.H{"error": "Balance limit exceeded"}
+			}
+			return http.StatusInternalServerError, gin.H{"error": "Failed to process top-up"}
+		}
+
+		return http.StatusOK, gin.H{
+			"status": "SUCCESS",
+			"result": gin.H{
+				"top_up_id":      transactionID,
+				"amount_top_up":  req.Amount,
+				"currency":       currency,
+				"balance_before": balanceBefore,
+				"balance_after":  balanceAfter,
+				"created_date":   time.Now().Format("2006-01-02 15:04:05"),
+			},
+		}
 	})
 }
 
-func Transfer(c *gin.Context) {
+// Transfer sends money to another user. With convert set, rates prices the
+// conversion for a recipient who doesn't hold the currency.
+func Transfer(rates fx.RateProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+		var req TransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		currency, code, body := resolveCurrency(req.Currency, req.Amount)
+		if currency == "" {
+			c.JSON(code, body)
+			return
+		}
+
+		recipient, code, body := resolveRecipient(userID, req)
+		if recipient == nil {
+			c.JSON(code, body)
+			return
+		}
+
+		respondIdempotent(c, userID, req, func(db *gorm.DB) (int, gin.H) {
+			transactionRepo := repositories.NewTransactionRepository(db)
+			var transaction *models.Transaction
+			var balanceBefore, balanceAfter models.Money
+			var err error
+			if req.Convert {
+				quote := transferConversionQuoter(c, rates, userID)
+				transaction, balanceBefore, balanceAfter, err = transactionRepo.TransferConverted(userID, currency, req.Amount, recipient.ID, req.Description, quote)
+			} else {
+				transaction, balanceBefore, balanceAfter, err = transactionRepo.Transfer(userID, currency, req.Amount, recipient.ID, req.Description)
+			}
+			if err != nil {
+				log.Printf("Transfer error: %v", err)
+				if code, body, ok := limitExceededResponse(err); ok {
+					return code, body
+				}
+				if err == fx.ErrRateNotAvailable {
+					return http.StatusServiceUnavailable, gin.H{"error": "Exchange rate not available"}
+				}
+				if err == models.ErrConversionTooSmall {
+					return http.StatusBadRequest, gin.H{"error": "Amount is too small to convert"}
+				}
+				if err == models.ErrWalletNotFound {
+					return http.StatusNotFound, gin.H{"error": "Wallet not found"}
+				}
+				if err == models.ErrCurrencyMismatch {
+					return http.StatusUnprocessableEntity, gin.H{"error": "Recipient has no " + currency + " wallet"}
+				}
+				if err == models.ErrInvalidTransaction {
+					return http.StatusBadRequest, gin.H{"error": "Balance is not enough"}
+				}
+				if err == models.ErrAmountOutOfRange {
+					return http.StatusBadRequest, gin.H{"error": "Recipient balance limit exceeded"}
+				}
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return http.StatusNotFound, gin.H{"error": "Recipient not found"}
+				}
+				return http.StatusInternalServerError, gin.H{"error": "Failed to process transfer"}
+			}
+
+			result := gin.H{
+				"transfer_id":    transaction.ID,
+				"amount":         transaction.Amount,
+				"currency":       transaction.Currency,
+				"balance_before": balanceBefore,
+				"balance_after":  balanceAfter,
+				"fee":            transaction.FeeBreakdown,
+				"remarks":        transaction.Description,
+				"created_date":   transaction.CreatedAt.Format("2006-01-02 15:04:05"),
+			}
+			if req.Convert {
+				conversion, err := repositories.NewFXRepository(db).FindTransactionQuote(transaction.ID)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return http.StatusInternalServerError, gin.H{"error": "Failed to process transfer"}
+				}
+				if conversion != nil {
+					result["conversion"] = fxQuoteResponse(conversion)
+				}
+			}
+
+			return http.StatusOK, gin.H{
+				"status": "SUCCESS",
+				"result": result,
+			}
+		})
+	}
+}
+
+// transferConversionQuoter prices a transfer's conversion at the current
+// rate, the same way CreateFXQuote does.
+func transferConversionQuoter(c *gin.Context, rates fx.RateProvider, userID uuid.UUID) repositories.ConversionQuoter {
+	return func(from, to string, amount models.Money) (*models.FXQuote, error) {
+		midRate, err := rates.Rate(c.Request.Context(), from, to)
+		if err != nil {
+			return nil, err
+		}
+		cfg := config.Get()
+		return models.NewFXQuote(userID, from, to, amount, midRate, cfg.FXSpreadBasisPoints, cfg.FXQuoteTTL)
+	}
+}
+
+// PreviewTransfer shows who a transfer would go to and what it would cost,
+// without moving any money
+func PreviewTransfer(c *gin.Context) {
 	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
 
-	var req TransactionRequest
+	var req TransferRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if req.RecipientID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Target user is required"})
+	currency, code, body := resolveCurrency(req.Currency, req.Amount)
+	if currency == "" {
+		c.JSON(code, body)
 		return
 	}
 
-	transactionRepo := repositories.NewTransactionRepository(config.DB)
-	transaction, balanceBefore, balanceAfter, err := transactionRepo.Transfer(userID, req.Amount, req.RecipientID, req.Description)
+	recipient, code, body := resolveRecipient(userID, req)
+	if recipient == nil {
+		c.JSON(code, body)
+		return
+	}
+
+	userRepo := repositories.NewUserRepository(config.DB)
+	sender, err := userRepo.FindByID(userID)
 	if err != nil {
-		log.Printf("Transfer error: %v", err)
-		if err == models.ErrInvalidTransaction {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Balance is not enough"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process transfer"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	walletRepo := repositories.NewWalletRepository(config.DB)
+	wallet, err := walletRepo.Find(userID, currency)
+	if err == models.ErrWalletNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview transfer"})
+		return
+	}
+	_, recipientErr := walletRepo.Find(recipient.ID, currency)
+	if recipientErr != nil && recipientErr != models.ErrWalletNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview transfer"})
+		return
+	}
+
+	holdRepo := repositories.NewHoldRepository(config.DB)
+	held, err := holdRepo.ActiveHoldsTotal(userID, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview transfer"})
+		return
+	}
+
+	quote := models.Fees.Quote(models.TRANSFER, sender.KYCTier, currency, req.Amount)
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "SUCCESS",
 		"result": gin.H{
-			"transfer_id":    transaction.ID,
-			"amount":         transaction.Amount,
-			"balance_before": balanceBefore,
-			"balance_after":  balanceAfter,
-			"remarks":        transaction.Description,
-			"created_date":   transaction.CreatedAt.Format("2006-01-02 15:04:05"),
+			"recipient": gin.H{
+				"user_id":      recipient.ID,
+				"name":         recipient.MaskedName(),
+				"phone_number": recipient.MaskedPhoneNumber(),
+			},
+			"amount":             req.Amount,
+			"currency":           currency,
+			"fee":                quote,
+			"total_amount":       quote.Total,
+			"available_balance":  wallet.Balance - held,
+			"sufficient_balance": wallet.Balance-held >= quote.Total,
+			"recipient_accepts":  recipientErr == nil,
+			"remarks":            req.Description,
 		},
 	})
 }
 
+// resolveRecipient looks up the transfer recipient by target_user or
+// phone_number. On failure it returns a nil user and the error response.
+func resolveRecipient(senderID uuid.UUID, req TransferRequest) (*models.User, int, gin.H) {
+	if (req.RecipientID == "") == (req.PhoneNumber == "") {
+		return nil, http.StatusBadRequest, gin.H{"error": "Either target_user or phone_number is required"}
+	}
+
+	userRepo := repositories.NewUserRepository(config.DB)
+
+	var recipient *models.User
+	var err error
+	if req.RecipientID != "" {
+		recipientID, parseErr := uuid.Parse(req.RecipientID)
+		if parseErr != nil {
+			return nil, http.StatusBadRequest, gin.H{"error": "Invalid target user"}
+		}
+		recipient, err = userRepo.FindByID(recipientID)
+	} else {
+		recipient, err = userRepo.FindByPhoneNumber(strings.TrimSpace(req.PhoneNumber))
+	}
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || err == repositories.ErrInvalidCredentials {
+			return nil, http.StatusNotFound, gin.H{"error": "Recipient not found"}
+		}
+		return nil, http.StatusInternalServerError, gin.H{"error": "Failed to find recipient"}
+	}
+
+	if recipient.ID == senderID {
+		return nil, http.StatusBadRequest, gin.H{"error": "Cannot transfer to yourself"}
+	}
+	return recipient, 0, nil
+}
+
 func Payment(c *gin.Context) {
 	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
 
@@ -102,31 +316,94 @@ func Payment(c *gin.Context) {
 		return
 	}
 
-	transactionRepo := repositories.NewTransactionRepository(config.DB)
-	transaction, balanceBefore, balanceAfter, err := transactionRepo.Payment(userID, req.Amount, req.Description)
-	if err != nil {
-		log.Printf("Payment error: %v", err)
-		if err == models.ErrInvalidTransaction {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Balance is not enough"})
+	var merchant *models.Merchant
+	if req.MerchantID != "" {
+		merchantID, err := uuid.Parse(req.MerchantID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment"})
+		merchantRepo := repositories.NewMerchantRepository(config.DB)
+		if merchant, err = merchantRepo.FindByID(merchantID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Merchant not found"})
+			return
+		}
+		if req.Currency == "" {
+			req.Currency = merchant.SettlementCurrency
+		}
+	}
+
+	currency, code, body := resolveCurrency(req.Currency, req.Amount)
+	if currency == "" {
+		c.JSON(code, body)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "SUCCESS",
-		"result": gin.H{
+	respondIdempotent(c, userID, req, func(db *gorm.DB) (int, gin.H) {
+		transactionRepo := repositories.NewTransactionRepository(db)
+		var transaction *models.Transaction
+		var balanceBefore, balanceAfter models.Money
+		var err error
+		if merchant != nil {
+			transaction, balanceBefore, balanceAfter, err = transactionRepo.PayMerchant(userID, merchant.ID, currency, req.Amount, req.Description)
+		} else {
+			transaction, balanceBefore, balanceAfter, err = transactionRepo.Payment(userID, currency, req.Amount, req.Description)
+		}
+		if err != nil {
+			log.Printf("Payment error: %v", err)
+			return paymentErrorResponse(err, merchant)
+		}
+
+		result := gin.H{
 			"payment_id":     transaction.ID,
 			"amount":         transaction.Amount,
+			"currency":       transaction.Currency,
 			"balance_before": balanceBefore,
 			"balance_after":  balanceAfter,
+			"fee":            transaction.FeeBreakdown,
 			"remark":         transaction.Description,
 			"created_at":     transaction.CreatedAt.Format("2006-01-02 15:04:05"),
-		},
+		}
+		if merchant != nil {
+			result["merchant"] = gin.H{
+				"merchant_id":   merchant.ID,
+				"name":          merchant.Name,
+				"category_code": merchant.CategoryCode,
+			}
+		}
+
+		return http.StatusOK, gin.H{
+			"status": "SUCCESS",
+			"result": result,
+		}
 	})
 }
 
+func paymentErrorResponse(err error, merchant *models.Merchant) (int, gin.H) {
+	if code, body, ok := limitExceededResponse(err); ok {
+		return code, body
+	}
+
+	switch {
+	case err == models.ErrWalletNotFound:
+		return http.StatusNotFound, gin.H{"error": "Wallet not found"}
+	case err == models.ErrInvalidTransaction:
+		return http.StatusBadRequest, gin.H{"error": "Balance is not enough"}
+	case err == models.ErrCurrencyMismatch && merchant != nil:
+		return http.StatusUnprocessableEntity, gin.H{"error": "Merchant only accepts " + merchant.SettlementCurrency}
+	case err == models.ErrMerchantNotActive:
+		return http.StatusUnprocessableEntity, gin.H{"error": "Merchant is not accepting payments"}
+	case err == models.ErrPayOwnMerchant:
+		return http.StatusBadRequest, gin.H{"error": "Cannot pay your own merchant"}
+	case err == models.ErrAmountOutOfRange:
+		return http.StatusBadRequest, gin.H{"error": "Merchant balance limit exceeded"}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"error": "Merchant not found"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "Failed to process payment"}
+	}
+}
+
 func GetTransactionHistory(c *gin.Context) {
 	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
 
@@ -140,32 +417,115 @@ func GetTransactionHistory(c *gin.Context) {
 		limit = 10
 	}
 
+	var filter models.TransactionFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !filter.StartDate.IsZero() && !filter.EndDate.IsZero() && filter.EndDate.Before(filter.StartDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return
+	}
+	if filter.MinAmount > 0 && filter.MaxAmount > 0 && filter.MaxAmount < filter.MinAmount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_amount must not be less than min_amount"})
+		return
+	}
+
+	// Cursor mode: ?cursor= (empty for the first page) or a next/prev token
+	if token, ok := c.GetQuery("cursor"); ok {
+		getTransactionHistoryByCursor(c, userID, filter, token, limit)
+		return
+	}
+
 	transactionRepo := repositories.NewTransactionRepository(config.DB)
-	transactions, err := transactionRepo.GetUserTransactions(userID, page, limit)
+	transactions, total, err := transactionRepo.GetUserTransactions(userID, filter, page, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
 		return
 	}
 
-	var transactionResponses []gin.H
-	for _, t := range transactions {
-		transactionResponses = append(transactionResponses, gin.H{
-			"id":             t.ID,
-			"amount":         t.Amount,
-			"type":           t.Type,
-			"remarks":        t.Description,
-			"balance_before": t.BalanceBefore,
-			"balance_after":  t.BalanceAfter,
-			"status":         t.Status,
-			"created_date":   t.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
+	transactionResponses := []gin.H{}
+	for i := range transactions {
+		transactionResponses = append(transactionResponses, transactionHistoryResponse(&transactions[i]))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"result": transactionResponses,
 		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
 		},
 	})
 }
+
+func getTransactionHistoryByCursor(c *gin.Context, userID uuid.UUID, filter models.TransactionFilter, token string, limit int) {
+	var cursor *models.TransactionCursor
+	if token != "" {
+		var err error
+		if cursor, err = models.DecodeTransactionCursor(token); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	transactionRepo := repositories.NewTransactionRepository(config.DB)
+	transactions, hasMore, err := transactionRepo.GetUserTransactionsByCursor(userID, filter, cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+
+	transactionResponses := []gin.H{}
+	for i := range transactions {
+		transactionResponses = append(transactionResponses, transactionHistoryResponse(&transactions[i]))
+	}
+
+	// Walking backwards means older rows exist; walking forwards from a
+	// cursor means newer rows exist
+	hasNext, hasPrev := hasMore, cursor != nil
+	if cursor != nil && cursor.Direction == models.CursorPrev {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	var nextCursor, prevCursor *string
+	if len(transactions) > 0 {
+		if hasNext {
+			token := models.NewTransactionCursor(&transactions[len(transactions)-1], models.CursorNext).Encode()
+			nextCursor = &token
+		}
+		if hasPrev {
+			token := models.NewTransactionCursor(&transactions[0], models.CursorPrev).Encode()
+			prevCursor = &token
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": transactionResponses,
+		"pagination": gin.H{
+			"limit":       limit,
+			"next_cursor": nextCursor,
+			"prev_cursor": prevCursor,
+		},
+	})
+}
+
+func transactionHistoryResponse(t *models.Transaction) gin.H {
+	return gin.H{
+		"id":                      t.ID,
+		"amount":                  t.Amount,
+		"currency":                t.Currency,
+		"type":                    t.Type,
+		"transaction_type":        t.TransactionType,
+		"counterparty_id":         t.CounterpartyID,
+		"original_transaction_id": t.OriginalTransactionID,
+		"merchant_id":             t.MerchantID,
+		"remarks":                 t.Description,
+		"balance_before":          t.BalanceBefore,
+		"balance_after":           t.BalanceAfter,
+		"fee":                     t.Fee,
+		"status":                  t.Status,
+		"created_date":            t.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
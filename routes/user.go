@@ -5,6 +5,7 @@ import (
 
 	"github.com/denys89/ewallet-api/config"
 	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
 	"github.com/denys89/ewallet-api/repositories"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -83,21 +84,44 @@ func UpdateProfile(c *gin.Context) {
 	})
 }
 
-// GetBalance handles retrieving user balance
+// GetBalance handles retrieving user balance in one currency, the default
+// currency unless ?currency= is given. balance is the current ledger
+// balance; available_balance excludes funds reserved by active holds.
 func GetBalance(c *gin.Context) {
 	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
 
-	userRepo := repositories.NewUserRepository(config.DB)
-	user, err := userRepo.FindByID(userID)
+	currency, code, body := resolveCurrency(c.Query("currency"), 0)
+	if currency == "" {
+		c.JSON(code, body)
+		return
+	}
+
+	walletRepo := repositories.NewWalletRepository(config.DB)
+	wallet, err := walletRepo.Find(userID, currency)
+	if err == models.ErrWalletNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+
+	holdRepo := repositories.NewHoldRepository(config.DB)
+	held, err := holdRepo.ActiveHoldsTotal(userID, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": "SUCCESS",
 		"result": gin.H{
-			"balance": user.Balance,
+			"currency":          wallet.Currency,
+			"balance":           wallet.Balance,
+			"current_balance":   wallet.Balance,
+			"available_balance": wallet.Balance - held,
+			"held_amount":       held,
 		},
 	})
 }
@@ -0,0 +1,120 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/denys89/ewallet-api/config"
+	"github.com/denys89/ewallet-api/middleware"
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OpenWalletRequest struct {
+	Currency string `json:"currency" binding:"required"`
+}
+
+// GetCurrencies lists the currencies wallets can be held in
+func GetCurrencies(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": gin.H{
+			"default_currency": models.DefaultCurrency,
+			"currencies":       models.SupportedCurrencies(),
+		},
+	})
+}
+
+// GetWallets lists the user's wallets with their available balances
+func GetWallets(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	walletRepo := repositories.NewWalletRepository(config.DB)
+	wallets, err := walletRepo.GetUserWallets(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallets"})
+		return
+	}
+
+	holdRepo := repositories.NewHoldRepository(config.DB)
+	walletResponses := []gin.H{}
+	for i := range wallets {
+		held, err := holdRepo.ActiveHoldsTotal(userID, wallets[i].Currency)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallets"})
+			return
+		}
+		walletResponses = append(walletResponses, walletResponse(&wallets[i], held))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "SUCCESS",
+		"result": walletResponses,
+	})
+}
+
+// OpenWallet opens a wallet in another currency
+func OpenWallet(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req OpenWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, err := models.LookupCurrency(req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+
+	walletRepo := repositories.NewWalletRepository(config.DB)
+	wallet, err := walletRepo.Open(userID, currency.Code)
+	if err != nil {
+		switch {
+		case err == models.ErrWalletExists:
+			c.JSON(http.StatusConflict, gin.H{"error": "Wallet already exists"})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open wallet"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "SUCCESS",
+		"result": walletResponse(wallet, 0),
+	})
+}
+
+func walletResponse(wallet *models.Wallet, held models.Money) gin.H {
+	return gin.H{
+		"currency":          wallet.Currency,
+		"balance":           wallet.Balance,
+		"available_balance": wallet.Balance - held,
+		"held_amount":       held,
+		"default":           wallet.Currency == models.DefaultCurrency,
+	}
+}
+
+// resolveCurrency returns the currency code of a request, defaulting to the
+// default currency, and checks that amount fits its precision. On failure
+// it returns an empty code and the error response.
+func resolveCurrency(code string, amount models.Money) (string, int, gin.H) {
+	if code == "" {
+		code = models.DefaultCurrency
+	}
+	currency, err := models.LookupCurrency(code)
+	if err != nil {
+		return "", http.StatusBadRequest, gin.H{"error": "Unsupported currency"}
+	}
+	if err := currency.ValidateAmount(amount); err != nil {
+		return "", http.StatusBadRequest, gin.H{"error": "Amount has more decimal places than " + currency.Code + " allows"}
+	}
+	return currency.Code, 0, nil
+}
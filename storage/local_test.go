@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	err = store.Put(ctx, "kyc/user/doc.jpg", strings.NewReader("image bytes"))
+	assert.NoError(t, err)
+
+	r, err := store.Get(ctx, "kyc/user/doc.jpg")
+	assert.NoError(t, err)
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, "image bytes", string(data))
+
+	assert.NoError(t, store.Delete(ctx, "kyc/user/doc.jpg"))
+	_, err = store.Get(ctx, "kyc/user/doc.jpg")
+	assert.Equal(t, ErrNotFound, err)
+
+	// Deleting a missing blob is not an error
+	assert.NoError(t, store.Delete(ctx, "kyc/user/doc.jpg"))
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	for _, key := range []string{"", "/etc/passwd", "../secret", "kyc/../../secret", "kyc//doc", "kyc\\doc", "kyc/./doc"} {
+		err := store.Put(context.Background(), key, strings.NewReader("x"))
+		assert.Equal(t, ErrInvalidKey, err, key)
+	}
+}
@@ -0,0 +1,37 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/denys89/ewallet-api/repositories"
+	"gorm.io/gorm"
+)
+
+// StartHoldExpiry periodically marks active holds past their expiry as
+// EXPIRED until ctx is cancelled. Expired holds already stop reducing the
+// available balance on their own; this keeps their status accurate.
+func StartHoldExpiry(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				holdRepo := repositories.NewHoldRepository(db)
+				expired, err := holdRepo.ExpireStale(now)
+				if err != nil {
+					log.Printf("Hold expiry error: %v", err)
+					continue
+				}
+				if expired > 0 {
+					log.Printf("Expired %d stale holds", expired)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,38 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/denys89/ewallet-api/repositories"
+	"gorm.io/gorm"
+)
+
+// StartLedgerReconciliation periodically compares every wallet's cached
+// balance with the ledger until ctx is cancelled, logging each wallet that
+// has drifted so it can be investigated before the difference spreads.
+func StartLedgerReconciliation(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ledgerRepo := repositories.NewLedgerRepository(db)
+				mismatches, err := ledgerRepo.FindBalanceMismatches()
+				if err != nil {
+					log.Printf("Ledger reconciliation error: %v", err)
+					continue
+				}
+				for _, mismatch := range mismatches {
+					log.Printf("Ledger mismatch: user %s %s wallet has %s cached, %s in the ledger",
+						mismatch.UserID, mismatch.Currency, mismatch.CachedBalance, mismatch.LedgerBalance)
+				}
+			}
+		}
+	}()
+}
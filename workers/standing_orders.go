@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/denys89/ewallet-api/models"
+	"github.com/denys89/ewallet-api/repositories"
+	"gorm.io/gorm"
+)
+
+// standingOrderBatch caps how many due orders one tick executes; the rest
+// are picked up on the following ticks.
+const standingOrderBatch = 100
+
+// StartStandingOrders periodically executes due standing orders until ctx is
+// cancelled. Each order runs in its own DB transaction, so one failing order
+// does not hold up the others.
+func StartStandingOrders(ctx context.Context, db *gorm.DB, interval time.Duration, retry models.RetryPolicy) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				orderRepo := repositories.NewStandingOrderRepository(db)
+				due, err := orderRepo.DueOrders(now, standingOrderBatch)
+				if err != nil {
+					log.Printf("Standing order scheduler error: %v", err)
+					continue
+				}
+				for _, id := range due {
+					order, _, err := orderRepo.Execute(id, now, retry)
+					switch {
+					case err == models.ErrStandingOrderNotActive:
+					case err != nil:
+						log.Printf("Standing order %s error: %v", id, err)
+					case order.LastError != "":
+						log.Printf("Standing order %s run failed: %s", id, order.LastError)
+					}
+				}
+			}
+		}
+	}()
+}